@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Permissions policies can grant on servers
+const (
+	Perm_List     = "list"     // See the server in lists and event streams
+	Perm_View     = "view"     // Watch the screen, read-only
+	Perm_Interact = "interact" // Send keyboard, mouse and clipboard input
+	Perm_Record   = "record"   // Watch recordings of the server
+	Perm_Manage   = "manage"   // Change or remove the server through the API
+)
+
+// Permissions each permission brings with it
+var permImplies = map[string][]string{
+	Perm_List:     {Perm_List},
+	Perm_View:     {Perm_View, Perm_List},
+	Perm_Interact: {Perm_Interact, Perm_View, Perm_List},
+	Perm_Record:   {Perm_Record, Perm_View, Perm_List},
+	Perm_Manage:   {Perm_Manage, Perm_Interact, Perm_Record, Perm_View, Perm_List},
+}
+
+var ErrForbidden = errors.New("permission denied")
+
+// Grants permissions on some servers to some users. A policy applies to users
+// named in it, in one of its groups or holding one of its roles; the user "*"
+// is anyone authenticated. Users from the htpasswd file are named as they are,
+// others by how they authenticated and their name, e.g. oidc:alice. It covers servers named in it, in one of its server
+// groups or with all its server labels, or every server if none are given.
+type accessPolicy struct {
+	Users        []string          `yaml:"users"` // See identity.subject
+	Groups       []string          `yaml:"groups"`
+	Roles        []string          `yaml:"roles"`
+	Servers      []string          `yaml:"servers"` // IDs, short names or slugs
+	ServerGroups []string          `yaml:"server_groups"`
+	ServerLabels map[string]string `yaml:"server_labels"`
+	Permissions  []string          `yaml:"permissions"`
+
+	grants map[string]bool // Permissions, with those they imply
+}
+
+type accessPolicyConfig struct {
+	Policies []accessPolicy `yaml:"policies"`
+}
+
+// Whether the policy covers every server
+func (this accessPolicy) allServers() bool {
+	return len(this.Servers) == 0 && len(this.ServerGroups) == 0 && len(this.ServerLabels) == 0
+}
+
+// Authentication methods whose users policies name with a prefix, so an
+// identity provider or certificate can't be used to pass as a local user
+var prefixedAuthMethods = map[string]bool{
+	Auth_OIDC: true,
+	Auth_Cert: true,
+}
+
+// The name policies know a user by
+func (this identity) subject() string {
+	if prefixedAuthMethods[this.Method] {
+		return this.Method + ":" + this.User
+	}
+	return this.User
+}
+
+func (this accessPolicy) appliesTo(id identity) bool {
+	subject := id.subject()
+	for _, user := range this.Users {
+		if user == "*" || user == subject {
+			return true
+		}
+	}
+	return containsAny(this.Groups, id.Groups) || containsAny(this.Roles, id.Roles)
+}
+
+func (this accessPolicy) covers(server vncServer) bool {
+	if this.allServers() {
+		return true
+	}
+	// Servers being added or changed don't have their default slug yet
+	slug := server.Slug
+	if slug == "" {
+		slug = server.defaultSlug()
+	}
+	for _, name := range this.Servers {
+		if name == server.Short() || (server.ID != "" && name == server.ID) || (slug != "" && name == slug) {
+			return true
+		}
+	}
+	if containsAny(this.ServerGroups, server.Groups) {
+		return true
+	}
+	return len(this.ServerLabels) > 0 && serverFilter{Labels: this.ServerLabels}.Match(server)
+}
+
+func containsAny(a []string, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decides what authenticated users may do with each server. A nil
+// accessControl allows everything, as when no policy file is given.
+type accessControl struct {
+	policies []accessPolicy
+}
+
+// Load policies from a YAML or JSON file
+func LoadAccessControl(filename string) (*accessControl, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := accessPolicyConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+
+	for idx := range config.Policies {
+		policy := &config.Policies[idx]
+		if len(policy.Users) == 0 && len(policy.Groups) == 0 && len(policy.Roles) == 0 {
+			return nil, fmt.Errorf("policy %v: no users, groups or roles given", idx)
+		}
+		for _, user := range policy.Users {
+			// htpasswd user names can't hold colons, so any prefix must be a method
+			if colon := strings.Index(user, ":"); colon != -1 && !prefixedAuthMethods[user[:colon]] {
+				return nil, fmt.Errorf("policy %v: unknown authentication method: %v", idx, user[:colon])
+			}
+		}
+		if len(policy.Permissions) == 0 {
+			return nil, fmt.Errorf("policy %v: no permissions given", idx)
+		}
+		policy.grants = make(map[string]bool)
+		for _, perm := range policy.Permissions {
+			implied, ok := permImplies[perm]
+			if !ok {
+				return nil, fmt.Errorf("policy %v: unknown permission: %v", idx, perm)
+			}
+			for _, p := range implied {
+				policy.grants[p] = true
+			}
+		}
+	}
+	return &accessControl{policies: config.Policies}, nil
+}
+
+// Check whether the user making a request has a permission on a server
+func (this *accessControl) Allowed(r *http.Request, server vncServer, perm string) bool {
+	if this == nil {
+		return true
+	}
+	id, ok := requestIdentity(r)
+	if !ok {
+		return false
+	}
+	for _, policy := range this.policies {
+		if policy.grants[perm] && policy.appliesTo(id) && policy.covers(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check whether the user may change a server as given. Slugs, IDs, groups and
+// labels can be changed through the API, so unless the user manages every
+// server, the change mustn't bring the server under policies which didn't cover
+// it, and so give other users access to it.
+func (this *accessControl) AllowedUpdate(r *http.Request, before vncServer, after vncServer) bool {
+	if !this.Allowed(r, after, Perm_Manage) {
+		return false
+	}
+	if this == nil || this.AllowedAll(r, Perm_Manage) {
+		return true
+	}
+	for _, policy := range this.policies {
+		if policy.covers(after) && !policy.covers(before) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether the user has a permission on every server, for things which
+// don't belong to one server such as layouts
+func (this *accessControl) AllowedAll(r *http.Request, perm string) bool {
+	if this == nil {
+		return true
+	}
+	id, ok := requestIdentity(r)
+	if !ok {
+		return false
+	}
+	for _, policy := range this.policies {
+		if policy.grants[perm] && policy.appliesTo(id) && policy.allServers() {
+			return true
+		}
+	}
+	return false
+}
+
+// Check a permission on a server given by name. Servers which no longer exist,
+// e.g. those of old recordings, need the permission on every server.
+func (this *accessControl) AllowedName(r *http.Request, manager *serverManager, name string, perm string) bool {
+	if this == nil {
+		return true
+	}
+	if server, found := manager.Get(name); found {
+		return this.Allowed(r, server, perm)
+	}
+	return this.AllowedAll(r, perm)
+}
+
+// Context key of the server a request was checked against
+type accessServerKey struct{}
+
+// The server named by the shortname parameter. This is the one Require checked
+// if the handler is wrapped, even if the name has since come to mean another.
+func requestServer(r *http.Request, manager *serverManager, ps httprouter.Params) (vncServer, bool) {
+	if server, ok := r.Context().Value(accessServerKey{}).(vncServer); ok {
+		return server, true
+	}
+	return manager.Get(ps.ByName("shortname"))
+}
+
+// Wrap a handler for a server named by the shortname parameter so it's only
+// reached with a permission on the server. Unknown servers are left to the
+// handler.
+func (this *accessControl) Require(manager *serverManager, perm string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		server, found := manager.Get(ps.ByName("shortname"))
+		if !found {
+			next(w, r, ps)
+			return
+		}
+		if !this.Allowed(r, server, perm) {
+			http.Error(w, ErrForbidden.Error(), 403)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), accessServerKey{}, server)), ps)
+	}
+}
+
+// Wrap a handler so it's only reached with a permission on every server
+func (this *accessControl) RequireAll(perm string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !this.AllowedAll(r, perm) {
+			http.Error(w, ErrForbidden.Error(), 403)
+			return
+		}
+		next(w, r, ps)
+	}
+}
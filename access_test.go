@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Load an access policy file with the given contents
+func loadTestPolicies(t *testing.T, contents string) (*accessControl, error) {
+	dir, err := ioutil.TempDir("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "policies.yml")
+	if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadAccessControl(filename)
+}
+
+func TestLoadAccessControl(t *testing.T) {
+	tests := []struct {
+		contents string
+		err      string // Part of the error expected, or empty if none
+	}{
+		{"policies: []", ""},
+		{"policies: [{users: [alice], permissions: [view]}]", ""},
+		{"policies: [{groups: [ops], roles: [admin], permissions: [manage]}]", ""},
+		{"policies: [{permissions: [view]}]", "no users, groups or roles"},
+		{"policies: [{users: [alice]}]", "no permissions"},
+		{`policies: [{users: ["oidc:alice", "cert:CN=host,O=ops"], permissions: [view]}]`, ""},
+		{`policies: [{users: ["sso:alice"], permissions: [view]}]`, "unknown authentication method: sso"},
+		{"policies: [{users: [alice], permissions: [view, delete]}]", "unknown permission: delete"},
+		{"policies: [{users: [alice], permissions: [view]}, {users: [bob], permissions: [View]}]", "policy 1"},
+		{"policies: {", "yaml"},
+	}
+	for _, test := range tests {
+		_, err := loadTestPolicies(t, test.contents)
+		if test.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.contents, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: got error %v, expected %q", test.contents, err, test.err)
+		}
+	}
+}
+
+const testPolicies = `
+policies:
+  - users: [admin]
+    permissions: [manage]
+  - users: ["*"]
+    servers: [lobby]
+    permissions: [list]
+  - users: [alice]
+    servers: [console, kiosk-screen]
+    permissions: [interact]
+  - groups: [support]
+    server_groups: [desks]
+    permissions: [view]
+  - roles: [auditor]
+    server_labels: {env: prod, team: ""}
+    permissions: [record]
+  - users: [watcher]
+    permissions: [view]
+  - users: ["oidc:oscar", "cert:build01"]
+    servers: [console]
+    permissions: [view]
+`
+
+func TestAccessAllowed(t *testing.T) {
+	access, err := loadTestPolicies(t, testPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lobby := vncServer{ID: "lobby"}
+	console := vncServer{ID: "console"}
+	kiosk := vncServer{NetType: "tcp", Address: "kiosk:5900", Slug: "kiosk-screen"}
+	desk := vncServer{ID: "desk1", Groups: []string{"floor2", "desks"}}
+	prod := vncServer{ID: "db", Labels: map[string]string{"env": "prod", "team": "data"}}
+	untagged := vncServer{ID: "web", Labels: map[string]string{"env": "prod"}}
+
+	tests := []struct {
+		id      *identity // Nil if the request isn't authenticated
+		server  vncServer
+		perm    string
+		allowed bool
+	}{
+		{&identity{User: "admin"}, console, Perm_Manage, true},
+		{&identity{User: "admin"}, prod, Perm_Record, true},
+		{&identity{User: "admin"}, desk, Perm_Interact, true},
+		{nil, lobby, Perm_List, false},
+		{&identity{User: "anyone"}, lobby, Perm_List, true},
+		{&identity{User: "anyone"}, lobby, Perm_View, false},
+		{&identity{User: "anyone"}, console, Perm_List, false},
+		{&identity{User: "alice"}, console, Perm_Interact, true},
+		{&identity{User: "alice"}, console, Perm_View, true},
+		{&identity{User: "alice"}, console, Perm_Record, false},
+		{&identity{User: "alice"}, console, Perm_Manage, false},
+		{&identity{User: "alice"}, kiosk, Perm_Interact, true},
+		{&identity{User: "alice"}, desk, Perm_List, false},
+		{&identity{User: "bob", Groups: []string{"support"}}, desk, Perm_View, true},
+		{&identity{User: "bob", Groups: []string{"support"}}, desk, Perm_Interact, false},
+		{&identity{User: "bob", Groups: []string{"sales"}}, desk, Perm_View, false},
+		{&identity{User: "carol", Roles: []string{"auditor"}}, prod, Perm_Record, true},
+		{&identity{User: "carol", Roles: []string{"auditor"}}, prod, Perm_View, true},
+		{&identity{User: "carol", Roles: []string{"auditor"}}, prod, Perm_Interact, false},
+		{&identity{User: "carol", Roles: []string{"auditor"}}, untagged, Perm_View, false},
+		{&identity{User: "watcher"}, prod, Perm_View, true},
+		{&identity{User: "watcher"}, prod, Perm_Interact, false},
+		// Users from elsewhere are only named with their authentication method
+		{&identity{User: "alice", Method: Auth_Session}, console, Perm_Interact, true},
+		{&identity{User: "alice", Method: Auth_OIDC}, console, Perm_List, false},
+		{&identity{User: "alice", Method: Auth_Cert}, console, Perm_List, false},
+		{&identity{User: "oscar", Method: Auth_OIDC}, console, Perm_View, true},
+		{&identity{User: "oscar", Method: Auth_Basic}, console, Perm_View, false},
+		{&identity{User: "build01", Method: Auth_Cert}, console, Perm_View, true},
+		{&identity{User: "build01", Method: Auth_OIDC}, console, Perm_View, false},
+		{&identity{User: "oscar", Method: Auth_OIDC}, lobby, Perm_List, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		user := "unauthenticated"
+		if test.id != nil {
+			r = withIdentity(r, *test.id)
+			user = test.id.subject()
+		}
+		if allowed := access.Allowed(r, test.server, test.perm); allowed != test.allowed {
+			t.Errorf("%v on %v for %v: got %v, expected %v", test.perm, test.server.Short(), user, allowed, test.allowed)
+		}
+		var none *accessControl
+		if !none.Allowed(r, test.server, test.perm) {
+			t.Errorf("%v on %v for %v: denied without policies", test.perm, test.server.Short(), user)
+		}
+	}
+}
+
+func TestAccessAllowedAll(t *testing.T) {
+	access, err := loadTestPolicies(t, testPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id      identity
+		perm    string
+		allowed bool
+	}{
+		{identity{User: "admin"}, Perm_Manage, true},
+		{identity{User: "admin"}, Perm_List, true},
+		{identity{User: "watcher"}, Perm_View, true},
+		{identity{User: "watcher"}, Perm_Record, false},
+		// Policies limited to some servers don't grant anything on all of them
+		{identity{User: "anyone"}, Perm_List, false},
+		{identity{User: "alice"}, Perm_List, false},
+		{identity{User: "bob", Groups: []string{"support"}}, Perm_View, false},
+	}
+	for _, test := range tests {
+		r := withIdentity(httptest.NewRequest("GET", "/", nil), test.id)
+		if allowed := access.AllowedAll(r, test.perm); allowed != test.allowed {
+			t.Errorf("%v for %v: got %v, expected %v", test.perm, test.id.User, allowed, test.allowed)
+		}
+	}
+	if access.AllowedAll(httptest.NewRequest("GET", "/", nil), Perm_List) {
+		t.Error("unauthenticated request allowed")
+	}
+}
+
+func TestAccessRequire(t *testing.T) {
+	access, err := loadTestPolicies(t, testPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewServerManager()
+	manager.Add(vncServer{ID: "console"})
+	manager.Add(vncServer{ID: "db"})
+
+	tests := []struct {
+		user   string
+		name   string
+		status int
+		server string // Server the handler is given, if it's reached
+	}{
+		{"alice", "console", 200, "console"},
+		{"alice", "db", 403, ""},
+		{"admin", "db", 200, "db"},
+		// Unknown servers are left to the handler
+		{"alice", "missing", 200, ""},
+	}
+	for _, test := range tests {
+		var server string
+		handler := access.Require(manager, Perm_Interact, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			if s, found := requestServer(r, manager, ps); found {
+				server = s.Short()
+			}
+		})
+		r := withIdentity(httptest.NewRequest("GET", "/", nil), identity{User: test.user})
+		w := httptest.NewRecorder()
+		handler(w, r, httprouter.Params{{Key: "shortname", Value: test.name}})
+		if w.Code != test.status || server != test.server {
+			t.Errorf("%v on %v: got %v with %q, expected %v with %q", test.user, test.name, w.Code, server, test.status, test.server)
+		}
+	}
+
+	// The handler gets the server which was checked, even if the name comes to
+	// mean another meanwhile
+	var server vncServer
+	handler := access.Require(manager, Perm_Interact, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		manager.RemoveByAddress("", "")
+		manager.Add(vncServer{NetType: "tcp", Address: "db:5900", Slug: "console"})
+		server, _ = requestServer(r, manager, ps)
+	})
+	handler(httptest.NewRecorder(), withIdentity(httptest.NewRequest("GET", "/", nil), identity{User: "alice"}), httprouter.Params{{Key: "shortname", Value: "console"}})
+	if server.ID != "console" {
+		t.Errorf("handler given %+v, expected the console", server)
+	}
+
+	// Permissions on every server
+	handler = access.RequireAll(Perm_Manage, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	for user, status := range map[string]int{"admin": 200, "alice": 403} {
+		w := httptest.NewRecorder()
+		handler(w, withIdentity(httptest.NewRequest("POST", "/", nil), identity{User: user}), nil)
+		if w.Code != status {
+			t.Errorf("%v managing everything: got %v, expected %v", user, w.Code, status)
+		}
+	}
+}
+
+func TestServerAPIAccess(t *testing.T) {
+	access, err := loadTestPolicies(t, `
+policies:
+  - users: [alice]
+    server_groups: [desks]
+    permissions: [list, manage]
+  - users: [bob]
+    servers: [console]
+    permissions: [view]
+  - users: [carol]
+    server_labels: {site: lab}
+    permissions: [view]
+  - users: [admin]
+    permissions: [manage]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename, cleanup := writeTempFile(t, "servers.json", `{"servers": [{"url": "tcp://desk:5900", "id": "desk", "groups": ["desks"]}, {"url": "tcp://db:5900", "id": "db"}]}`)
+	defer cleanup()
+	manager := NewServerManager()
+	store := NewServerStore(filename, manager)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	router := newAPIRouter(manager, store, access)
+	desk2 := shortOf(t, "tcp://desk2:5900")
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"get", "alice", "GET", "/api/servers/desk", "", 200},
+		{"get unlisted", "alice", "GET", "/api/servers/db", "", 403},
+		{"update", "alice", "PATCH", "/api/servers/desk", `{"name": "Desk"}`, 200},
+		{"update unmanaged", "alice", "PATCH", "/api/servers/db", `{"groups": ["desks"]}`, 403},
+		{"delete unmanaged", "alice", "DELETE", "/api/servers/db", "", 403},
+		{"delete", "alice", "DELETE", "/api/servers/desk", "", 204},
+		{"add", "alice", "POST", "/api/servers", `{"url": "tcp://desk2:5900", "groups": ["desks"]}`, 201},
+		{"add out of reach", "alice", "POST", "/api/servers", `{"url": "tcp://lab:5900"}`, 403},
+		{"move out of reach", "alice", "PATCH", "/api/servers/" + desk2, `{"groups": ["lab"]}`, 403},
+		// Nor into other users' reach, by changing what their policies match
+		{"rename into reach", "alice", "PATCH", "/api/servers/" + desk2, `{"slug": "console"}`, 403},
+		{"rename into reach by name", "alice", "PATCH", "/api/servers/" + desk2, `{"name": "Console"}`, 403},
+		{"label into reach", "alice", "PATCH", "/api/servers/" + desk2, `{"labels": {"site": "lab"}}`, 403},
+		{"rename", "alice", "PATCH", "/api/servers/" + desk2, `{"slug": "desk-2"}`, 200},
+		{"label", "alice", "PATCH", "/api/servers/" + desk2, `{"labels": {"site": "office"}}`, 200},
+		{"label by admin", "admin", "PATCH", "/api/servers/" + desk2, `{"labels": {"site": "lab"}}`, 200},
+	}
+	for _, test := range tests {
+		r := withIdentity(httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)), identity{User: test.user})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, expected %v: %s", test.name, w.Code, test.status, w.Body.Bytes())
+		}
+	}
+	if _, found := manager.Get("db"); !found {
+		t.Error("unmanaged server removed")
+	}
+	if server, _ := manager.Get(desk2); !reflect.DeepEqual(server.Groups, []string{"desks"}) || server.Slug != "desk-2" {
+		t.Errorf("forbidden update changed server to %+v", server)
+	}
+	if entry := store.servers[desk2]; !reflect.DeepEqual(entry.Groups, []string{"desks"}) {
+		t.Errorf("forbidden update stored groups %v", entry.Groups)
+	}
+}
+
+func TestAccessLayoutsAndKiosks(t *testing.T) {
+	access, err := loadTestPolicies(t, `
+policies:
+  - users: [admin]
+    permissions: [manage]
+  - users: [alice]
+    server_groups: [lobby]
+    permissions: [list]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, _, cleanup := newKioskFixtures(t)
+	defer cleanup()
+	filename, cleanup := writeTempFile(t, "layouts.json", `[{"name": "wall", "columns": 2, "rows": 1, "cells": [{"server": "a", "column": 0, "row": 0}, {"server": "c", "column": 1, "row": 0}]}]`)
+	defer cleanup()
+	layouts := NewLayoutStore(filename)
+	if err := layouts.Load(); err != nil {
+		t.Fatal(err)
+	}
+	kiosks := NewKioskRotator(manager, layouts, []kioskConfig{{Name: "lobby", Dwell: time.Minute}})
+	if _, err := kiosks.Show("lobby", kioskPage{Server: "c"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	router := httprouter.New()
+	router.GET("/api/layouts", layoutListHandler(layouts, manager, access))
+	router.GET("/api/layouts/:layout", layoutGetHandler(layouts, manager, access))
+	router.GET("/api/kiosks", kioskListHandler(kiosks, access))
+	router.GET("/api/kiosks/:kiosk", kioskGetHandler(kiosks, access))
+
+	tests := []struct {
+		user  string
+		cells []string
+		kiosk string // Server the kiosk is reported to show
+	}{
+		{"admin", []string{"a", "c"}, "c"},
+		{"alice", []string{"a"}, ""},
+	}
+	for _, test := range tests {
+		get := func(path string, v interface{}) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, withIdentity(httptest.NewRequest("GET", path, nil), identity{User: test.user}))
+			if err := json.NewDecoder(w.Body).Decode(v); err != nil || w.Code != 200 {
+				t.Fatalf("%v %v: status %v, error %v", test.user, path, w.Code, err)
+			}
+		}
+		cellServers := func(layout dashboardLayout) []string {
+			servers := []string{}
+			for _, cell := range layout.Cells {
+				servers = append(servers, cell.Server)
+			}
+			return servers
+		}
+
+		layout := dashboardLayout{}
+		get("/api/layouts/wall", &layout)
+		list := []dashboardLayout{}
+		get("/api/layouts", &list)
+		if cells := cellServers(layout); !reflect.DeepEqual(cells, test.cells) || len(list) != 1 || !reflect.DeepEqual(cellServers(list[0]), test.cells) {
+			t.Errorf("%v: got cells %v and list %+v, expected %v", test.user, cells, list, test.cells)
+		}
+
+		status := kioskStatus{}
+		get("/api/kiosks/lobby", &status)
+		statuses := []kioskStatus{}
+		get("/api/kiosks", &statuses)
+		if status.View.Server != test.kiosk || len(statuses) != 1 || statuses[0].View.Server != test.kiosk {
+			t.Errorf("%v: kiosk showing %+v and listed as %+v, expected %q", test.user, status.View, statuses, test.kiosk)
+		}
+		if test.kiosk == "" && status.View.Name != "" {
+			t.Errorf("%v: kiosk shows name %q", test.user, status.View.Name)
+		}
+	}
+}
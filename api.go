@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrServerNotFound    = errors.New("server not found")
+	ErrServerExists      = errors.New("server already exists")
+	ErrServerNotEditable = errors.New("server is not managed through the API")
+)
+
+// Servers added through the API, persisted to a state file so they survive
+// restarts. Kept apart from the inventory file and watched sockets, which the API
+// can't change.
+type serverStore struct {
+	filename string
+	manager  *serverManager
+	servers  map[string]serverConfig // By short name
+	mtx      sync.Mutex
+}
+
+func NewServerStore(filename string, manager *serverManager) *serverStore {
+	return &serverStore{
+		filename: filename,
+		manager:  manager,
+		servers:  make(map[string]serverConfig),
+	}
+}
+
+// Load the servers in the state file into the manager. A missing file is an empty
+// one.
+func (this *serverStore) Load() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	b, err := ioutil.ReadFile(this.filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	config := inventoryConfig{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return err
+	}
+	servers := make(map[string]serverConfig)
+	for idx, entry := range config.Servers {
+		server, err := this.validate(entry)
+		if err != nil {
+			return fmt.Errorf("server %v: %v", idx, err)
+		}
+		servers[server.Short()] = entry
+	}
+	this.servers = servers
+	this.sync()
+	return nil
+}
+
+// Write the state file
+func (this *serverStore) save() error {
+	// Sorted so the file only changes where the servers did
+	shortnames := []string{}
+	for k := range this.servers {
+		shortnames = append(shortnames, k)
+	}
+	sort.Strings(shortnames)
+	config := inventoryConfig{Servers: []serverConfig{}}
+	for _, k := range shortnames {
+		config.Servers = append(config.Servers, this.servers[k])
+	}
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Holds credentials, so only readable by us
+	return writeFileAtomic(this.filename, b, 0600)
+}
+
+// Write a file by renaming a temporary one over it, so readers never see it half
+// written. Missing directories are created.
+func writeFileAtomic(filename string, b []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// Publish the stored servers to the manager
+func (this *serverStore) sync() {
+	servers := []vncServer{}
+	for _, entry := range this.servers {
+		if server, err := this.validate(entry); err == nil {
+			servers = append(servers, server)
+		}
+	}
+	this.manager.Sync(Source_API, servers)
+}
+
+// Convert a config entry into a server, checking it is valid
+func (this *serverStore) validate(entry serverConfig) (vncServer, error) {
+	if entry.URL == "" {
+		return vncServer{}, errors.New("no url specified")
+	}
+	// Loading watch rules fills in their reference images
+	server, err := entry.copy().Server()
+	if err != nil {
+		return vncServer{}, err
+	}
+	// Reference images are relative to the state file
+	if err := loadWatchRules(server.Watches, filepath.Dir(this.filename)); err != nil {
+		return vncServer{}, err
+	}
+	server.Source = Source_API
+	return server, nil
+}
+
+// Add a new server
+func (this *serverStore) Add(entry serverConfig) (vncServer, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	server, err := this.validate(entry)
+	if err != nil {
+		return vncServer{}, err
+	}
+	if _, exists := this.manager.Get(server.Short()); exists {
+		return vncServer{}, ErrServerExists
+	}
+
+	this.servers[server.Short()] = entry
+	if err := this.save(); err != nil {
+		delete(this.servers, server.Short())
+		return vncServer{}, err
+	}
+	this.sync()
+	return server, nil
+}
+
+// Change a server with a function applied to its config. Changing the URL or ID
+// changes its short name.
+func (this *serverStore) Update(name string, update func(entry *serverConfig) error) (vncServer, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	shortname, old, err := this.editable(name)
+	if err != nil {
+		return vncServer{}, err
+	}
+	// The stored entry is shared with the running server, so a rejected update
+	// must not touch it
+	entry := old.copy()
+	if err := update(&entry); err != nil {
+		return vncServer{}, err
+	}
+	server, err := this.validate(entry)
+	if err != nil {
+		return vncServer{}, err
+	}
+	if _, exists := this.manager.Get(server.Short()); exists && server.Short() != shortname {
+		return vncServer{}, ErrServerExists
+	}
+
+	delete(this.servers, shortname)
+	this.servers[server.Short()] = entry
+	if err := this.save(); err != nil {
+		delete(this.servers, server.Short())
+		this.servers[shortname] = old
+		return vncServer{}, err
+	}
+	this.sync()
+	return server, nil
+}
+
+// Remove a server
+func (this *serverStore) Remove(name string) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	shortname, old, err := this.editable(name)
+	if err != nil {
+		return err
+	}
+	delete(this.servers, shortname)
+	if err := this.save(); err != nil {
+		this.servers[shortname] = old
+		return err
+	}
+	this.sync()
+	return nil
+}
+
+// Find the short name and config of a server the API may change. The server may
+// be given by any name the manager accepts.
+func (this *serverStore) editable(name string) (string, serverConfig, error) {
+	server, exists := this.manager.Get(name)
+	if !exists {
+		return "", serverConfig{}, ErrServerNotFound
+	}
+	entry, ok := this.servers[server.Short()]
+	if !ok {
+		return "", serverConfig{}, ErrServerNotEditable
+	}
+	return server.Short(), entry, nil
+}
+
+// A server and its short name, as returned by the API
+type serverResponse struct {
+	Shortname string    `json:"shortname"`
+	Server    vncServer `json:"server"`
+}
+
+// Map store errors onto HTTP responses
+func writeServerError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrServerNotFound:
+		http.Error(w, err.Error(), 404)
+	case ErrServerExists, ErrServerNotEditable:
+		http.Error(w, err.Error(), 409)
+	case ErrForbidden:
+		http.Error(w, err.Error(), 403)
+	default:
+		http.Error(w, err.Error(), 400)
+	}
+}
+
+func writeServerResponse(w http.ResponseWriter, status int, server vncServer) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(serverResponse{server.Short(), server})
+}
+
+func serverGetHandler(manager *serverManager) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		server, found := requestServer(r, manager, ps)
+		if !found {
+			http.Error(w, "VNC host not found", 404)
+			return
+		}
+		writeServerResponse(w, 200, server)
+	}
+}
+
+// Add a server from a JSON body in the same format as the inventory file entries
+func serverCreateHandler(store *serverStore, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		entry := serverConfig{}
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			http.Error(w, "Invalid server: "+err.Error(), 400)
+			return
+		}
+		// Policies may only allow managing some servers, so the new one must be
+		// among them
+		if server, err := entry.Server(); err == nil && !access.Allowed(r, server, Perm_Manage) {
+			writeServerError(w, ErrForbidden)
+			return
+		}
+
+		server, err := store.Add(entry)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		log.With("server_shortpath", server.Short()).With("remote_addr", r.RemoteAddr).Infoln("Server added through API")
+		w.Header().Set("Location", "/api/servers/"+server.Short())
+		writeServerResponse(w, 201, server)
+	}
+}
+
+// Change the fields of a server given in a JSON body
+func serverUpdateHandler(store *serverStore, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		checked, found := requestServer(r, store.manager, ps)
+		if !found {
+			writeServerError(w, ErrServerNotFound)
+			return
+		}
+
+		server, err := store.Update(checked.Short(), func(entry *serverConfig) error {
+			before, err := entry.Server()
+			if err != nil {
+				return err
+			}
+			// Fields missing from the body keep their values. Labels are replaced
+			// rather than merged, like every other field.
+			fields := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(body, &fields); err != nil {
+				return err
+			}
+			if _, ok := fields["labels"]; ok {
+				entry.Labels = nil
+			}
+			if err := json.Unmarshal(body, entry); err != nil {
+				return err
+			}
+			// Servers can't be moved out of the user's reach, or into other users', by
+			// changing their names, groups or labels
+			if server, err := entry.Server(); err == nil && !access.AllowedUpdate(r, before, server) {
+				return ErrForbidden
+			}
+			return nil
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		log.With("server_shortpath", server.Short()).With("remote_addr", r.RemoteAddr).Infoln("Server updated through API")
+		writeServerResponse(w, 200, server)
+	}
+}
+
+func serverDeleteHandler(store *serverStore) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		server, found := requestServer(r, store.manager, ps)
+		if !found {
+			writeServerError(w, ErrServerNotFound)
+			return
+		}
+		if err := store.Remove(server.Short()); err != nil {
+			writeServerError(w, err)
+			return
+		}
+		log.With("server_shortpath", server.Short()).With("remote_addr", r.RemoteAddr).Infoln("Server removed through API")
+		w.WriteHeader(204)
+	}
+}
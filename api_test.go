@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Short name of the server a URL describes
+func shortOf(t *testing.T, url string) string {
+	server, err := serverConfig{URL: url}.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server.Short()
+}
+
+func newAPIRouter(manager *serverManager, store *serverStore, access *accessControl) *httprouter.Router {
+	router := httprouter.New()
+	router.POST("/api/servers", serverCreateHandler(store, access))
+	router.GET("/api/servers/:shortname", access.Require(manager, Perm_List, serverGetHandler(manager)))
+	router.PATCH("/api/servers/:shortname", access.Require(manager, Perm_Manage, serverUpdateHandler(store, access)))
+	router.DELETE("/api/servers/:shortname", access.Require(manager, Perm_Manage, serverDeleteHandler(store)))
+	return router
+}
+
+func TestServerStoreLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string // Missing file if empty
+		expected []string
+		err      string
+	}{
+		{"missing", "", []string{}, ""},
+		{"empty", `{"servers": []}`, []string{}, ""},
+		{"servers", `{"servers": [{"url": "tcp://a:5900", "name": "A"}, {"url": "unix:///run/b.sock"}]}`, []string{"/run/b.sock=", "a:5900=A"}, ""},
+		{"no url", `{"servers": [{"url": "tcp://a:5900"}, {"name": "B"}]}`, []string{}, "server 1: no url specified"},
+		{"invalid watch", `{"servers": [{"url": "tcp://a:5900", "watches": [{"name": "w"}]}]}`, []string{}, "server 0: watch w: invalid region"},
+		{"invalid json", `{"servers": [`, []string{}, "unexpected end"},
+	}
+	for _, test := range tests {
+		filename, cleanup := writeTempFile(t, "servers.json", test.contents)
+		if test.contents == "" {
+			os.Remove(filename)
+		}
+		manager := NewServerManager()
+		err := NewServerStore(filename, manager).Load()
+		cleanup()
+		checkErrText(t, test.name, err, test.err)
+		if got := listNames(manager); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: got servers %v, expected %v", test.name, got, test.expected)
+		}
+		for _, server := range manager.List() {
+			if server.Source != Source_API {
+				t.Errorf("%v: server %v from source %v", test.name, server.Address, server.Source)
+			}
+		}
+	}
+}
+
+func TestServerAPI(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "servers.json", "")
+	defer cleanup()
+	os.Remove(filename)
+	manager := NewServerManager()
+	manager.Sync(Source_Config, []vncServer{{NetType: "tcp", Address: "config:5900", Name: "Config"}})
+	store := NewServerStore(filename, manager)
+	router := newAPIRouter(manager, store, nil)
+	events := manager.Subscribe()
+
+	a := shortOf(t, "tcp://a:5900")
+	a2 := shortOf(t, "tcp://a:5901")
+	config := shortOf(t, "tcp://config:5900")
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		status    int
+		shortname string   // Expected in the response
+		events    []string // Published by the request
+		servers   []string // Afterwards
+	}{
+		{"add", "POST", "/api/servers", `{"url": "tcp://a:5900", "name": "A", "tags": ["lab"]}`,
+			201, a, []string{"added a:5900"}, []string{"a:5900=A", "config:5900=Config"}},
+		{"add again", "POST", "/api/servers", `{"url": "tcp://a:5900"}`,
+			409, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"add config server", "POST", "/api/servers", `{"url": "tcp://config:5900"}`,
+			409, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"add without url", "POST", "/api/servers", `{"name": "B"}`,
+			400, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"add invalid url", "POST", "/api/servers", `{"url": "b:5900"}`,
+			400, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"add invalid json", "POST", "/api/servers", `{"url": `,
+			400, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"get", "GET", "/api/servers/" + a, "",
+			200, a, nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"get config server", "GET", "/api/servers/" + config, "",
+			200, config, nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"get missing", "GET", "/api/servers/missing", "",
+			404, "", nil, []string{"a:5900=A", "config:5900=Config"}},
+		{"rename", "PATCH", "/api/servers/" + a, `{"name": "Renamed"}`,
+			200, a, []string{"updated a:5900"}, []string{"a:5900=Renamed", "config:5900=Config"}},
+		{"update by slug", "PATCH", "/api/servers/renamed", `{"tags": ["lab", "linux"]}`,
+			200, a, []string{"updated a:5900"}, []string{"a:5900=Renamed", "config:5900=Config"}},
+		{"invalid update", "PATCH", "/api/servers/" + a, `{"url": ""}`,
+			400, "", nil, []string{"a:5900=Renamed", "config:5900=Config"}},
+		{"unparseable update", "PATCH", "/api/servers/" + a, `{"name": 1}`,
+			400, "", nil, []string{"a:5900=Renamed", "config:5900=Config"}},
+		{"move onto config server", "PATCH", "/api/servers/" + a, `{"url": "tcp://config:5900"}`,
+			409, "", nil, []string{"a:5900=Renamed", "config:5900=Config"}},
+		{"move", "PATCH", "/api/servers/" + a, `{"url": "tcp://a:5901"}`,
+			200, a2, []string{"added a:5901", "removed a:5900"}, []string{"a:5901=Renamed", "config:5900=Config"}},
+		{"update moved", "PATCH", "/api/servers/" + a, `{"name": "Old"}`,
+			404, "", nil, []string{"a:5901=Renamed", "config:5900=Config"}},
+		{"update config server", "PATCH", "/api/servers/" + config, `{"name": "Changed"}`,
+			409, "", nil, []string{"a:5901=Renamed", "config:5900=Config"}},
+		{"delete config server", "DELETE", "/api/servers/" + config, "",
+			409, "", nil, []string{"a:5901=Renamed", "config:5900=Config"}},
+		{"delete missing", "DELETE", "/api/servers/missing", "",
+			404, "", nil, []string{"a:5901=Renamed", "config:5900=Config"}},
+		{"delete", "DELETE", "/api/servers/" + a2, "",
+			204, "", []string{"removed a:5901"}, []string{"config:5900=Config"}},
+		{"add back", "POST", "/api/servers", `{"url": "tcp://user:pw@a:5900", "password": "secret", "readonly": true}`,
+			201, shortOf(t, "tcp://user:secret@a:5900"), []string{"added a:5900"}, []string{"a:5900=", "config:5900=Config"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, expected %v: %s", test.name, w.Code, test.status, w.Body.Bytes())
+		}
+		if test.shortname != "" {
+			response := serverResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Errorf("%v: %v", test.name, err)
+			}
+			if response.Shortname != test.shortname || response.Server.Address == "" {
+				t.Errorf("%v: got %v, expected %v", test.name, response.Shortname, test.shortname)
+			}
+		}
+		if test.status == 201 && w.Header().Get("Location") != "/api/servers/"+test.shortname {
+			t.Errorf("%v: got location %v", test.name, w.Header().Get("Location"))
+		}
+		if got := takeEvents(events); len(got) != len(test.events) || len(got) > 0 && !reflect.DeepEqual(got, test.events) {
+			t.Errorf("%v: got events %v, expected %v", test.name, got, test.events)
+		}
+		if got := listNames(manager); !reflect.DeepEqual(got, test.servers) {
+			t.Errorf("%v: got servers %v, expected %v", test.name, got, test.servers)
+		}
+	}
+
+	// Only API servers are saved, and only we can read them
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file has mode %v", info.Mode())
+	}
+	reloaded := NewServerManager()
+	if err := NewServerStore(filename, reloaded).Load(); err != nil {
+		t.Fatal(err)
+	}
+	server, ok := reloaded.Get(shortOf(t, "tcp://user:secret@a:5900"))
+	if len(reloaded.List()) != 1 || !ok || !server.ReadOnly || server.Password != "secret" {
+		t.Errorf("reloaded %+v", reloaded.List())
+	}
+}
+
+func TestServerStoreSaveFailure(t *testing.T) {
+	// The state file's directory can't be created under a file
+	notDir, cleanup := writeTempFile(t, "file", "")
+	defer cleanup()
+	manager := NewServerManager()
+	store := NewServerStore(filepath.Join(notDir, "servers.json"), manager)
+
+	if _, err := store.Add(serverConfig{URL: "tcp://a:5900"}); err == nil {
+		t.Fatal("added a server which couldn't be saved")
+	}
+	if len(store.servers) != 0 || len(manager.List()) != 0 {
+		t.Errorf("unsaved server kept: %v", listNames(manager))
+	}
+	if _, err := ioutil.ReadFile(store.filename); err == nil {
+		t.Error("state file written")
+	}
+}
+
+func TestServerAPIUpdateEvents(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "servers.json", `{"servers": [{"url": "tcp://a:5900", "tags": ["lab"], "groups": ["desks"]}]}`)
+	defer cleanup()
+	manager := NewServerManager()
+	store := NewServerStore(filename, manager)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	router := newAPIRouter(manager, store, nil)
+	a := shortOf(t, "tcp://a:5900")
+	sub := manager.Subscribe()
+
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{`{"tags": ["prod"]}`, []string{"updated a:5900"}},
+		{`{"groups": ["servers"]}`, []string{"updated a:5900"}},
+		{`{"watches": [{"name": "login", "width": 10, "height": 10}]}`, []string{"updated a:5900"}},
+		{`{"watches": [{"name": "login", "width": 10, "height": 10}]}`, []string{}},
+		{`{"tags": ["prod", "linux"], "url": ""}`, []string{}},
+	}
+	for _, test := range tests {
+		before, _ := manager.Get(a)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PATCH", "/api/servers/"+a, strings.NewReader(test.body)))
+		if events := takeEvents(sub); !reflect.DeepEqual(events, test.expected) {
+			t.Errorf("%v: got events %v, expected %v", test.body, events, test.expected)
+		}
+		if w.Code != 200 {
+			// Rejected updates leave the server alone
+			if after, _ := manager.Get(a); !reflect.DeepEqual(after, before) {
+				t.Errorf("%v: rejected update changed %+v to %+v", test.body, before, after)
+			}
+		}
+	}
+}
+
+func TestServerAPILabels(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "servers.json", `{"servers": [{"url": "tcp://a:5900", "labels": {"site": "hq", "rack": "1"}}]}`)
+	defer cleanup()
+	manager := NewServerManager()
+	store := NewServerStore(filename, manager)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	router := newAPIRouter(manager, store, nil)
+	a := shortOf(t, "tcp://a:5900")
+
+	tests := []struct {
+		body   string
+		labels map[string]string
+		groups []string
+	}{
+		{`{"name": "A"}`, map[string]string{"site": "hq", "rack": "1"}, nil},
+		{`{"labels": {"site": "branch"}}`, map[string]string{"site": "branch"}, nil},
+		{`{"groups": ["lab"]}`, map[string]string{"site": "branch"}, []string{"lab"}},
+		{`{"labels": null}`, nil, []string{"lab"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PATCH", "/api/servers/"+a, strings.NewReader(test.body)))
+		if w.Code != 200 {
+			t.Errorf("%v: got status %v: %s", test.body, w.Code, w.Body.Bytes())
+		}
+		server, _ := manager.Get(a)
+		if !reflect.DeepEqual(server.Labels, test.labels) || !reflect.DeepEqual(server.Groups, test.groups) {
+			t.Errorf("%v: got labels %v, groups %v", test.body, server.Labels, server.Groups)
+		}
+	}
+}
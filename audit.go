@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/prometheus/common/log"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit log destination which sends records to the local syslog daemon
+const auditSyslog = "syslog"
+
+// Audit record events
+const (
+	Audit_SessionStart = "session_start"
+	Audit_SessionEnd   = "session_end"
+	Audit_Key          = "key"
+	Audit_Pointer      = "pointer"
+	Audit_Clipboard    = "clipboard"
+)
+
+// Kinds of viewer sessions
+const (
+	Viewer_WebSocket  = "websocket"
+	Viewer_MJPEG      = "mjpeg"
+	Viewer_Screenshot = "screenshot"
+)
+
+// Directions of clipboard transfers
+const (
+	Clipboard_ToServer = "to_server"
+	Clipboard_ToViewer = "to_viewer"
+)
+
+// Fields identifying the viewer session an audit record belongs to
+type auditContext struct {
+	Session    string `json:"session"` // Random ID tying a session's records together
+	User       string `json:"user,omitempty"`
+	AuthMethod string `json:"auth_method,omitempty"`
+	RemoteAddr string `json:"remote_addr"`
+	Server     string `json:"server"` // Short name of the server
+}
+
+// Written when a viewer session starts and ends
+type auditSessionRecord struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	auditContext
+	ServerName  string  `json:"server_name,omitempty"`
+	Viewer      string  `json:"viewer"`                     // One of the Viewer_ values
+	Interactive bool    `json:"interactive"`                // Input is passed to the server
+	Duration    float64 `json:"duration_seconds,omitempty"` // Only at the end
+	BytesIn     int64   `json:"bytes_in,omitempty"`         // From the viewer. Only at the end.
+	BytesOut    int64   `json:"bytes_out,omitempty"`        // To the viewer. Only at the end.
+	Error       string  `json:"error,omitempty"`            // Why the session ended, if it failed
+}
+
+// Written for each input event when input is audited
+type auditInputRecord struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	auditContext
+	Key       *auditKey       `json:"key,omitempty"`
+	Pointer   *auditPointer   `json:"pointer,omitempty"`
+	Clipboard *auditClipboard `json:"clipboard,omitempty"`
+	Dropped   bool            `json:"dropped,omitempty"` // Not passed on because the session is read-only
+}
+
+type auditKey struct {
+	Keysym uint32 `json:"keysym"` // X11 keysym
+	Down   bool   `json:"down"`
+}
+
+type auditPointer struct {
+	X       int   `json:"x"`
+	Y       int   `json:"y"`
+	Buttons uint8 `json:"buttons"` // Mask of buttons held down
+}
+
+type auditClipboard struct {
+	Direction string `json:"direction"` // One of the Clipboard_ values
+	Length    int    `json:"length"`
+	Text      string `json:"text,omitempty"` // Only when clipboard text is audited
+}
+
+// Append-only log of who used which server, as JSON lines
+type auditLog struct {
+	w             io.Writer
+	input         bool // Record key, pointer and clipboard events
+	clipboardText bool // Include the text of clipboard transfers
+	mtx           sync.Mutex
+}
+
+// Open the audit log. The destination is syslog, or a file relative to dir.
+func NewAuditLog(destination string, dir string, input bool, clipboardText bool) (*auditLog, error) {
+	var w io.Writer
+	if destination == auditSyslog {
+		writer, err := openAuditSyslog()
+		if err != nil {
+			return nil, err
+		}
+		w = writer
+	} else {
+		filename := destination
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	return &auditLog{w: w, input: input, clipboardText: clipboardText}, nil
+}
+
+func (this *auditLog) write(record interface{}) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		log.Errorln("Error encoding audit record:", err)
+		return
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	// A single write per record, so syslog gets one message each
+	if _, err := this.w.Write(append(b, '\n')); err != nil {
+		log.Errorln("Error writing audit log:", err)
+	}
+}
+
+// Record a viewer session starting. A nil log records nothing.
+func (this *auditLog) Start(r *http.Request, server vncServer, viewer string, interactive bool) *auditSession {
+	if this == nil {
+		return nil
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	session := &auditSession{
+		log:   this,
+		start: time.Now(),
+		context: auditContext{
+			Session:    hex.EncodeToString(id),
+			RemoteAddr: r.RemoteAddr,
+			Server:     server.Short(),
+		},
+		serverName:  server.Name,
+		viewer:      viewer,
+		interactive: interactive,
+	}
+	if id, ok := requestIdentity(r); ok {
+		session.context.User = id.User
+		session.context.AuthMethod = id.Method
+	}
+
+	this.write(auditSessionRecord{
+		Time:         session.start,
+		Event:        Audit_SessionStart,
+		auditContext: session.context,
+		ServerName:   session.serverName,
+		Viewer:       viewer,
+		Interactive:  interactive,
+	})
+	return session
+}
+
+// Audit records of one viewer session. Methods of a nil session do nothing, so
+// callers needn't check whether auditing is enabled.
+type auditSession struct {
+	log         *auditLog
+	start       time.Time
+	context     auditContext
+	serverName  string
+	viewer      string
+	interactive bool
+}
+
+// Record the session ending, with the bytes sent each way
+func (this *auditSession) End(bytesIn int64, bytesOut int64, err error) {
+	if this == nil {
+		return
+	}
+	record := auditSessionRecord{
+		Time:         time.Now(),
+		Event:        Audit_SessionEnd,
+		auditContext: this.context,
+		ServerName:   this.serverName,
+		Viewer:       this.viewer,
+		Interactive:  this.interactive,
+		Duration:     time.Since(this.start).Seconds(),
+		BytesIn:      bytesIn,
+		BytesOut:     bytesOut,
+	}
+	if err != nil && err != io.EOF {
+		record.Error = err.Error()
+	}
+	this.log.write(record)
+}
+
+// Record an input message from the viewer, if input is audited. Dropped input
+// is recorded too, since attempts matter as much as what got through.
+func (this *auditSession) Input(msgType uint8, message []byte, dropped bool) {
+	if this == nil || !this.log.input {
+		return
+	}
+
+	record := auditInputRecord{Time: time.Now(), auditContext: this.context, Dropped: dropped}
+	switch msgType {
+	case rfbMsgKeyEvent:
+		record.Event = Audit_Key
+		record.Key = &auditKey{
+			Keysym: binary.BigEndian.Uint32(message[4:8]),
+			Down:   message[1] != 0,
+		}
+	case rfbMsgPointerEvent:
+		record.Event = Audit_Pointer
+		record.Pointer = &auditPointer{
+			X:       int(binary.BigEndian.Uint16(message[2:4])),
+			Y:       int(binary.BigEndian.Uint16(message[4:6])),
+			Buttons: message[1],
+		}
+	case rfbMsgClientCutText:
+		record.Event = Audit_Clipboard
+		record.Clipboard = this.clipboard(Clipboard_ToServer, message[8:])
+	default:
+		return
+	}
+	this.log.write(record)
+}
+
+// Record clipboard text sent from the server to the viewer, if input is audited
+func (this *auditSession) ServerCutText(text []byte) {
+	if this == nil || !this.log.input {
+		return
+	}
+	this.log.write(auditInputRecord{
+		Time:         time.Now(),
+		Event:        Audit_Clipboard,
+		auditContext: this.context,
+		Clipboard:    this.clipboard(Clipboard_ToViewer, text),
+	})
+}
+
+func (this *auditSession) clipboard(direction string, text []byte) *auditClipboard {
+	clipboard := &auditClipboard{Direction: direction, Length: len(text)}
+	if this.log.clipboardText {
+		// RFB clipboard text is Latin-1
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		clipboard.Text = string(runes)
+	}
+	return clipboard
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Decode audit records, leaving out the fields which change between runs.
+// Generated records must have them.
+func decodeAuditRecords(t *testing.T, b []byte, generated bool) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		record := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		for _, field := range []string{"time", "session"} {
+			if _, ok := record[field]; generated && !ok {
+				t.Errorf("record %q has no %v", line, field)
+			}
+			delete(record, field)
+		}
+		delete(record, "duration_seconds")
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditRecords(t *testing.T) {
+	console := vncServer{ID: "console", Name: "Front desk"}
+	keyDown := []byte{rfbMsgKeyEvent, 1, 0, 0, 0, 0, 0xff, 0x0d}
+	pointer := []byte{rfbMsgPointerEvent, 5, 0x01, 0x02, 0x00, 0x20}
+	cutText := []byte{rfbMsgClientCutText, 0, 0, 0, 0, 0, 0, 5, 'c', 'a', 'f', 0xe9, '!'}
+
+	tests := []struct {
+		name          string
+		input         bool
+		clipboardText bool
+		record        func(session *auditSession)
+		expected      []string // Records written after session_start
+	}{
+		{
+			"session end", false, false,
+			func(session *auditSession) { session.End(12, 3456, nil) },
+			[]string{`{"event":"session_end","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","server_name":"Front desk","viewer":"websocket","interactive":true,"bytes_in":12,"bytes_out":3456}`},
+		},
+		{
+			"session failed", false, false,
+			func(session *auditSession) { session.End(0, 0, errors.New("connection refused")) },
+			[]string{`{"event":"session_end","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","server_name":"Front desk","viewer":"websocket","interactive":true,"error":"connection refused"}`},
+		},
+		{
+			"viewer closed", false, false,
+			func(session *auditSession) { session.End(1, 2, io.EOF) },
+			[]string{`{"event":"session_end","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","server_name":"Front desk","viewer":"websocket","interactive":true,"bytes_in":1,"bytes_out":2}`},
+		},
+		{
+			"input not audited", false, false,
+			func(session *auditSession) {
+				session.Input(rfbMsgKeyEvent, keyDown, false)
+				session.ServerCutText([]byte("text"))
+			},
+			nil,
+		},
+		{
+			"key", true, false,
+			func(session *auditSession) { session.Input(rfbMsgKeyEvent, keyDown, false) },
+			[]string{`{"event":"key","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","key":{"keysym":65293,"down":true}}`},
+		},
+		{
+			"pointer dropped", true, false,
+			func(session *auditSession) { session.Input(rfbMsgPointerEvent, pointer, true) },
+			[]string{`{"event":"pointer","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","pointer":{"x":258,"y":32,"buttons":5},"dropped":true}`},
+		},
+		{
+			"clipboard length", true, false,
+			func(session *auditSession) { session.Input(rfbMsgClientCutText, cutText, false) },
+			[]string{`{"event":"clipboard","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","clipboard":{"direction":"to_server","length":5}}`},
+		},
+		{
+			"clipboard text", true, true,
+			func(session *auditSession) {
+				session.Input(rfbMsgClientCutText, cutText, false)
+				session.ServerCutText([]byte{'n', 0xe4, 'h'})
+			},
+			[]string{
+				`{"event":"clipboard","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","clipboard":{"direction":"to_server","length":5,"text":"café!"}}`,
+				`{"event":"clipboard","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","clipboard":{"direction":"to_viewer","length":3,"text":"näh"}}`,
+			},
+		},
+		{
+			"other messages", true, true,
+			func(session *auditSession) {
+				session.Input(rfbMsgSetEncodings, []byte{rfbMsgSetEncodings, 0, 0, 0}, false)
+			},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		audit := &auditLog{w: buf, input: test.input, clipboardText: test.clipboardText}
+		r := withIdentity(httptest.NewRequest("GET", "/", nil), identity{User: "alice", Method: Auth_Session})
+		session := audit.Start(r, console, Viewer_WebSocket, true)
+		test.record(session)
+
+		expected := []string{`{"event":"session_start","user":"alice","auth_method":"session","remote_addr":"192.0.2.1:1234","server":"console","server_name":"Front desk","viewer":"websocket","interactive":true}`}
+		expected = append(expected, test.expected...)
+		records := decodeAuditRecords(t, buf.Bytes(), true)
+		expectedRecords := decodeAuditRecords(t, []byte(strings.Join(expected, "\n")), false)
+		if !reflect.DeepEqual(records, expectedRecords) {
+			t.Errorf("%v: got records\n%s\nexpected\n%s", test.name, buf.Bytes(), strings.Join(expected, "\n"))
+		}
+	}
+}
+
+func TestAuditSessionIDs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	audit := &auditLog{w: buf}
+	r := httptest.NewRequest("GET", "/", nil)
+	first := audit.Start(r, vncServer{ID: "console"}, Viewer_MJPEG, false)
+	second := audit.Start(r, vncServer{ID: "console"}, Viewer_Screenshot, false)
+	first.End(0, 10, nil)
+
+	var records []auditSessionRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := auditSessionRecord{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %v records, expected 3", len(records))
+	}
+	if records[0].Session == "" || records[0].Session == records[1].Session {
+		t.Errorf("sessions share ID %q", records[0].Session)
+	}
+	if records[2].Session != records[0].Session || records[2].Viewer != Viewer_MJPEG {
+		t.Errorf("end recorded for session %v (%v), expected %v", records[2].Session, records[2].Viewer, records[0].Session)
+	}
+	if records[1].User != "" || records[1].AuthMethod != "" {
+		t.Errorf("unauthenticated session recorded as %v (%v)", records[1].User, records[1].AuthMethod)
+	}
+	second.End(0, 0, nil)
+}
+
+func TestAuditDisabled(t *testing.T) {
+	var audit *auditLog
+	session := audit.Start(httptest.NewRequest("GET", "/", nil), vncServer{ID: "console"}, Viewer_WebSocket, true)
+	if session != nil {
+		t.Fatal("nil audit log started a session")
+	}
+	// None of these may panic
+	session.Input(rfbMsgKeyEvent, []byte{rfbMsgKeyEvent, 1, 0, 0, 0, 0, 0, 'a'}, false)
+	session.ServerCutText([]byte("text"))
+	session.End(1, 2, nil)
+}
+
+func TestNewAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Relative names are in the data directory, and the log is appended to
+	for i := 0; i < 2; i++ {
+		audit, err := NewAuditLog("logs/audit.log", dir, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		audit.Start(httptest.NewRequest("GET", "/", nil), vncServer{ID: "console"}, Viewer_WebSocket, false).End(0, 0, nil)
+		audit.w.(io.Closer).Close()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "logs", "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := decodeAuditRecords(t, b, true); len(records) != 4 {
+		t.Errorf("got %v records, expected 4", len(records))
+	}
+}
+
+// The outcome of the one session in the audit records: "ok", "error", or empty
+// if nothing was recorded
+func auditOutcome(t *testing.T, b []byte, viewer string) string {
+	t.Helper()
+	records := decodeAuditRecords(t, b, true)
+	if len(records) == 0 {
+		return ""
+	}
+	if len(records) != 2 || records[0]["event"] != Audit_SessionStart || records[1]["event"] != Audit_SessionEnd {
+		t.Fatalf("got audit records %s", b)
+	}
+	if records[1]["viewer"] != viewer {
+		t.Errorf("audited viewer %v, expected %v", records[1]["viewer"], viewer)
+	}
+	if _, failed := records[1]["error"]; failed {
+		return "error"
+	}
+	return "ok"
+}
+
+// Audit log destination which can be read while handlers write to it
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mtx sync.Mutex
+}
+
+func (this *lockedBuffer) Write(p []byte) (int, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.buf.Write(p)
+}
+
+func (this *lockedBuffer) Bytes() []byte {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return append([]byte{}, this.buf.Bytes()...)
+}
+
+func (this *lockedBuffer) Reset() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.buf.Reset()
+}
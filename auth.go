@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Name of the cookie holding login sessions
+const sessionCookieName = "vncdashboard_session"
+
+// Shortest session secret accepted from a file
+const sessionMinSecretLength = 32
+
+var ErrSessionSecretTooShort = fmt.Errorf("session secret must be at least %v bytes", sessionMinSecretLength)
+
+// Who made a request
+type identity struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"` // Groups given by the identity provider
+	Roles  []string `json:"roles,omitempty"`  // Roles the groups map to
+	Method string   `json:"method"`           // How they authenticated
+}
+
+// Authentication methods
+const (
+	Auth_Basic   = "basic"
+	Auth_Session = "session" // Logged in with the login form
+	Auth_OIDC    = "oidc"    // Logged in through an OpenID Connect provider
+	Auth_Cert    = "cert"    // TLS client certificate
+)
+
+// Client certificate fields which may name the user
+const (
+	CertUser_CommonName = "cn"
+	CertUser_Email      = "email" // First email SAN
+	CertUser_DNS        = "dns"   // First DNS SAN
+	CertUser_URI        = "uri"   // First URI SAN
+	CertUser_Subject    = "subject"
+)
+
+type identityKey struct{}
+
+// The identity of an authenticated request, if authentication is enabled
+func requestIdentity(r *http.Request) (identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(identity)
+	return id, ok
+}
+
+func withIdentity(r *http.Request, id identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+}
+
+// A way of telling who made a request. Returns false if the request doesn't
+// carry valid credentials for the method. Methods may set cookies on the
+// response, e.g. to refresh a session.
+type authMethod interface {
+	Authenticate(w http.ResponseWriter, r *http.Request) (identity, bool)
+}
+
+// Checks a user name and password, for basic auth and the login form
+type passwordVerifier interface {
+	Verify(user string, password string) bool
+	Exists(user string) bool // Whether the user may still log in
+}
+
+// HTTP basic auth
+type basicAuth struct {
+	passwords passwordVerifier
+}
+
+func (this basicAuth) Authenticate(w http.ResponseWriter, r *http.Request) (identity, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok || !this.passwords.Verify(user, password) {
+		return identity{}, false
+	}
+	return identity{User: user, Method: Auth_Basic}, true
+}
+
+// TLS client certificates verified against the configured CAs. The user is
+// named by one field of the certificate, and the organizational units of its
+// subject are the user's groups.
+type certAuth struct {
+	userField string // One of the CertUser_ values
+}
+
+// Check a client certificate field is one which can be used
+func validCertUserField(field string) bool {
+	switch field {
+	case CertUser_CommonName, CertUser_Email, CertUser_DNS, CertUser_URI, CertUser_Subject:
+		return true
+	}
+	return false
+}
+
+func (this certAuth) Authenticate(w http.ResponseWriter, r *http.Request) (identity, bool) {
+	// Chains are only verified when a certificate was given and checked out
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return identity{}, false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	user := ""
+	switch this.userField {
+	case CertUser_CommonName:
+		user = cert.Subject.CommonName
+	case CertUser_Email:
+		if len(cert.EmailAddresses) > 0 {
+			user = cert.EmailAddresses[0]
+		}
+	case CertUser_DNS:
+		if len(cert.DNSNames) > 0 {
+			user = cert.DNSNames[0]
+		}
+	case CertUser_URI:
+		if len(cert.URIs) > 0 {
+			user = cert.URIs[0].String()
+		}
+	case CertUser_Subject:
+		user = cert.Subject.String()
+	}
+	if user == "" {
+		log.With("subject", cert.Subject.String()).With("remote_addr", r.RemoteAddr).Warnln("Client certificate has no", this.userField, "to name the user")
+		return identity{}, false
+	}
+	return identity{User: user, Groups: cert.Subject.OrganizationalUnit, Method: Auth_Cert}, true
+}
+
+// Seals and opens session cookies. A session is its JSON encrypted with AES-GCM,
+// so it can't be read or forged without the secret, base64 encoded.
+type sessionCodec struct {
+	aead     cipher.AEAD
+	lifetime time.Duration
+}
+
+// A login session
+type sessionPayload struct {
+	Identity  identity `json:"id"`
+	Expires   int64    `json:"exp"`
+	Refresh   string   `json:"rt,omitempty"`  // OpenID Connect refresh token
+	RefreshAt int64    `json:"rat,omitempty"` // When to check the session with the identity provider
+}
+
+// Load the secret from a file, or make a random one which lasts until restart if
+// there isn't a file
+func NewSessionCodec(secretFile string, lifetime time.Duration) (*sessionCodec, error) {
+	secret := make([]byte, 32)
+	if secretFile != "" {
+		b, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = []byte(strings.TrimSpace(string(b)))
+		if len(secret) < sessionMinSecretLength {
+			return nil, ErrSessionSecretTooShort
+		}
+	} else {
+		log.Warnln("No session secret file given, sessions will end when the dashboard restarts")
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionCodec{aead: aead, lifetime: lifetime}, nil
+}
+
+// Start a session for an identity
+func (this *sessionCodec) New(id identity) sessionPayload {
+	return sessionPayload{Identity: id, Expires: time.Now().Add(this.lifetime).Unix()}
+}
+
+func (this *sessionCodec) Encode(session sessionPayload) string {
+	payload, _ := json.Marshal(session)
+	return this.seal(sessionCookieName, payload)
+}
+
+// Open a session value, returning it if it's genuine and current
+func (this *sessionCodec) Decode(value string) (sessionPayload, bool) {
+	payload, ok := this.open(sessionCookieName, value)
+	if !ok {
+		return sessionPayload{}, false
+	}
+	session := sessionPayload{}
+	if err := json.Unmarshal(payload, &session); err != nil || session.Identity.User == "" {
+		return sessionPayload{}, false
+	}
+	if time.Now().Unix() >= session.Expires {
+		return sessionPayload{}, false
+	}
+	return session, true
+}
+
+// Encrypt a value for a cookie. The cookie name is authenticated with it, so a
+// value sealed for one cookie can't be passed off as another.
+func (this *sessionCodec) seal(name string, payload []byte) string {
+	nonce := make([]byte, this.aead.NonceSize())
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(this.aead.Seal(nonce, nonce, payload, []byte(name)))
+}
+
+// Decrypt a value sealed for a cookie, returning false if it was tampered with
+// or sealed for another cookie
+func (this *sessionCodec) open(name string, value string) ([]byte, bool) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < this.aead.NonceSize() {
+		return nil, false
+	}
+	nonce := sealed[:this.aead.NonceSize()]
+	payload, err := this.aead.Open(nil, nonce, sealed[len(nonce):], []byte(name))
+	if err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// Set a cookie holding a sealed value
+func (this *sessionCodec) setCookie(w http.ResponseWriter, r *http.Request, name string, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Set the session cookie
+func (this *sessionCodec) SetCookie(w http.ResponseWriter, r *http.Request, session sessionPayload) {
+	this.setCookie(w, r, sessionCookieName, this.Encode(session), time.Unix(session.Expires, 0))
+}
+
+func (this *sessionCodec) ClearCookie(w http.ResponseWriter, r *http.Request) {
+	this.clearCookie(w, r, sessionCookieName)
+}
+
+func (this *sessionCodec) clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Renews sessions with the identity provider that started them
+type sessionRefresher interface {
+	Refresh(session sessionPayload) (sessionPayload, error)
+}
+
+// Session cookies, as set by the login form or OpenID Connect login
+type sessionAuth struct {
+	sessions  *sessionCodec
+	refresher sessionRefresher // Renews OpenID Connect sessions. May be nil.
+	passwords passwordVerifier // Ends login form sessions of removed users. May be nil.
+}
+
+func (this sessionAuth) Authenticate(w http.ResponseWriter, r *http.Request) (identity, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return identity{}, false
+	}
+	session, ok := this.sessions.Decode(cookie.Value)
+	if !ok {
+		return identity{}, false
+	}
+	if session.Identity.Method == Auth_Session && (this.passwords == nil || !this.passwords.Exists(session.Identity.User)) {
+		log.With("user", session.Identity.User).Infoln("Ending session of user no longer in the htpasswd file")
+		this.sessions.ClearCookie(w, r)
+		return identity{}, false
+	}
+	if session.RefreshAt == 0 || time.Now().Unix() < session.RefreshAt {
+		return session.Identity, true
+	}
+
+	// Websocket upgrades can't set cookies, so a refresh there would be lost. The
+	// session is checked again on the next ordinary request.
+	if websocket.IsWebSocketUpgrade(r) {
+		return session.Identity, true
+	}
+	if this.refresher == nil {
+		return identity{}, false
+	}
+	refreshed, err := this.refresher.Refresh(session)
+	if err != nil {
+		log.With("user", session.Identity.User).Infoln("Session refresh failed:", err)
+		this.sessions.ClearCookie(w, r)
+		return identity{}, false
+	}
+	this.sessions.SetCookie(w, r, refreshed)
+	return refreshed.Identity, true
+}
+
+// Paths anyone may request, so the login page works
+var authPublicPaths = map[string]bool{
+	"/login":                   true,
+	"/login/methods":           true,
+	"/logout":                  true,
+	"/auth/oidc/login":         true,
+	"/auth/oidc/callback":      true,
+	"/static/login.html":       true,
+	"/static/dashboard.css":    true,
+	"/static/include/base.css": true,
+}
+
+// Requires every request to be authenticated by one of a set of methods
+type authenticator struct {
+	methods   []authMethod
+	sessions  *sessionCodec
+	passwords passwordVerifier // For the login form. May be nil.
+	oidc      *oidcProvider    // May be nil.
+	basic     bool             // Challenge clients to use basic auth
+	public    map[string]bool  // Extra paths anyone may request
+}
+
+// Whether users can log in from the browser, rather than only with certificates
+func (this *authenticator) canLogin() bool {
+	return this.passwords != nil || this.oidc != nil
+}
+
+// Check whether a request changing state came from another site. Browsers send
+// cookies and basic auth credentials with those too, so they must be refused.
+// Requests to the API with a body must also be JSON, which other sites can't
+// send without the browser asking first.
+func crossSiteRequest(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		if site != "same-origin" && site != "none" {
+			return true
+		}
+	} else if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return true
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/") && r.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap a handler so only authenticated requests reach it. Browsers navigating to
+// pages are sent to the login form; anything else gets a 401. Requests changing
+// state from other sites are refused.
+func (this *authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if crossSiteRequest(r) {
+			log.With("path", r.URL.Path).With("remote_addr", r.RemoteAddr).Warnln("Refused cross-site request")
+			http.Error(w, "Cross-site request refused", 403)
+			return
+		}
+		for _, method := range this.methods {
+			if id, ok := method.Authenticate(w, r); ok {
+				next.ServeHTTP(w, withIdentity(r, id))
+				return
+			}
+		}
+		if authPublicPaths[r.URL.Path] || this.public[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if this.canLogin() && r.Method == "GET" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), 302)
+			return
+		}
+		if this.basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="VNC Dashboard"`)
+		}
+		http.Error(w, "Authentication required", 401)
+	})
+}
+
+// Only follow redirects within the dashboard after logging in. Browsers ignore
+// tabs and newlines in URLs and treat backslashes as slashes, so anything with
+// those could lead elsewhere.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\") {
+		return "/"
+	}
+	for _, c := range next {
+		if c < 0x20 || c == 0x7f {
+			return "/"
+		}
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "//") {
+		return "/"
+	}
+	return next
+}
+
+// Serve the login form. When single sign-on is the only way to log in, go
+// straight to the identity provider.
+func (this *authenticator) loginPageHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if this.passwords == nil && this.oidc != nil {
+			http.Redirect(w, r, "/auth/oidc/login?next="+url.QueryEscape(safeRedirect(r.URL.Query().Get("next"))), 302)
+			return
+		}
+		b, err := Asset("login.html")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(b)
+	}
+}
+
+// Check the login form and start a session
+func (this *authenticator) loginHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		user := r.PostFormValue("username")
+		next := safeRedirect(r.PostFormValue("next"))
+		if this.passwords == nil || !this.passwords.Verify(user, r.PostFormValue("password")) {
+			log.With("user", user).With("remote_addr", r.RemoteAddr).Warnln("Failed login")
+			http.Redirect(w, r, "/login?error=1&next="+url.QueryEscape(next), 303)
+			return
+		}
+
+		log.With("user", user).With("remote_addr", r.RemoteAddr).Infoln("Logged in")
+		this.sessions.SetCookie(w, r, this.sessions.New(identity{User: user, Method: Auth_Session}))
+		http.Redirect(w, r, next, 303)
+	}
+}
+
+// Which ways of logging in the login form should offer
+func (this *authenticator) loginMethodsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{
+			"password": this.passwords != nil,
+			"oidc":     this.oidc != nil,
+		})
+	}
+}
+
+// End the session
+func (this *authenticator) logoutHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		this.sessions.ClearCookie(w, r)
+		http.Redirect(w, r, "/login", 303)
+	}
+}
+
+// Who the request is from
+func whoamiHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		id, ok := requestIdentity(r)
+		if !ok {
+			http.Error(w, "Authentication is disabled", 404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(id)
+	}
+}
@@ -24,6 +24,15 @@
 // static/include/util.js
 // static/include/websock.js
 // static/include/webutil.js
+// static/kiosk.html
+// static/kiosk.js
+// static/login.html
+// static/player.html
+// static/player.js
+// static/recordings.html
+// static/recordings.js
+// static/thumbnails.html
+// static/thumbnails.js
 // static/vnc_auto.html
 // DO NOT EDIT!
 
@@ -92,7 +101,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _dashboardCss = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x74\xce\xbb\xae\xc2\x30\x0c\x06\xe0\x3d\x4f\x11\xe9\xcc\x3d\xea\x9c\x3e\x8d\x71\x2e\x58\x35\x76\x95\x98\x16\x84\x78\x77\x50\x95\x25\x03\x1e\xbf\xdf\xb7\xff\x5d\x70\x42\x15\xab\xca\xcd\xbf\x9c\xff\x96\xa5\x87\x4d\xc0\x54\x24\x78\x4c\x62\xa9\x2e\xee\xed\x1c\x82\xec\xd0\xce\x81\x83\x24\xea\xd1\xdb\x23\xb5\x8d\xe1\x19\xfc\x85\x15\xd7\xe5\xb4\x1b\xd4\x42\x32\x55\x2a\x57\x0b\x1e\xee\xa6\x83\x73\xca\x03\x6f\x10\x23\x49\xe9\x3e\x8f\xd8\x97\xcc\xe7\x13\x10\x98\x64\xed\x97\x51\x59\x6b\xf0\x7f\x39\xe7\x1e\xee\xd4\xc8\x52\xfc\x91\x7f\x02\x00\x00\xff\xff\x98\xf1\x7d\xf2\xed\x00\x00\x00")
+var _dashboardCss = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x55\xdb\x8e\x9b\x30\x10\x7d\xcf\x57\x58\x8a\x22\xb5\xd2\x82\xd8\x2c\x8d\x56\x44\xfd\x92\x2a\x0f\xc6\x36\x30\x8a\xb1\x2d\xdb\xe4\xa2\xaa\xff\x5e\x1b\x0c\x01\x02\xd9\xcd\x13\x0c\x33\x9e\x33\xc7\xe7\x4c\xe2\x8b\x20\x11\x91\xc2\x6a\xc9\x0d\xfa\xbb\x41\xee\x67\xd9\xcd\x46\x98\x43\x29\x32\x44\x98\xb0\x4c\x1f\x37\xff\x36\x9b\xd8\xa7\x0a\x5c\xb3\x90\x56\x63\x5d\x82\x88\x34\x94\x95\xcd\xd0\xa7\xba\x3d\xb2\xa8\xbc\x0a\x44\xb0\xb8\x60\xd3\xbe\x5f\x41\xb8\x50\xa8\x93\x0a\x13\xb0\xf7\x0c\x25\xf1\xc7\xa3\xc4\x58\xcc\xd9\x7a\x4d\x63\x39\x08\x96\xa1\x0f\x75\x43\x46\x72\xa0\x68\x4b\x92\xa4\x2d\x5f\xab\xa1\x60\x14\xc7\xae\x4f\xce\x25\x39\x1f\x17\x30\xe3\xc6\xca\x49\x9c\xb3\x62\x12\x56\x98\x52\x10\x65\x88\x27\xd3\x60\x38\xa4\x03\x81\x33\x87\xef\x1c\x3a\x13\xc9\xa5\xce\xd0\xb6\x28\x8a\xf0\xf1\x02\x06\x2c\xa3\x2b\xdf\x37\xb1\xc3\xe9\x46\x8c\x4a\x0d\x74\x8e\xde\xc7\xba\xc6\xfe\x29\x2a\xb1\xca\x50\xea\xc9\xf6\xa1\x8a\x75\x20\x08\xe6\xe4\xc7\x7b\x92\x5c\x2a\x14\xa1\x34\x51\xb7\x9f\x1d\xb3\x67\x90\xe6\x8c\x62\x85\x4b\x36\xbf\xe5\xa1\x81\x90\x82\x4d\xb2\x9f\xc1\xf4\x6d\xda\x0e\x5d\x6e\x48\x22\x8c\xf3\xfe\x8e\x2e\x4c\x17\x5c\x5e\x33\x54\x01\xa5\x4c\x04\x66\x1d\xad\x57\xa0\xb6\x1a\xf8\xf3\x91\xfe\xc0\x8e\xbc\x45\x7c\x63\x15\xb6\x54\x4f\xd8\x0f\x1c\xb4\xc5\x0e\x0a\xd3\xdf\x11\xf1\xf7\xea\x41\xa8\xc6\xfe\xb1\x77\xc5\x7e\x6b\x2c\x4a\x76\x0a\x27\x86\x29\x7e\x25\xbb\xee\x24\x37\xae\x05\x47\x7c\xdf\xa5\x76\x53\xf3\x8e\x49\x8b\x73\xce\x62\xcd\x88\xd4\xbe\x99\x99\x3a\x66\xae\xb2\x35\x51\xe6\xae\xba\x05\xc6\x39\x56\xc6\x89\xbf\x7f\x5a\xee\x61\xe9\x1b\x7a\x0e\x56\xa1\xf7\x30\xf7\xde\x39\xe8\x7d\xdf\x0b\x68\x4c\x93\xc7\xd5\x1e\xbd\xb5\x55\x53\xe7\x02\xc3\x97\x2b\x61\x48\x6c\xe9\xc3\xce\xa2\x7a\x2e\x2f\x10\xde\xb9\xd1\xc8\x84\x73\xe2\xac\x54\x63\x22\x32\x74\x08\x57\x03\x75\xf9\xe8\xf0\xc2\xd5\x1d\x51\x4e\x9e\x8f\xe5\x90\xa6\xe9\x13\x42\xac\x2c\x48\xb1\xe4\xc1\x39\x41\xf3\x52\xa6\xb5\xd4\x68\x09\xce\xd3\x32\x83\x42\xbb\x15\xd9\x59\x29\xf2\xba\xee\xf9\x97\x6e\x03\xb8\xf6\x19\x2a\xe0\xc6\x82\xa3\xdd\xe4\x83\x2d\x26\x3b\x26\x68\xcd\xf9\x6d\x77\x9c\x3b\x70\x37\x1d\x7a\x66\xdf\x88\xd5\xca\xde\xd7\x86\x5c\x73\x45\x90\x60\x8b\x27\x7d\x78\x5c\xba\xe0\x54\xfd\x7b\xbf\x5c\xa6\xb7\xb5\xf7\x5b\xc7\xab\x16\x25\x23\xf1\xce\x77\x5c\x38\x8c\xe3\x9c\xf1\x37\x14\xde\x46\x56\x33\x4d\x5e\x83\x3d\x7d\xbd\xbb\x73\x69\xad\xac\x47\x7f\x38\x4f\x47\xf9\x21\x4f\x4b\x3d\x14\x36\xe6\xea\x68\x7b\xd5\x65\xc2\xfc\x70\x7c\x24\x81\x12\x84\x57\xf6\x77\xc8\xe9\x54\x32\x4b\x39\x1c\x5e\xa1\xff\x0f\x84\x27\x93\xb8\x7f\x07\x00\x00")
 
 func dashboardCssBytes() ([]byte, error) {
 	return bindataRead(
@@ -107,12 +116,12 @@ func dashboardCss() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "dashboard.css", size: 237, mode: os.FileMode(420), modTime: time.Unix(1464936336, 0)}
+	info := bindataFileInfo{name: "dashboard.css", size: 1919, mode: os.FileMode(436), modTime: time.Unix(1792237853, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
 
-var _dashboardHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8c\x90\xbd\x8e\xc3\x20\x10\x84\x7b\x9e\x82\xa3\xc7\xbc\x00\xa6\xb9\xab\xae\xb8\xe6\xa4\xf4\x18\x36\x82\x64\x63\x5b\xec\x3a\x92\xdf\x3e\x3f\xb6\x83\x22\x25\x52\x68\xd0\x32\xc3\xec\xa7\xb1\x89\x4f\xe8\x84\x4d\xe0\xa3\x13\xc2\x72\x66\x04\xb7\xfb\xfb\x96\x3f\x9e\x52\x37\xf8\x12\xad\x59\x1e\x85\xbc\x1e\xfb\xa5\xb5\xfc\xe7\x19\x81\x12\x00\x93\xd4\x7a\x15\x30\xf7\x47\x59\x00\x5b\x45\x0f\x59\xc9\x54\x60\xdf\xaa\xdc\x07\x9c\x22\x98\xce\x13\x34\x81\x48\xc9\x7b\x64\xab\x46\xf4\xb9\x57\x1f\x24\xc4\x8d\xe6\xed\xf7\x8a\xf7\xeb\xcf\x9e\x42\xc9\x23\x57\xba\x75\xa6\x12\x2a\xcd\xc4\x19\x9b\x03\x29\x67\xcd\x22\xbf\xf0\xd6\xbd\xcf\x46\x6b\xb6\xc6\xba\x21\xce\xb7\x79\xbb\x97\x42\x2f\x01\x00\x00\xff\xff\x02\x2b\xbe\xc2\x58\x01\x00\x00")
+var _dashboardHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x52\xcb\x52\xc4\x20\x10\xbc\xe7\x2b\x46\xee\x59\x7e\x80\xa4\xca\xd2\x93\x07\x0f\x6a\x79\x9f\xc0\xec\x06\x65\x21\xc5\x90\xad\xca\xdf\x4b\xb2\x79\x18\x6b\xb5\xe4\x04\x33\xdd\x4d\x37\x83\x6a\xd3\xd9\xd5\x85\x6a\x09\x4d\x5d\x14\x2a\xd9\xe4\xa8\x7e\x7f\x7e\x80\x47\xe4\xb6\x09\x18\x8d\x92\xd7\x62\x01\x79\xa9\xbb\xb2\x84\x7b\xc7\x01\x98\xe2\x85\x0c\x1c\x43\x04\xc6\x71\xe7\x70\x08\x7d\x62\xe8\xbd\xa1\x08\xd2\x2c\x7c\x09\x65\x39\x93\x1b\x64\x82\x36\xd2\xb1\x12\x92\x13\x26\xab\xa5\xf8\xa6\xfb\x9a\x06\x47\xdc\x12\x65\x95\x95\xe3\xac\xff\x84\x48\xae\x12\xbc\xb6\xc5\x2c\x62\xbd\x76\xbd\x21\x39\xea\x1e\x34\xb3\x80\xc9\x6a\x25\x3a\x87\xd6\x8b\x7f\x28\xac\x2e\x7f\xa5\x6f\xf6\x9e\xf0\x82\xac\xa3\xed\xd2\xe6\x6e\x3e\x73\xd4\x9b\x9b\x3e\x59\x77\xf8\x60\x51\x2b\x79\x6d\xdf\xc0\x6e\xf7\xee\x81\x4a\x2e\x93\x68\x82\x19\x66\xa2\xb1\x17\xd0\x0e\x99\xb3\x33\x3c\x51\xa9\x83\x4f\x31\x38\x9e\x1d\x4e\x18\xfc\xf1\xae\xa9\xed\xcf\x8d\x47\xeb\xf8\x30\x8e\x58\xd4\x6f\x6b\x41\x49\xfc\x83\x18\x49\x87\x68\xac\x3f\x2d\xc4\x97\xb5\xb0\x27\x72\x87\x1e\xac\xa9\xc4\x3c\xf8\x29\x46\xae\xdd\x82\x58\x43\x3e\xbf\xed\xb0\xc7\x28\x99\x93\x8d\x99\xaf\x59\x73\xf6\xe9\x33\x7e\x01\x2a\x55\x78\x91\x94\x02\x00\x00")
 
 func dashboardHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -127,12 +136,12 @@ func dashboardHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "dashboard.html", size: 344, mode: os.FileMode(420), modTime: time.Unix(1464935394, 0)}
+	info := bindataFileInfo{name: "dashboard.html", size: 660, mode: os.FileMode(436), modTime: time.Unix(1792237455, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
 
-var _dashboardJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xac\x57\x5b\x6f\xdb\x3a\x12\x7e\xcf\xaf\xe0\xea\xc5\x32\xd6\x95\x53\x6c\x76\x71\x10\x23\x58\xb4\x39\xcd\xb6\x8b\xde\x90\x4b\x5b\x20\x08\x0c\x5a\x1a\x5b\xdc\xd0\xa4\x4a\x52\x76\x8d\xc0\xff\x7d\x67\x28\xea\x66\x2b\x41\x1f\x8e\x1f\x12\x91\x9c\xf9\x86\x73\x1f\x9e\x6c\xb8\x61\xb0\x71\x37\xba\x34\x29\xb0\x0b\xa6\x60\xcb\xde\x6d\x40\x85\x9d\x38\x9a\xf2\x42\x4c\xa5\xb0\x6e\x6a\xcb\x85\x4d\x8d\x58\x40\x34\x9e\x79\xbe\x8d\x4a\x6f\xc0\x5a\xa1\x95\x45\xce\xa7\xfd\xec\xc4\x6f\xe7\xda\xba\x09\x2b\xb4\x71\xb8\xb1\x2c\x55\xea\x90\x82\x95\x45\xc6\x1d\xdc\x38\xfc\x13\x9b\xe5\x62\xc2\x2c\x7d\x4e\x98\x96\x59\xf8\x5a\xdb\xd5\x98\x3d\x9d\x30\xfc\xa5\x08\xa9\x25\x24\x52\xaf\x62\x7f\x8a\x12\x69\x5f\x2c\x59\xb5\x66\x17\x17\x6c\x94\x09\x8b\x84\x0a\x52\x07\xd9\xa8\x66\xa5\x1f\xe2\x27\xe1\x84\x64\x25\x73\xfc\x33\xaf\xae\xd5\x2c\xe9\x7e\xdd\x25\xb7\x76\xab\x4d\xd6\xdb\x72\xf9\xd8\x63\xee\x4f\xf6\x1d\x4d\xd0\x44\xdf\x3e\x5f\x5e\x4a\x81\x56\x8a\x89\x68\xc2\x32\xb1\x99\xb0\x94\xab\x0d\xb7\x43\x2a\x44\x97\x06\xb8\x13\x6a\xc5\x90\x91\xa5\x9e\xf3\x9c\x45\xec\xef\xcc\xcb\xa8\x74\x73\x66\xd7\x57\x21\x78\xe3\xfa\xea\x6d\xfc\x34\x72\xdc\xac\xc0\x8d\xce\xc3\x71\x25\x6b\xd2\x90\xd3\x6f\x04\x2a\x35\xbb\xa2\x21\xfa\x0e\x8b\x3b\x27\x64\x82\x7c\x97\x5a\x2d\xc5\xea\x1b\x37\x71\x43\xd4\xe7\xa5\x5f\xbc\x15\x2a\xd3\x5b\xbc\x71\xca\x49\xd1\xa4\x30\xda\xe9\x54\x4b\x34\xf6\x05\x8b\x72\xe7\x0a\x7b\x1e\x8d\xc7\x07\x52\x0d\x14\xa8\x1c\x98\x0f\x7f\x7a\xc1\xc3\x52\x3b\x44\x13\x36\x1a\x1d\x62\x38\x53\xc2\x1c\x25\x69\xf3\x02\x46\x87\x68\xc2\x68\x71\x88\x42\x17\x97\xf3\xb4\x34\xd6\xe3\x0c\xa3\x84\xe3\x61\x04\x9b\x73\x83\xb1\x54\x5b\x79\x18\x21\x10\x0d\x23\x6c\x04\x6c\xe7\x5a\xc9\x5d\x05\x32\x8c\xd0\x12\x0d\x83\x68\x75\xd7\x66\x0b\x01\x75\x92\xe7\x88\xf4\xc7\xa6\xf8\xa0\x44\xf0\xba\x2a\xa5\x3c\xa2\xf8\x1a\x62\xfb\x1a\x7e\x96\x22\xe8\x37\x48\x78\xf5\xf6\xee\x52\xaf\x0b\x09\x5e\x2a\x91\xec\x43\x70\xee\x31\xe2\x5c\x9a\xb3\x18\x7e\xa5\xdd\x4c\xeb\x46\xf9\xe8\x4e\xf1\x85\x04\xe6\x34\x4b\x29\xde\x81\x42\x37\x44\x3b\x7b\xf5\x8a\x8d\x30\xde\x89\x7d\xd6\x06\x39\xb8\xd2\xa8\x19\x9b\x4e\x59\xa6\xd5\xc8\x11\x1c\xa6\x49\x09\x94\x0c\x94\x2e\x04\x55\xe5\x71\xc8\x43\xff\xaf\x9b\xde\x6d\xb1\x99\xb0\x28\x9a\xd4\x09\xe5\xe9\x3a\xf5\xe9\x9e\xf6\x1f\x30\xa3\xee\xab\x5c\xa5\xf4\x7a\x98\x0d\xa4\xf5\x7b\xc4\x8b\xa1\x56\xd1\x21\x47\x17\x25\xc2\xc5\x94\xf2\x16\x12\x74\x08\x7f\x68\x8a\x92\xa3\x82\x14\x95\x2a\x83\xa5\x50\x90\x45\xcf\xd9\x28\x7a\x23\xd1\x34\xd9\x8e\xe5\x7c\x03\x8c\x33\x5b\x21\xa3\xa2\x2d\xea\x91\x81\xba\xba\xe3\xed\xf1\x4e\x99\x4e\xcb\x35\x9a\x35\xa9\x0c\xfd\x4e\x02\xad\xe2\x08\x4f\xa3\xc0\x8e\x9f\x49\x2a\xd1\xf3\x9f\xf9\x9a\x0a\x3b\x5d\xfd\x15\xd9\x97\xe3\x05\x4d\xd4\x12\x89\x0c\x4f\x2b\xc9\xc1\x6e\x44\x65\xb4\xfc\xf3\x77\x45\xb5\xf4\xc3\x12\xf1\xcc\x46\xad\x52\x52\xa8\xc7\x17\x80\x79\x34\xee\xd3\x26\x16\xdc\x1b\xe7\xb0\xf3\x94\xd8\x3b\xa2\xdc\xc0\x12\x1d\x1d\x4d\xa9\x11\x88\x74\x8a\x52\xe6\xbc\x74\x3a\xc9\xdd\x5a\xfe\x9b\xfc\x7c\xd1\xf7\xd2\x21\x9c\xc0\xc8\x31\xef\x6f\x3f\x7d\xa4\x3b\x5e\x61\x90\x63\x57\x03\x50\xd1\x91\x32\xbc\x28\x40\x65\x97\xb9\x90\x59\x4c\x9c\x75\x5c\x55\xc5\xf7\x05\x15\x2a\x82\xc6\x3c\x7e\x75\x6c\x9a\xaa\xdc\x46\xb3\xc6\xb1\x3d\x81\xed\x3d\x3a\x0e\xed\x11\x54\xed\xa6\x66\xaf\xaf\xb2\xd0\xd9\xae\x47\x97\x79\x04\x4f\x84\x79\xf6\x41\xa1\xd9\x30\xc9\x28\x3d\xb9\xef\x2f\x6d\x4b\xf2\x34\xbd\xee\x76\x10\xef\xfd\x46\x87\xa0\xdd\xfc\x31\xb0\xd6\x1b\xc8\x8e\x72\xa8\x17\xfe\xd7\x44\x34\xd4\x08\x7b\xae\xfa\xeb\xd3\xee\x93\x40\x28\x14\x1b\xf2\x0d\x15\xd1\xb4\x54\xda\xe5\xf4\x7f\xa9\xcd\xc0\x35\x8e\x32\xb0\x4d\x40\x77\x7f\xfa\x30\xab\x8b\x91\x5f\xbf\x7e\x98\xb5\xd5\xa9\x9d\x4c\xe2\xda\x7b\x3d\xff\x54\xb6\xea\xfa\xc7\xd3\x00\x55\xde\x17\x35\xef\x97\x2c\x53\xaa\x37\x45\x11\xd7\x5a\xfb\x4e\x23\x35\xcf\xe6\x34\xa7\x15\xce\xc6\xf7\xd1\x16\x30\x6b\x70\xfb\x7f\x96\x72\x66\xc1\x2d\xfc\xeb\x2c\x2c\xf0\xc8\xea\xf4\x31\xac\x32\xb0\xfe\xab\xd1\x3c\x7a\x84\x9d\xdd\xad\xd1\xb4\x81\x02\xd7\x0b\xcd\x4d\x16\x96\x42\x15\xa5\xab\x99\x85\x2d\x24\xdf\x1d\x00\x08\xb5\x94\xdc\x69\x13\x88\xc8\x30\x0d\x12\x22\xd3\xe2\x61\xdc\xd7\x88\x6e\x7f\x5d\x2a\x85\x4e\xf9\xa6\x52\xdb\xa8\x86\x91\xfb\x55\xa4\x8f\x65\xc1\xb8\xda\xa1\x17\xcd\x06\x8c\x65\x5b\x60\x6b\xb1\xca\xdd\x68\x43\x1f\xd6\x42\x56\xd5\x7d\x1c\x42\x0d\xfc\x0c\xf3\xd3\x8f\x4f\x1f\xdf\xe3\xe8\x42\xcd\x0f\x6c\xe3\x0f\x3c\x4f\x78\x96\xf9\x51\xf7\x23\x4e\xb7\x80\x35\x21\x8e\x48\x3c\x5e\xb0\xbe\x4e\xdc\x8d\xa7\xfe\x7c\x16\xfa\x0b\xb1\xa2\x9c\xff\xde\x7c\xf9\x9c\x14\xdc\x58\x9c\x6d\x11\xd8\x80\x2d\xd0\x7b\x70\x0b\xbf\xdc\xb8\xc7\x82\x81\xc6\x62\xba\x1f\xce\x56\x05\x18\xb7\x63\x42\xd5\x38\xe3\x03\xf8\x3a\xc8\xc3\x71\x92\x73\xfb\x65\xab\xbe\x06\xc6\xb8\x46\x18\x0f\xf1\xb5\x99\xec\x93\xf1\x09\x87\x65\x0c\x9f\x11\x3b\x6f\x05\xef\x3b\x51\x5e\xff\xf6\x27\xc3\xab\xe7\xda\x3f\xfd\x7a\x79\x76\xc5\x85\x84\x8c\x91\x29\x28\xaf\x6a\x43\x30\x87\x96\x48\xd8\x77\x21\x25\x65\x94\xf1\x6a\xbf\xb6\x49\xd4\xb7\x0e\x16\xfa\x5b\xb1\x06\x5d\xba\xf8\x20\x10\x26\xec\xf5\xe9\xe9\x69\x4b\x5d\x5d\x6d\xdf\x71\x26\x6a\xa5\xe2\xe8\x3f\xef\x6e\x7d\x6f\xa8\x1f\x2d\x51\x18\xb0\x5a\x3a\x8b\x85\x31\xae\xa2\x2e\xcc\xbb\x78\xc1\x2a\x5d\x48\x26\x3a\xb3\x09\xc6\xc6\xfd\xf5\x14\x27\x70\xce\x9a\xa3\x9e\x2b\xbc\x55\x3c\x3c\xda\x85\x53\x9a\x72\xb7\xdc\xa8\xd1\xb8\x53\x7b\xdf\xee\x30\xc5\x97\xbc\x94\x38\xab\x94\x64\x94\x1c\xfc\x63\x09\x63\x3a\xf3\x23\x0c\xd3\xcb\x10\xdb\x78\xc6\x5d\xf5\x9d\xe1\xb7\xb0\x6c\x89\x86\xf5\x40\x9e\xe3\xe2\x99\xc9\x92\x0e\x51\xf6\xe1\x24\x4f\xdb\x0a\x5b\x4f\xb0\x83\x97\xf5\x1c\x04\x1d\x0e\x40\xd0\x76\x47\x17\x0c\xcc\x0a\xe5\x82\xfd\x71\xca\x62\x0c\xeb\xb3\xb3\x7f\x8c\x49\x25\xc5\x84\x63\x5b\x3f\xce\x2d\x00\xc3\x0d\x2c\xcd\x7f\xa4\xa2\xcd\x75\x29\x33\xdc\xad\x41\xd0\xdf\x6c\xcd\x55\xc9\xa5\xdc\x35\x35\xfd\x6f\x5e\x52\x27\xc8\x68\xf3\xb9\xa7\x49\x42\x8f\x52\x1c\x0d\xd0\x1f\xa7\x93\x7f\x8e\xfd\xb3\xd0\x3f\x54\x46\x87\x61\x1a\x74\xc6\x5b\xce\x4e\x8e\x03\x1c\x24\xfa\xe3\xb7\x05\x9d\xb5\x82\x9e\x93\xf3\xc7\xe9\xa1\x98\x30\xc0\x35\x8f\xed\x81\xf2\x83\x3b\x40\xf5\xa7\xcd\x5c\xac\x45\x1c\xaf\x16\xfc\xf6\x12\x6f\x68\xbf\xc8\xdd\x6f\xc4\x2d\x82\x87\x38\x2a\xb0\x3e\x13\x9a\xee\xa4\x95\x1f\x4f\xfd\x1b\x3b\xcd\xb9\x5a\xc1\x60\x42\x90\xa5\x1a\x1e\xcf\x71\x53\xbf\xca\xa3\x34\xbc\x20\x7a\xcd\xb8\xee\x54\xb3\xfa\x3d\x3d\xfb\x7f\x00\x00\x00\xff\xff\x43\x3e\x7c\x82\x7a\x10\x00\x00")
+var _dashboardJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xcd\x5a\xdd\x73\xdb\x36\x12\x7f\xcf\x5f\x81\xf2\xa1\xa6\x26\x0a\xe5\xdc\xe5\x6e\x3a\x51\x75\x99\xc4\x4d\x2e\xb9\x4b\xd3\x8c\x9d\xb4\x9d\x71\x55\x0f\x4c\x42\x22\x2f\x14\xa0\x10\xa0\x54\x5d\xea\xff\xfd\x76\x17\x20\x09\x7e\xc9\xf6\x43\x66\x2e\x0f\xb1\x48\x2e\x76\x81\xc5\x7e\xfc\x76\x81\x07\xb3\x19\xfb\x49\xe6\x07\xa6\x53\xb5\x67\x26\x15\x4c\x8b\x62\x27\x0a\x0d\x7f\x73\x11\x1b\x91\xb0\xeb\x03\x5b\x17\xaa\xdc\x32\x2e\x13\x96\xf3\x6b\x91\xb3\x2d\x2f\xf8\x46\x18\x20\x9b\x32\x11\xad\xa3\x07\xc0\x25\xe1\x3a\xbd\x56\xbc\x48\xa2\xd4\x6c\xf2\x67\x34\x64\xb1\xcd\xb9\x34\x8f\xf8\xb7\x34\x6c\xa1\x33\x23\x16\x52\x15\x26\x7d\xb0\xe3\x85\x93\xf4\x2a\xcb\x81\x11\x5b\xb0\x70\x55\xca\xd8\x64\x4a\x86\x13\xf6\xe5\x01\x83\x7f\x48\x44\xa2\x34\x7c\x96\x62\xcf\x3e\x9e\xbf\xbd\x10\xbc\x88\xd3\xf7\xf4\x36\xdc\x67\x32\x51\xfb\x28\x57\x31\xc7\x81\x91\xa6\x8f\x93\x79\x3d\x7a\x55\x31\x1f\x1a\xed\xe8\xac\x84\x68\x2d\xcc\xf3\x3c\x0f\x03\x9a\x78\x30\x89\x56\xaa\x78\xc9\xe3\xb4\x99\xd5\x0e\xa6\xe5\x18\x46\x7c\xbb\x15\x32\xa9\x88\xa7\x6c\x37\x99\xb3\x9b\x61\x7e\xb4\xf4\xbb\xf2\xb3\xc4\x6d\x7e\xb8\x8e\xcf\xb0\x04\x47\x6a\xd4\x85\x29\x32\xb9\xae\xa6\x5f\x08\x53\x16\x12\x28\x9e\xb1\xe0\x59\xc0\x1e\xc2\xaf\xa7\x2c\x08\xe6\x0f\x6e\x26\x48\x42\x9a\x16\x3b\x73\xa1\xca\x22\x16\x4e\x13\x2f\x77\x42\xba\x37\x61\x30\xe3\xdb\x6c\x96\x67\xda\xcc\x74\x79\xad\xe3\x22\xbb\x16\xc8\xc6\xdf\x1d\xe4\x03\x5b\x7c\xc1\x77\x02\x4d\xe0\xa0\x4a\xc3\xae\x05\x4c\x82\xcc\x46\x4e\xd9\xaa\x50\x1b\x36\xab\x4d\x60\xf6\xbd\x04\xfb\xf8\x07\x6a\x5c\x47\xec\x97\xcc\xa4\x38\x42\xc9\xc6\xba\x78\x21\x90\x23\x0d\x67\x99\x24\xcb\x53\x45\x02\x7b\x05\xbf\x0e\xf0\xb9\xc8\x76\x22\xa2\xc9\x5b\x79\xef\x80\xe1\xa8\x91\x6c\xe0\x4b\xd7\x16\xb6\xdc\xa4\x38\x8b\x68\xc3\x0d\xa8\x7d\xf6\xfb\x6f\xcd\xfc\x7e\x9b\x85\x97\xf0\xbc\x7c\x38\x99\xb5\xb5\xb8\x01\x2d\x26\x22\x56\x89\xf8\x78\xfe\xe6\x4c\x6d\xb6\x30\x67\x69\xc2\xcd\xe5\xe3\xe5\x04\xd4\x2a\xcb\x3c\xaf\x14\xdb\x4c\x0d\x95\x4a\x1f\x9a\x57\x67\x22\xcf\xd1\x68\x2f\x97\x73\x06\xcb\xbc\x64\x31\xbc\x98\xb2\x24\xdb\xb1\x25\x03\x4b\x60\x02\x4c\x81\x5e\x32\xb5\xa2\xc5\xdb\x71\xa4\xe6\xe7\x4c\x83\x6a\xf3\x4a\x59\xb8\xf1\x39\xea\x1a\xc9\xb6\x7c\x2d\x9c\xba\x9f\xd9\xcf\x0b\xab\xeb\x29\xe3\xda\xa9\x13\x1c\xf6\x53\xa6\xf4\x27\x6d\xbd\x8c\x78\x5d\x58\x56\xf7\x72\x23\x34\xe1\x30\xb0\x52\x02\xb7\xe2\x9d\x8c\x2f\x84\xd6\x40\x85\xcb\xfb\x72\xd3\xbc\x35\xdc\x94\xee\x1d\x2e\xf9\xb5\xe0\xb9\x49\xd9\xb6\x50\xd7\xb0\x10\xf8\x28\x70\xa5\xb4\x6c\xcb\xd1\x5a\x66\xaa\xb4\x99\xb2\x2d\xc4\x04\x30\xb1\x6a\x6f\x59\xa6\x3f\x6e\x43\x4b\x56\x6d\xb3\xdb\xa0\x5a\xd2\xa5\xfd\xbc\x64\x8b\xc5\x82\x95\x32\x11\xab\x4c\x82\x71\xfe\xf9\xe7\x20\x09\x0b\xc0\x4d\x61\xe7\x3c\x19\xe5\x36\x81\x49\x21\xa5\x08\x8b\xd5\xf5\xd4\x4e\x72\xca\x54\x9e\xb8\x5f\x1b\xbd\xae\xa4\xc7\xb0\x5e\x95\x0b\x50\xd1\x3a\xa4\xaf\xce\x6c\xd0\x29\x9c\x45\xef\xd3\x0c\xd6\x06\x76\xcd\x12\xdc\x04\xfc\x51\x80\x25\x49\x69\xa3\xe8\x3e\x15\x64\xe5\x59\x41\x82\x40\x55\x9a\x1f\x74\x65\xed\x02\xa6\x43\x0c\xb3\x15\xb3\x02\x70\xd2\x27\x49\xa6\x6b\x0e\x27\xec\xdb\x6f\xad\x62\x60\xb6\xd1\x15\xfc\x77\x85\x26\x1e\x15\x02\x82\x2c\xf8\xf1\xec\x77\x58\xf8\x6f\xb3\xd9\x14\x9c\x7f\x32\xa9\x26\x4e\xaa\x03\x7a\xc7\xa6\x19\x6b\x15\xdf\xb0\x82\x1d\xf0\x1f\xb9\xd6\x7b\xf0\xc7\xd6\x2b\x93\x4e\x88\xe7\x4d\x4b\x8f\x60\x50\x3f\xbf\x3b\x3b\xcb\x33\x74\x14\x24\x22\x33\x9f\xb2\x98\xcb\x1d\xd7\x43\x0a\x0c\xce\x0a\x01\x86\x06\x16\x0d\x03\x59\x4c\x23\x21\x64\x41\xcc\x21\x19\x56\xb3\xa6\x38\xb4\x97\xe0\x6c\xf7\xfc\xd5\x8b\xf0\xcb\x89\xe1\x05\x18\xe7\xc9\x53\xf7\xd9\xca\x9a\xd6\xe4\xf8\xef\x44\xc8\xb8\x38\x6c\x6b\xa2\x5f\xc4\xf5\x47\x93\xe5\x68\xd4\x67\x4a\xae\xb2\xf5\xcf\xbc\x08\x6b\xa2\xf6\x58\xfc\xd7\xf3\x0a\x30\x65\xa3\x62\x95\x93\xc5\x05\xa9\x31\x5b\xfd\x14\x34\xdd\x91\x0a\xdb\x01\x8b\x13\xc5\x9b\x1f\x48\xf0\xb0\x54\x8f\x68\xca\x4e\x4e\xba\x3c\x4c\x51\x8a\x2b\x90\xa4\x8a\x23\x3c\x3c\xa2\x29\xc3\x87\x2e\x17\x9c\x78\x7e\x15\x97\x85\x26\x3e\xc3\x5c\xdc\xe7\x61\x0e\x3a\x05\xcb\x4c\x6a\x2d\x0f\x73\x70\x44\xc3\x1c\x76\x99\xd8\x5f\x29\xc0\x17\x96\xc9\x30\x87\x86\x68\x98\x89\x92\x1f\x1b\x5f\x45\x46\x9e\xeb\xf6\x48\x5f\xbd\x38\x17\x3a\xfb\x2f\xd1\x31\x0d\x1a\x10\x1f\x14\x46\xe3\x1e\xe1\xaf\xbb\xed\x1b\x99\x39\xf3\xc0\xf8\xdd\xa3\x78\xef\x9c\xe0\x5c\x7c\x2e\x33\xa7\x88\x41\xc2\x57\x2f\x3e\x62\xae\xc8\x05\x4d\x0f\x49\xaa\xdc\x7d\x03\xa6\x09\xd9\x87\x85\xe2\x8f\xd8\x77\x49\xdf\x1d\x4e\x3e\x4a\x7e\x0d\xd1\xde\x28\x16\xa3\x63\x08\xb4\x71\xe7\x16\xec\xd1\x23\x76\x02\x8e\x81\xc3\xe7\x8d\x37\x50\x2c\xa4\x30\x9b\x28\x79\x62\x90\x1d\xf8\x53\x29\xd0\x6b\x28\x53\x28\xe6\x1c\xde\x39\xec\x83\x6e\x1c\x68\xe2\x2e\xc6\x8b\x69\xe5\x79\x36\xa3\x36\x31\xfe\x12\xdf\x2f\x31\x91\x59\xa7\x46\x3f\x5c\x52\x1c\x05\xd9\xaf\xc0\x41\x30\xae\x41\xf4\xe2\x55\xae\x5a\x2b\xa1\x9f\xb2\xcc\xe8\x2a\x37\xda\xc4\xa7\x28\xaf\x33\xc0\x38\x55\xbe\xc3\x44\xd6\x04\x11\x9c\x0e\x58\x84\xe1\x10\xc4\x8b\x10\xd2\x58\x61\x30\xab\x4d\x59\x3b\x09\x60\x74\x0c\x3d\x3c\x00\xd1\xde\x4f\x6f\x13\x0c\x91\xdf\xd8\xef\xbe\xb6\x61\xae\xef\xb3\xf8\x13\x44\x61\x40\xb1\x4a\x02\x0c\x6a\x32\x2e\x4b\x21\x6f\xe6\x8a\x27\x22\xe9\xe8\xd7\x25\x75\xab\xc0\x4a\xfa\x00\x77\x47\x9d\xa8\xb8\xdc\xc0\x96\x45\xd7\x2a\x39\xf8\xc3\x30\xdd\x87\x98\xeb\x32\xd0\xe3\xe9\x1c\xfe\x7c\xef\xa3\x84\x28\x17\x72\x6d\x52\x78\xff\xf0\xa1\xcf\x16\x47\x48\x0b\x7a\x3c\xea\xcb\x6c\x79\x79\xba\x8c\xac\x5a\x1a\x8b\xc0\xa9\x59\xe2\x05\xab\xb5\x87\xea\xa9\x5f\xd2\x80\x28\x4b\x06\x5e\xea\xbc\x5c\xfb\x92\xbd\x45\x75\x24\x3f\x5e\x36\x22\x6f\xbc\x25\xb6\x14\xd6\x4f\x0e\xaf\x61\x77\xc7\x37\x15\xf1\x93\x6f\x72\x01\x3c\xcc\x08\x81\x56\x23\x96\xb5\xfa\x0d\xfb\xc6\x4f\xf7\x63\x2e\x15\x3c\xcf\xc1\x93\x92\x03\x6c\xee\xce\x1a\x27\xf1\x46\xbf\x68\x31\xee\xf9\x94\xb7\xa6\xb8\x32\x47\x98\xde\x6d\xe6\x39\x6f\xec\xa3\x1e\x36\x3a\xb7\x77\xca\x54\x88\xd7\xaa\xf7\xe9\xdd\x26\x45\x7f\x10\x41\x2e\x1a\x5b\xb3\x01\xe3\x65\x2e\xf0\x29\x0c\xe0\x6b\xe0\x86\xc3\xcf\x28\xce\x21\x82\x39\xe0\x8c\x5a\x7d\x54\x4f\x2e\x68\x88\xc0\x24\x3c\x9b\x71\x21\x00\x09\x0b\x95\xff\x70\x57\x69\x0d\xfd\xb0\x50\xf8\xa6\x83\x66\x5d\xb6\x8a\x1c\xe7\xac\xb7\x5c\x06\x93\x0e\x7d\x9f\x33\x4e\xb8\xcb\x35\x32\xe2\x0f\xda\x2b\x8c\x9e\xb5\x89\x57\xee\x10\x78\xe4\xde\x9c\x6d\xf9\x75\x96\x66\x79\x12\x12\x17\x5f\x76\x26\x3f\x1d\x99\x2a\x0f\x3a\xb4\xe0\x9b\xe6\xb9\x81\x12\xed\xba\x04\x64\x19\xa4\x85\x58\x41\x7c\x0d\x66\x88\xea\xb2\x78\x06\xf3\xbe\xe2\xa5\x51\xb6\x4a\xc6\xf0\xba\xe8\xd9\x7b\x97\x63\x06\x31\xbb\x78\xfd\xe1\xc7\xb7\xb8\xf0\x57\xe0\x64\x50\xa5\x09\x21\x83\x9e\xee\x5b\xeb\x80\x91\x55\x44\xb7\xf8\xe8\xc8\x2a\x2c\x41\xbd\x9b\xf4\xd4\xd7\xb7\x45\x44\xc1\xbc\x36\xc5\x96\xc0\x66\x1e\x9e\x09\xb6\x08\x2c\x22\xf4\x4c\x8c\x6c\xb1\x45\x93\xd0\xe8\x06\x0a\xbb\x08\x45\x88\xd9\xf7\x28\x50\xb2\x05\xf9\x7d\x6f\xac\xa8\xe7\xbe\xdf\x40\x0a\x78\x23\xe1\x03\xe4\x49\xcc\xb0\x9c\xb0\x64\x03\x3f\x89\xa6\x85\x64\xfb\x51\xa8\x8d\x6b\x61\x92\xfd\x6a\xe2\xd6\x58\xd7\x71\x5f\xc0\x41\x6e\x0b\x5e\x1c\xde\x24\x61\xd7\x00\x28\xa0\xa0\x46\xbc\x85\x1f\x8d\xa8\x47\xc2\x19\x6e\xc6\xe7\x52\x14\x87\x0b\xea\xeb\xa8\x22\x0c\xa2\xda\x87\x26\xb7\x7b\x8d\xbf\xd8\x42\x6c\xd4\xae\xbf\xd8\x41\xac\x7f\x8e\xb4\x43\x58\xbf\xbb\xd8\xfb\xa5\x81\xc5\x9d\xd2\xc0\x8f\x99\xd6\xd4\xa5\xb0\x4c\x61\x07\x15\x3e\x4a\x65\x52\xfc\x0b\x99\xf9\xe9\xdd\x13\x82\xdd\x3b\x03\xe9\x77\xfe\xa0\xa9\x49\x0c\x25\xc5\x1a\x5f\x35\x15\x5b\xe8\x79\xc1\x16\x20\xb2\x34\xef\x54\x22\x22\xab\x3b\xdf\xd8\x89\x48\x20\x78\xbc\x6d\xfd\x15\xf0\xba\x40\x58\x8b\x69\x8d\xc2\x00\x66\xb5\x55\x66\xfa\xa0\x8b\x6b\x1f\xe7\x70\x6c\x08\xd4\x5b\xe8\x21\x63\xac\x09\x7d\x80\xe5\x20\x0e\x6e\xbc\xfd\x15\x11\x31\x55\xd1\x52\x49\xb0\x96\x1e\xf8\xf1\xe1\x0e\xe2\x96\xee\x5e\xb6\x8a\xc8\xe5\x7c\x6c\x1f\x51\xe4\x37\x48\x0b\x7e\x71\x05\x9a\x84\xc2\xf6\x10\x52\x1b\xe2\x6a\x9f\x25\x26\x0d\x27\xb7\x8b\xa6\x9e\x8a\xdd\x26\x4f\xed\x4d\x27\xad\xca\x46\x15\xcd\x90\x4f\xd4\x19\xcb\xeb\xc0\x39\x1d\xb0\xfe\xf4\x30\x9a\xd3\xd7\x10\x65\x47\xd6\xc6\x7f\xc1\xf9\x36\x85\x82\xf7\xe5\xb5\xc8\xd6\x29\x00\xfb\x7a\x26\x91\x5a\xad\x20\x9c\xd9\xf7\xd3\xbe\xce\x75\x5a\x40\x28\xaf\xe6\x52\xc9\xdf\xa8\x52\x0b\x90\x0e\x23\xaf\xac\x74\xfa\x7f\x52\xd9\xc8\x8b\x12\x2c\x8c\xf6\x7f\x5d\x64\x84\xba\x79\xd5\x63\x6a\x8c\x20\x55\xfb\xb7\xf4\x2e\xcc\x2b\xcd\xd6\x2d\x2d\x07\x7d\xeb\x58\x65\x32\x93\x37\x60\xd4\xc6\x86\x87\xe0\x3d\x8f\xc8\xb3\x7f\xa8\x5a\x6b\x55\x76\x40\x9d\x91\xe4\x3b\xe1\x07\xa4\x6c\xe7\x1b\x2b\xe6\x11\x7e\x08\x3c\x1a\x6d\x0e\xe0\xdc\xf8\xf3\x83\x80\x8a\x0b\xd8\x9d\xa9\xbc\xdc\x50\x37\x2a\xb0\x65\x75\x88\x4e\xe3\x66\x19\xbb\x8f\x30\xd1\x29\x7b\xbc\x2a\x26\xc7\x79\x9d\xab\xfd\x08\xa3\x02\xbf\x74\xb9\x54\x42\x08\xc6\xf7\xfa\xbb\xf8\xba\x0b\xe8\xef\x0c\xdf\x06\x21\x9c\xd3\x09\x32\x0e\xda\x64\xcd\x52\xac\x3a\xb0\x53\x6a\x6d\xce\x3e\x3e\x64\x8f\x27\xb4\x5b\x33\x86\xd8\x8a\x82\x1e\x7d\x27\xbf\x1a\xe3\x05\xea\xa8\x19\x81\x02\xc6\xb9\xa4\x64\xbb\x0d\x1b\x52\x6f\x3f\xad\x37\x40\xad\xa9\x7e\xb6\xa5\x4e\xc3\x56\x7f\xb4\x4a\xdc\x93\x8e\x01\x62\x5d\xd5\x62\x8a\x52\x2a\xb0\xe0\x9a\x35\x3c\x49\xa8\xb7\xfd\x36\xd3\x90\xca\x00\xab\xc3\x56\x62\x37\x00\xf6\xad\xd7\x38\x6e\xd5\x66\x18\x98\x10\x95\x7b\x51\xab\x5b\x12\xf9\x21\xb3\x57\x22\x63\x83\xb8\x57\x1b\x4d\xda\x69\x13\xab\x4c\xe7\x6e\x50\xb6\x0b\xbf\x81\x5d\x88\xcf\xae\xbf\xf5\xeb\x8f\x6f\x5f\x1b\xb3\xc5\x9e\x83\xd0\xa6\x69\xf2\x7f\x1e\x58\x1b\x32\x1c\x5b\x19\x86\x57\x1c\xe5\x9a\x8d\xdf\x2c\xd8\x5f\x4e\x4f\xbb\x4b\x6a\x25\xcb\x57\x3c\xcb\xb1\xbd\x0f\x4c\x31\x39\xfa\xb5\x09\x32\x02\x45\x6e\x81\x5c\x7c\x00\xa4\xe0\x2d\xb5\x93\x2a\x9b\xd5\x93\xca\x9a\x00\xf3\xaf\x8b\x9f\xde\x61\x40\x86\xa0\xd5\xe3\xe6\xdb\x3c\x28\x26\xec\x58\x00\xd2\x2b\xd8\xf6\x30\xf8\xe7\xcb\x0f\x84\xa5\xe9\xc4\x82\xf8\x6a\x4a\x91\x42\xf6\x5a\xf6\x4d\x97\x60\xe2\xba\x4a\x0d\x33\x8d\x67\x2d\x75\xa4\xbc\xc0\x93\xaf\x7d\xaa\x58\x86\x8d\x80\xf5\x1a\x74\x90\x01\x54\xa0\x86\x2d\xc4\x76\xf8\x03\xb8\x9d\xbb\xbe\x34\x88\xc2\x66\x4d\xd2\xde\xd5\x37\x09\x12\x99\x43\xf8\x7f\xb0\xa7\xa3\x9b\x41\x2d\x08\x8c\xc7\xc7\xb6\x62\xde\x22\x27\x2f\x1f\xc7\xab\x41\xe6\xd6\xed\x47\x2d\x1c\xd3\xf6\xfd\x76\xb4\x43\x31\x98\x92\x43\x08\x11\x90\xc2\x0a\x0a\x28\x81\x6f\x03\xb8\x4c\xf8\xb8\x11\x26\x55\x89\x4d\x81\xd6\xd7\x02\x04\x08\xed\x2f\x2a\x4b\xe2\xa0\xab\x02\x3a\x87\xb9\x47\xd9\x76\x5b\xe9\x06\x56\x01\xb6\x34\x38\xa2\x8d\x9b\x83\xb7\x6a\xcd\x90\xb4\x4d\xd9\x53\x89\xab\xcd\xfa\xe1\x62\xdc\xdc\xc1\x40\xf9\x26\x0b\x6e\xb1\xe5\xb7\xb8\x6a\xc0\x83\x02\xe0\xfb\x81\x69\xef\xbc\x6e\x28\x0c\x21\xb5\xfe\xca\x36\x7b\x17\x33\x72\xae\xec\x2b\xf8\x98\x8d\xf6\x53\x6d\xfe\xb5\x2d\xa0\x39\xd9\x1c\x0b\x37\x04\x88\x26\xb7\x9b\x88\x25\xbc\x9f\x7d\xdc\xcb\xad\x3a\xce\x74\x73\x9f\x60\x7a\xd4\xbc\x1a\xd8\xd8\xd4\xde\x54\x27\x4e\x59\xbb\x3e\x1f\x38\x80\x73\x14\x14\xb9\xc4\xfc\x2e\xa5\x70\xb7\xb1\xd6\x29\x83\x11\xa8\x54\x88\xd4\xe7\x0d\xbb\x13\xba\x67\x51\x14\x90\xdd\x9f\xb1\xc0\xd5\x78\xfe\x5b\x3c\x21\xef\x44\x9c\x16\x17\x77\x62\xd8\x35\xab\x1a\x91\xa1\xed\xbb\x52\x8e\x3a\x05\x8f\xf0\xcc\xcf\xe7\x78\xc3\x44\xae\xc5\xd1\xe1\xe0\x47\x23\x63\xfd\xc6\xc5\x78\x59\x6c\x75\x3b\x1f\xeb\x8d\x62\x2f\x7c\x68\x4d\xf8\x1e\x8b\x56\x5b\x90\x8d\x9c\x35\x4e\x7a\x67\x6f\xa6\xd5\xfc\xfd\x2a\x47\x8a\xf5\x61\x2a\x86\x2f\x09\xd5\x6c\xcc\x0b\x88\x62\xcd\xa5\x14\xac\x68\xa1\xd0\xdd\x73\x5d\x1f\xa7\x5a\x52\x96\x92\x67\xf8\x69\x19\xb4\x2b\x6c\x9c\x0a\x6b\xa4\x25\x76\xed\xec\x27\xa2\x84\x1b\xee\x34\xef\x35\x56\x04\x80\xe0\xa6\xb7\x82\x4f\x95\x35\xf6\x5b\x3e\xf7\x92\xd1\xee\x12\xdd\x4d\x8c\xb5\xb2\xae\x98\x76\x0b\xa6\x2f\x2f\xf2\x5b\x1a\x2d\xe7\x35\x80\x62\x3b\xcc\x8e\xbb\xe2\x2d\xcc\x8f\x38\xe8\x80\xad\x93\xf8\xa0\xee\xcd\xb5\x17\x1a\xc3\x92\x8a\xde\x52\xbf\xfe\xec\x7c\x47\x3e\x32\x41\xeb\x4d\xf7\xd9\xef\x26\x52\x8e\xec\x75\xd3\xab\xb4\xf6\xff\xd2\x1a\xfe\x1e\xcf\xd4\x36\x99\xd6\x74\x71\x00\x60\x39\xf3\xbd\x13\xe2\xad\xc2\xc9\x14\x86\xf1\x35\x87\xa2\x85\xae\x83\xa0\x33\xac\xca\x3c\x67\x78\x95\xc7\xd7\x2a\x4c\xa2\x33\x67\xcc\xd7\xe7\xa5\x94\x00\xf3\x7f\x96\xb1\xee\xc6\xf7\xa2\x94\xcf\xb7\xdb\x3a\x8b\xd3\x89\x2d\x0e\xb9\xc2\xcb\x41\x5b\xa3\xc3\xcb\x60\x2f\x20\x41\xc2\xeb\xff\x60\xca\x08\xae\xb9\x16\x7f\x7f\xe2\x1e\xe0\x93\x56\xf1\x27\xf7\x94\x08\x4d\xbf\x6a\xcd\x07\x9f\xc4\x41\x1f\x36\x10\xa4\x1c\x05\x3c\xdb\x6b\x63\xf6\x31\x93\x5b\x28\xa9\xdd\x60\xdb\x63\xe9\x30\xc8\xe4\x0a\x6a\x75\x55\x38\x22\x8c\x26\x35\x27\xe0\x8c\x0f\xcb\x81\xca\xab\xb5\x60\xb7\x34\x77\x30\x48\x77\xdb\x0e\xf5\xdd\xa4\x3d\xea\x1e\xea\xd9\x93\x5d\xb5\x09\x53\xba\xfb\x96\x14\xca\x12\xda\xab\x1e\x7b\x0c\x3c\x7c\xcf\x0f\x5f\x11\x3d\xb5\x6f\x41\xb8\xe4\x8a\x43\x6f\x81\xf1\x5e\x1e\x1a\x3e\x80\x1e\xab\xfe\x90\x1f\x56\x7f\x15\x37\x86\xe8\x05\xaf\x70\x81\x61\x41\x65\x01\xd3\x01\x7b\x7b\xac\xa3\x60\xd2\x46\x27\xc2\x7c\xc8\x36\x82\x3a\x49\x6d\x5d\x4f\xd9\xe3\x53\x28\x52\xee\x56\xa2\xdc\xab\x1c\xaf\x8f\x39\x17\x6c\xfc\x22\x4c\x1b\x44\x51\x7b\xd3\x69\x30\x4a\xb9\xfe\x69\x2f\xdf\xc3\xa6\x8a\x02\x4a\xb7\x26\x6a\x74\x25\xf5\xe3\xed\x50\xbf\xb8\xbd\x92\xa1\x35\x39\x49\x6e\x5d\x38\x87\xae\x24\x9c\xdf\xc8\xf4\xaa\xd1\x83\xb3\xf3\x72\x57\x45\x37\xad\x64\x5c\x56\x6f\x96\xb7\x4c\xf6\x16\x84\x08\xac\xba\xd7\x02\x8f\xa1\x45\xd7\x90\x81\x8d\xb3\x41\x03\xcd\x02\x2f\x31\x56\x2e\x59\x9b\x79\x75\x27\x24\x93\x99\xb9\xc2\xfa\x1b\xef\x36\x0e\x5f\x14\x71\x5f\xf1\xce\xcc\x9e\x17\xf2\x64\x52\x75\x7f\xb0\xdf\x79\x60\x10\x53\x78\x99\x03\xe6\x28\xb5\x3d\xe1\x47\x40\x42\x9e\x8b\x50\x04\x5b\xa0\x0e\x46\x98\x94\x1b\xfb\x1b\x3b\xa4\x50\xd4\xaf\xc0\xf6\x89\x11\x8d\x58\x8c\xdc\x53\xc1\x8f\x20\xbb\x7b\x2f\x08\x5f\x7b\xb6\x40\xb2\xc6\x58\xe0\xc7\x01\x16\xf8\xda\x5b\x0b\x98\x81\xe5\xb2\x60\xdf\x9d\xb2\x10\x0c\xe8\xc9\x93\xbf\x4e\x70\x49\x92\xf0\x0f\xdd\xf9\xb8\x16\x60\x51\x10\xdc\xa5\x5d\x22\x98\x64\x99\x27\xf0\xb6\x62\x02\x2e\xc9\x36\x5c\x96\x3c\xcf\x0f\x4d\x6f\x9f\x24\x75\x5a\x0a\x63\x17\x9d\x22\xbc\x0f\x6a\xef\x9a\x9e\x4e\xff\x36\x21\x94\x48\xd7\x9e\x4e\xba\x36\xe8\xd6\x0c\xb3\x9c\x0f\x78\x00\x21\xe1\x3b\x0b\x7a\xd2\x08\x1a\x93\xf3\xdd\xe9\x08\x58\xae\xef\xb9\x0e\x84\x59\x02\x84\x60\xce\x0d\x30\x84\x98\xcb\x61\x6a\x6e\xdf\x8e\x8d\x75\x50\x0f\x46\xfb\xa0\xef\xee\xe3\x1d\x86\x83\xf1\x3e\x9a\xbb\xfb\x78\x0b\x4a\xa6\x1e\x78\xbb\x8f\x6c\x07\xab\x48\xba\x0f\xb1\xee\x25\x1f\x50\x4a\x30\xf5\x21\xd0\x7d\x66\x00\xb6\x48\xd2\x2b\x28\xd2\x8c\xad\xac\xf5\xdf\x74\x61\xb5\xb9\x85\x8e\xd7\x83\xc0\x4d\xec\xc5\x61\x3c\xd9\xaa\x4e\x4b\x6a\x53\x1e\x76\x30\xba\xf8\x7a\x52\xf1\x6f\x41\xbe\x56\x1f\xb9\x03\x4d\x69\x58\x8d\xfa\xba\xb5\x5b\xaf\x63\x32\x6f\x7d\x6a\xba\x7f\xad\x23\x67\x9c\xa4\xd7\x85\x1c\xe4\xd7\xcd\x96\xed\x19\x50\x59\xda\xba\xdf\xf4\x65\xa8\xb5\xfa\x25\xa0\x43\xdc\xa7\xad\xbb\x50\x10\xb7\xdd\xe1\x07\x7c\x78\x8c\x38\x49\xed\xab\x9f\xd4\xc9\xc6\xb2\x78\x95\x99\xa0\x7d\x9b\x2d\xa0\xc3\x0c\xf8\x76\xf9\xa5\xba\xf9\x3b\xc2\x17\xde\x9f\x5a\xb6\xee\x17\x1d\x25\x38\x09\xf6\x40\x00\x1f\x6e\x96\x37\x1d\x75\x75\x90\xe7\xa8\xc6\x87\xe8\x30\xb3\xd4\x1b\xa9\x24\x5d\xf2\xa1\x12\x36\x4e\xb9\x5c\x8b\xc1\x04\x43\xf0\xbf\x1a\x43\x23\x2e\xea\x7a\x38\x76\xd7\xf6\xda\x67\x9a\x0e\xff\xd6\x32\xe7\xff\x03\x44\x54\x09\xb3\x26\x31\x00\x00")
 
 func dashboardJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -147,7 +156,7 @@ func dashboardJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "dashboard.js", size: 4218, mode: os.FileMode(420), modTime: time.Unix(1464936101, 0)}
+	info := bindataFileInfo{name: "dashboard.js", size: 12582, mode: os.FileMode(436), modTime: time.Unix(1792237957, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -572,6 +581,186 @@ func includeWebutilJs() (*asset, error) {
 	return a, nil
 }
 
+var _kioskHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x91\x31\x6f\xc4\x20\x0c\x85\xf7\xfc\x0a\x97\x9d\x63\xaf\x48\x96\x76\x6a\xa5\x5b\x2a\x75\x27\xe0\x3b\x68\x38\x88\x30\x97\x2a\xff\xbe\x24\xe1\x9a\x6b\xd5\xa1\x6c\xd8\x7e\x4f\xdf\xb3\xa5\xcd\x17\xdf\x35\xd2\xa2\x32\x5d\xd3\xc8\xec\xb2\xc7\xee\xfd\xf8\x04\xcf\x8a\x6c\x1f\x55\x32\xc0\xe1\xd5\x45\x1a\xa4\xd8\x9a\x0d\x94\x27\x1f\x38\x87\x37\x4c\x13\x1a\xb8\x06\x83\x09\xc4\xb0\x0c\x09\xe0\xbc\x4e\xf4\x8a\x10\x6c\xc2\x53\xcb\x04\x65\x95\x9d\x16\xec\x5e\x9c\x67\x8f\x64\x11\x33\xed\x1a\xef\xc2\x00\x09\x7d\xcb\xe8\xbb\xcd\xaa\x89\x0b\xda\x5f\x0d\x8a\xc5\xf7\xa0\x89\x18\xac\x3c\x2d\x1b\xbd\x72\x81\xfd\xc3\xc1\xdc\x22\xfd\x29\xdf\xd1\x5e\xd4\xa4\x48\x27\x37\xe6\x9d\xac\xfe\x29\xe9\x96\xad\x49\x0f\x1f\xc4\x3a\x29\xb6\x7a\xd9\xa0\xb8\xad\xb0\x8f\x66\xae\x22\xe3\x26\x70\xa6\x0a\x38\x5e\xc6\x3c\x33\xd0\x5e\x11\xfd\xac\x75\xc7\x98\xad\x0b\x67\xc8\x11\xc8\xc6\x4f\x29\x8a\xb2\x7a\xb8\x53\x52\x17\xbc\xb3\x19\xd5\x19\x7f\xb9\x6c\xa5\x35\x70\x49\xe9\xa8\x44\x9a\x1f\x21\xc4\x80\x0b\xe2\xe6\xb0\x20\x6e\x68\x05\x75\x3d\xfa\x17\x1f\x61\xb7\x96\xfc\x01\x00\x00")
+
+func kioskHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_kioskHtml,
+		"kiosk.html",
+	)
+}
+
+func kioskHtml() (*asset, error) {
+	bytes, err := kioskHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "kiosk.html", size: 508, mode: os.FileMode(420), modTime: time.Unix(1792237352, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _kioskJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x85\x54\x5b\x6f\xd3\x30\x14\x7e\xef\xaf\x38\xf2\x03\x4a\x45\x97\x88\xe7\xaa\x42\x30\xf6\x30\x40\x03\x31\xc1\xd3\x84\xe4\x3a\x27\x8d\x55\xc7\x8e\x6c\x27\x51\xc4\xf6\xdf\x39\x76\x2e\x4d\x57\x36\xf2\x90\xd4\xf6\xf1\x77\x39\x97\x66\x19\x7c\x91\xc6\x1d\x1d\x14\x46\x29\xd3\x81\x2b\xe9\x25\x4c\x55\x71\x9d\xd3\xa6\x35\x15\xf8\x12\xc1\xa1\x6d\xd1\x6e\xa0\x2b\xa5\x28\xe1\x88\x58\xbb\xb8\x6f\x8d\xe7\x5e\x1a\xbd\x01\x67\x00\x29\xa4\x5f\x65\x19\x38\x61\x11\x75\xc4\x92\xfa\x00\x1c\x8e\x81\x03\x9c\xe7\xbd\x03\x49\x07\x1e\x6b\x20\x02\x10\x5c\xc3\x9e\xd0\x3b\xe9\x45\x89\x39\x58\xac\x8c\x47\xd5\xa7\xab\x96\xdb\xe1\xd6\x1d\xaf\x10\x76\x90\xa3\x30\x39\xfe\xfc\x71\x7b\x6d\xaa\xda\x68\xd4\x3e\x21\xec\xdc\x74\xa9\x32\x22\x4a\x48\x6b\xee\x4b\x4d\xd1\xa9\xc5\x5a\x71\x81\x49\xf6\xfb\x21\x8b\x18\x0f\x59\xb6\x01\xc6\xd6\xeb\x6d\xc4\xc5\xd6\xdf\x9b\xc6\x8a\x80\xab\xb1\x83\x9b\x96\xe0\x86\x9d\x84\x65\xbc\x96\xc3\x2d\x97\x31\x78\x0b\xa8\x2f\x88\x67\x5d\x6b\x3a\x67\x99\x6b\xf6\x64\x58\xee\x91\x8d\xf8\xa2\xb1\x36\x20\x5a\x11\x08\x1a\xa5\xb6\xab\x90\x95\xef\xfc\x80\x60\x8a\x98\xb7\x9c\xbb\x72\x6f\xb8\xcd\x17\x49\x6a\x25\x76\xab\xa2\xd1\x22\xb8\x89\x2b\x42\x48\xc2\x77\x0d\x7f\x56\x40\x8f\x2c\x20\xae\x53\xc5\x7b\xd3\xf8\x69\x3b\x3c\x16\x7d\x63\x35\xa9\x99\x91\x5f\x52\x7f\x06\x40\xfa\xdf\x47\x3b\xbb\x77\x6c\x1b\xc1\x9e\xce\x99\x86\xc2\xff\x93\xc9\x85\xd2\x8b\x13\x61\x5a\xfa\x4a\x4d\x68\x6f\x86\x8b\xbb\xd7\x44\x8c\xd8\x4b\xde\x11\x7c\x48\xda\xd3\xea\x94\x8e\x90\xa6\x58\xa7\x04\x27\x31\x01\x83\x12\xfc\xf9\xfe\xdb\x1d\xd5\xde\x3a\x4c\x30\xcd\xb9\xe7\x23\xa0\x8b\xe9\x3f\x4b\xe3\x76\xf6\x16\x0f\x77\x8b\x4a\x5d\x3a\x5c\xca\x3a\xab\x28\xdd\xa5\x82\x86\xed\xc2\x8e\xcd\x69\x44\x53\xd1\x79\x7a\x40\x7f\xa3\x30\xfc\xfc\xd8\xdf\xe6\x09\x8b\xc9\xb8\xaa\xa9\xf0\x6c\x24\xc7\xaa\xf6\xfd\xff\xaf\xc4\x30\x76\x2e\x78\x29\x31\x32\xa7\xee\xa4\xe7\xd9\x81\xef\x15\x25\x43\x3a\x1a\x84\xc0\xc6\xd8\x29\x22\x42\x5f\x46\x68\x2a\xcc\x22\x6a\xd6\xe7\xa5\x57\xc1\xe3\x50\xb2\x30\x5f\xf0\xf8\x08\x8b\x26\x9a\x97\x53\xab\x50\x4f\xc1\x15\xfc\xba\xbb\x86\x4f\x53\x6b\x4c\xbd\x05\xa8\x1c\x5e\xd8\x08\x53\xdf\xe2\x07\xef\x69\x88\x1a\x4f\x33\x48\x8e\x26\xef\xaf\x78\x7a\xa6\xf8\x05\x5f\x73\x5b\x53\x37\xcd\x83\x9f\xf2\x3c\x8f\xdd\xf4\x55\xd2\x7f\x91\x46\x4b\x9c\xd4\x60\x6c\x73\xea\xb3\x0d\x14\x9c\xc4\x92\x8c\xbf\x28\x26\x24\xe2\x24\x05\x00\x00")
+
+func kioskJsBytes() ([]byte, error) {
+	return bindataRead(
+		_kioskJs,
+		"kiosk.js",
+	)
+}
+
+func kioskJs() (*asset, error) {
+	bytes, err := kioskJsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "kiosk.js", size: 1316, mode: os.FileMode(420), modTime: time.Unix(1792237338, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _loginHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x95\x55\xc9\x6e\xdb\x30\x10\xbd\xe7\x2b\xa6\x3c\xd9\x28\x2c\xde\x13\xd9\x01\x9a\xf6\x10\x20\x68\x83\x04\xe9\x9d\x16\x47\x16\x1b\x89\x34\x48\xca\xae\x51\xe4\xdf\x3b\x24\xb5\xd9\x89\xdb\xc4\x17\x53\xb3\xbc\x59\xde\x0c\x99\x57\xbe\xa9\x57\x17\x79\x85\x42\xae\x2e\x2e\x72\xaf\x7c\x8d\xab\x9f\xdf\x6f\xe0\xab\x70\xd5\xda\x08\x2b\x61\x01\x77\x66\x03\x4a\xe7\x3c\x69\x2f\x80\x7e\xf9\xa7\xc5\x02\x1e\xd1\xee\x50\x82\xf0\xc0\x6b\xb3\x51\x1a\x16\x8b\x4e\xbb\x16\x0e\xa1\xb2\x58\x2e\x19\x77\x5e\x78\x55\x70\x36\x75\xf4\x87\x1a\x5d\x85\xe8\xdd\xe8\x53\x2b\xfd\x0c\x16\xeb\x25\x73\x83\x9a\x75\x20\x4a\x17\x75\x2b\x91\x07\xdc\xac\x70\x8e\x41\xcc\x65\xc9\xb6\xb5\x50\x9a\xbd\x03\x41\xf6\xf5\xbc\xe9\x9e\xf3\xbe\x03\x6b\x23\x0f\x1d\x9c\x54\x3b\x28\x6a\xe1\xdc\x92\xc5\xf2\xba\x30\x51\x57\x1a\xdb\x80\x92\x9d\x66\xb1\x25\xab\xbd\xb1\x92\x41\x83\xbe\x32\x24\xdf\x1a\x47\xb1\x45\xe1\x95\xd1\xd4\x84\x53\x80\x21\xc0\x88\x81\xd6\x1a\xcb\x8e\x22\xf6\xb2\x58\x0e\xd5\xa0\x1c\x25\x7c\xb8\x04\x6d\x34\xb2\xd5\xad\x2e\x8c\xb5\x58\x78\x68\x1d\x5a\xd0\xa2\x41\x30\x16\xfa\x5c\x72\x4e\xf8\x27\x11\x6b\xb1\xc6\x7a\xf5\x34\x98\xe7\x4a\x6f\x5b\x0f\xfe\xb0\x25\x78\x8f\xbf\x29\xe5\x20\x5f\xb2\x80\x18\x4e\x54\x42\xeb\x4d\x69\x8a\xd6\xad\x72\x9e\xdc\xdf\x82\xbc\xef\x82\x1e\x23\x8e\x6d\x49\xa8\xc3\xf7\x19\xac\xa9\x6f\xa5\xa4\x44\xdd\x7b\xea\x98\x5b\x68\x56\x3a\xed\x44\xdd\x92\x98\xb3\x7f\x20\xb8\x76\xdd\xa8\xd1\x36\x4d\xf1\x94\x44\x1e\x58\x9c\x7c\x1f\xf3\x61\x94\x2c\x4e\xe8\x48\xa2\xb7\xd9\x38\xce\x43\x9c\x00\x2d\xc2\x6c\xf6\xb3\xc8\xa9\xa7\x15\x0f\xe2\x7e\x2e\x52\x6e\xb0\x57\xbe\x02\xa7\xf4\xa6\x46\xfa\xdb\x90\x2b\x6d\x9d\x98\x66\x3c\x50\x3a\x3d\xba\xc2\xaa\xad\x1f\xcd\x38\x87\x07\xf4\xad\xd5\xe0\x0d\xf8\x0a\x69\x24\x36\x08\xfb\x4a\x15\x15\x08\xf7\x4c\x2b\x4b\x85\x47\x45\x0c\x3f\xf8\xed\x44\x98\x1e\x2b\x1a\x07\x4b\xd0\xb8\x87\xa7\x87\xbb\x47\x14\xb6\xa8\xee\xa3\x74\xb6\x57\x5a\x9a\x7d\x56\x9b\x42\x84\xb9\xce\x5c\x54\xce\xaf\x06\x04\x55\xc2\x2c\x21\x64\x1b\xf4\xb3\x44\xd6\x7c\x0e\x7f\x8e\xba\x23\x69\x9c\x1a\xd4\x3e\xd8\x7c\xab\x31\x1c\xbf\x1c\x6e\x65\x6f\x9e\x45\xc2\x28\x85\xd7\x48\x63\xa4\x97\xb3\x31\xd3\xce\xbc\x3f\xe8\x74\xd3\xe6\x59\x24\x37\xeb\xb8\xa5\x1c\x18\x9b\xc6\x9c\xb6\xf8\x47\x59\x62\xea\xe2\x5e\x1c\x1c\x98\x32\x74\x93\xa0\x12\x93\xa9\xd9\x16\xa1\x30\xba\x54\x9b\xd6\xa2\x3c\xea\x73\xa8\x87\xe0\xdf\xd5\x8a\x31\x83\xff\x14\x31\x4e\xda\x3c\x0b\xa3\x16\xf2\x3f\x1d\xb6\xeb\x80\xbc\x64\xf0\x19\x90\xee\x0f\x89\x4f\x0f\xb7\x37\xa6\xd9\xd2\x0c\x6b\x3f\x0b\xba\x49\x93\x4b\xf4\x45\x35\xeb\x6e\x2f\x9e\xae\x36\x47\xd8\x54\xb4\x9e\x95\xad\x8e\xb7\xdb\xcc\xa2\x23\x77\x87\xa7\x1d\xb7\x69\x06\x7b\x75\xf6\xcb\x91\xf1\x94\xc2\x53\xa0\x2e\xc0\x07\x99\x1b\x2e\x95\xd7\xe4\x75\x80\x59\x6f\x02\xd7\xc4\x27\x5c\x02\x8b\x2b\x7b\xf5\x91\x28\x71\xf5\xcf\x47\x08\xea\x73\xe8\x2f\x5d\xcd\x39\xef\x17\x35\xe7\xe9\x99\xa1\x67\x27\xbe\xbf\x7f\x01\x39\x7a\x08\x03\x87\x07\x00\x00")
+
+func loginHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_loginHtml,
+		"login.html",
+	)
+}
+
+func loginHtml() (*asset, error) {
+	bytes, err := loginHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "login.html", size: 1927, mode: os.FileMode(420), modTime: time.Unix(1792237853, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _playerHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x53\x4b\x6f\xe3\x20\x10\xbe\xe7\x57\x50\xee\x36\x69\xd5\x4a\xd5\x8a\x70\xd9\x3d\xf5\x50\x55\xbb\xd2\xde\xc7\x30\x8d\x69\x08\x58\x0c\x4e\xeb\x7f\xbf\xd8\x38\x0f\x6d\xdd\x2a\x96\x25\x64\xbe\xc7\x7c\x0c\x63\xd9\xa6\xbd\x53\x2b\xd9\x22\x18\xb5\x5a\xc9\x64\x93\x43\xf5\xf7\xf9\x27\xfb\x05\xd4\x36\x01\xa2\x61\x15\x7b\x71\x30\x34\xa0\x77\x52\x14\x7c\xc5\xf2\x23\x6f\xaa\x8a\xfd\x49\x83\x43\x6a\x11\x13\xb1\xaa\x9a\x01\x67\xfd\x8e\x45\x74\x1b\x4e\x27\x98\xb3\x36\xe2\xeb\x86\x5b\xaf\x5d\x6f\x50\x34\x40\x58\x6b\x22\xce\x26\xcb\x0d\xef\x1c\x58\xcf\xaf\x70\x30\xc7\x60\x8b\xf2\x73\xb4\x27\x38\x00\xe9\x68\xbb\x74\x4e\x36\x7f\x53\xd4\xe7\x24\x7d\xb2\xae\x7e\x23\xae\xa4\x28\xf0\x02\x37\xbb\x0f\x18\xff\x63\x49\x71\xec\x5a\x13\xcc\x30\xab\x8c\x3d\x30\xed\x80\x28\x8b\x60\x8b\x95\x0e\x3e\xc5\xe0\x68\x3e\xd9\xc4\x81\xf9\x24\x82\x12\x24\xab\x45\x44\x1d\xa2\xb1\x7e\x4b\xf5\x78\x1d\x5c\xfd\x3e\x6d\x48\x01\xb3\xb1\xc8\xce\x0b\x35\xa6\x60\x8b\x55\xac\xef\xfa\xc4\xd2\xd0\xe5\xee\x34\x7d\x4a\xc1\x73\x66\x4d\x91\x70\x76\x00\xd7\x67\xe0\x05\x7a\xc2\xaf\x54\x11\xfc\x16\x8b\x88\x10\x77\x9c\xed\xad\xdf\xf0\x75\x5e\xe1\x63\x5a\x67\x93\xf5\xa5\x01\x75\xe0\x4b\x9d\x40\x36\xd9\x5c\x55\xad\x7f\xac\xc7\x37\x77\x2e\x63\x8a\x89\x0b\x92\xe9\x23\x2c\x91\x2e\xfc\xd0\xa1\x4e\x25\x44\x87\x68\x2e\x6a\x4d\x78\xe8\x46\xfd\x29\x4a\xfd\x90\xad\xea\x87\x0f\x29\x0a\xf0\x2d\xfb\x96\xb3\x62\x8f\x46\xdd\x5e\x27\xb9\xe3\xea\xee\x3a\xe6\x3d\x57\xf7\xd7\x31\x1f\xb9\x7a\x5c\x60\xe6\x56\x4c\xe1\x3e\x0f\x80\x06\x9f\x67\xfb\x74\x9b\xe3\x00\x4c\x3b\xfc\x38\x16\x07\xaf\xab\x77\xeb\x4d\x78\x1f\x07\xb6\x80\xe3\xc0\x96\x41\xcd\x83\x3b\xfd\xf5\xff\x00\xb7\x30\x01\x0c\xfd\x03\x00\x00")
+
+func playerHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_playerHtml,
+		"player.html",
+	)
+}
+
+func playerHtml() (*asset, error) {
+	bytes, err := playerHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "player.html", size: 1021, mode: os.FileMode(420), modTime: time.Unix(1792235158, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _playerJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x95\x58\x6d\x6f\xdb\x36\x10\xfe\x9e\x5f\xc1\x09\xdb\x2c\x2f\xae\x9d\xee\xa5\x28\xea\x65\xc3\x96\xb5\x40\x07\xac\x0d\xda\xa5\xfb\x50\x14\x06\x2d\x9d\x6c\x2e\x94\x68\x90\x54\x12\xa3\xcd\x7f\xdf\x73\xd4\xbb\xe2\x26\xab\x3f\x04\x22\x79\x3c\xde\x3d\xf7\xf0\xee\x98\xc5\x42\x9c\x6b\xb9\x77\x62\x2d\x93\x4b\x61\x29\x31\x36\x55\xc5\xc6\x89\x5c\xa6\x24\xd6\x7b\xe1\xb7\x24\x52\xe9\xb6\x6b\x23\x6d\x3a\x13\xd7\x5b\x95\x6c\x85\xb4\x24\x54\x11\xd6\x32\x63\x73\xe9\x45\xe9\x28\x85\xf8\xd1\x62\x81\x85\x44\x97\x29\x2d\x76\xd0\xcb\x5a\xe7\xff\xba\xb9\xb8\x28\xb4\xba\x24\xd1\x9b\xc3\x6e\xe5\x84\x2b\x77\x3b\x63\xbd\x13\x3b\x59\x3a\x1c\x3c\x13\x8e\xe8\x12\x1f\x42\x16\x29\x6b\x4b\xb6\xb2\xd8\xf0\xd8\xed\x88\xd2\xf9\xd1\xd1\x95\xb4\xc2\x66\xeb\x65\xf8\xc8\xac\xcc\xe9\x65\x7a\x23\x4e\xc5\xc9\x52\x54\x3f\x6c\x7a\x45\x37\xbe\x5a\x13\xde\x88\x94\xb4\xba\x22\x1b\x36\xec\x8c\x53\x5e\x99\x62\xb4\xe1\xbc\xb6\xab\x5b\x87\x7b\xb9\x9b\x09\xe9\x84\xc9\xda\xd9\xbf\x55\x4e\x03\x35\x3c\xd1\xa8\x82\x9a\x7f\xa4\xd6\x22\xd1\x06\x8a\x3c\xaf\xb4\xda\xae\xa1\x47\x4b\x07\x9c\x76\xa9\xf4\x94\x06\x25\xc1\x23\xec\x7e\x5c\xf9\xc2\x08\x84\x71\x26\xb5\xa3\x6a\x8e\xb5\x58\x4c\x15\xa5\xd6\xcb\xa3\xa3\xac\x2c\x92\xa0\x2f\xf8\xc6\x87\xc7\x2a\xbd\x99\x8a\x8f\x47\xec\x47\x0b\x08\x36\xbc\x7b\x75\xb6\x0a\xdf\x2b\x9c\x27\xdf\x43\xea\xc3\x32\x08\x59\xf2\xa5\x2d\x70\x98\x75\xf4\xb2\xf0\x71\x10\x9a\x3b\xad\x12\x8a\x1f\xcf\xaa\xfd\x73\x55\xa4\x74\xf3\x3a\x8b\x27\x1f\x27\x33\xf1\x78\x3a\xc5\x9f\x93\xe9\xf2\xe8\x76\x6c\xc1\x99\x29\xa1\xa2\x39\x1f\x00\xfc\x0d\x46\xb4\x24\x12\x88\xaf\x27\x9b\xab\x82\x5d\x66\x32\x4d\x9e\xbf\x7e\x31\xe9\x9b\x31\x34\x73\xae\xa9\xd8\xf8\xad\x78\xc4\x90\xf4\x0f\x4b\x4b\x2b\xf9\xa3\x3d\xaa\xde\x3e\x30\xe2\x17\x71\x22\x7e\xed\x21\x33\x58\x84\xca\xa9\x78\x86\x40\x0d\xf4\x26\xa5\xb5\x54\xf8\xf3\x3a\x4c\xad\x7a\x95\x89\xb8\x0e\xc7\xa7\x4f\x4d\x10\x4e\xab\x30\x34\x32\x7d\x30\xeb\xfd\x15\xc2\xb7\x7d\x0b\xff\x92\x7e\x3b\x07\x02\x71\x4b\x85\x63\x11\xc7\x05\x5d\x8b\x3f\x00\x4a\x3c\x9d\xce\x37\xe4\x83\xb9\x6c\x63\x9f\x56\x53\xf1\x5d\xc5\x90\x59\xcf\xfd\x51\x10\x1c\x75\xb6\xe7\xae\xb1\xac\xc7\xf1\xdc\x2d\x07\x53\x35\x5f\x0f\x1b\x30\x0a\x70\xb8\xd8\x61\xa5\x53\x1d\x68\x8b\xf8\x16\xa9\x83\x9a\xe0\x5c\xa6\x8d\xb1\x10\x11\x0b\x90\xe4\x84\x69\xd2\x08\x6e\x87\x22\xcd\xbe\x85\xf8\xe1\xc9\x40\x2e\x1f\xca\xb5\x82\xdf\x54\x82\xd8\xf0\xa4\x2f\xce\x27\x77\x22\x4f\x4e\x06\xbc\xde\x02\xdf\xe8\x59\xc4\x28\xe7\xe2\x67\x58\x04\x4a\x44\x27\x11\x42\x1f\x45\x53\xcc\xe6\xdd\xba\x3b\xb4\xee\x02\x08\x60\xf2\x99\x25\xe0\x23\x24\xae\xb3\x02\x45\xea\xc4\x07\x46\x67\x20\x45\xa0\x96\x13\xa9\x02\xd7\xbd\xde\x23\x59\x38\x4f\x32\xe5\x54\x91\x59\x93\x63\xd7\x35\xad\x1d\xd2\x00\xf9\x0e\x50\x60\xce\x69\x86\x6c\xc7\xe2\x6c\xcd\x77\x1b\xb1\x78\xf3\xe2\xf7\xf8\xe3\xc4\x4b\x8b\x60\x4c\x9e\x89\xaf\xff\x88\x27\xbb\x20\xfb\x28\x91\xc5\x95\x74\x93\xe9\xac\x25\x5d\xef\x37\xb9\x52\x74\xbd\x32\x85\xde\x63\x93\xb7\x25\x1d\x96\x42\x42\x92\x7a\x05\xb2\x3b\x63\x21\x18\xf2\xcb\x61\x49\x53\x5c\x84\x04\xf5\xd6\xe3\x0f\x8b\x36\xc6\xc7\xb0\x15\xb9\x99\xa7\x67\xc2\xe8\xb4\xfe\xca\xdd\xa6\x7f\x1f\x46\x3f\xc4\xc8\x19\x4d\x73\x6d\x36\x71\xd8\x10\xc2\xe2\x36\x8c\x39\x00\xe7\x70\x60\x10\xa0\xaf\xe3\x3b\xfa\xdd\xde\xd6\xf3\x38\x7d\xbe\x62\x40\xe7\xab\xdc\xa4\xc3\x04\x47\x45\x62\x38\xdd\x8c\x25\x1d\x15\x21\x9b\xb6\x2e\xc0\xd2\xdb\xb1\x10\x92\xb5\xa3\x07\xa5\x32\x5d\xba\xed\x7d\x52\xc9\x96\x92\xcb\xe7\x57\x20\x8a\x7b\x50\x99\x2a\x94\x8f\x27\x6b\x64\x45\xbb\x47\x7a\x9d\x5c\x23\xba\x3d\x99\xb2\x0b\x40\x3c\x39\xb7\xc6\x9b\xc4\xe8\x77\x64\x1d\x14\x42\x3c\xc2\x8a\xf5\x9c\x5d\x9b\x5a\x1a\x8d\x2e\x6e\x5d\xee\x5e\x30\x38\x5f\x5c\x1e\xc2\x05\xe3\x13\xd8\x8d\x43\xb5\x00\x41\x7b\xdc\x89\x96\x4f\x6b\x02\x5f\xa8\xc2\x3f\xfd\xcd\x5a\xb9\xaf\x2b\x4a\x9b\xca\x83\xb6\xda\x41\xe4\x14\x11\xf3\x3e\x55\x15\x4d\x85\x2b\x78\x48\x1c\x2b\xc7\xc7\x7d\x62\x95\x4f\xdf\xab\x0f\xad\x49\xe8\x09\xec\x19\x68\xf0\x9b\x8f\x2b\x5b\x8f\x85\x9a\x0e\x92\x6f\x47\x17\xdc\xd0\xab\x15\x2e\xab\x93\x1b\xc2\x15\x63\x77\xc1\xeb\xf2\xe9\xed\x38\x95\x22\x84\x69\xa9\x89\x5b\x87\xf6\x82\x26\x9a\xa4\xe5\x14\x68\x4a\x1f\x87\x32\x50\x1f\x33\xac\xcb\x77\x4b\x46\xdb\x97\xfc\x72\x3a\x28\x52\x77\x8b\x47\x63\x76\x0b\x29\xc2\x27\xf7\x4d\x46\xcc\xe5\x4d\x1c\x8f\x4a\xda\x4b\x0e\xe9\xa3\xbb\xb5\x8b\x13\x65\x5d\x30\x4e\x46\x66\xba\x2a\xc5\xb3\x17\x03\x6e\xb6\xb6\xf4\x0b\xc9\x81\xe3\x7a\xb7\x73\xc0\xae\x56\xa0\x5b\x6f\xa6\x8e\x8f\xbb\xb9\x21\xb6\xb5\xc7\xb3\xca\xd3\x69\x93\x70\xff\x2c\xf3\x1d\x77\x6b\xb2\xab\x5f\xe8\x18\x2c\x31\xcd\x99\xee\x84\x53\xf7\x35\x81\xcb\x20\xa9\x7c\x95\x6d\xb9\x0d\x5d\x13\x9a\xc4\x02\x72\xfd\xe2\x48\x97\xbd\xd2\xf5\x05\xb1\xec\x65\xea\x9a\xb7\xfd\x36\x33\xcc\xa0\x1c\x68\x12\x2d\x00\x0d\x8f\x9b\x76\xe3\xdb\x6f\xc5\xa1\xa8\xfd\xcc\x15\xb9\x8f\xfb\x97\xa2\x59\xd1\x7b\x54\xf6\xab\xa5\x31\xc8\x7d\x72\x7b\xb3\xd9\x68\x3a\x67\x7a\x1e\xe8\x71\xfa\x06\x8d\x9b\xd0\x43\x04\x69\xe2\xd3\xb3\xb3\x29\x58\x93\xe9\xfc\x4a\xea\x92\x53\x4c\x14\x8e\x8b\x1e\x60\x81\x20\x1c\xf3\x19\x22\xde\x65\xf8\xf2\xae\x9d\x5c\xf5\x1e\xb0\x03\xe3\x87\xcc\x18\x77\x55\x6f\xf9\x1e\xb5\x50\xfd\x1f\xa3\x9a\x66\x3e\x34\xd6\x2f\xb4\x91\x3e\x66\x63\xc2\x74\x63\xcd\xff\x88\x54\x95\xfd\xcf\x4c\xe1\xad\xd1\x2e\x1e\xf6\x5d\x74\xf9\xbb\x64\xa6\x06\xc5\x18\x35\x75\x83\x43\x99\x9a\xa4\xcc\x61\xd8\x5c\x42\xd1\x15\x3d\xd7\xc4\x23\xf1\xd5\xe9\x69\xb3\x71\x78\xdf\xc3\x54\x0b\xd2\x1d\xaf\xfa\x74\x0b\xa0\xd6\x2b\x70\xc5\xc3\x6c\x36\x90\xd5\x9f\xf6\xdb\xc4\x43\xd0\x0c\x70\xe5\x5c\xdd\xbc\xb4\x5a\xd7\x3a\x67\x38\xdb\x41\x63\xd7\xed\x2e\x5b\x81\x66\xee\xbe\xe3\x07\x5d\xf2\x88\x0c\x06\x6f\x52\x85\x57\xd9\x69\xff\x2a\x74\x52\x4d\x94\x20\xc6\xcf\x4d\xaa\x52\x66\x20\xc1\x72\x6c\x64\x4f\xe6\x73\xb9\x14\x79\xa7\x7d\x5f\xf5\x76\x06\xb0\xc3\x53\xaa\x81\x77\xd9\xd0\x0b\x92\x64\xb1\x1e\x0f\xe3\x3f\x13\xdf\xff\xd4\xe4\xf2\xa0\xb6\x7e\x85\x5d\xa3\x28\x9b\x6b\x98\xe2\x12\xab\x76\xde\x81\x6e\xe9\x41\x7b\xfe\xa1\xf5\x85\x57\x3a\xf4\x1c\x2b\xb4\x61\xfc\x92\x8e\x9b\x49\xf4\x9a\x38\x28\x53\x9b\x77\xd2\xc6\x93\x7a\x35\x74\x24\xd2\x02\x69\x1c\xd5\x72\x4f\xb1\xfa\xc3\xfb\x54\x8a\x2d\xe1\x61\xd4\x91\xf1\x2b\x35\x48\x2a\xf7\x12\x28\x7a\x65\x7a\x4f\x46\x44\x22\x51\x99\xa2\xb4\x77\x63\x87\x85\x32\xa4\xcd\x86\xec\x5e\x79\x1d\x2e\x39\x1a\x1a\x3c\x68\xea\x7f\x53\xa0\x3a\xb6\x0f\xfa\x47\xa1\xcf\x54\x69\xcf\x99\x0a\x34\x66\x5a\xa3\x26\x09\xcd\x7e\x7d\x67\xe2\xa8\x12\x88\xda\xeb\xca\xa3\xb9\xb3\x09\x1f\xb4\x90\x3b\xb5\xe8\xfe\x4f\xb2\x60\xed\xa1\x03\xa5\x8b\x37\x2f\xcf\x4c\xbe\x33\x05\xeb\x60\x00\xf0\xd2\x58\x84\x67\xed\xbf\x2e\x1a\xa8\x42\xc3\x5e\x05\x6c\x70\x23\x46\x22\x64\xad\xb1\x9f\x25\xd9\xfd\x90\xa2\x0c\xe9\x54\x14\xc6\x8b\x70\x50\x6b\x6e\x34\xe0\x5d\xeb\xfe\x16\x6f\x97\xb9\xdc\xed\xd0\x30\x9f\xa1\xae\xa5\x71\x65\x04\x73\x0d\xb0\xb5\x62\xa6\x00\x4c\xe9\x3e\x74\xf2\xf7\xdc\x81\x41\x36\x0a\x3b\x42\x33\x8b\x07\xb4\x88\x12\x00\xa4\xc9\x53\xd4\xf7\x25\x90\x8a\x0d\x5d\xd5\x74\x8e\xdf\x47\x78\x3f\x95\x3c\x0d\xe8\xd0\xf3\xae\xa5\xa3\x27\x3f\xd6\x83\xfa\x69\x55\x8f\x52\x72\xe1\x6b\xf0\x7c\x88\x2e\x69\xef\xf6\x79\x4a\x59\x2d\x85\x71\x60\x46\x3d\x54\xc5\xae\xf4\x8d\x02\xe5\x38\x49\x1c\x50\xa2\x8a\x4c\x4b\x6f\x6c\x2d\xc8\x4d\x65\xab\x0d\xda\x79\xf0\xa1\x2b\x20\xcb\xa3\xff\x00\x80\x4f\x0d\x0f\x4c\x13\x00\x00")
+
+func playerJsBytes() ([]byte, error) {
+	return bindataRead(
+		_playerJs,
+		"player.js",
+	)
+}
+
+func playerJs() (*asset, error) {
+	bytes, err := playerJsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "player.js", size: 4940, mode: os.FileMode(420), modTime: time.Unix(1792235165, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _recordingsHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x91\x31\x72\x03\x21\x0c\x45\xfb\x3d\x85\x42\x8f\xb9\x00\x4b\x13\x57\x29\x5c\x24\x33\xe9\xb5\xa0\x18\x62\x02\x1e\x44\x3c\xe3\xdb\x07\xef\xe2\x5d\x17\x29\xac\x4e\xf3\xa5\xaf\xc7\x47\xfb\xfa\x13\xcd\xa0\x3d\xa1\x33\xc3\xa0\x6b\xa8\x91\xcc\xe7\xe1\x15\xf6\xc8\x7e\xca\x58\x1c\x48\x78\x27\x9b\x8b\x0b\xe9\xc8\x5a\x2d\x13\x03\xb4\xd2\x2f\x52\xc2\x47\xbd\x46\x62\x4f\x54\x19\xa4\xec\x42\x0c\xe9\x04\x85\xe2\x28\x78\x95\x05\xf8\x42\x5f\xa3\x08\xc9\xc6\x5f\x47\x6a\x42\xa6\x9d\x65\x16\x30\x5b\x8e\xe2\x1c\x31\x24\xf1\x84\x83\xbb\xa3\xfd\xbb\xbe\xa1\xbd\xe1\x05\xd9\x96\x70\xae\x1b\x59\xef\xb9\xd8\x51\x94\xf5\x59\xbb\x6f\x16\x46\xab\x45\x6c\x71\xa8\x7b\x1e\x53\x76\xd7\xbe\xe9\xc2\x05\x6c\x44\xe6\x76\x0a\x8f\x24\x6d\x4e\xb5\xe4\xc8\x9d\x78\x9e\xc1\x4e\xa8\xb8\x62\x0d\x56\x6d\xa4\xb7\x9c\x85\x59\x43\xd5\x0a\xbb\xad\x6a\xbe\x0f\x17\x82\x7b\xe4\xba\x41\xcd\xba\x56\x0b\x49\x23\x9b\x3f\xec\x0f\x87\x4f\xf2\x7f\xb8\x01\x00\x00")
+
+func recordingsHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_recordingsHtml,
+		"recordings.html",
+	)
+}
+
+func recordingsHtml() (*asset, error) {
+	bytes, err := recordingsHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "recordings.html", size: 440, mode: os.FileMode(420), modTime: time.Unix(1792235157, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _recordingsJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x56\x6d\x6f\xdb\x36\x10\xfe\xee\x5f\xc1\x11\x58\x41\x23\xae\xec\x74\x43\x3e\xd4\xcd\x8a\x26\xcd\xb2\x6c\x49\x1a\xc4\x1d\x30\xc0\xc8\x07\x5a\x3a\x47\x42\x64\x52\x21\xa9\xb8\x5e\xe1\xff\xbe\x3b\xbd\x52\xb2\xe3\x4e\x1f\x6c\x51\x7a\xee\xfd\xb9\x3b\x0d\x96\xb9\x0a\x5d\xa2\x15\x5b\x6a\xb3\x92\xee\x73\x6e\x24\x1d\x85\x85\x50\xab\xc8\x0e\xd9\xf7\x01\xc3\xab\x3a\xb2\x53\x76\x23\x5d\x1c\x2c\x53\xad\x4d\x83\x99\x16\x90\x17\x69\x58\xbc\x17\xc0\xc6\xec\x97\x93\xc9\xc4\xc3\xad\xba\xb8\x06\xf8\x73\x09\x44\x81\x13\x1f\x4e\x76\x5b\xc8\xc9\xa4\x7c\x63\xc0\xe5\x46\xa1\xcd\x23\xc6\xdf\x73\xfc\x15\x2b\xf6\x81\x1d\x4f\xd8\x47\xc6\x27\x9c\xbd\x67\x9c\x0f\xf1\xe9\xaa\x7d\x6f\xf7\xbd\xb7\xd3\xc1\x76\x30\xe8\xe5\x61\x96\xfc\x0b\x62\xb1\x71\xd0\x64\x80\xfc\xc8\x55\xe2\xc8\x97\x39\x3f\xe3\x23\xc6\xff\x2a\x7e\x6f\x8a\xdf\xcb\x33\xfe\xd0\x3a\x9c\x20\xa8\xf2\x72\x1d\x27\x29\xb0\x52\x17\xfb\xed\x14\xed\xbf\xfb\x95\xbd\x79\x83\x90\x0f\xa5\xbe\x20\x05\xf5\xe8\x62\xf6\x96\x1d\xd7\xb6\xe8\x2a\x05\xc6\xa5\xc0\xb4\x79\x9c\x1c\x1d\x95\x87\xad\x9f\x83\x02\x1c\x38\xfd\x7b\xf2\x0d\x22\x81\xd6\xd1\x3c\x86\x39\xc1\x20\x8f\x29\x46\xce\x28\xfe\xc2\xdc\x3c\x79\xe8\x06\xac\x60\x7d\x0e\x69\x2a\x8c\x5e\x8f\x98\x83\x6f\xae\xf6\x22\xc4\xa7\x18\x47\xa4\xc3\x7c\x05\xca\x05\xa1\x01\xe9\xe0\x22\x05\x3a\x09\xee\x22\x5e\x95\x88\x80\x01\x49\x9e\x6b\xe5\xf0\x1d\x0a\xd1\xa9\xaa\x92\x5e\x07\x32\xcb\x40\x45\xe7\x98\x89\x48\x10\x78\xd8\x29\x20\x3d\xe9\xba\x64\x63\xbd\xbe\xc7\x7a\x9b\x28\x51\x8f\x56\x98\xe6\x76\x84\x34\x30\x2f\x60\x9a\xaa\x20\x27\x9c\x4c\x14\x18\xdf\xd1\x47\x70\x95\x97\x67\x9b\xab\x48\xf0\x56\x9e\x3c\x2e\xe4\xc6\x63\x76\x69\x74\x9e\x61\xe6\x2a\x95\x23\xf6\x04\x90\x21\x88\xb9\x18\x28\x29\x60\xdd\xdb\x65\x62\xac\x63\x28\x8c\x06\x96\x46\xaf\x8a\x77\x9f\xee\xae\x9a\x42\x2f\x36\xb3\x42\x1a\xcd\x7f\xdf\xb6\xf5\x2f\x25\x90\x28\x15\x27\x90\x54\x4c\xb4\xc4\x28\x8a\xdf\x7a\x55\x31\x60\x4a\xc5\xf5\x29\x40\x1a\x5a\x54\x51\xb8\x86\x06\x4b\x26\x7e\xaa\x8d\x07\xb1\xb4\x5f\xd6\xea\xce\xe8\x0c\x8c\xdb\x08\x13\x94\x21\x0d\x7d\x65\x25\xa7\x4a\x81\x79\x8d\x78\xf0\x7c\xac\xaf\xc2\xf7\x20\xcb\x6d\xdc\x2a\x6a\x11\xdb\xc1\xeb\xca\x2a\xa1\x61\x4d\xd0\x41\xed\x69\xa9\xb2\xe2\x39\x71\xd3\x77\xac\x29\x61\x8f\x42\xfc\x56\xfb\x39\xe2\xad\x0f\x25\x6f\x3a\x56\xf6\x25\xd8\xb7\xba\x93\x5b\x5b\x97\xad\x22\xd4\xbc\x40\x63\x8e\xbd\x6c\xc4\x20\xc9\xf6\x81\x1e\x88\xdf\xf1\xe1\x0e\xbe\x17\x87\xa8\x4c\x61\xcf\x97\x77\x81\x92\x2b\x18\x62\x7b\x7a\x47\x6c\xd4\xda\x81\xe9\x9e\xcc\xf8\x0d\x54\x59\xa9\x99\x4c\x97\x93\x0b\x9c\x31\x07\x5a\x95\xde\xfb\x9e\x16\x0f\x82\x30\x95\xd6\xde\x92\x75\xcc\xb6\xd7\x24\xdd\x88\xba\xbd\xd5\xd7\x6c\x7c\xb5\x73\x3e\x73\xd2\x38\x88\x68\x22\xd6\xab\x84\xee\x69\x9c\xd2\x3f\x7f\x08\xb0\x52\x17\x32\x8c\x45\xd3\xec\xc2\x25\x2e\x85\x3e\x55\x89\x28\xaf\x5b\x8d\x7d\xab\x25\xbc\x3f\x80\x48\x69\x17\x53\x06\xd3\xc9\xa5\x8b\x7d\x6a\xef\x24\xa8\x9f\xf6\xa2\x13\x76\x1b\xa0\xa1\xce\x9e\xe0\x4c\x3f\x30\x9c\x87\xff\x3b\x9f\x74\x75\xc6\x33\x1e\xd8\x67\xc4\x53\x67\x52\xa6\x87\x38\xf3\xaf\x75\x28\x53\x98\x39\x83\xb5\x13\xc3\x43\xd2\xbd\x15\x6f\x82\xa8\xba\xa5\x15\x81\x47\x89\x3e\xbf\x00\xad\x47\xd6\xce\xdc\x61\xb5\x29\x7f\xac\xb8\xd8\x99\xe8\x18\xfe\xf5\xd1\x69\xa2\x9e\x0e\x44\x2d\xf9\x1e\x3c\x4e\x15\xf7\xc9\x61\x58\x8b\x1c\x03\xe6\xb1\x81\x25\x51\x68\x8c\x81\xbb\x24\x1c\x67\xa9\xdc\xd0\xe8\x73\xab\xf4\x63\x12\x9d\xd2\x7e\x03\x15\xea\x08\xfe\xbe\xbf\x3a\xd7\xab\x4c\x2b\x52\x6d\x82\x24\xda\xe7\x4c\x7f\xd6\xdc\xa1\x36\x7e\x20\x42\x4c\x40\x87\x0d\xa4\xa3\x4f\xc1\x1d\xca\xa0\xe4\x2b\xf4\xda\xdf\xd9\x85\x86\x66\x7a\xfa\x0b\x11\x37\xda\x9f\xb3\x2f\xb7\x22\x37\xe9\x88\x61\xb9\xd3\x85\x0c\x9f\xfc\x2f\x13\x03\xcf\x18\x06\xd1\xe3\x9f\x9b\xeb\x3f\x9c\xcb\xee\xe1\x39\xc7\x0d\x26\x9a\x55\xfb\x1c\xc8\x28\xba\x78\xc1\x78\xaf\x13\x8b\x61\x83\x11\x3c\xd5\x92\x5a\xb5\x36\x23\x3a\x63\xb9\xb2\x22\xc8\x70\x90\x49\x63\xb1\xb8\xa8\xc5\x80\xc5\xdc\x5a\xf8\x4a\x5f\x0a\xb5\xb3\x9e\x15\xdc\x41\x4a\xf0\xcb\x8b\xaf\xa8\xb8\x70\xd7\x99\x1c\xbc\xf7\x16\xc3\x25\xaf\x30\xbc\x86\x0e\x5a\x21\x1f\xa2\x0d\x55\x16\xc2\x58\xaa\x47\x9a\x48\x6d\x0f\xd5\x6e\xd1\x2a\x69\x64\x0a\x89\x19\x49\xd0\x46\xe1\x21\x96\x3c\x05\x87\x53\xce\x8b\x01\xb7\x7c\xb5\x9e\x69\xc6\x5a\x4c\x3b\xce\xba\x66\x8f\xa7\x09\xed\xf6\x25\x7b\x52\x7a\xad\xea\x3d\xd0\xc8\xd6\x29\xe7\x63\x99\x25\x63\xc2\x7a\x99\xaa\x87\xba\xed\x77\x77\x57\xca\x9b\xa9\xbe\x6c\xfb\xb8\x2f\x5e\x6c\xa6\x1f\x7f\xfc\x74\x89\xb7\xdd\x43\x32\xa4\xcf\x74\xf0\x1f\x45\x8a\x84\xde\xdd\x0b\x00\x00")
+
+func recordingsJsBytes() ([]byte, error) {
+	return bindataRead(
+		_recordingsJs,
+		"recordings.js",
+	)
+}
+
+func recordingsJs() (*asset, error) {
+	bytes, err := recordingsJsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "recordings.js", size: 3037, mode: os.FileMode(420), modTime: time.Unix(1792235157, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _thumbnailsHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x91\xc1\x6e\xc3\x20\x0c\x86\xef\x79\x0a\xc6\x9d\xf2\x02\x24\x97\xed\x34\x4d\x3b\x74\xd3\xee\x0e\x78\xc5\x1b\x85\x0a\xd3\x48\x7d\xfb\xd1\x84\x26\x3d\x4c\xd3\xb8\xc1\xef\xdf\xfe\x7e\x63\x7c\x39\x86\xa1\x33\x1e\xc1\x0d\x5d\x67\x0a\x95\x80\xc3\xc7\xeb\xa3\x78\x02\xf6\x63\x82\xec\x84\x12\xef\xfe\x7c\x1c\x23\x50\x60\xa3\x97\x8a\x4e\xd4\x63\x1e\x94\x12\x6f\xe5\x12\x90\x3d\x62\x61\xa1\x54\x13\x02\xc5\x6f\x91\x31\xf4\x92\x57\x59\x0a\x9f\xf1\xb3\x97\x14\x6d\x38\x3b\xd4\x23\x30\xee\x2c\xb3\x14\x73\xcb\x5e\x9e\x02\x50\x94\xff\xe8\xe0\x6e\x68\xbf\xda\x37\xb4\x67\x98\x80\x6d\xa6\x53\xd9\xc8\xda\x9d\xb3\xed\x65\x59\x63\xed\xbe\x58\x0e\x46\x2f\x62\x5d\x87\xbe\xed\x63\x4c\xee\xd2\x9c\x8e\x26\x61\x03\x30\xd7\x51\x70\x40\x65\x53\x2c\x39\x05\x6e\xc4\x73\x0d\x34\x42\xcd\x05\x0a\x59\xbd\x91\x5e\xf7\x2c\x87\x17\x9a\xd0\x68\xf8\xc3\x91\xd1\xa6\xec\x28\x1e\xb8\x59\xf6\xeb\xc3\x6a\x34\xba\xb2\xdc\x51\x91\xbb\xcf\x72\x0d\x32\xeb\x46\x2f\xf4\x35\xcd\xfc\xc9\x3f\x88\x38\xe2\xf1\xec\x01\x00\x00")
+
+func thumbnailsHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_thumbnailsHtml,
+		"thumbnails.html",
+	)
+}
+
+func thumbnailsHtml() (*asset, error) {
+	bytes, err := thumbnailsHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "thumbnails.html", size: 492, mode: os.FileMode(420), modTime: time.Unix(1792235931, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _thumbnailsJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8d\x54\x4d\x6f\xdb\x30\x0c\xbd\xe7\x57\x10\xbe\xd4\x41\x53\xf9\xde\x22\x28\xb6\xa0\x87\x0d\x43\x77\xe8\xb0\xcb\x30\x0c\x8a\xcc\xc4\x42\x65\xd9\x90\x64\x77\x45\x91\xff\x3e\x4a\xb6\x6c\xa5\x4b\x82\xe6\xe2\x48\xfc\x78\x8f\x8f\xa4\x8a\x02\x7e\x54\x5d\xbd\xd5\x5c\x2a\x0b\xdc\x20\x18\xd4\x25\x1a\x2c\x61\xfb\x0a\xae\x42\xb0\x68\x7a\x34\x2b\xb0\x4d\x38\xbe\x70\xa5\x40\x34\xd6\x59\x68\x34\x82\xac\xf9\x1e\xa1\x45\x33\xfa\x2d\x8a\x02\x90\xbe\xaf\xb0\xc3\x17\xba\x13\x8d\x2e\x2d\x18\x4e\xa1\x86\xe2\xb9\x06\x0e\x4a\xf6\x08\x3f\x1f\x37\x20\x94\x44\xed\x00\xb9\xa8\xd8\xa2\xe7\x86\x22\xdd\x53\xd3\x19\x81\xb0\x06\x4d\xf1\x0f\x3d\xd9\x87\x9b\x3c\x2b\x78\x2b\x0b\x37\x91\x2d\x6c\xb7\xb5\xc2\xc8\x2d\x66\xcb\xbb\x10\x3d\xdb\x28\xfc\xed\x70\xb7\x58\xec\x3a\x2d\x9c\x6c\xb4\x4f\x36\x95\x99\x0f\x4c\x97\xf0\xb6\x00\xfa\x95\xb2\x27\xf7\xb2\x11\x5d\x4d\x60\x4c\x18\xe4\x0e\x1f\x14\xfa\x53\x9e\x91\xd5\xa7\x1f\x1d\x99\x50\xdc\xda\x47\x5e\x7b\x82\xd9\x84\x77\x43\x55\x3a\x2e\x35\x9a\x8c\x40\xbd\x2f\xa9\xb0\x51\x52\x3c\x13\x27\xd3\x74\xfb\x0a\x5c\x13\x0b\xb7\x68\x2d\x51\x0a\x6e\x4a\xea\xe7\x0b\xe0\x3c\x42\x7b\x3f\x66\xd1\x7d\x72\x8e\x0a\xee\x1c\xa9\x51\x19\xdc\x65\x2b\xc8\x0a\xeb\xb8\x93\xa2\xe8\xb5\xf8\xc3\x3b\xd7\xb0\xca\xd5\xea\xbe\x25\xc1\xd7\x74\x55\x64\x70\x3d\x76\x66\x39\x52\x93\xf5\xfe\x02\x24\x59\x23\x28\xfd\x3d\x53\x6f\xac\x52\xf0\x36\xa8\xfb\x21\xf9\x46\xe7\xb3\x12\x0e\xe6\x98\x3a\x54\xcc\xdb\x96\x86\x71\x53\x49\x55\xe6\xc4\x26\x15\x23\x35\x8d\xa1\x49\x9b\x52\xab\x77\x8f\xa6\xc8\x72\x8f\x6e\xa4\xf8\xf9\xf5\x4b\x99\xcf\x34\x6c\xb6\x3c\x0a\xa6\x64\x51\xb7\xd9\xe7\xd7\x20\xe8\x6f\x3f\x65\x70\x45\x2e\x57\x70\xeb\x61\x57\x70\x45\x2c\xfd\x81\x3e\x74\x18\x79\xf9\x8b\xa8\xd4\x61\x20\x62\xd0\x75\x46\x9f\x48\x79\xb7\x38\x24\x53\xdb\xb5\x25\x89\x39\x0f\x2e\xc6\x99\x0d\x81\x04\xff\xf5\xe9\xfb\x23\x6b\xb9\xb1\x98\x23\x23\x5f\x3e\x16\xea\xc8\x96\x24\x0f\x7f\xd9\x04\x11\x9a\xbb\x83\x9c\xbc\xd6\x6b\xe8\x68\xdd\x77\x34\xbb\x65\x4c\x1e\x13\x1c\xed\x4c\x9a\x62\x04\x39\x8c\xba\xb0\xd8\x59\x87\x7f\xdd\x86\x36\xc1\xef\xf4\x88\xcf\xb4\x6f\xf4\x7d\x7a\xb8\x85\x34\x57\xc2\x26\xdc\xa2\x31\x8d\x39\x62\xc2\xa6\xbd\xfb\x26\xad\x63\xbc\x4c\xfb\x75\x13\xfc\xe3\x88\x9d\xa7\x73\x4d\xb3\x06\x37\xe0\xb7\x21\xc1\x99\xa3\x86\x86\xc4\xba\x4e\xe1\x1a\xac\x9b\x1e\xcf\x42\x3b\xe6\xd7\xe5\x45\x96\xae\x9a\x6a\x0f\xa7\xd4\x5c\xa1\xdc\x57\xb3\x36\xc3\x31\x75\xb0\x46\x4c\xd6\xf0\xb2\x1e\xcf\xc3\xc0\xe1\xd4\x3c\x1c\xf7\x7b\x98\x85\x8f\x75\xfa\xff\xca\x3f\xb6\x25\x03\x97\x61\x4b\x82\x56\x71\xc7\x50\xa1\xc3\x93\x6c\xa8\x94\xe9\x81\xf7\x7d\x0c\xaf\xbb\x17\x17\xe9\xe5\x4c\xd2\xd3\xb3\xf6\x6e\xf2\x57\xb0\xe3\xca\x22\x41\x5c\x4a\x30\x50\x2a\x29\xfc\x9d\x50\x73\xf8\x3f\x90\x3c\x7b\xee\xef\x06\x00\x00")
+
+func thumbnailsJsBytes() ([]byte, error) {
+	return bindataRead(
+		_thumbnailsJs,
+		"thumbnails.js",
+	)
+}
+
+func thumbnailsJs() (*asset, error) {
+	bytes, err := thumbnailsJsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "thumbnails.js", size: 1775, mode: os.FileMode(420), modTime: time.Unix(1792235931, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _vnc_autoHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xa4\x5a\xff\x72\xdb\x36\x12\xfe\xdf\x4f\x81\xf0\x7a\x91\xd4\x8a\x92\xec\x26\x6d\xc7\x36\x7d\x93\x38\xc9\xd4\x37\x49\x93\x89\xe3\xb6\x37\x77\x37\x1e\x88\x84\x24\xc4\x10\xc0\x02\xa0\x6c\x5d\xeb\x77\xbf\x5d\x80\x94\xf8\x53\x52\x53\x4e\xa7\x11\x89\xdd\x6f\x17\x8b\xc5\x87\x05\xe0\xf3\x27\xaf\xde\x5f\x7e\xfa\xd7\x87\xd7\x64\x61\x97\xe2\xe2\xe8\xbc\xf8\x87\xd1\xe4\xe2\xe8\x88\xc0\x73\xfe\x24\x0c\xdd\x0f\xa9\x7e\xfe\xe9\x92\xb0\x07\xba\x4c\x05\x3b\x25\x86\xe3\xbf\xc5\x3b\xc9\x0c\x97\x73\x92\xb0\x19\xcd\x84\x25\x37\x57\x4e\xe5\x52\xa5\x6b\xcd\xe7\x0b\x4b\xfa\x97\x03\x72\x32\x39\x3e\x21\xff\x54\x4c\x90\x77\x54\x5b\x2e\xdb\x45\xbe\x25\xd7\x74\x99\x39\x21\x29\xd9\x82\x25\x64\xa6\x34\xb9\x64\x32\xe1\x8a\xbc\x78\x59\x72\x85\x1b\x22\x78\xcc\xa4\x01\x99\x4c\x26\x4c\x13\xbb\x60\xe4\xdd\x87\xb7\xe4\x64\x34\x21\x7d\xc3\x18\x79\x7b\x75\xf9\xfa\xa7\xeb\xd7\x23\xfb\x60\x07\x4e\xf3\xd3\x02\xb4\x66\x1c\x1c\x6e\xd7\x3e\x09\x2f\x05\xcd\x0c\x23\x2f\xaf\x5f\x15\xed\x4d\xa8\xd1\x51\xee\x3b\x78\x18\x5b\x92\x52\x4d\x97\xcc\x32\x6d\x08\xd5\x8c\xa4\x5a\xad\x78\x02\xb0\x5c\x92\xdf\x32\xa6\xd7\xc4\x58\x0d\xd1\x39\x75\x4a\xf8\x2c\xac\x4d\x4f\xc7\xe3\x3c\x76\xa3\x58\x2d\xc7\xff\x58\x28\x63\xa3\x1f\xdf\x5f\x7f\x7a\x9a\x2a\x6d\xa3\x0f\xef\x3f\x7e\x7a\xca\x64\xac\xd7\xa9\x8d\x8e\x9f\x5a\x9d\xb1\xdb\x58\x09\xa5\xa3\x63\x07\xa3\xbc\xbf\x33\x4d\xe7\x4b\x26\xed\x4e\xec\xbf\xfd\x49\xec\x30\xbc\xf0\x43\x6f\xb9\x15\xec\xc2\x45\xfb\x7c\xec\x5f\xf2\xa4\x80\xee\x52\x12\x2f\xa8\x36\xcc\x46\x41\x66\x67\xe1\x0f\x41\x29\x61\xc8\x0b\x71\x4f\xd7\x06\x87\x2e\x66\x44\x50\xcb\x8c\x25\x57\xaf\x89\x66\x18\x69\xcc\x14\x26\xe7\x5c\x42\x68\xd9\x8a\x49\x0c\x14\x97\x56\x53\xc9\xec\x80\x3c\x25\x97\x0b\xad\x96\x8c\xbc\xc1\xa8\x6e\xfa\x55\x3c\x1f\xd9\x52\xad\x18\x74\x1e\x06\x90\xcf\xc8\x5a\x65\x04\xc7\x0b\x83\x31\x5a\x58\x1a\xc7\xcc\x98\x6d\x0f\x9c\x9f\x18\x92\x90\xfd\x96\xf1\x55\x14\xfc\x1a\xde\xbc\x08\x2f\xd5\x32\xa5\x96\x4f\x05\x0b\x48\xac\xa4\x85\x00\x46\xc1\xd5\xeb\x88\x25\x73\x36\x8c\x9d\xf5\xe8\xb8\xda\x9f\x14\x93\x9c\xbf\xbf\x86\xec\x9c\x51\xcd\x09\xf4\x1b\x52\x78\x5e\x37\x25\xc1\xe5\x28\x58\x71\x76\x8f\x91\x2e\xa1\xdf\xf3\xc4\x2e\xa2\x84\xad\x20\xa7\x42\xf7\x32\x84\x3e\x73\xcb\xa9\x08\x4d\x4c\x05\x18\x1c\x4d\x86\x64\x49\x1f\xf8\x32\x5b\x96\x3f\x41\xef\xb4\x7b\xa7\xe0\x6f\x24\x55\xd0\xb4\x47\xd1\xbb\x70\xa9\xa6\x90\xd8\xe1\x3d\x9b\x86\xf0\x21\x8c\x69\x4a\xab\x3d\x5c\x33\x13\x90\xf1\x81\xea\xc6\x52\x9b\x99\x70\x4a\xc1\xb8\x5d\x57\x70\xa6\x82\xc6\x77\x21\x8e\x97\x11\x19\x4c\x11\xbb\x45\xcd\x63\x45\xae\x2d\x4c\x71\x72\x05\x2a\xa5\x74\x12\x5c\xde\x41\x0a\x88\xc2\xa2\x55\x59\xbc\x40\x43\xda\x66\x69\xc8\x97\x74\x0e\x56\x16\x9a\xcd\xa2\xc0\xbd\x98\xb1\x89\x35\x63\xf2\xf6\xdb\x93\xc9\xc3\xb3\xef\x26\xa3\x54\xce\xab\xa6\xde\xc0\x34\xc0\x41\xf1\x71\x35\x38\x2a\x2e\x13\x38\x1a\xb6\xca\x65\x06\x24\x09\x86\x90\x4c\x95\xba\x5b\x52\x7d\x07\x93\x14\x1c\xc4\xf6\x05\xe3\x9a\x2c\x60\xb0\xbd\x99\x7d\x8e\x22\x68\xbb\x7f\xcf\xbf\x7f\x78\xfe\xbd\xf3\xee\xa2\xca\x99\x3b\x90\xc2\x54\x33\x98\x9c\xa9\x02\x02\xda\x87\x5a\xf4\x19\x1d\x2c\x25\xe5\x35\x8e\x8b\x59\x30\x66\x4d\x9b\xef\x66\xd3\xbc\xc1\x97\xb1\xc8\x12\x36\x9e\x52\x03\xcc\x60\x20\x1b\xdc\xa4\x8e\x82\x54\x50\x2e\x8b\x84\x2f\xb9\x0f\x9e\xf0\x14\x42\xba\x4e\x59\xd4\xb3\xec\xc1\x8e\x3f\xd3\x15\xf5\x5f\x7b\x9b\xa9\x69\x74\x1c\xf5\x72\xde\x99\x33\x3b\xe3\x9a\x4d\xb3\xb9\xa3\x1e\x70\x84\x81\x31\x33\x16\xdc\xb2\xf1\xf1\xe8\x64\x9c\xb7\x86\xf8\x21\xc4\x00\x68\x98\xb0\x2c\x19\x7d\x36\xbd\x8b\xf3\xb1\x87\xbe\xa8\xd0\x50\xd9\x13\x34\xb5\xe9\x46\x66\xb9\x00\xbd\xa0\xa4\x77\x3e\xce\x97\xae\xf3\xa9\x4a\x90\x78\xd7\xd8\x3d\x18\x76\x60\x9b\x53\x32\x49\x1f\xce\x8a\x21\x4a\xf8\x8a\xf0\x24\x0a\x1c\xbb\xdd\xfa\x90\x07\x17\x15\xba\xa9\xcb\xb8\x19\x71\x0b\x33\x02\xe6\x82\xa0\xc6\xb4\x35\x54\x2c\xc2\x70\xa7\x15\xab\x15\x74\x8b\xd3\x13\xd2\x52\x03\x25\x46\x13\xe2\x19\x22\x38\x9e\x4c\xfe\x0e\x3d\xb2\xba\xa9\xe1\xb5\x92\x8b\x56\xbf\x36\xa6\x21\xa3\x80\x56\x14\x74\x17\x82\x0f\x2c\xb7\x62\x67\x04\x32\x1d\x96\xd8\x53\x42\x33\xab\xda\x7c\x29\x9e\xb7\x8a\x26\xc0\x6a\xed\x96\xc7\x60\x16\x42\x0d\x0e\x74\x79\xb6\xe9\x03\xf6\xa0\xea\xe4\x34\xb3\x56\x49\xb3\xc3\xf6\x39\x97\x69\x96\xe7\x9a\x97\x26\x2b\x2a\x32\xe8\xd1\x35\xac\x1a\xe4\xd2\x6a\xf1\x42\xd8\x57\x4c\x04\x9d\x10\xf8\xa0\x49\x03\x0a\x5b\xf9\x97\x0e\x6c\x97\x65\x93\x52\x59\xf2\xf5\x61\x95\xfe\x35\x7f\x17\x99\x4d\xd4\xbd\xdc\xef\x28\x58\x2a\x84\xf7\x7b\xd9\x69\xef\x23\x03\x6e\xb3\x07\x59\xf3\xa2\x7f\xc9\x16\x70\xec\x81\xa6\x40\x72\xbf\xa5\x31\x06\xbf\xbb\xdd\xcb\x74\x67\x1e\x7c\xd5\xd8\x84\x73\xa9\x36\x7b\x9d\x56\xf5\x53\x4c\x25\xd0\x57\x69\xa8\xfd\x87\xa0\x48\xdc\xef\x9e\xc1\x64\x0d\xf2\xe9\x12\x05\x27\xf8\xd6\xb4\x79\xe9\x51\xa4\x02\x3e\xca\x52\x5c\xe8\x81\xbf\x6a\xb6\x3d\x70\x89\xc0\xbc\x37\x75\x42\xdb\x0a\x8c\xbf\xfe\x6c\x80\xbb\x2d\xb9\x5f\x00\x33\x9e\x92\x19\x15\xb0\x78\x7d\x3d\x2e\x09\xcc\x85\x9a\x52\x01\xbe\x4a\x48\x98\x21\xf9\x6a\x48\x6e\x80\x01\x87\xe4\xe3\x9b\x97\xc3\xb2\x64\x80\xcb\x1e\xd6\x9c\xb1\x0d\xce\xb6\x26\xc7\x63\x37\xbf\x0b\x9f\xb1\x0a\xf3\x4e\x98\x8d\x08\xe2\x8d\x04\x08\xdd\xe6\x2d\xfd\x7f\x07\x50\x10\x14\x44\x3b\x24\x01\x2e\x1d\xdf\x3d\xcb\x5f\xa0\xc9\xa8\xf8\x2e\x7f\x4b\x98\x71\xbf\x76\x8d\x65\x70\xc7\xd6\x66\xbd\x84\x7d\x42\xae\x04\xef\x53\x45\x75\x92\xbf\xba\xbc\x2b\xf0\xb8\x81\x75\x69\xbd\x1f\x93\xcb\x19\x10\x9d\xd2\xb9\x9e\x9e\x4d\xb7\xe0\x60\x0c\x5f\xfe\x3b\x28\x05\x62\x45\x35\x01\xa1\xb3\xea\x07\x66\xf8\xff\xd8\x27\xbe\x64\x2a\xb3\x67\x47\x5b\xe9\x59\x26\x63\xe4\x53\xd8\xd4\x78\x99\xfe\x80\xfc\x5e\xf1\x07\x2a\x8c\xfe\x2f\x6c\xea\x82\x07\xeb\x1f\x6c\x0b\x66\x7c\xfe\x33\xd5\xfd\x9e\x57\xe8\x0d\xfd\x68\x0e\xea\x8a\x85\x6d\x0e\xfb\x08\xfd\x0b\x89\xf2\xa1\x1d\xf9\x77\x4c\xc9\xb3\x6e\xf9\x1f\x6b\xf2\x3f\xba\xa4\x6d\x57\xc0\xd2\x4d\x2b\x01\x2b\x14\x6a\x7d\xf5\xaa\xdf\xab\xaf\x5c\xbd\xc1\x48\xcd\x66\x30\x63\x77\xc1\xa4\x34\xc1\xe5\x01\x20\x9e\x37\x05\x30\x0a\x79\x47\x9e\x44\x91\xdb\x53\xcd\xa0\xc4\x4f\xc8\xd3\xa7\x85\xc3\x95\xef\x83\xd6\x31\xc5\xc1\x03\x2f\x5e\x31\x73\x07\x8b\xe7\x35\x86\xdb\x83\x0e\x0b\x90\xb0\xd2\x9b\xb0\x70\x6a\x50\xf5\xe8\xf1\xa8\xf9\x6b\x33\x92\x6f\x5e\xde\xe0\x16\x40\xc0\x9e\xad\x0f\x06\x61\x78\xa6\x59\x7d\x6c\xb6\xa3\x5d\x05\xce\x1d\xbc\x55\xb2\x8c\x52\x40\x63\x6e\x90\xc7\x92\x4a\x8b\xf5\x14\x4a\x87\x7b\x58\xf5\x3f\xe2\x96\x44\xb3\x04\x5d\xa8\x5b\xc7\x68\x2f\xcd\xbc\x6a\x1a\x3e\x40\xe8\x7b\xe7\xb0\xab\x5a\x42\x01\x6b\xb2\xe9\x92\x03\x4b\x69\x66\x33\x2d\xb1\xf6\xfd\x90\x23\x83\xcf\x41\xaf\xa9\xfb\x0d\x28\x93\x5a\x85\x32\x55\x40\xd0\x4b\x57\xa4\x04\x17\x1d\x3a\x05\x2c\x29\x3c\x3e\x25\x1d\x92\xe5\x75\xa3\xe8\x26\xc1\x28\x46\xc7\x13\xc7\xba\xc5\xc7\x5b\x27\xd8\x5a\x47\x75\xba\x71\xfe\x9f\x31\xf6\xbc\xde\xdc\x91\xcd\x10\x8e\x17\x16\x38\x10\xc8\x8b\xf5\x03\x67\x07\x29\xa1\x22\x79\x4f\xb5\x0c\x06\xbb\xe1\x00\xca\x27\xde\xa7\x77\x6f\x21\xfa\x95\x41\x69\x19\xdc\xca\x28\xd4\x06\xd5\xa7\x8e\x4c\x36\x02\x68\xab\x1a\x11\xb0\xe6\x16\xda\x7a\xd2\xf9\x21\x76\x2c\xb2\xc7\x7c\xb9\xf2\xe9\xf4\xa0\x2c\xf2\x85\x96\x4a\xa5\x4b\xab\x99\x4a\xfb\x97\xdb\xf0\x05\x4b\x97\x85\xa2\xf5\xaf\xe0\xc3\x88\x75\xc3\xbb\xc6\x2f\x44\xcf\xd2\x84\x5a\x06\x3b\xe1\x82\x65\x30\xa1\xd8\x90\x28\x91\xe4\xbf\x20\x99\xda\x26\xbe\x01\x51\x10\x8f\x69\x32\x24\x82\xad\x98\xa8\x3a\x60\x5a\x28\xbc\x57\x73\xd2\x4c\x3b\x79\xbe\x2a\x08\x46\x72\xc9\xb6\xa2\xb9\x01\x7b\xcf\x6d\xbc\x20\x7d\xe7\x7f\xdb\x6a\x16\x43\x79\x40\x7a\x33\xca\x05\x4b\x7a\xa7\xf9\x47\xd7\x07\xb0\x12\x30\xad\x95\x0e\xce\x08\x99\x6a\x46\xef\x9a\x4b\x48\xa1\x6d\xa9\xd8\x28\xff\x59\x6d\x09\x14\x51\x52\xdf\x68\xfb\xef\xa0\xbe\x53\x1b\x6a\x8e\xd8\x9f\xea\x39\xff\xff\xa4\x36\x56\x4e\x6d\xfd\xde\xa7\x9d\x1f\x9b\x9e\x56\x3e\x6e\xb4\x1d\x4b\x41\xb7\xdb\xb4\x1f\x8f\x1a\x95\x88\x1b\x1b\x12\x45\x5b\xbb\xed\x03\x95\x8c\xa0\xb3\x58\x36\x63\x02\xd4\xb2\xd9\x41\x13\x86\x15\xe8\x5e\x55\x3c\x3e\x6c\x76\x09\x66\xcf\x95\xe4\xb6\x3f\x69\xac\xcb\x0d\x87\x71\xb5\x50\xb3\xbe\x9b\x0c\x58\x21\xf4\x36\x25\x42\xaf\xcd\x73\x33\x3d\x88\xdb\x03\xf2\x8d\x0f\xe1\xa0\xe9\x9c\xe9\xa6\x74\xef\x63\x8b\xb7\x79\xa9\xa5\xa4\x2f\x0b\x30\x66\xc5\x44\x6f\xd0\x07\x54\xda\xbf\x2c\x98\x3b\x61\xca\xf5\xc8\x02\x36\x0c\x53\x3c\x64\xf2\xea\x30\xb3\xef\x29\xb7\x50\x0d\x41\xd1\xe8\xe4\x1c\xa8\x66\x4b\xca\xa5\xa9\x83\xb9\x76\xba\x64\xee\x28\x7c\x32\x7a\x0e\x34\x0f\x59\x9a\x20\x22\x7c\x61\x8e\xf5\xb1\x2e\x43\x39\x0d\x6b\x34\x9e\xb6\xa2\x68\xbc\xa0\x78\xcc\x3a\x6f\xc3\x03\x3f\x94\xc8\x9c\xff\x6a\xe6\x2d\x30\x63\xe0\xb5\x4a\x0f\x82\x51\x9d\x17\xc5\xfd\x4a\x89\xdc\x60\xc5\x52\x1b\x04\x07\x86\xa8\x50\xdb\xd6\x46\xcd\xc1\xec\xaa\xb2\x1e\x87\xe4\xf9\xa4\x9c\x3b\x8f\x67\x2d\x15\x79\x91\x64\x2b\xa6\xdb\x78\x14\x9a\xf3\x6d\x7c\x15\x7c\xfb\xbd\x42\x92\xa5\x6d\x7f\x9d\xf7\x30\x4d\xc1\x08\xb9\x88\xc8\x71\x5b\x4e\x6e\x11\x47\xae\xbc\x1a\xe5\x7b\x17\x2c\xd7\xb8\x84\x6d\x1d\xeb\x1d\x38\xb9\x76\x21\x49\xd5\xc4\xd9\x99\xaa\xf9\x26\x0e\x69\x69\x67\xbe\x62\xb0\xf0\x8e\x60\x48\x70\x63\x38\xdc\x54\xa8\xf8\x0b\x0f\xaa\xad\xba\x63\xf2\xec\xa8\x51\x27\xb5\x2f\x19\x0d\xc7\x03\x1f\x82\xa0\x59\x68\x75\x00\x28\x19\x0b\x1e\xdf\xb9\x34\x2a\x0b\x34\x01\x1a\x07\x28\x15\xed\x52\x6b\xab\x6a\xf9\x34\xa4\xae\xe8\xdb\x3a\xd4\x36\x27\x1b\x4d\x2d\x68\xaa\x45\xaa\xd8\x1a\xe2\x71\xff\xad\x50\x73\x9c\x91\x1d\xfb\xc5\xbc\x15\x36\x8c\x3d\x64\xfd\xde\xa0\x96\x87\x89\x8a\x33\xbc\xee\x19\xb9\x73\x5b\x82\xbb\x29\x66\x62\x9a\xb2\x0e\x3c\x27\x86\x68\x2e\xc3\x1b\x70\x40\x05\x2f\xd7\xc5\xea\x33\xdc\xdc\xa3\x60\x2a\x10\x2a\x13\x97\x0d\xc8\x0e\x86\xe9\x95\xbb\x20\xa3\xd6\xff\x4e\xfc\xfd\x0b\x5e\xa4\x55\x00\x9d\x66\x44\xda\x9d\xc1\x46\xf0\x25\xcf\x4e\xa1\x62\x8a\xd9\x38\xc2\xcf\x78\x0f\x51\xf3\xcd\xd9\xee\x82\xc2\xc6\x16\x28\xfc\x3c\xa8\x45\x1f\xfa\x08\xb3\xd7\xa3\x45\xe4\x87\x09\xe9\x03\x31\x3e\x7b\xf6\xed\x00\xbb\x2a\x09\x70\xf0\x3d\x0c\xa3\x05\x2a\x25\x78\x14\x0d\xc1\x75\x5d\x37\x0b\x95\x89\x04\xbe\xd6\xc1\xf0\x8a\x61\x49\x65\x46\x85\x58\x37\x38\xe2\x89\xf3\xa0\x65\x5a\x63\x63\xc3\x5b\xad\xac\x8a\x95\x18\xc1\x46\xce\xdf\x10\xf6\x27\xc3\xe7\x03\x74\xd3\x9d\xa6\x9b\xd6\xe5\xaf\x14\x1b\xe8\x45\x73\x6d\x7b\x6c\x7c\x71\x4c\x73\xb0\x03\xcf\xb6\x0e\xec\xb3\xff\xc3\x64\x9f\xf9\xda\x6a\xbf\xd9\x12\x76\x8e\x6b\x2e\x80\x29\x5b\xe7\x60\xa4\xa2\x1d\x8a\x76\xe1\x66\x8d\x3f\x91\xe2\xb3\x75\xaf\x25\x11\xae\x66\x84\x7a\x32\x43\xca\xe3\xee\xfc\x1d\xd2\x18\xad\xba\xeb\xda\xe1\xe6\x02\x69\x73\xa5\x8b\x77\x93\x94\xc4\x4a\xdd\x71\x36\xaa\xe3\xb9\xdb\x64\xf8\x2f\x43\xf5\xe9\x9a\x48\xb5\xa2\x21\xfc\x4f\xc6\x10\xda\x87\x75\x55\xde\xdb\xed\xea\x80\x6b\x85\x1e\xc8\x4c\xd4\x2b\x16\x57\x23\x61\x33\x0e\x47\x23\xe0\x3e\xbf\x3d\x38\xb8\x42\x05\x14\x89\xc9\x7a\x93\xcb\x5c\xe6\xfd\x81\xe0\xdd\xb3\x22\xad\xdd\xbd\x98\x6d\x60\xd5\x42\xcc\xe5\x67\x28\x84\x3f\x60\x28\xae\x66\xef\xb8\xc1\x2b\xfe\xbe\x5f\x11\x02\x67\x30\xc8\x97\x86\x7a\xa8\xf1\x29\x40\x62\xf0\xc7\xb2\x4b\x17\xc0\x6d\x37\xdd\xbf\x43\x58\x4c\xf7\x15\x87\xfd\x27\xc8\x0f\x03\xf2\xc7\x1f\xc5\xec\x6a\x4b\xca\xf2\x56\x0b\x23\x38\x2c\x76\x11\x3e\xa0\xf0\xfa\x2e\x03\x66\x32\x29\x8b\x21\x35\x6a\x04\x07\x21\xba\xf9\xf8\xb6\x9e\x6e\xf8\xf8\xad\xde\xce\x02\xd6\xea\x75\x8b\x3f\xb0\xdd\x83\x38\x4a\x76\x8f\x67\xb3\xfd\xdf\x7b\x96\x6a\x18\xee\xcd\xc6\x60\x5b\x73\xf8\x63\xe2\xde\x60\xe7\x01\x27\x3e\xbd\xfc\xbe\xbe\xc0\x68\xcf\xa2\x42\x68\x2f\xdc\xe6\xe9\x24\x05\xbf\x83\x70\x3c\x74\x1a\x0c\x0e\x70\x50\xb3\x14\x47\x5a\x5f\xbd\x72\x3e\x76\x1d\x88\x6e\x84\xdc\x14\xdf\x0f\xbb\xfd\xeb\x84\x1d\xb0\x25\xa1\xa1\xdb\x90\x1c\x00\x8c\x3d\x16\xb7\x71\xa6\x8d\x83\x6e\x07\xce\x9b\x0f\x06\x35\x0b\xaa\x4b\x3b\xc0\x76\xd0\x5c\xe8\x60\x50\xfc\x73\x82\x5b\x25\xc5\xda\xe3\xb6\x83\x6e\x85\x8a\xd3\xe6\xfd\xc0\x4a\xde\x6c\x27\x0e\x82\x97\xe6\xd1\x21\xda\xbf\xfa\xfa\xdb\xbb\x95\x17\xe3\x87\xe8\x7d\xa8\x9d\x80\x22\x40\xfd\x54\xf4\x10\x9c\xd2\x09\x2c\x40\x94\xde\x1e\xeb\xdb\x09\xd8\xb4\xba\x83\x0b\xf6\x10\x7f\x19\x7f\xdc\x48\xb7\x5a\x58\x45\x3c\xa1\xe1\xc4\x86\xdd\x11\x47\x8e\x0d\x43\xd2\x83\xdd\x26\x62\x77\x72\x08\xf2\x74\xe2\x0a\x0d\x3c\xbd\xe6\x32\x63\x48\x1d\x6e\xd3\xa6\x48\x7e\xe4\xb0\x8b\x67\xf0\x44\x2a\x17\xeb\x77\x97\xea\xd5\x0d\x53\xf1\x73\x7b\x3d\x7e\xe4\x5f\xf1\x6e\xdc\xdd\x95\xbb\xbf\xf6\xfa\x7f\x00\x00\x00\xff\xff\x8c\x0d\xcc\xe5\x05\x26\x00\x00")
 
 func vnc_autoHtmlBytes() ([]byte, error) {
@@ -668,6 +857,15 @@ var _bindata = map[string]func() (*asset, error){
 	"include/util.js": includeUtilJs,
 	"include/websock.js": includeWebsockJs,
 	"include/webutil.js": includeWebutilJs,
+	"kiosk.html": kioskHtml,
+	"kiosk.js": kioskJs,
+	"login.html": loginHtml,
+	"player.html": playerHtml,
+	"player.js": playerJs,
+	"recordings.html": recordingsHtml,
+	"recordings.js": recordingsJs,
+	"thumbnails.html": thumbnailsHtml,
+	"thumbnails.js": thumbnailsJs,
 	"vnc_auto.html": vnc_autoHtml,
 }
 
@@ -739,6 +937,15 @@ var _bintree = &bintree{nil, map[string]*bintree{
 		"websock.js": &bintree{includeWebsockJs, map[string]*bintree{}},
 		"webutil.js": &bintree{includeWebutilJs, map[string]*bintree{}},
 	}},
+	"kiosk.html": &bintree{kioskHtml, map[string]*bintree{}},
+	"kiosk.js": &bintree{kioskJs, map[string]*bintree{}},
+	"login.html": &bintree{loginHtml, map[string]*bintree{}},
+	"player.html": &bintree{playerHtml, map[string]*bintree{}},
+	"player.js": &bintree{playerJs, map[string]*bintree{}},
+	"recordings.html": &bintree{recordingsHtml, map[string]*bintree{}},
+	"recordings.js": &bintree{recordingsJs, map[string]*bintree{}},
+	"thumbnails.html": &bintree{thumbnailsHtml, map[string]*bintree{}},
+	"thumbnails.js": &bintree{thumbnailsJs, map[string]*bintree{}},
 	"vnc_auto.html": &bintree{vnc_autoHtml, map[string]*bintree{}},
 }}
 
@@ -3,9 +3,11 @@ package main
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
+	"errors"
 	"github.com/prometheus/common/log"
 	"io/ioutil"
 	"math/big"
@@ -68,3 +70,25 @@ func EnsureCert(hostname string, sslCert string, sslKey string) {
 
 	return
 }
+
+// TLS settings for checking client certificates against a PEM bundle of CAs.
+// Unless optional is set, clients without a valid certificate can't connect.
+func ClientCertTLSConfig(caBundle string, optional bool) (*tls.Config, error) {
+	b, err := ioutil.ReadFile(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("no certificates found in CA bundle")
+	}
+
+	config := &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+	if optional {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	stdlog "log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Make a certificate from a template, signed by a parent or self-signed if the
+// parent is nil
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestCertAuth(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops", "lab"}},
+		EmailAddresses: []string{"alice@example.com", "a@example.com"},
+		DNSNames:       []string{"alice.example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/alice"}},
+	}
+	bare := &x509.Certificate{Subject: pkix.Name{Organization: []string{"Example"}}}
+
+	tests := []struct {
+		field    string
+		state    *tls.ConnectionState
+		expected string
+	}{
+		{CertUser_CommonName, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "alice"},
+		{CertUser_Email, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "alice@example.com"},
+		{CertUser_DNS, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "alice.example.com"},
+		{CertUser_URI, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "spiffe://example.com/alice"},
+		{CertUser_Subject, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, "CN=alice,OU=ops+OU=lab"},
+		{CertUser_CommonName, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{bare}}}, ""},
+		{CertUser_Email, &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{bare}}}, ""},
+		{CertUser_CommonName, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, ""}, // Not verified
+		{CertUser_CommonName, nil, ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/list", nil)
+		r.TLS = test.state
+		id, ok := certAuth{test.field}.Authenticate(httptest.NewRecorder(), r)
+		if ok != (test.expected != "") || id.User != test.expected {
+			t.Errorf("%v: got %q (%v), expected %q", test.field, id.User, ok, test.expected)
+		}
+		if ok && (id.Method != Auth_Cert || !reflect.DeepEqual(id.Groups, []string{"ops", "lab"})) {
+			t.Errorf("%v: got identity %+v", test.field, id)
+		}
+	}
+
+	for field, valid := range map[string]bool{"cn": true, "subject": true, "CN": false, "": false} {
+		if validCertUserField(field) != valid {
+			t.Errorf("field %q: expected valid %v", field, valid)
+		}
+	}
+}
+
+func TestClientCertTLSConfig(t *testing.T) {
+	ca, caKey := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	client, clientKey := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "alice"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	stranger, strangerKey := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "mallory"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil, nil)
+
+	bundle, cleanup := writeTempFile(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})))
+	defer cleanup()
+
+	tests := []struct {
+		name     string
+		optional bool
+		cert     *x509.Certificate
+		key      *rsa.PrivateKey
+		status   int // 0 if the handshake fails
+	}{
+		{"signed", false, client, clientKey, 200},
+		{"unsigned", false, stranger, strangerKey, 0},
+		{"none", false, nil, nil, 0},
+		{"optional signed", true, client, clientKey, 200},
+		{"optional none", true, nil, nil, 401},
+		{"optional unsigned", true, stranger, strangerKey, 401}, // Not offered for the CA
+	}
+	for _, test := range tests {
+		config, err := ClientCertTLSConfig(bundle, test.optional)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth := &authenticator{methods: []authMethod{certAuth{CertUser_CommonName}}}
+		server := httptest.NewUnstartedServer(auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+		server.TLS = config
+		server.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+
+		transport := &tls.Config{InsecureSkipVerify: true}
+		if test.cert != nil {
+			transport.Certificates = []tls.Certificate{{Certificate: [][]byte{test.cert.Raw}, PrivateKey: test.key}}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: transport}}
+		req, _ := http.NewRequest("GET", server.URL+"/static/dashboard.html", nil)
+		req.Header.Set("Accept", "text/html") // No login form, so no redirect
+		status := 0
+		if resp, err := c.Do(req); err == nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		server.Close()
+		if status != test.status {
+			t.Errorf("%v: got status %v, expected %v", test.name, status, test.status)
+		}
+	}
+
+	empty, cleanup := writeTempFile(t, "ca.pem", "not a certificate")
+	defer cleanup()
+	if _, err := ClientCertTLSConfig(empty, false); err == nil {
+		t.Error("CA bundle without certificates loaded")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/fsnotify.v1"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Editors tend to write files in several steps, so wait this long after the last
+// change event before reloading.
+const configReloadDelay = time.Millisecond * 250
+
+// Single server entry in the inventory file
+type serverConfig struct {
+	ID       string            `yaml:"id" json:"id,omitempty"`             // Stable identifier used in URLs. Defaults to a hash of the address.
+	Slug     string            `yaml:"slug" json:"slug,omitempty"`         // Readable alternative identifier. Defaults to one made from the name.
+	URL      string            `yaml:"url" json:"url"`                     // Server URL, e.g. tcp://host:5901 or unix:///path/to/socket
+	Name     string            `yaml:"name" json:"name,omitempty"`         // Display name
+	Username string            `yaml:"username" json:"username,omitempty"` // Overrides any username in the URL
+	Password string            `yaml:"password" json:"password,omitempty"` // Overrides any password in the URL
+	Tags     []string          `yaml:"tags" json:"tags,omitempty"`         // Free-form tags
+	Labels   map[string]string `yaml:"labels" json:"labels,omitempty"`     // Key-value metadata, for filtering
+	Groups   []string          `yaml:"groups" json:"groups,omitempty"`     // Groups the server belongs to
+	ReadOnly bool              `yaml:"readonly" json:"readonly,omitempty"` // Drop keyboard, mouse and clipboard input from viewers
+	Record   *bool             `yaml:"record" json:"record,omitempty"`     // Record sessions. Defaults to -recording.all.
+
+	Watches []watchRule `yaml:"watches" json:"watches,omitempty"` // Regions of the screen to watch for changes
+}
+
+// Static server inventory. JSON files are accepted since they are valid YAML.
+type inventoryConfig struct {
+	Servers []serverConfig `yaml:"servers" json:"servers"`
+}
+
+// Copy a config entry, so changes to the copy don't reach servers made from the
+// original
+func (this serverConfig) copy() serverConfig {
+	if this.Tags != nil {
+		this.Tags = append([]string{}, this.Tags...)
+	}
+	if this.Groups != nil {
+		this.Groups = append([]string{}, this.Groups...)
+	}
+	if this.Watches != nil {
+		this.Watches = append([]watchRule{}, this.Watches...)
+	}
+	if this.Labels != nil {
+		labels := make(map[string]string, len(this.Labels))
+		for k, v := range this.Labels {
+			labels[k] = v
+		}
+		this.Labels = labels
+	}
+	if this.Record != nil {
+		record := *this.Record
+		this.Record = &record
+	}
+	return this
+}
+
+// Convert a config entry into a server
+func (this serverConfig) Server() (vncServer, error) {
+	server, err := ParseVNCServer(this.URL)
+	if err != nil {
+		return vncServer{}, err
+	}
+
+	if this.ID != "" && !validIdentifier(this.ID) {
+		return vncServer{}, fmt.Errorf("invalid id: %v", this.ID)
+	}
+	if this.Slug != "" && !validIdentifier(this.Slug) {
+		return vncServer{}, fmt.Errorf("invalid slug: %v", this.Slug)
+	}
+	server.ID = this.ID
+	server.Slug = this.Slug
+
+	if this.Username != "" {
+		server.Username = this.Username
+	}
+	if this.Password != "" {
+		server.Password = this.Password
+	}
+	server.Name = this.Name
+	server.Tags = this.Tags
+	server.Labels = this.Labels
+	server.Groups = this.Groups
+	server.ReadOnly = this.ReadOnly
+	server.Record = *recordAll
+	if this.Record != nil {
+		server.Record = *this.Record
+	}
+	server.Watches = this.Watches
+	server.Source = Source_Config
+
+	return server, nil
+}
+
+// Load the list of servers from an inventory file
+func LoadServerConfig(filename string) ([]vncServer, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := inventoryConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+
+	servers := []vncServer{}
+	for idx, entry := range config.Servers {
+		if entry.URL == "" {
+			return nil, fmt.Errorf("server %v: no url specified", idx)
+		}
+		server, err := entry.Server()
+		if err != nil {
+			return nil, fmt.Errorf("server %v: %v", idx, err)
+		}
+		// Reference images are relative to the config file
+		if err := loadWatchRules(server.Watches, filepath.Dir(filename)); err != nil {
+			return nil, fmt.Errorf("server %v: %v", idx, err)
+		}
+		servers = append(servers, server)
+	}
+
+	return servers, nil
+}
+
+// Reload the server inventory into the manager, keeping the old one on error
+func reloadServerConfig(filename string, manager *serverManager) {
+	log.Infoln("Reloading server config:", filename)
+	servers, err := LoadServerConfig(filename)
+	if err != nil {
+		log.Errorln("Error reloading server config, keeping existing servers:", err)
+		return
+	}
+	manager.Sync(Source_Config, servers)
+}
+
+// Watch the server config file for changes and SIGHUP, and reload the inventory
+// when either happens.
+func watchServerConfig(filename string, manager *serverManager) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	// Watch the directory rather than the file, since editors commonly replace it.
+	var events <-chan fsnotify.Event
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorln("Could not watch server config, only SIGHUP will reload:", err)
+	} else {
+		defer watcher.Close()
+		if err := watcher.Add(filepath.Dir(filename)); err != nil {
+			log.Errorln("Could not watch server config, only SIGHUP will reload:", err)
+		} else {
+			events = watcher.Events
+		}
+	}
+
+	var reloadCh <-chan time.Time
+	for {
+		select {
+		case <-sigCh:
+			log.Infoln("Received SIGHUP")
+			reloadServerConfig(filename, manager)
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(e.Name) != filepath.Clean(filename) {
+				continue
+			}
+			if e.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 {
+				log.Debugln("Server config event:", e.String())
+				reloadCh = time.After(configReloadDelay)
+			}
+		case <-reloadCh:
+			reloadCh = nil
+			reloadServerConfig(filename, manager)
+		}
+	}
+}
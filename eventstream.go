@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// How often to send a comment on idle event streams so proxies keep them open
+const sseKeepaliveInterval = 15 * time.Second
+
+var ErrStreamingUnsupported = errors.New("response does not support streaming")
+
+// Writes server-sent events to a response
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// Start a server-sent event stream in response to a request
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// Parse the Last-Event-ID header sent by reconnecting clients. Returns false if
+// there isn't one.
+func lastEventID(r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	return id, err == nil
+}
+
+// Write an event. The ID is omitted if empty.
+func (this *eventStream) WriteEvent(id string, event string, data []byte) error {
+	buf := &bytes.Buffer{}
+	if id != "" {
+		fmt.Fprintf(buf, "id: %s\n", id)
+	}
+	fmt.Fprintf(buf, "event: %s\n", event)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+
+	if _, err := this.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	this.flusher.Flush()
+	return nil
+}
+
+// Write an event with a JSON body
+func (this *eventStream) WriteJSONEvent(id string, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return this.WriteEvent(id, event, data)
+}
+
+// Write a comment, which clients ignore, to keep idle connections open
+func (this *eventStream) WriteComment(comment string) error {
+	if _, err := fmt.Fprintf(this.w, ": %s\n\n", comment); err != nil {
+		return err
+	}
+	this.flusher.Flush()
+	return nil
+}
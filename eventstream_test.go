@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A ResponseWriter which can't stream
+type unflushableWriter struct {
+	http.ResponseWriter
+}
+
+func TestEventStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(stream *eventStream) error
+		expected string
+	}{
+		{"event", func(s *eventStream) error { return s.WriteEvent("7", "added", []byte("a")) },
+			"id: 7\nevent: added\ndata: a\n\n"},
+		{"no id", func(s *eventStream) error { return s.WriteEvent("", "removed", []byte("a")) },
+			"event: removed\ndata: a\n\n"},
+		{"no data", func(s *eventStream) error { return s.WriteEvent("3", "reset", nil) },
+			"id: 3\nevent: reset\ndata: \n\n"},
+		{"multiple lines", func(s *eventStream) error { return s.WriteEvent("", "text", []byte("one\ntwo\n")) },
+			"event: text\ndata: one\ndata: two\ndata: \n\n"},
+		{"json", func(s *eventStream) error {
+			return s.WriteJSONEvent("1", "status", map[string]string{"state": "up"})
+		}, "id: 1\nevent: status\ndata: {\"state\":\"up\"}\n\n"},
+		{"unencodable json", func(s *eventStream) error { return s.WriteJSONEvent("1", "status", func() {}) }, ""},
+		{"comment", func(s *eventStream) error { return s.WriteComment("keepalive") }, ": keepalive\n\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		stream, err := newEventStream(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = test.write(stream)
+		if (err != nil) != (test.expected == "") {
+			t.Errorf("%v: got error %v", test.name, err)
+		}
+		if body := w.Body.String(); body != test.expected {
+			t.Errorf("%v: wrote %q, expected %q", test.name, body, test.expected)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" || !w.Flushed {
+			t.Errorf("%v: got content type %v, flushed %v", test.name, contentType, w.Flushed)
+		}
+	}
+
+	if _, err := newEventStream(unflushableWriter{httptest.NewRecorder()}); err != ErrStreamingUnsupported {
+		t.Errorf("got error %v from unflushable response", err)
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	tests := []struct {
+		header string
+		id     uint64
+		ok     bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"42", 42, true},
+		{"-1", 0, false},
+		{"abc", 0, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/list/subscribe", nil)
+		if test.header != "" {
+			r.Header.Set("Last-Event-ID", test.header)
+		}
+		if id, ok := lastEventID(r); id != test.id || ok != test.ok {
+			t.Errorf("%q: got %v, %v, expected %v, %v", test.header, id, ok, test.id, test.ok)
+		}
+	}
+}
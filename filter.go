@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Selects servers by group and label, from the query string of list and
+// subscription requests
+type serverFilter struct {
+	Groups []string                    // Server must be in every group
+	Labels map[string]string           // Server must have every label. An empty value only requires the key.
+	Allow  func(server vncServer) bool // Further restricts the selection, e.g. to what the user may see. May be nil.
+}
+
+// Parse group=name and label=key=value (or label=key) parameters
+func parseServerFilter(query url.Values) (serverFilter, error) {
+	filter := serverFilter{Labels: make(map[string]string)}
+	for _, group := range query["group"] {
+		if group == "" {
+			return serverFilter{}, errors.New("empty group")
+		}
+		filter.Groups = append(filter.Groups, group)
+	}
+	for _, label := range query["label"] {
+		kv := strings.SplitN(label, "=", 2)
+		if kv[0] == "" {
+			return serverFilter{}, fmt.Errorf("invalid label: %v", label)
+		}
+		if len(kv) == 1 {
+			filter.Labels[kv[0]] = ""
+		} else {
+			filter.Labels[kv[0]] = kv[1]
+		}
+	}
+	return filter, nil
+}
+
+// Check whether the filter selects nothing in particular
+func (this serverFilter) Empty() bool {
+	return len(this.Groups) == 0 && len(this.Labels) == 0 && this.Allow == nil
+}
+
+func (this serverFilter) Match(server vncServer) bool {
+	if this.Allow != nil && !this.Allow(server) {
+		return false
+	}
+	for _, group := range this.Groups {
+		found := false
+		for _, g := range server.Groups {
+			if g == group {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range this.Labels {
+		value, ok := server.Labels[k]
+		if !ok || (v != "" && value != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rewrite an event for a subscriber which only sees the servers the filter
+// selects. Servers updated into or out of the selection appear to be added or
+// removed. Returns false if the subscriber shouldn't see the event at all.
+func (this serverFilter) Event(e ManagerAction) (ManagerAction, bool) {
+	if this.Empty() {
+		return e, true
+	}
+	matched := this.Match(e.Server)
+	if e.Action != Manager_UpdatedServer {
+		return e, matched
+	}
+
+	update, _ := e.Detail.(updateEvent)
+	previous := this.Match(update.Previous)
+	switch {
+	case matched && !previous:
+		e.Action = Manager_AddedServer
+		e.Detail = nil
+	case !matched && previous:
+		e.Action = Manager_RemovedServer
+		e.Detail = nil
+		e.Server = update.Previous
+	case !matched:
+		return e, false
+	}
+	return e, true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseServerFilter(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected serverFilter
+		err      string
+	}{
+		{"", serverFilter{Labels: map[string]string{}}, ""},
+		{"group=lab&group=linux", serverFilter{Groups: []string{"lab", "linux"}, Labels: map[string]string{}}, ""},
+		{"label=site=hq&label=rack", serverFilter{Labels: map[string]string{"site": "hq", "rack": ""}}, ""},
+		{"label=note=a=b", serverFilter{Labels: map[string]string{"note": "a=b"}}, ""},
+		{"label=site=", serverFilter{Labels: map[string]string{"site": ""}}, ""},
+		{"group=", serverFilter{}, "empty group"},
+		{"label==hq", serverFilter{}, "invalid label: =hq"},
+		{"label=", serverFilter{}, "invalid label"},
+	}
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter, err := parseServerFilter(query)
+		checkErrText(t, test.query, err, test.err)
+		if test.err == "" && !reflect.DeepEqual(filter, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.query, filter, test.expected)
+		}
+	}
+}
+
+func TestServerFilterMatch(t *testing.T) {
+	server := vncServer{
+		NetType: "tcp",
+		Address: "console:5900",
+		Groups:  []string{"lab", "linux"},
+		Labels:  map[string]string{"site": "hq", "rack": "3"},
+	}
+	tests := []struct {
+		name    string
+		filter  serverFilter
+		matched bool
+	}{
+		{"empty", serverFilter{}, true},
+		{"group", serverFilter{Groups: []string{"lab"}}, true},
+		{"every group", serverFilter{Groups: []string{"linux", "lab"}}, true},
+		{"missing group", serverFilter{Groups: []string{"lab", "windows"}}, false},
+		{"label", serverFilter{Labels: map[string]string{"site": "hq"}}, true},
+		{"label key", serverFilter{Labels: map[string]string{"rack": ""}}, true},
+		{"label value", serverFilter{Labels: map[string]string{"site": "branch"}}, false},
+		{"missing label", serverFilter{Labels: map[string]string{"floor": ""}}, false},
+		{"group and label", serverFilter{Groups: []string{"lab"}, Labels: map[string]string{"site": "hq"}}, true},
+		{"group but not label", serverFilter{Groups: []string{"lab"}, Labels: map[string]string{"site": "branch"}}, false},
+	}
+	for _, test := range tests {
+		if matched := test.filter.Match(server); matched != test.matched {
+			t.Errorf("%v: got %v, expected %v", test.name, matched, test.matched)
+		}
+	}
+	if !(serverFilter{}).Empty() || (serverFilter{Groups: []string{"lab"}}).Empty() {
+		t.Error("Empty is wrong")
+	}
+}
+
+func TestServerFilterEvent(t *testing.T) {
+	lab := vncServer{NetType: "tcp", Address: "a:5900", Groups: []string{"lab"}}
+	office := vncServer{NetType: "tcp", Address: "a:5900", Groups: []string{"office"}}
+	renamed := lab
+	renamed.Name = "Renamed"
+	filter := serverFilter{Groups: []string{"lab"}}
+
+	tests := []struct {
+		name   string
+		filter serverFilter
+		event  ManagerAction
+		ok     bool
+		action ManagerActionType
+		server vncServer
+	}{
+		{"unfiltered", serverFilter{}, ManagerAction{Action: Manager_UpdatedServer, Server: office, Detail: updateEvent{lab}},
+			true, Manager_UpdatedServer, office},
+		{"added", filter, ManagerAction{Action: Manager_AddedServer, Server: lab}, true, Manager_AddedServer, lab},
+		{"added elsewhere", filter, ManagerAction{Action: Manager_AddedServer, Server: office}, false, "", vncServer{}},
+		{"stale", filter, ManagerAction{Action: Manager_StaleServer, Server: lab}, true, Manager_StaleServer, lab},
+		{"updated", filter, ManagerAction{Action: Manager_UpdatedServer, Server: renamed, Detail: updateEvent{lab}},
+			true, Manager_UpdatedServer, renamed},
+		{"updated in", filter, ManagerAction{Action: Manager_UpdatedServer, Server: lab, Detail: updateEvent{office}},
+			true, Manager_AddedServer, lab},
+		{"updated out", filter, ManagerAction{Action: Manager_UpdatedServer, Server: office, Detail: updateEvent{lab}},
+			true, Manager_RemovedServer, lab},
+		{"updated elsewhere", filter, ManagerAction{Action: Manager_UpdatedServer, Server: office, Detail: updateEvent{office}},
+			false, "", vncServer{}},
+	}
+	for _, test := range tests {
+		e, ok := test.filter.Event(test.event)
+		if ok != test.ok {
+			t.Errorf("%v: got %v, expected %v", test.name, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if e.Action != test.action || !reflect.DeepEqual(e.Server, test.server) {
+			t.Errorf("%v: got %v of %+v, expected %v of %+v", test.name, e.Action, e.Server, test.action, test.server)
+		}
+		if e.Action != Manager_UpdatedServer && e.Detail != nil {
+			t.Errorf("%v: got detail %+v", test.name, e.Detail)
+		}
+	}
+}
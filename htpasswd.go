@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/prometheus/common/log"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a password which checked out is remembered, so basic auth doesn't pay
+// for a bcrypt comparison on every request
+const passwordCacheTime = time.Minute
+
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// Users and password hashes from an Apache htpasswd file. bcrypt, Apache MD5
+// ($apr1$) and SHA1 ({SHA}) hashes are supported. The file is reloaded when it
+// changes.
+type htpasswdFile struct {
+	filename string
+
+	users   map[string]string // Hash by user name
+	modTime time.Time
+	checked map[[sha256.Size]byte]time.Time // Recently verified credentials, until when
+	mtx     sync.Mutex
+}
+
+func NewHtpasswdFile(filename string) (*htpasswdFile, error) {
+	this := &htpasswdFile{filename: filename}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if err := this.reload(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+// Read the file if it changed since it was last read. Must be called with mtx
+// held.
+func (this *htpasswdFile) reload() error {
+	fi, err := os.Stat(this.filename)
+	if err != nil {
+		return err
+	}
+	if this.users != nil && fi.ModTime().Equal(this.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(this.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		users[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	log.With("file", this.filename).With("users", len(users)).Infoln("Loaded htpasswd file")
+	this.users = users
+	this.modTime = fi.ModTime()
+	this.checked = make(map[[sha256.Size]byte]time.Time)
+	return nil
+}
+
+// Check a user's password
+func (this *htpasswdFile) Verify(user string, password string) bool {
+	key := sha256.Sum256([]byte(user + ":" + password))
+	hash, cached, ok := this.lookup(user, key)
+	if cached || !ok {
+		return cached
+	}
+
+	// Hashes are slow to check on purpose, so other logins aren't made to wait
+	match, err := checkPasswordHash(hash, password)
+	if err != nil {
+		log.With("user", user).Warnln("Can't check password:", err)
+		return false
+	}
+	if match {
+		this.mtx.Lock()
+		// Unless the password changed while it was being checked
+		if this.users[user] == hash {
+			this.checked[key] = time.Now().Add(passwordCacheTime)
+		}
+		this.mtx.Unlock()
+	}
+	return match
+}
+
+// Find a user's hash, and whether their credentials were verified recently
+func (this *htpasswdFile) lookup(user string, key [sha256.Size]byte) (string, bool, bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if err := this.reload(); err != nil {
+		log.Errorln("Error reloading htpasswd file, keeping existing users:", err)
+	}
+	if until, ok := this.checked[key]; ok && time.Now().Before(until) {
+		return "", true, true
+	}
+	hash, ok := this.users[user]
+	return hash, false, ok
+}
+
+// Check a user is still in the file
+func (this *htpasswdFile) Exists(user string) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if err := this.reload(); err != nil {
+		log.Errorln("Error reloading htpasswd file, keeping existing users:", err)
+	}
+	_, ok := this.users[user]
+	return ok
+}
+
+// Compare a password against an htpasswd hash
+func checkPasswordHash(hash string, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.SplitN(strings.TrimPrefix(hash, "$apr1$"), "$", 2)[0]
+		return subtle.ConstantTimeCompare([]byte(apr1Hash(password, salt)), []byte(hash)) == 1, nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1, nil
+	}
+	return false, ErrUnsupportedHash
+}
+
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Apache's variant of the MD5 crypt algorithm
+func apr1Hash(password string, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.Sum([]byte(password + salt + password))
+	ctx := bytes.NewBufferString(password + magic + salt)
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			ctx.Write(alt[:])
+		} else {
+			ctx.Write(alt[:i])
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.WriteByte(0)
+		} else {
+			ctx.WriteByte(pw[0])
+		}
+	}
+	final := md5.Sum(ctx.Bytes())
+
+	for i := 0; i < 1000; i++ {
+		round := &bytes.Buffer{}
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(final[:])
+		}
+		if i%3 != 0 {
+			round.WriteString(salt)
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 != 0 {
+			round.Write(final[:])
+		} else {
+			round.Write(pw)
+		}
+		final = md5.Sum(round.Bytes())
+	}
+
+	out := []byte(magic + salt + "$")
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			out = append(out, apr1Alphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(uint32(final[0])<<16|uint32(final[6])<<8|uint32(final[12]), 4)
+	encode(uint32(final[1])<<16|uint32(final[7])<<8|uint32(final[13]), 4)
+	encode(uint32(final[2])<<16|uint32(final[8])<<8|uint32(final[14]), 4)
+	encode(uint32(final[3])<<16|uint32(final[9])<<8|uint32(final[15]), 4)
+	encode(uint32(final[4])<<16|uint32(final[10])<<8|uint32(final[5]), 4)
+	encode(uint32(final[11]), 2)
+	return string(out)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckPasswordHash(t *testing.T) {
+	tests := []struct {
+		hash     string
+		password string
+		match    bool
+		err      error
+	}{
+		// bcrypt, from the OpenBSD test vectors, in each variant's prefix
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, nil},
+		{"$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, nil},
+		{"$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, nil},
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.VGOzA784oUp/Z0DY336zx7pLYAy0lwK", "U*U*", true, nil},
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U*", false, nil},
+		{"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRG", "U*U", false, nil},
+
+		// Apache MD5, from openssl passwd -apr1
+		{"$apr1$abcdefgh$FBwExRW4dCc8aL.OvjpIE1", "password", true, nil},
+		{"$apr1$abcdefgh$FBwExRW4dCc8aL.OvjpIE1", "Password", false, nil},
+		{"$apr1$xyz$WwqNnbd18lBlDn9Zbto0B0", "pässwörd with spaces", true, nil},
+		{"$apr1$12345678$sHuPAw7VA9xjRbJz7zKV7/", "", true, nil},
+		{"$apr1$abcdefgh$Eqv4oIyMsS.tjfvQCJYY1/", "a much longer password than sixteen bytes", true, nil},
+		{"$apr1$abcdefgh$", "password", false, nil},
+
+		// SHA1
+		{"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", "password", true, nil},
+		{"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", "password ", false, nil},
+
+		// Crypt and plain text aren't supported
+		{"rl2rCHkX4k2CE", "password", false, ErrUnsupportedHash},
+		{"password", "password", false, ErrUnsupportedHash},
+		{"", "", false, ErrUnsupportedHash},
+	}
+	for _, test := range tests {
+		match, err := checkPasswordHash(test.hash, test.password)
+		if err != test.err {
+			t.Errorf("%v: got error %v, expected %v", test.hash, err, test.err)
+		}
+		if match != test.match {
+			t.Errorf("%v with %q: got %v, expected %v", test.hash, test.password, match, test.match)
+		}
+	}
+}
+
+func TestHtpasswdFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "htpasswd")
+	contents := `# Operators
+alice:$apr1$abcdefgh$FBwExRW4dCc8aL.OvjpIE1
+
+  bob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=
+malformed line
+carol:$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW
+dave:plaintext
+`
+	if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passwords, err := NewHtpasswdFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user     string
+		password string
+		exists   bool
+		verified bool
+	}{
+		{"alice", "password", true, true},
+		{"alice", "wrong", true, false},
+		{"bob", "password", true, true},
+		{"carol", "U*U", true, true},
+		{"carol", "U*U", true, true}, // Remembered
+		{"dave", "plaintext", true, false},
+		{"malformed line", "", false, false},
+		{"# Operators", "", false, false},
+		{"erin", "password", false, false},
+		{"", "", false, false},
+	}
+	for _, test := range tests {
+		if exists := passwords.Exists(test.user); exists != test.exists {
+			t.Errorf("%q: exists %v, expected %v", test.user, exists, test.exists)
+		}
+		if verified := passwords.Verify(test.user, test.password); verified != test.verified {
+			t.Errorf("%q with %q: verified %v, expected %v", test.user, test.password, verified, test.verified)
+		}
+	}
+
+	// Changes to the file are picked up
+	if err := ioutil.WriteFile(filename, []byte("erin:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !passwords.Verify("erin", "password") || passwords.Exists("carol") {
+		t.Error("changed file not reloaded")
+	}
+
+	// Users are kept while the file can't be read
+	os.Remove(filename)
+	if !passwords.Verify("erin", "password") {
+		t.Error("users lost with the file")
+	}
+
+	if _, err := NewHtpasswdFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
+
+func TestHtpasswdFileChecksUnlocked(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename, cleanup := writeTempFile(t, "htpasswd", "alice:"+string(hash)+"\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n")
+	defer cleanup()
+	passwords, err := NewHtpasswdFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other users get in while a slow hash is being checked
+	checked := make(chan bool, 1)
+	go func() { checked <- passwords.Verify("alice", "wrong") }()
+	time.Sleep(10 * time.Millisecond)
+	if !passwords.Verify("bob", "password") || !passwords.Exists("alice") {
+		t.Error("users missing")
+	}
+	select {
+	case <-checked:
+		t.Error("waited for another user's password to be checked")
+	default:
+		if <-checked {
+			t.Error("wrong password accepted")
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long a kiosk shows each page unless told otherwise
+const kioskDefaultDwell = time.Second * 30
+
+// How often kiosks check whether to move on, and whether their page went away
+const kioskCheckInterval = time.Second
+
+var ErrKioskNotFound = errors.New("kiosk not found")
+
+// A page of a kiosk rotation: a single server or a saved layout
+type kioskPage struct {
+	Server string        `yaml:"server"` // Short name, ID or slug of a server
+	Layout string        `yaml:"layout"` // Name of a saved layout
+	Dwell  time.Duration `yaml:"dwell"`  // Overrides the kiosk's dwell time
+}
+
+// A kiosk cycles through its pages, then through the servers in its group
+type kioskConfig struct {
+	Name  string        `yaml:"name"`
+	Dwell time.Duration `yaml:"dwell"` // How long to show each page
+	Group string        `yaml:"group"` // Servers to rotate through. Every server if there are no pages either.
+	Pages []kioskPage   `yaml:"pages"`
+}
+
+type kiosksConfig struct {
+	Kiosks []kioskConfig `yaml:"kiosks"`
+}
+
+// Load kiosk definitions from a YAML or JSON file
+func LoadKioskConfig(filename string) ([]kioskConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := kiosksConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{})
+	for idx := range config.Kiosks {
+		kiosk := &config.Kiosks[idx]
+		if !validIdentifier(kiosk.Name) {
+			return nil, fmt.Errorf("kiosk %v: invalid name: %v", idx, kiosk.Name)
+		}
+		if _, ok := names[kiosk.Name]; ok {
+			return nil, fmt.Errorf("kiosk %v: duplicate name", kiosk.Name)
+		}
+		names[kiosk.Name] = struct{}{}
+		if kiosk.Dwell == 0 {
+			kiosk.Dwell = kioskDefaultDwell
+		}
+		for i, page := range kiosk.Pages {
+			if (page.Server == "") == (page.Layout == "") {
+				return nil, fmt.Errorf("kiosk %v: page %v: exactly one of server or layout must be given", kiosk.Name, i)
+			}
+		}
+	}
+	return config.Kiosks, nil
+}
+
+// What a kiosk is showing
+type kioskView struct {
+	Server string    `json:"server,omitempty"` // Short name of the server
+	Name   string    `json:"name,omitempty"`   // Display name of the server
+	Layout string    `json:"layout,omitempty"` // Name of the layout
+	Until  time.Time `json:"until"`            // When the kiosk moves on
+	Remote bool      `json:"remote"`           // Switched to through the API rather than by the rotation
+}
+
+// Same page, ignoring timing
+func (this kioskView) Same(other kioskView) bool {
+	return this.Server == other.Server && this.Layout == other.Layout
+}
+
+// Rotation state of a kiosk
+type kiosk struct {
+	config  kioskConfig
+	view    kioskView
+	index   int // Position of the current page in the rotation
+	watched map[*kioskSubscriber]struct{}
+}
+
+// State of a kiosk as returned by the API
+type kioskStatus struct {
+	Name  string    `json:"name"`
+	Dwell float64   `json:"dwell"` // Seconds
+	Group string    `json:"group,omitempty"`
+	View  kioskView `json:"view"`
+}
+
+// Woken whenever a kiosk's view changes
+type kioskSubscriber struct {
+	notify chan struct{}
+}
+
+func (this *kioskSubscriber) Notify() <-chan struct{} {
+	return this.notify
+}
+
+// Moves kiosks through their pages on schedule. Pages are worked out afresh on
+// every check, so servers and layouts coming and going are picked up and a
+// kiosk showing one which went away moves on straight away.
+type kioskRotator struct {
+	manager *serverManager
+	layouts *layoutStore
+
+	kiosks map[string]*kiosk
+	mtx    sync.Mutex
+}
+
+func NewKioskRotator(manager *serverManager, layouts *layoutStore, configs []kioskConfig) *kioskRotator {
+	kiosks := make(map[string]*kiosk)
+	for _, config := range configs {
+		kiosks[config.Name] = &kiosk{
+			config:  config,
+			index:   -1,
+			watched: make(map[*kioskSubscriber]struct{}),
+		}
+	}
+	return &kioskRotator{
+		manager: manager,
+		layouts: layouts,
+		kiosks:  kiosks,
+	}
+}
+
+// Every kiosk, sorted by name
+func (this *kioskRotator) List() []kioskStatus {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	names := []string{}
+	for name := range this.kiosks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r := []kioskStatus{}
+	for _, name := range names {
+		r = append(r, this.kiosks[name].status())
+	}
+	return r
+}
+
+func (this *kioskRotator) Get(name string) (kioskStatus, bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	k, ok := this.kiosks[name]
+	if !ok {
+		return kioskStatus{}, false
+	}
+	return k.status(), true
+}
+
+func (this *kiosk) status() kioskStatus {
+	return kioskStatus{
+		Name:  this.config.Name,
+		Dwell: this.config.Dwell.Seconds(),
+		Group: this.config.Group,
+		View:  this.view,
+	}
+}
+
+// Watch a kiosk's view. Returns false if there's no such kiosk.
+func (this *kioskRotator) Subscribe(name string) (*kioskSubscriber, bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	k, ok := this.kiosks[name]
+	if !ok {
+		return nil, false
+	}
+	sub := &kioskSubscriber{notify: make(chan struct{}, 1)}
+	k.watched[sub] = struct{}{}
+	return sub, true
+}
+
+func (this *kioskRotator) Unsubscribe(name string, sub *kioskSubscriber) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if k, ok := this.kiosks[name]; ok {
+		delete(k.watched, sub)
+	}
+}
+
+// Change a kiosk's view and wake its subscribers. Must be called with mtx held.
+func (this *kiosk) show(view kioskView) {
+	this.view = view
+	for sub := range this.watched {
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Show a server or layout on a kiosk for a while, after which the rotation
+// carries on from there. A zero hold uses the kiosk's dwell time.
+func (this *kioskRotator) Show(name string, page kioskPage, hold time.Duration) (kioskView, error) {
+	view := kioskView{Remote: true}
+	if page.Server != "" {
+		server, ok := this.manager.Get(page.Server)
+		if !ok {
+			return kioskView{}, ErrServerNotFound
+		}
+		view.Server = server.Short()
+		view.Name = server.Name
+	} else if page.Layout != "" {
+		if _, ok := this.layouts.Get(page.Layout); !ok {
+			return kioskView{}, ErrLayoutNotFound
+		}
+		view.Layout = page.Layout
+	} else {
+		return kioskView{}, errors.New("no server or layout specified")
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	k, ok := this.kiosks[name]
+	if !ok {
+		return kioskView{}, ErrKioskNotFound
+	}
+	if hold == 0 {
+		hold = k.config.Dwell
+	}
+	view.Until = time.Now().Add(hold)
+	k.show(view)
+	log.With("kiosk", name).With("server", view.Server).With("layout", view.Layout).Infoln("Kiosk switched remotely")
+	return view, nil
+}
+
+// Work out the pages of a kiosk's rotation as things are now, with their dwell
+// times
+func (this *kioskRotator) pages(config kioskConfig, servers map[string]vncServer) ([]kioskView, []time.Duration) {
+	views := []kioskView{}
+	dwells := []time.Duration{}
+	seen := make(map[string]struct{})
+
+	for _, page := range config.Pages {
+		dwell := page.Dwell
+		if dwell == 0 {
+			dwell = config.Dwell
+		}
+		if page.Layout != "" {
+			if _, ok := this.layouts.Get(page.Layout); ok {
+				views = append(views, kioskView{Layout: page.Layout})
+				dwells = append(dwells, dwell)
+			}
+		} else if server, ok := this.manager.Get(page.Server); ok {
+			views = append(views, kioskView{Server: server.Short(), Name: server.Name})
+			dwells = append(dwells, dwell)
+			seen[server.Short()] = struct{}{}
+		}
+	}
+
+	if config.Group == "" && len(config.Pages) != 0 {
+		return views, dwells
+	}
+	filter := serverFilter{}
+	if config.Group != "" {
+		filter.Groups = []string{config.Group}
+	}
+	shortnames := []string{}
+	for k, server := range servers {
+		if _, ok := seen[k]; !ok && filter.Match(server) {
+			shortnames = append(shortnames, k)
+		}
+	}
+	// By name, so the order is predictable
+	sort.Sort(byServerName{shortnames, servers})
+	for _, k := range shortnames {
+		views = append(views, kioskView{Server: k, Name: servers[k].Name})
+		dwells = append(dwells, config.Dwell)
+	}
+	return views, dwells
+}
+
+// Sorts short names by the names of their servers
+type byServerName struct {
+	shortnames []string
+	servers    map[string]vncServer
+}
+
+func (this byServerName) Len() int { return len(this.shortnames) }
+func (this byServerName) Swap(i, j int) {
+	this.shortnames[i], this.shortnames[j] = this.shortnames[j], this.shortnames[i]
+}
+func (this byServerName) Less(i, j int) bool {
+	a, b := this.servers[this.shortnames[i]], this.servers[this.shortnames[j]]
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return this.shortnames[i] < this.shortnames[j]
+}
+
+// Move a kiosk on if its time is up or its page went away. Must be called with
+// mtx held.
+func (this *kioskRotator) advance(k *kiosk, servers map[string]vncServer, now time.Time) {
+	views, dwells := this.pages(k.config, servers)
+	current := -1
+	for i, view := range views {
+		if view.Same(k.view) {
+			current = i
+			break
+		}
+	}
+	if current != -1 {
+		k.index = current
+	}
+
+	// Pages switched to remotely needn't be part of the rotation
+	if now.Before(k.view.Until) && (current != -1 || (k.view.Remote && this.exists(k.view, servers))) {
+		return
+	}
+	if len(views) == 0 {
+		if k.view != (kioskView{}) {
+			k.index = -1
+			k.show(kioskView{})
+		}
+		return
+	}
+
+	next := k.index + 1
+	if current == -1 && !k.view.Remote && k.index >= 0 {
+		// The page went away, so the one after it has taken its place
+		next = k.index
+	}
+	next %= len(views)
+	k.index = next
+	view := views[next]
+	view.Until = now.Add(dwells[next])
+	k.show(view)
+}
+
+// Check the server or layout of a view still exists
+func (this *kioskRotator) exists(view kioskView, servers map[string]vncServer) bool {
+	if view.Layout != "" {
+		_, ok := this.layouts.Get(view.Layout)
+		return ok
+	}
+	_, ok := servers[view.Server]
+	return ok
+}
+
+// Keep every kiosk moving
+func (this *kioskRotator) Run() {
+	for {
+		servers := this.manager.List()
+		now := time.Now()
+
+		this.mtx.Lock()
+		for _, k := range this.kiosks {
+			this.advance(k, servers, now)
+		}
+		this.mtx.Unlock()
+
+		time.Sleep(kioskCheckInterval)
+	}
+}
+
+// Map kiosk errors onto HTTP responses
+func writeKioskError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrKioskNotFound, ErrServerNotFound, ErrLayoutNotFound:
+		http.Error(w, err.Error(), 404)
+	default:
+		http.Error(w, err.Error(), 400)
+	}
+}
+
+// Hide the server a kiosk shows from users who may not list it
+func visibleKioskView(r *http.Request, manager *serverManager, access *accessControl, view kioskView) kioskView {
+	if view.Server != "" && !access.AllowedName(r, manager, view.Server, Perm_List) {
+		view.Server = ""
+		view.Name = ""
+	}
+	return view
+}
+
+func kioskListHandler(kiosks *kioskRotator, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		list := kiosks.List()
+		for i := range list {
+			list[i].View = visibleKioskView(r, kiosks.manager, access, list[i].View)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func kioskGetHandler(kiosks *kioskRotator, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		status, ok := kiosks.Get(ps.ByName("kiosk"))
+		if !ok {
+			writeKioskError(w, ErrKioskNotFound)
+			return
+		}
+		status.View = visibleKioskView(r, kiosks.manager, access, status.View)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// Body of requests to switch a kiosk
+type kioskShowRequest struct {
+	Server string  `json:"server"`
+	Layout string  `json:"layout"`
+	Hold   float64 `json:"hold"` // Seconds to show it for. Defaults to the kiosk's dwell time.
+}
+
+// Switch a kiosk to a server or layout
+func kioskShowHandler(kiosks *kioskRotator) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		req := kioskShowRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), 400)
+			return
+		}
+		if req.Hold < 0 {
+			http.Error(w, "hold must not be negative", 400)
+			return
+		}
+
+		hold := time.Duration(req.Hold * float64(time.Second))
+		view, err := kiosks.Show(ps.ByName("kiosk"), kioskPage{Server: req.Server, Layout: req.Layout}, hold)
+		if err != nil {
+			writeKioskError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(view)
+	}
+}
+
+// Stream show commands to a kiosk page. The current view is sent on connecting.
+func kioskSubscribeHandler(kiosks *kioskRotator, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		name := ps.ByName("kiosk")
+		sub, ok := kiosks.Subscribe(name)
+		if !ok {
+			writeKioskError(w, ErrKioskNotFound)
+			return
+		}
+		defer kiosks.Unsubscribe(name, sub)
+
+		stream, err := newEventStream(w)
+		if err != nil {
+			log.Errorln("SSE upgrade failed:", err)
+			http.Error(w, "Failed to upgrade connection", 500)
+			return
+		}
+
+		keepalive := time.NewTicker(sseKeepaliveInterval)
+		defer keepalive.Stop()
+		for {
+			status, ok := kiosks.Get(name)
+			if !ok {
+				return
+			}
+			if err := stream.WriteJSONEvent("", "show", visibleKioskView(r, kiosks.manager, access, status.View)); err != nil {
+				return
+			}
+
+		wait:
+			for {
+				select {
+				case <-sub.Notify():
+					break wait
+				case <-keepalive.C:
+					if err := stream.WriteComment("keepalive"); err != nil {
+						return
+					}
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// Serve the kiosk page. The page follows its commands itself.
+func kioskPageHandler(kiosks *kioskRotator) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := kiosks.Get(ps.ByName("kiosk")); !ok {
+			http.Error(w, "Kiosk not found", 404)
+			return
+		}
+		b, err := Asset("kiosk.html")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(b)
+	}
+}
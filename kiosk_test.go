@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadKioskConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected []kioskConfig
+		err      string
+	}{
+		{"empty", "kiosks: []", []kioskConfig{}, ""},
+		{
+			"kiosks", `
+kiosks:
+  - name: lobby
+    group: lobby
+  - name: noc
+    dwell: 10s
+    pages:
+      - layout: wall
+        dwell: 1m
+      - server: console
+`,
+			[]kioskConfig{
+				{Name: "lobby", Dwell: kioskDefaultDwell, Group: "lobby"},
+				{Name: "noc", Dwell: 10 * time.Second, Pages: []kioskPage{{Layout: "wall", Dwell: time.Minute}, {Server: "console"}}},
+			},
+			"",
+		},
+		{"no name", "kiosks: [{group: lobby}]", nil, "kiosk 0: invalid name"},
+		{"invalid name", "kiosks: [{name: 'a b'}]", nil, "kiosk 0: invalid name: a b"},
+		{"duplicate", "kiosks: [{name: a}, {name: a}]", nil, "kiosk a: duplicate name"},
+		{"empty page", "kiosks: [{name: a, pages: [{dwell: 1s}]}]", nil, "kiosk a: page 0: exactly one"},
+		{"both", "kiosks: [{name: a, pages: [{server: s, layout: l}]}]", nil, "kiosk a: page 0: exactly one"},
+		{"invalid dwell", "kiosks: [{name: a, dwell: soon}]", nil, "yaml"},
+	}
+	for _, test := range tests {
+		filename, cleanup := writeTempFile(t, "kiosks.yml", test.contents)
+		kiosks, err := LoadKioskConfig(filename)
+		cleanup()
+		checkErrText(t, test.name, err, test.err)
+		if test.err == "" && !reflect.DeepEqual(kiosks, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, kiosks, test.expected)
+		}
+	}
+}
+
+// A manager and layout store with a few servers and layouts
+func newKioskFixtures(t *testing.T) (*serverManager, *layoutStore, func()) {
+	manager := NewServerManager()
+	for _, server := range []vncServer{
+		{ID: "a", NetType: "tcp", Address: "a:5900", Name: "Alpha", Groups: []string{"lobby"}},
+		{ID: "b", NetType: "tcp", Address: "b:5900", Name: "Bravo", Groups: []string{"lobby"}},
+		{ID: "c", NetType: "tcp", Address: "c:5900", Name: "Charlie"},
+	} {
+		manager.Add(server)
+	}
+	filename, cleanup := writeTempFile(t, "layouts.json", `[{"name": "wall", "columns": 1, "rows": 1}]`)
+	layouts := NewLayoutStore(filename)
+	if err := layouts.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return manager, layouts, cleanup
+}
+
+// Describe a kiosk view as the server or layout it shows
+func viewName(view kioskView) string {
+	if view.Layout != "" {
+		return "layout " + view.Layout
+	}
+	return view.Server
+}
+
+func TestKioskPages(t *testing.T) {
+	manager, layouts, cleanup := newKioskFixtures(t)
+	defer cleanup()
+	rotator := NewKioskRotator(manager, layouts, nil)
+
+	tests := []struct {
+		name     string
+		config   kioskConfig
+		expected []string
+		dwells   []time.Duration
+	}{
+		{"everything", kioskConfig{Dwell: time.Second}, []string{"a", "b", "c"}, []time.Duration{time.Second, time.Second, time.Second}},
+		{"group", kioskConfig{Dwell: time.Second, Group: "lobby"}, []string{"a", "b"}, []time.Duration{time.Second, time.Second}},
+		{"pages", kioskConfig{Dwell: time.Second, Pages: []kioskPage{{Layout: "wall", Dwell: time.Minute}, {Server: "charlie"}}},
+			[]string{"layout wall", "c"}, []time.Duration{time.Minute, time.Second}},
+		{"missing pages", kioskConfig{Dwell: time.Second, Pages: []kioskPage{{Layout: "desk"}, {Server: "missing"}, {Server: "b"}}},
+			[]string{"b"}, []time.Duration{time.Second}},
+		{"pages then group", kioskConfig{Dwell: time.Second, Group: "lobby", Pages: []kioskPage{{Server: "b"}, {Layout: "wall"}}},
+			[]string{"b", "layout wall", "a"}, []time.Duration{time.Second, time.Second, time.Second}},
+		{"empty group", kioskConfig{Dwell: time.Second, Group: "office"}, []string{}, []time.Duration{}},
+	}
+	for _, test := range tests {
+		views, dwells := rotator.pages(test.config, manager.List())
+		names := []string{}
+		for _, view := range views {
+			names = append(names, viewName(view))
+		}
+		if !reflect.DeepEqual(names, test.expected) || !reflect.DeepEqual(dwells, test.dwells) {
+			t.Errorf("%v: got %v %v, expected %v %v", test.name, names, dwells, test.expected, test.dwells)
+		}
+	}
+}
+
+func TestKioskRotation(t *testing.T) {
+	manager, layouts, cleanup := newKioskFixtures(t)
+	defer cleanup()
+	config := kioskConfig{Name: "lobby", Dwell: 10 * time.Second, Pages: []kioskPage{{Layout: "wall", Dwell: 20 * time.Second}, {Server: "a"}, {Server: "b"}}}
+	rotator := NewKioskRotator(manager, layouts, []kioskConfig{config})
+	sub, _ := rotator.Subscribe("lobby")
+	// Remote switches are timed from now, so they happen at 42s
+	start := time.Now().Add(-42 * time.Second)
+
+	tests := []struct {
+		name     string
+		at       time.Duration // After the start
+		change   func()
+		expected string
+		remote   bool
+	}{
+		{"first page", 0, nil, "layout wall", false},
+		{"still showing", 19 * time.Second, nil, "layout wall", false},
+		{"page dwell", 20 * time.Second, nil, "a", false},
+		{"kiosk dwell", 30 * time.Second, nil, "b", false},
+		{"wraps around", 40 * time.Second, nil, "layout wall", false},
+		{"page went away", 41 * time.Second, func() { layouts.Remove("wall") }, "a", false},
+		{"remote switch", 42 * time.Second, func() {
+			if _, err := rotator.Show("lobby", kioskPage{Server: "c"}, 0); err != nil {
+				t.Fatal(err)
+			}
+		}, "c", true},
+		// Remote views are held for the dwell time from when they were switched to
+		{"remote held", 42 * time.Second, nil, "c", true},
+		{"remote expired", 60 * time.Second, nil, "b", false},
+		{"remote page in rotation", 61 * time.Second, func() {
+			if _, err := rotator.Show("lobby", kioskPage{Server: "a"}, 25*time.Second); err != nil {
+				t.Fatal(err)
+			}
+		}, "a", true},
+		{"carries on after remote page", 70 * time.Second, nil, "b", false},
+		{"nothing left", 71 * time.Second, func() {
+			manager.RemoveByAddress("", "a:5900")
+			manager.RemoveByAddress("", "b:5900")
+		}, "", false},
+	}
+	for _, test := range tests {
+		if test.change != nil {
+			test.change()
+		}
+		rotator.mtx.Lock()
+		rotator.advance(rotator.kiosks["lobby"], manager.List(), start.Add(test.at))
+		rotator.mtx.Unlock()
+
+		status, _ := rotator.Get("lobby")
+		if viewName(status.View) != test.expected || status.View.Remote != test.remote {
+			t.Errorf("%v: showing %v (remote %v), expected %v (remote %v)", test.name, viewName(status.View), status.View.Remote, test.expected, test.remote)
+		}
+	}
+	select {
+	case <-sub.Notify():
+	default:
+		t.Error("subscriber not notified")
+	}
+	rotator.Unsubscribe("lobby", sub)
+	if len(rotator.kiosks["lobby"].watched) != 0 {
+		t.Error("subscriber left watching")
+	}
+}
+
+func TestKioskAPI(t *testing.T) {
+	manager, layouts, cleanup := newKioskFixtures(t)
+	defer cleanup()
+	rotator := NewKioskRotator(manager, layouts, []kioskConfig{{Name: "lobby", Dwell: time.Minute, Group: "lobby"}})
+	router := httprouter.New()
+	router.GET("/kiosk/:kiosk", kioskPageHandler(rotator))
+	router.GET("/api/kiosks", kioskListHandler(rotator, nil))
+	router.GET("/api/kiosks/:kiosk", kioskGetHandler(rotator, nil))
+	router.POST("/api/kiosks/:kiosk/show", kioskShowHandler(rotator))
+	router.GET("/api/kiosks/:kiosk/subscribe", kioskSubscribeHandler(rotator, nil))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Follow the kiosk's commands
+	resp, err := http.Get(ts.URL + "/api/kiosks/lobby/subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	nextView := func() kioskView {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				view := kioskView{}
+				if err := json.Unmarshal([]byte(line[6:]), &view); err != nil {
+					t.Fatal(err)
+				}
+				return view
+			}
+		}
+	}
+	if view := nextView(); view != (kioskView{}) {
+		t.Errorf("got first view %+v", view)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		view   string // Shown afterwards
+	}{
+		{"page", "GET", "/kiosk/lobby", "", 200, ""},
+		{"missing page", "GET", "/kiosk/office", "", 404, ""},
+		{"list", "GET", "/api/kiosks", "", 200, ""},
+		{"get", "GET", "/api/kiosks/lobby", "", 200, ""},
+		{"get missing", "GET", "/api/kiosks/office", "", 404, ""},
+		{"show server", "POST", "/api/kiosks/lobby/show", `{"server": "charlie", "hold": 5}`, 200, "c"},
+		{"show layout", "POST", "/api/kiosks/lobby/show", `{"layout": "wall"}`, 200, "layout wall"},
+		{"show missing server", "POST", "/api/kiosks/lobby/show", `{"server": "delta"}`, 404, "layout wall"},
+		{"show missing layout", "POST", "/api/kiosks/lobby/show", `{"layout": "desk"}`, 404, "layout wall"},
+		{"show nothing", "POST", "/api/kiosks/lobby/show", `{}`, 400, "layout wall"},
+		{"negative hold", "POST", "/api/kiosks/lobby/show", `{"server": "a", "hold": -1}`, 400, "layout wall"},
+		{"unparseable", "POST", "/api/kiosks/lobby/show", `{"server": `, 400, "layout wall"},
+		{"missing kiosk", "POST", "/api/kiosks/office/show", `{"server": "a"}`, 404, "layout wall"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, expected %v: %s", test.name, w.Code, test.status, w.Body.Bytes())
+		}
+		status, _ := rotator.Get("lobby")
+		if viewName(status.View) != test.view {
+			t.Errorf("%v: showing %v, expected %v", test.name, viewName(status.View), test.view)
+		}
+		if test.status == 200 && test.view != "" && test.method == "POST" {
+			if view := nextView(); viewName(view) != test.view || !view.Remote {
+				t.Errorf("%v: sent %+v", test.name, view)
+			}
+		}
+	}
+
+	status, _ := rotator.Get("lobby")
+	if until := time.Until(status.View.Until); until < 50*time.Second || until > time.Minute {
+		t.Errorf("held for %v, expected the dwell time", until)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/kiosks/office/subscribe", nil))
+	if w.Code != 404 {
+		t.Errorf("subscribed to a missing kiosk: %v", w.Code)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Largest grid a layout may have in either direction
+const layoutMaxGridSize = 16
+
+// How servers are scaled to fit their cells
+const (
+	Scale_Fit    = "fit"    // Scale up or down to fill the cell, keeping the aspect ratio
+	Scale_Shrink = "shrink" // Only scale down screens too big for the cell
+	Scale_None   = "none"   // Show screens at their real size
+)
+
+var (
+	ErrLayoutNotFound = errors.New("layout not found")
+	ErrLayoutExists   = errors.New("layout already exists")
+)
+
+// A server placed in a layout grid. Cells are numbered from 0 at the top left.
+type layoutCell struct {
+	Server string `json:"server"`           // Short name, ID or slug of the server
+	Column int    `json:"column"`           // Column of the top left of the cell
+	Row    int    `json:"row"`              // Row of the top left of the cell
+	Width  int    `json:"width,omitempty"`  // Columns the cell spans. Defaults to 1.
+	Height int    `json:"height,omitempty"` // Rows the cell spans. Defaults to 1.
+}
+
+// A named arrangement of servers on a dashboard
+type dashboardLayout struct {
+	Name    string       `json:"name"`
+	Columns int          `json:"columns"`
+	Rows    int          `json:"rows"`
+	Scale   string       `json:"scale,omitempty"` // One of the Scale_ values. Defaults to fit.
+	Cells   []layoutCell `json:"cells"`
+}
+
+// Check a layout, filling in defaults
+func (this *dashboardLayout) validate() error {
+	if !validIdentifier(this.Name) {
+		return fmt.Errorf("invalid layout name: %v", this.Name)
+	}
+	if this.Columns < 1 || this.Columns > layoutMaxGridSize || this.Rows < 1 || this.Rows > layoutMaxGridSize {
+		return fmt.Errorf("grid must be between 1 and %v cells in each direction", layoutMaxGridSize)
+	}
+	switch this.Scale {
+	case "":
+		this.Scale = Scale_Fit
+	case Scale_Fit, Scale_Shrink, Scale_None:
+	default:
+		return fmt.Errorf("invalid scale: %v", this.Scale)
+	}
+	if this.Cells == nil {
+		this.Cells = []layoutCell{}
+	}
+	for i := range this.Cells {
+		cell := &this.Cells[i]
+		if cell.Server == "" {
+			return fmt.Errorf("cell %v: no server specified", i)
+		}
+		if cell.Width == 0 {
+			cell.Width = 1
+		}
+		if cell.Height == 0 {
+			cell.Height = 1
+		}
+		if cell.Column < 0 || cell.Row < 0 || cell.Width < 0 || cell.Height < 0 ||
+			cell.Column+cell.Width > this.Columns || cell.Row+cell.Height > this.Rows {
+			return fmt.Errorf("cell %v: outside the grid", i)
+		}
+	}
+	return nil
+}
+
+// Layouts, persisted to a JSON file
+type layoutStore struct {
+	filename string
+	layouts  map[string]dashboardLayout
+	mtx      sync.RWMutex
+}
+
+func NewLayoutStore(filename string) *layoutStore {
+	return &layoutStore{
+		filename: filename,
+		layouts:  make(map[string]dashboardLayout),
+	}
+}
+
+// Load the layout file. A missing file has no layouts.
+func (this *layoutStore) Load() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	b, err := ioutil.ReadFile(this.filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	layouts := []dashboardLayout{}
+	if err := json.Unmarshal(b, &layouts); err != nil {
+		return err
+	}
+	this.layouts = make(map[string]dashboardLayout)
+	for _, layout := range layouts {
+		if err := layout.validate(); err != nil {
+			return err
+		}
+		this.layouts[layout.Name] = layout
+	}
+	return nil
+}
+
+// Write the layout file. Must be called with mtx held.
+func (this *layoutStore) save() error {
+	b, err := json.MarshalIndent(this.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(this.filename, b, 0644)
+}
+
+// Every layout, sorted by name
+func (this *layoutStore) List() []dashboardLayout {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+
+	return this.listLocked()
+}
+
+func (this *layoutStore) listLocked() []dashboardLayout {
+	names := []string{}
+	for name := range this.layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r := []dashboardLayout{}
+	for _, name := range names {
+		r = append(r, this.layouts[name])
+	}
+	return r
+}
+
+func (this *layoutStore) Get(name string) (dashboardLayout, bool) {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+
+	layout, ok := this.layouts[name]
+	return layout, ok
+}
+
+// Save a layout. Unless replace is set, existing layouts aren't overwritten.
+// Returns true if the layout was created.
+func (this *layoutStore) Put(layout dashboardLayout, replace bool) (bool, error) {
+	if err := layout.validate(); err != nil {
+		return false, err
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	old, exists := this.layouts[layout.Name]
+	if exists && !replace {
+		return false, ErrLayoutExists
+	}
+	this.layouts[layout.Name] = layout
+	if err := this.save(); err != nil {
+		if exists {
+			this.layouts[layout.Name] = old
+		} else {
+			delete(this.layouts, layout.Name)
+		}
+		return false, err
+	}
+	return !exists, nil
+}
+
+func (this *layoutStore) Remove(name string) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	old, exists := this.layouts[name]
+	if !exists {
+		return ErrLayoutNotFound
+	}
+	delete(this.layouts, name)
+	if err := this.save(); err != nil {
+		this.layouts[name] = old
+		return err
+	}
+	return nil
+}
+
+// Map layout store errors onto HTTP responses
+func writeLayoutError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrLayoutNotFound:
+		http.Error(w, err.Error(), 404)
+	case ErrLayoutExists:
+		http.Error(w, err.Error(), 409)
+	default:
+		http.Error(w, err.Error(), 400)
+	}
+}
+
+func writeLayoutResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Copy of a layout without the cells of servers the user may not list
+func visibleLayout(r *http.Request, manager *serverManager, access *accessControl, layout dashboardLayout) dashboardLayout {
+	if access == nil {
+		return layout
+	}
+	cells := []layoutCell{}
+	for _, cell := range layout.Cells {
+		if access.AllowedName(r, manager, cell.Server, Perm_List) {
+			cells = append(cells, cell)
+		}
+	}
+	layout.Cells = cells
+	return layout
+}
+
+func layoutListHandler(layouts *layoutStore, manager *serverManager, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		list := layouts.List()
+		for i := range list {
+			list[i] = visibleLayout(r, manager, access, list[i])
+		}
+		writeLayoutResponse(w, 200, list)
+	}
+}
+
+func layoutGetHandler(layouts *layoutStore, manager *serverManager, access *accessControl) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		layout, ok := layouts.Get(ps.ByName("layout"))
+		if !ok {
+			writeLayoutError(w, ErrLayoutNotFound)
+			return
+		}
+		writeLayoutResponse(w, 200, visibleLayout(r, manager, access, layout))
+	}
+}
+
+// Create a layout, named in the body
+func layoutCreateHandler(layouts *layoutStore) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		layout := dashboardLayout{}
+		if err := json.NewDecoder(r.Body).Decode(&layout); err != nil {
+			http.Error(w, "Invalid layout: "+err.Error(), 400)
+			return
+		}
+		if _, err := layouts.Put(layout, false); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+		log.With("layout", layout.Name).With("remote_addr", r.RemoteAddr).Infoln("Layout created")
+		w.Header().Set("Location", "/api/layouts/"+layout.Name)
+		layout, _ = layouts.Get(layout.Name)
+		writeLayoutResponse(w, 201, layout)
+	}
+}
+
+// Create or replace the layout named in the path
+func layoutPutHandler(layouts *layoutStore) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		layout := dashboardLayout{}
+		if err := json.NewDecoder(r.Body).Decode(&layout); err != nil {
+			http.Error(w, "Invalid layout: "+err.Error(), 400)
+			return
+		}
+		layout.Name = ps.ByName("layout")
+		created, err := layouts.Put(layout, true)
+		if err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+		log.With("layout", layout.Name).With("remote_addr", r.RemoteAddr).Infoln("Layout saved")
+		status := 200
+		if created {
+			status = 201
+		}
+		layout, _ = layouts.Get(layout.Name)
+		writeLayoutResponse(w, status, layout)
+	}
+}
+
+func layoutDeleteHandler(layouts *layoutStore) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if err := layouts.Remove(ps.ByName("layout")); err != nil {
+			writeLayoutError(w, err)
+			return
+		}
+		log.With("layout", ps.ByName("layout")).With("remote_addr", r.RemoteAddr).Infoln("Layout removed")
+		w.WriteHeader(204)
+	}
+}
+
+// Serve the dashboard page for a layout. The page loads the layout itself.
+func layoutDashboardHandler(layouts *layoutStore) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := layouts.Get(ps.ByName("layout")); !ok {
+			http.Error(w, "Layout not found", 404)
+			return
+		}
+		b, err := Asset("dashboard.html")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(b)
+	}
+}
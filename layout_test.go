@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDashboardLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   dashboardLayout
+		expected dashboardLayout // After filling in defaults
+		err      string
+	}{
+		{"defaults", dashboardLayout{Name: "wall", Columns: 2, Rows: 2},
+			dashboardLayout{Name: "wall", Columns: 2, Rows: 2, Scale: Scale_Fit, Cells: []layoutCell{}}, ""},
+		{"cells", dashboardLayout{Name: "wall", Columns: 3, Rows: 2, Scale: Scale_None, Cells: []layoutCell{
+			{Server: "a", Column: 0, Row: 0, Width: 2, Height: 2},
+			{Server: "b", Column: 2, Row: 1},
+		}}, dashboardLayout{Name: "wall", Columns: 3, Rows: 2, Scale: Scale_None, Cells: []layoutCell{
+			{Server: "a", Column: 0, Row: 0, Width: 2, Height: 2},
+			{Server: "b", Column: 2, Row: 1, Width: 1, Height: 1},
+		}}, ""},
+		{"largest", dashboardLayout{Name: "wall", Columns: layoutMaxGridSize, Rows: layoutMaxGridSize, Scale: Scale_Shrink},
+			dashboardLayout{Name: "wall", Columns: layoutMaxGridSize, Rows: layoutMaxGridSize, Scale: Scale_Shrink, Cells: []layoutCell{}}, ""},
+		{"no name", dashboardLayout{Columns: 1, Rows: 1}, dashboardLayout{}, "invalid layout name"},
+		{"invalid name", dashboardLayout{Name: "a/b", Columns: 1, Rows: 1}, dashboardLayout{}, "invalid layout name: a/b"},
+		{"no columns", dashboardLayout{Name: "wall", Rows: 1}, dashboardLayout{}, "grid must be"},
+		{"too many rows", dashboardLayout{Name: "wall", Columns: 1, Rows: layoutMaxGridSize + 1}, dashboardLayout{}, "grid must be"},
+		{"invalid scale", dashboardLayout{Name: "wall", Columns: 1, Rows: 1, Scale: "stretch"}, dashboardLayout{}, "invalid scale: stretch"},
+		{"no server", dashboardLayout{Name: "wall", Columns: 1, Rows: 1, Cells: []layoutCell{{}}}, dashboardLayout{}, "cell 0: no server"},
+		{"off the right", dashboardLayout{Name: "wall", Columns: 2, Rows: 1, Cells: []layoutCell{{Server: "a", Column: 1, Width: 2}}},
+			dashboardLayout{}, "cell 0: outside the grid"},
+		{"off the bottom", dashboardLayout{Name: "wall", Columns: 1, Rows: 1, Cells: []layoutCell{{Server: "a"}, {Server: "b", Row: 1}}},
+			dashboardLayout{}, "cell 1: outside the grid"},
+		{"negative", dashboardLayout{Name: "wall", Columns: 1, Rows: 1, Cells: []layoutCell{{Server: "a", Column: -1}}},
+			dashboardLayout{}, "cell 0: outside the grid"},
+		{"negative size", dashboardLayout{Name: "wall", Columns: 2, Rows: 1, Cells: []layoutCell{{Server: "a", Column: 1, Width: -1}}},
+			dashboardLayout{}, "cell 0: outside the grid"},
+	}
+	for _, test := range tests {
+		err := test.layout.validate()
+		checkErrText(t, test.name, err, test.err)
+		if err == nil && !reflect.DeepEqual(test.layout, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, test.layout, test.expected)
+		}
+	}
+}
+
+func TestLayoutStoreLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string // Missing file if empty
+		expected []string
+		err      string
+	}{
+		{"missing", "", []string{}, ""},
+		{"layouts", `[{"name": "b", "columns": 1, "rows": 1}, {"name": "a", "columns": 2, "rows": 1}]`, []string{"a", "b"}, ""},
+		{"invalid layout", `[{"name": "a", "columns": 1, "rows": 1}, {"name": "b"}]`, nil, "grid must be"},
+		{"invalid json", `[`, []string{}, "unexpected end"},
+	}
+	for _, test := range tests {
+		filename, cleanup := writeTempFile(t, "layouts.json", test.contents)
+		if test.contents == "" {
+			os.Remove(filename)
+		}
+		store := NewLayoutStore(filename)
+		err := store.Load()
+		cleanup()
+		checkErrText(t, test.name, err, test.err)
+		if test.expected == nil {
+			continue
+		}
+		names := []string{}
+		for _, layout := range store.List() {
+			names = append(names, layout.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%v: got layouts %v, expected %v", test.name, names, test.expected)
+		}
+	}
+}
+
+func TestLayoutAPI(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "layouts.json", "")
+	defer cleanup()
+	os.Remove(filename)
+	store := NewLayoutStore(filename)
+	router := httprouter.New()
+	router.GET("/dashboard/:layout", layoutDashboardHandler(store))
+	router.GET("/api/layouts", layoutListHandler(store, NewServerManager(), nil))
+	router.POST("/api/layouts", layoutCreateHandler(store))
+	router.GET("/api/layouts/:layout", layoutGetHandler(store, NewServerManager(), nil))
+	router.PUT("/api/layouts/:layout", layoutPutHandler(store))
+	router.DELETE("/api/layouts/:layout", layoutDeleteHandler(store))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		layouts []string // Afterwards
+	}{
+		{"list none", "GET", "/api/layouts", "", 200, []string{}},
+		{"create", "POST", "/api/layouts", `{"name": "wall", "columns": 2, "rows": 1, "cells": [{"server": "a"}]}`, 201, []string{"wall"}},
+		{"create again", "POST", "/api/layouts", `{"name": "wall", "columns": 1, "rows": 1}`, 409, []string{"wall"}},
+		{"create invalid", "POST", "/api/layouts", `{"name": "desk", "columns": 0, "rows": 1}`, 400, []string{"wall"}},
+		{"create unparseable", "POST", "/api/layouts", `{"name": `, 400, []string{"wall"}},
+		{"get", "GET", "/api/layouts/wall", "", 200, []string{"wall"}},
+		{"get missing", "GET", "/api/layouts/desk", "", 404, []string{"wall"}},
+		{"dashboard", "GET", "/dashboard/wall", "", 200, []string{"wall"}},
+		{"dashboard missing", "GET", "/dashboard/desk", "", 404, []string{"wall"}},
+		{"replace", "PUT", "/api/layouts/wall", `{"name": "ignored", "columns": 1, "rows": 1, "scale": "none"}`, 200, []string{"wall"}},
+		{"replace invalid", "PUT", "/api/layouts/wall", `{"columns": 1, "rows": 1, "scale": "stretch"}`, 400, []string{"wall"}},
+		{"put new", "PUT", "/api/layouts/desk", `{"columns": 1, "rows": 1}`, 201, []string{"desk", "wall"}},
+		{"put invalid name", "PUT", "/api/layouts/a.b%20c", `{"columns": 1, "rows": 1}`, 400, []string{"desk", "wall"}},
+		{"list", "GET", "/api/layouts", "", 200, []string{"desk", "wall"}},
+		{"delete", "DELETE", "/api/layouts/desk", "", 204, []string{"wall"}},
+		{"delete missing", "DELETE", "/api/layouts/desk", "", 404, []string{"wall"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, expected %v: %s", test.name, w.Code, test.status, w.Body.Bytes())
+		}
+		names := []string{}
+		for _, layout := range store.List() {
+			names = append(names, layout.Name)
+		}
+		if !reflect.DeepEqual(names, test.layouts) {
+			t.Errorf("%v: got layouts %v, expected %v", test.name, names, test.layouts)
+		}
+	}
+
+	// The replaced layout was saved with its defaults
+	reloaded := NewLayoutStore(filename)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []dashboardLayout{{Name: "wall", Columns: 1, Rows: 1, Scale: Scale_None, Cells: []layoutCell{}}}
+	if layouts := reloaded.List(); !reflect.DeepEqual(layouts, expected) {
+		t.Errorf("reloaded %+v", layouts)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/layouts/wall", nil))
+	layout := dashboardLayout{}
+	if err := json.Unmarshal(w.Body.Bytes(), &layout); err != nil || !reflect.DeepEqual(layout, expected[0]) {
+		t.Errorf("got %+v, %v", layout, err)
+	}
+}
+
+func TestLayoutStoreRollback(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "layouts.json", `[{"name": "wall", "columns": 2, "rows": 2}]`)
+	defer cleanup()
+	store := NewLayoutStore(filename)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	// Saving fails while a directory is in the way of the temporary file
+	if err := os.Mkdir(filename+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"create", func() error {
+			_, err := store.Put(dashboardLayout{Name: "desk", Columns: 1, Rows: 1}, false)
+			return err
+		}},
+		{"replace", func() error {
+			_, err := store.Put(dashboardLayout{Name: "wall", Columns: 1, Rows: 1}, true)
+			return err
+		}},
+		{"remove", func() error { return store.Remove("wall") }},
+	}
+	expected := []dashboardLayout{{Name: "wall", Columns: 2, Rows: 2, Scale: Scale_Fit, Cells: []layoutCell{}}}
+	for _, test := range tests {
+		if err := test.change(); err == nil {
+			t.Errorf("%v: unsaved change succeeded", test.name)
+		}
+		if layouts := store.List(); !reflect.DeepEqual(layouts, expected) {
+			t.Errorf("%v: got layouts %+v after failing", test.name, layouts)
+		}
+	}
+	if b, err := ioutil.ReadFile(filename); err != nil || !strings.Contains(string(b), `"columns": 2`) {
+		t.Errorf("layout file changed: %s", b)
+	}
+}
@@ -8,20 +8,23 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/JanBerktold/sse"
 	"github.com/gorilla/websocket"
 	"github.com/julienschmidt/httprouter"
 	"github.com/kardianos/osext"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
 	"gopkg.in/fsnotify.v1"
+	"io/ioutil"
 	"mime"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,8 +46,58 @@ var (
 
 	insecure = flag.Bool("listen.ssl.disable", false, "Disable SSL entirely (INSECURE!)")
 
-	socketPaths = flag.String("servers.watch-glob", "", "Glob path to watch for VNC UNIX socket servers appearing")
-	watchPollInterval = flag.Duration("servers.watch-interval", time.Second * 5, "If no inotify events in this long, manually poll the watch paths. 0 disables.")
+	clientCA       = flag.String("listen.ssl.client-ca", "", "PEM bundle of CAs client certificates must be signed by. Enables client certificate authentication.")
+	clientOptional = flag.Bool("listen.ssl.client-cert-optional", false, "Let clients without a certificate connect and log in another way")
+	certUserField  = flag.String("auth.cert.user-field", CertUser_CommonName, "Client certificate field naming the user: cn, email, dns, uri or subject")
+
+	socketPaths       = flag.String("servers.watch-glob", "", "Glob path to watch for VNC UNIX socket servers appearing")
+	watchPollInterval = flag.Duration("servers.watch-interval", time.Second*5, "If no inotify events in this long, manually poll the watch paths. 0 disables.")
+	serverConfigFile  = flag.String("servers.config", "", "YAML or JSON file listing static VNC servers by URL")
+	legacyNames       = flag.Bool("servers.legacy-names", true, "Accept the short names of earlier versions, which hashed the credentials. Deprecated.")
+	serverStateFile   = flag.String("servers.state-file", "", "JSON file servers added through the API are kept in. Defaults to servers.json in -filedir.")
+	layoutsFile       = flag.String("layouts.file", "", "JSON file dashboard layouts are kept in. Defaults to layouts.json in -filedir.")
+	kiosksConfigFile  = flag.String("kiosks.config", "", "YAML or JSON file defining kiosk rotations")
+	forceReadOnly     = flag.Bool("servers.read-only", false, "Make all servers read-only regardless of their configuration")
+	handshakeTimeout  = flag.Duration("servers.handshake-timeout", time.Second*10, "Timeout for the RFB handshake with VNC servers")
+	sessionKeepalive  = flag.Duration("servers.keepalive", time.Second*30, "How often to check VNC sessions are alive. Sessions whose server doesn't answer within twice this end. 0 disables.")
+	probeInterval     = flag.Duration("servers.probe-interval", time.Second*30, "How often to check the health of every server with an RFB handshake. 0 disables.")
+	probeTimeout      = flag.Duration("servers.probe-timeout", time.Second*5, "Timeout for health probes")
+
+	recordAll         = flag.Bool("recording.all", false, "Record sessions of all servers which don't disable it, into -filedir")
+	recordInterval    = flag.Duration("recording.interval", 0, "Ignored, since recordings hold every update from the server. Deprecated.")
+	recordMaxAge      = flag.Duration("recording.max-age", 0, "Delete recordings older than this. 0 keeps them forever.")
+	recordMaxSize     = flag.Int64("recording.max-size-mb", 0, "Delete the oldest recordings when they total more than this many megabytes. 0 is unlimited.")
+	recordPrunePeriod = flag.Duration("recording.prune-interval", time.Minute, "How often to check recordings against the retention limits")
+
+	thumbnailInterval = flag.Duration("thumbnails.interval", time.Second*2, "Minimum time between thumbnails of a server")
+	thumbnailWidth    = flag.Int("thumbnails.width", 320, "Width of server thumbnails in pixels")
+	thumbnailQuality  = flag.Int("thumbnails.quality", 60, "JPEG quality of server thumbnails (1-100)")
+
+	staleAfter   = flag.Duration("stale.after", 0, "Mark servers stale when their screen hasn't changed for this long. 0 disables monitoring.")
+	staleWebhook = flag.String("stale.webhook", "", "URL to POST JSON to when a server becomes stale or recovers")
+
+	mjpegFrameRate    = flag.Float64("mjpeg.fps", 5, "Default frame rate of MJPEG streams")
+	mjpegMaxFrameRate = flag.Float64("mjpeg.max-fps", 25, "Highest frame rate MJPEG streams may request")
+
+	authHtpasswd        = flag.String("auth.htpasswd", "", "Apache htpasswd file of users allowed to log in. Enables authentication.")
+	authSessionSecret   = flag.String("auth.session-secret-file", "", "File holding the secret session cookies are encrypted with. Sessions end on restart without one.")
+	authSessionLifetime = flag.Duration("auth.session-lifetime", time.Hour*12, "How long login sessions last")
+	authMetricsPublic   = flag.Bool("auth.metrics-public", false, "Serve /metrics without authentication, for Prometheus")
+
+	oidcIssuer       = flag.String("auth.oidc.issuer", "", "OpenID Connect issuer URL. Enables logging in through the provider.")
+	oidcClientID     = flag.String("auth.oidc.client-id", "", "OpenID Connect client ID")
+	oidcClientSecret = flag.String("auth.oidc.client-secret-file", "", "File holding the OpenID Connect client secret. Public clients rely on PKCE alone.")
+	oidcRedirectURL  = flag.String("auth.oidc.redirect-url", "", "Callback URL registered with the provider. Defaults to /auth/oidc/callback on the host requested.")
+	oidcScopes       = flag.String("auth.oidc.scopes", "openid profile email", "Scopes to request, separated by spaces")
+	oidcUserClaim    = flag.String("auth.oidc.user-claim", "preferred_username", "ID token claim holding the user name. Falls back to sub.")
+	oidcGroupsClaim  = flag.String("auth.oidc.groups-claim", "groups", "ID token claim holding the user's groups")
+	oidcRoleMap      = flag.String("auth.oidc.role-map", "", "Roles given to groups, like admins=admin,ops=operator")
+
+	accessPolicyFile = flag.String("auth.policy", "", "YAML or JSON file of policies granting users permissions on servers. Without one everyone authenticated has full access.")
+
+	auditDestination   = flag.String("audit.log", "", "Audit log of viewer sessions: a file of JSON lines relative to -filedir, or syslog. Disabled if empty.")
+	auditInput         = flag.Bool("audit.input", false, "Also audit every key, pointer and clipboard event")
+	auditClipboardText = flag.Bool("audit.clipboard-text", false, "Include the text of clipboard transfers in the audit log")
 
 	debugWeb = flag.String("debug.webapp-proxy", "", "Proxy all requests for static assets to this IP instead")
 )
@@ -67,29 +120,72 @@ type VNCServer interface {
 }
 
 type vncServer struct {
-	NetType  string `json:"nettype"`  // Golang network type
-	Address  string `json:"address"`  // Address
-	Username string `json:"username"` // Username
-	Password string `json:"-"`        // Password
+	ID       string   `json:"id,omitempty"`   // Configured identifier, used instead of the address hash
+	Slug     string   `json:"slug,omitempty"` // Human-readable alternative to the short name
+	NetType  string   `json:"nettype"`        // Golang network type
+	Address  string   `json:"address"`        // Address
+	Username string   `json:"username"`       // Username
+	Password string   `json:"-"`              // Password
+	Name     string   `json:"name"`           // Display name
+	Tags     []string `json:"tags"`           // Free-form tags
+	ReadOnly bool     `json:"readonly"`       // Drop input from viewers
+	Record   bool     `json:"record"`         // Record sessions to the export directory
+	Source   string   `json:"source"`         // Where the server was discovered from
+
+	Labels map[string]string `json:"labels,omitempty"` // Key-value metadata, for filtering
+	Groups []string          `json:"groups,omitempty"` // Groups the server belongs to, for filtering
+
+	Watches []watchRule   `json:"watches,omitempty"` // Regions of the screen to watch for changes
+	Status  *serverStatus `json:"status,omitempty"`  // Result of the latest health probe
 }
 
+// Sources servers can be discovered from
+const (
+	Source_Watch  = "watch"
+	Source_Config = "config"
+	Source_API    = "api"
+)
+
 // Types used for publishing server events
 type ManagerActionType string
 
 const (
-	Manager_AddedServer   ManagerActionType = "added"
-	Manager_RemovedServer ManagerActionType = "removed"
+	Manager_AddedServer     ManagerActionType = "added"
+	Manager_RemovedServer   ManagerActionType = "removed"
+	Manager_UpdatedServer   ManagerActionType = "updated"   // Details of the server changed
+	Manager_StaleServer     ManagerActionType = "stale"     // Screen stopped changing
+	Manager_RecoveredServer ManagerActionType = "recovered" // Stale screen changed again
+	Manager_WatchEvent      ManagerActionType = "watch"     // A watch rule fired
+	Manager_StatusServer    ManagerActionType = "status"    // Health probe state changed
 )
 
+// Number of events kept for subscribers which reconnect
+const managerJournalSize = 1024
+
+// An event published by the manager. Events are numbered in the order they were
+// published.
 type ManagerAction struct {
-	action ManagerActionType
-	server VNCServer
+	ID        uint64            `json:"id"`
+	Action    ManagerActionType `json:"action"`
+	Shortname string            `json:"shortname"`
+	Server    vncServer         `json:"server"`           // The server as of the event
+	Detail    interface{}       `json:"detail,omitempty"` // Details specific to the action
+	Time      time.Time         `json:"time"`
 }
 
-func ParseVNCServer(address string) vncServer {
+// Detail of update events
+type updateEvent struct {
+	Previous vncServer `json:"previous"` // The server before the update
+}
+
+func ParseVNCServer(address string) (vncServer, error) {
 	urlp, err := url.Parse(address)
 	if err != nil {
-		return vncServer{}
+		return vncServer{}, err
+	}
+
+	if urlp.Scheme == "" {
+		return vncServer{}, fmt.Errorf("no network type in server url: %v", address)
 	}
 
 	user := ""
@@ -105,15 +201,17 @@ func ParseVNCServer(address string) vncServer {
 			Address:  urlp.Path,
 			Username: user,
 			Password: password,
-		}
-	} else { // actual network sockets
+		}, nil
+	} else if urlp.Host != "" { // actual network sockets
 		return vncServer{
 			NetType:  urlp.Scheme,
 			Address:  urlp.Host,
 			Username: user,
 			Password: password,
-		}
+		}, nil
 	}
+
+	return vncServer{}, fmt.Errorf("no address in server url: %v", address)
 }
 
 // String representation (url form)
@@ -126,76 +224,234 @@ func (this vncServer) String() string {
 	return u.String()
 }
 
-// Short representation for the manager and access paths.
-// This is just the MD5 hash of the URL form representation, in hex
+// URL form without the password, for logs
+func (this vncServer) Redacted() string {
+	u := url.URL{}
+	u.Host = this.Address
+	u.Scheme = this.NetType
+	if this.Username != "" {
+		u.User = url.User(this.Username)
+	}
+
+	return u.String()
+}
+
+// Short representation for the manager and access paths. This is the configured
+// ID, or the MD5 hash of the network type and address in hex, so it doesn't
+// change when credentials do.
 func (this vncServer) Short() string {
+	if this.ID != "" {
+		return this.ID
+	}
+	hasher := md5.New()
+	hasher.Write([]byte(this.NetType + "://" + this.Address))
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Short name used by earlier versions, the MD5 hash of the URL form including
+// credentials. Still accepted so old links keep working.
+func (this vncServer) LegacyShort() string {
 	hasher := md5.New()
 	hasher.Write([]byte(this.String()))
 
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
+// Slug derived from the display name, or the address if there isn't one
+func (this vncServer) defaultSlug() string {
+	if this.Name != "" {
+		return slugify(this.Name)
+	}
+	if this.NetType == "unix" {
+		base := filepath.Base(this.Address)
+		return slugify(strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	return slugify(this.Address)
+}
+
+var slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Lowercase a string and replace runs of anything but letters and digits with
+// dashes
+func slugify(s string) string {
+	return strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+var identifierValid = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Check an ID or slug can be used in a path
+func validIdentifier(s string) bool {
+	return identifierValid.MatchString(s)
+}
+
 // Maintains the list of currently available VNC files
 type serverManager struct {
 	availableServers map[string]vncServer
-	subscribers      []chan ManagerAction // Subscribers requesting server updates
+	legacyNames      bool // Accept legacy short names
 	mtx              sync.RWMutex
-	smtx             sync.Mutex
+
+	journal     []ManagerAction // Most recent events, oldest first
+	nextID      uint64          // ID of the next event published
+	subscribers map[*managerSubscriber]struct{}
+	smtx        sync.Mutex
 }
 
-// Request a channel that publishes updates
-func (this *serverManager) Subscribe() chan ManagerAction {
+// Follows the events published by a manager
+type managerSubscriber struct {
+	manager *serverManager
+	next    uint64 // ID of the next event to take
+	notify  chan struct{}
+}
+
+// Channel signalled when there are events to take
+func (this *managerSubscriber) Notify() <-chan struct{} {
+	return this.notify
+}
+
+// Take the events published since the last call. Returns false if the subscriber
+// fell so far behind that events were lost from the journal, in which case it
+// skips to the latest event.
+func (this *managerSubscriber) Take() ([]ManagerAction, bool) {
+	this.manager.smtx.Lock()
+	defer this.manager.smtx.Unlock()
+
+	events, ok := this.manager.eventsFrom(this.next)
+	if !ok {
+		droppedEvents.Inc()
+		log.Infoln("Subscriber fell behind the event journal")
+	}
+	this.next = this.manager.nextID
+	return events, ok
+}
+
+// Events from an ID onwards. Returns false if some are no longer in the journal.
+// Must be called with smtx held.
+func (this *serverManager) eventsFrom(id uint64) ([]ManagerAction, bool) {
+	first := this.nextID - uint64(len(this.journal))
+	if id < first || id > this.nextID {
+		return nil, false
+	}
+	events := make([]ManagerAction, this.nextID-id)
+	copy(events, this.journal[id-first:])
+	return events, true
+}
+
+// Subscribe to events published from now on
+func (this *serverManager) Subscribe() *managerSubscriber {
 	this.smtx.Lock()
 	defer this.smtx.Unlock()
 
-	ch := make(chan ManagerAction, 1)
-	this.subscribers = append(this.subscribers, ch)
-	return ch
+	sub := &managerSubscriber{
+		manager: this,
+		next:    this.nextID,
+		notify:  make(chan struct{}, 1),
+	}
+	this.subscribers[sub] = struct{}{}
+	return sub
 }
 
-func (this *serverManager) Unsubscribe(ch chan ManagerAction) {
+// Subscribe to events published after the given event ID, replaying those still
+// in the journal. Returns false if any have been lost, in which case only events
+// from now on are delivered.
+func (this *serverManager) SubscribeAfter(id uint64) (*managerSubscriber, bool) {
+	sub := this.Subscribe()
+
 	this.smtx.Lock()
 	defer this.smtx.Unlock()
 
-	for idx, kch := range this.subscribers {
-		if ch == kch {
-			this.subscribers = append(this.subscribers[:idx], this.subscribers[idx+1:]...)
-			break
-		}
+	if _, ok := this.eventsFrom(id + 1); !ok {
+		return sub, false
+	}
+	sub.next = id + 1
+	if sub.next != this.nextID {
+		sub.notify <- struct{}{}
 	}
+	return sub, true
+}
+
+func (this *serverManager) Unsubscribe(sub *managerSubscriber) {
+	this.smtx.Lock()
+	defer this.smtx.Unlock()
+
+	delete(this.subscribers, sub)
 }
 
-func (this *serverManager) publish(action ManagerActionType, server VNCServer) {
-	for _, ch := range this.subscribers {
-		// Always send messages
+// Add an event to the journal and wake subscribers. Subscribers read events from
+// the journal, so they are never dropped while a subscriber keeps up.
+func (this *serverManager) publish(action ManagerActionType, server vncServer, detail interface{}) {
+	this.smtx.Lock()
+	defer this.smtx.Unlock()
+
+	this.journal = append(this.journal, ManagerAction{
+		ID:        this.nextID,
+		Action:    action,
+		Shortname: server.Short(),
+		Server:    server,
+		Detail:    detail,
+		Time:      time.Now(),
+	})
+	this.nextID++
+	if len(this.journal) > managerJournalSize {
+		this.journal = this.journal[len(this.journal)-managerJournalSize:]
+	}
+
+	for sub := range this.subscribers {
 		select {
-		case ch <- ManagerAction{action, server}:
-			continue
+		case sub.notify <- struct{}{}:
 		default:
-			log.Infoln("Dropping message due to full channel")
 		}
 	}
 }
 
-// Add a server to the list
+// Publish an event about a server which doesn't change the list, optionally with
+// details of the event.
+func (this *serverManager) Notify(action ManagerActionType, server vncServer, detail interface{}) {
+	this.publish(action, server, detail)
+}
+
+// Add a server to the list, or update it if it's already there
 func (this *serverManager) Add(server vncServer) {
 	this.mtx.Lock()
 	defer this.mtx.Unlock()
 
-	_, ok := this.availableServers[server.Short()]
+	if server.Slug == "" {
+		server.Slug = server.defaultSlug()
+	}
+	existing, ok := this.availableServers[server.Short()]
 
 	// Don't duplicate server publications
 	if !ok {
-		log.With("server_shortpath", server.Short()).With("server", server.String()).Infoln("Adding server")
+		log.With("server_shortpath", server.Short()).With("server", server.Redacted()).Infoln("Adding server")
 		this.availableServers[server.Short()] = server
-		this.publish(Manager_AddedServer, server)
-	} else {
-		log.With("server_shortpath", server.Short()).With("server", server.String()).Debugln("Ignoring already added server")
+		this.publish(Manager_AddedServer, server, nil)
+	} else if replaces(server.Source, existing) {
+		this.update(existing, server)
 	}
 }
 
-// Remove a server from the list by it's network type and address
-func (this *serverManager) RemoveByAddress(address string) {
+// Whether a source may change a server. Sockets found by watching give way to
+// servers configured in a file or through the API, which are only changed by
+// the source they came from.
+func replaces(source string, existing vncServer) bool {
+	return existing.Source == source || existing.Source == Source_Watch
+}
+
+// Replace a server in the list, publishing it if anything but its probe results
+// changed. Must be called with mtx held.
+func (this *serverManager) update(existing vncServer, server vncServer) {
+	// Probe results aren't part of the configuration
+	server.Status = existing.Status
+	if reflect.DeepEqual(existing, server) {
+		return
+	}
+	log.With("server_shortpath", server.Short()).With("server", server.Redacted()).Infoln("Updating server")
+	this.availableServers[server.Short()] = server
+	this.publish(Manager_UpdatedServer, server, updateEvent{Previous: existing})
+}
+
+// Remove the servers from a source with the given address
+func (this *serverManager) RemoveByAddress(source string, address string) {
 	this.mtx.Lock()
 	defer this.mtx.Unlock()
 
@@ -203,17 +459,82 @@ func (this *serverManager) RemoveByAddress(address string) {
 
 	toRemove := []string{}
 	for k, v := range this.availableServers {
-		if v.Address == address {
+		if v.Source == source && v.Address == address {
 			toRemove = append(toRemove, k)
 		}
 	}
 
 	for _, k := range toRemove {
-		this.publish(Manager_RemovedServer, this.availableServers[k])
+		this.publish(Manager_RemovedServer, this.availableServers[k], nil)
 		delete(this.availableServers, k)
 	}
 }
 
+// Replace all servers from the given source with a new set. Only servers which
+// actually appear, disappear or change are published, so existing sessions are
+// left alone. Servers which only changed in metadata are updated in place, and
+// servers belonging to other sources are only taken over as replaces allows.
+func (this *serverManager) Sync(source string, servers []vncServer) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	updated := make(map[string]vncServer)
+	for _, server := range servers {
+		server.Source = source
+		if server.Slug == "" {
+			server.Slug = server.defaultSlug()
+		}
+		updated[server.Short()] = server
+	}
+
+	for k, v := range this.availableServers {
+		if v.Source != source {
+			continue
+		}
+		if _, ok := updated[k]; !ok {
+			log.With("server_shortpath", k).With("server", v.Redacted()).Infoln("Removing server")
+			this.publish(Manager_RemovedServer, v, nil)
+			delete(this.availableServers, k)
+		}
+	}
+
+	for k, v := range updated {
+		existing, ok := this.availableServers[k]
+		if !ok {
+			log.With("server_shortpath", k).With("server", v.Redacted()).Infoln("Adding server")
+			this.availableServers[k] = v
+			this.publish(Manager_AddedServer, v, nil)
+		} else if replaces(source, existing) {
+			this.update(existing, v)
+		} else {
+			log.With("server_shortpath", k).With("source", existing.Source).Warnln("Ignoring server already configured elsewhere")
+		}
+	}
+}
+
+// Record the result of probing a server, publishing it if the state changed
+func (this *serverManager) SetStatus(shortname string, status serverStatus) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	server, ok := this.availableServers[shortname]
+	if !ok {
+		// Removed while being probed
+		return
+	}
+	changed := server.Status == nil || server.Status.State != status.State
+	if server.Status != nil && status.LastSeen.IsZero() {
+		status.LastSeen = server.Status.LastSeen
+	}
+	server.Status = &status
+	this.availableServers[shortname] = server
+
+	if changed {
+		log.With("server_shortpath", shortname).With("state", status.State).Infoln("Server status changed")
+		this.publish(Manager_StatusServer, server, statusEvent{shortname, status})
+	}
+}
+
 // Make a deep-copy list of the current map
 func (this *serverManager) List() map[string]vncServer {
 	this.mtx.RLock()
@@ -227,9 +548,50 @@ func (this *serverManager) List() map[string]vncServer {
 	return r
 }
 
+// Find a server by its short name, its slug, or the short name older versions
+// gave it. Slugs shared by several servers don't match any of them.
+func (this *serverManager) Get(shortname string) (vncServer, bool) {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+
+	if server, ok := this.availableServers[shortname]; ok {
+		return server, true
+	}
+
+	matches := []vncServer{}
+	for _, server := range this.availableServers {
+		if server.Slug == shortname {
+			matches = append(matches, server)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+	if len(matches) > 1 {
+		log.With("slug", shortname).Warnln("Slug matches more than one server")
+		return vncServer{}, false
+	}
+
+	// Only once nothing current matches, so a slug always wins
+	if !this.legacyNames {
+		return vncServer{}, false
+	}
+	for _, server := range this.availableServers {
+		if server.LegacyShort() == shortname {
+			log.With("server_shortpath", server.Short()).
+				Warnln("Server found by legacy short name, which will stop working in a future version. Use", server.Short(), "instead.")
+			return server, true
+		}
+	}
+	return vncServer{}, false
+}
+
 func NewServerManager() *serverManager {
 	m := serverManager{}
 	m.availableServers = make(map[string]vncServer)
+	m.legacyNames = true
+	m.subscribers = make(map[*managerSubscriber]struct{})
+	m.nextID = 1
 	return &m
 }
 
@@ -240,13 +602,12 @@ func pollSocketDirectory(glob string, manager *serverManager) {
 	}
 
 	for _, globPath := range watchPaths {
-		// Add existent files to the manager
+		if isSidecarFile(globPath) {
+			continue
+		}
+		// Add existent files to the manager, picking up changed metadata
 		if s, err := os.Stat(globPath); !s.Mode().IsDir() && !os.IsNotExist(err) {
-			server := vncServer{
-				NetType: "unix",
-				Address: globPath,
-			}
-			manager.Add(server)
+			manager.Add(watchedServer(globPath))
 		}
 	}
 }
@@ -258,17 +619,15 @@ func handleSocketDirectoryEvent(glob string, manager *serverManager, e fsnotify.
 	if err != nil {
 		log.Error("Filepath globber error:", err)
 	}
-	if matched {
+	if isSidecarFile(e.Name) {
+		handleSidecarEvent(manager, e)
+	} else if matched {
 		switch e.Op {
 		case fsnotify.Create:
-			server := vncServer{
-				NetType: "unix",
-				Address: e.Name,
-			}
-			manager.Add(server)
+			manager.Add(watchedServer(e.Name))
 		case fsnotify.Remove, fsnotify.Rename:
 			// Remove and rename have same relative effect - server no longer available
-			manager.RemoveByAddress(e.Name)
+			manager.RemoveByAddress(Source_Watch, e.Name)
 		default:
 			// Ignore
 			log.Debugln("Ignoring Op:", e.String())
@@ -341,12 +700,106 @@ func main() {
 
 	// Setup a new server manager
 	manager := NewServerManager()
+	manager.legacyNames = *legacyNames
+	registerManagerMetrics(manager)
+
+	// Recordings of sessions go in the export directory
+	if *recordInterval != 0 {
+		log.Warnln("-recording.interval is deprecated and ignored")
+	}
+	recordings := NewRecordingStore(*fileDir, *recordMaxAge, *recordMaxSize*1024*1024)
+	go recordings.pruneLoop(*recordPrunePeriod)
+
+	// Shares upstream VNC connections between viewers
+	broker := NewSessionBroker(recordings)
+	go broker.FollowManager(manager)
+
+	// Scaled-down screens for the thumbnail wall
+	thumbnails := NewThumbnailer(manager, broker, *thumbnailInterval, *thumbnailWidth, *thumbnailQuality)
+
+	// Frozen screen detection. Keeps a session open to every server.
+	staleMonitor := NewStaleMonitor(manager, broker, *staleAfter, *staleWebhook)
+	if *staleAfter != 0 {
+		go staleMonitor.Run()
+	}
+
+	// Region watch rules
+	watches := NewWatchEngine(manager, broker, time.Second*5)
+	go watches.Run()
+
+	// Load the static server inventory
+	if *serverConfigFile != "" {
+		servers, err := LoadServerConfig(*serverConfigFile)
+		if err != nil {
+			log.Fatalln("Error loading server config:", err)
+		}
+		manager.Sync(Source_Config, servers)
+
+		// Reload on SIGHUP or file change
+		go watchServerConfig(*serverConfigFile, manager)
+	}
+
+	// Servers added through the API
+	if *serverStateFile == "" {
+		*serverStateFile = filepath.Join(*fileDir, "servers.json")
+	}
+	store := NewServerStore(*serverStateFile, manager)
+	if err := store.Load(); err != nil {
+		log.Fatalln("Error loading server state file:", err)
+	}
+
+	if *layoutsFile == "" {
+		*layoutsFile = filepath.Join(*fileDir, "layouts.json")
+	}
+	layouts := NewLayoutStore(*layoutsFile)
+	if err := layouts.Load(); err != nil {
+		log.Fatalln("Error loading layouts file:", err)
+	}
+
+	kioskConfigs := []kioskConfig{}
+	if *kiosksConfigFile != "" {
+		configs, err := LoadKioskConfig(*kiosksConfigFile)
+		if err != nil {
+			log.Fatalln("Error loading kiosk config:", err)
+		}
+		kioskConfigs = configs
+	}
+	kiosks := NewKioskRotator(manager, layouts, kioskConfigs)
+	go kiosks.Run()
+
+	// Audit log of viewer sessions
+	var audit *auditLog
+	if *auditDestination != "" {
+		opened, err := NewAuditLog(*auditDestination, *fileDir, *auditInput, *auditClipboardText)
+		if err != nil {
+			log.Fatalln("Error opening audit log:", err)
+		}
+		audit = opened
+	}
+
+	// Who may do what with each server
+	var access *accessControl
+	if *accessPolicyFile != "" {
+		if *authHtpasswd == "" && *oidcIssuer == "" && *clientCA == "" {
+			log.Fatalln("Access policies need authentication to be enabled")
+		}
+		policies, err := LoadAccessControl(*accessPolicyFile)
+		if err != nil {
+			log.Fatalln("Error loading access policies:", err)
+		}
+		access = policies
+	}
 
 	if *socketPaths != "" {
 		// Setup a listener service to add/remove VNC targets
 		go watchSocketFiles(socketWatcher.Events, *socketPaths, manager)
 	}
 
+	// Health checks
+	if *probeInterval != 0 {
+		go NewHealthProber(manager, *probeInterval, *probeTimeout).Run()
+	}
+
 	// Router
 	router := httprouter.New()
 
@@ -392,45 +845,132 @@ func main() {
 		}
 	})
 
+	// Dashboard arranged by a saved layout
+	router.GET("/dashboard/:layout", layoutDashboardHandler(layouts))
+
+	// Kiosk page following a rotation
+	router.GET("/kiosk/:kiosk", kioskPageHandler(kiosks))
+
 	// VNC websocket endpoint
-	router.GET("/vnc/:shortname", vncWebSocket(manager))
+	router.GET("/vnc/:shortname", vncWebSocket(manager, broker, access, audit))
+
+	// Manage servers at runtime
+	router.POST("/api/servers", serverCreateHandler(store, access))
+	router.GET("/api/servers/:shortname", access.Require(manager, Perm_List, serverGetHandler(manager)))
+	router.PATCH("/api/servers/:shortname", access.Require(manager, Perm_Manage, serverUpdateHandler(store, access)))
+	router.DELETE("/api/servers/:shortname", access.Require(manager, Perm_Manage, serverDeleteHandler(store)))
+
+	// Still images and motion JPEG streams of server screens. httprouter can't
+	// mix fixed and named segments, so dispatch on the file name.
+	screenshot := screenshotHandler(manager, broker, audit)
+	stream := mjpegHandler(manager, broker, audit, *mjpegFrameRate, *mjpegMaxFrameRate)
+	router.GET("/api/servers/:shortname/:file", access.Require(manager, Perm_View, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if ps.ByName("file") == "stream.mjpeg" {
+			stream(w, r, ps)
+		} else {
+			screenshot(w, r, ps)
+		}
+	}))
+
+	// Prometheus metrics
+	router.Handler("GET", "/metrics", promhttp.Handler())
+
+	// Saved dashboard layouts
+	router.GET("/api/layouts", layoutListHandler(layouts, manager, access))
+	router.POST("/api/layouts", access.RequireAll(Perm_Manage, layoutCreateHandler(layouts)))
+	router.GET("/api/layouts/:layout", layoutGetHandler(layouts, manager, access))
+	router.PUT("/api/layouts/:layout", access.RequireAll(Perm_Manage, layoutPutHandler(layouts)))
+	router.DELETE("/api/layouts/:layout", access.RequireAll(Perm_Manage, layoutDeleteHandler(layouts)))
+
+	// Kiosk rotations, which can be switched remotely
+	router.GET("/api/kiosks", kioskListHandler(kiosks, access))
+	router.GET("/api/kiosks/:kiosk", kioskGetHandler(kiosks, access))
+	router.POST("/api/kiosks/:kiosk/show", access.RequireAll(Perm_Manage, kioskShowHandler(kiosks)))
+	router.GET("/api/kiosks/:kiosk/subscribe", kioskSubscribeHandler(kiosks, access))
 
 	// Return a list of known servers as JSON
 	router.GET("/api/list", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		filter, err := parseServerFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if access != nil {
+			filter.Allow = func(server vncServer) bool { return access.Allowed(r, server, Perm_List) }
+		}
 		servers := manager.List()
+		for k, server := range servers {
+			if !filter.Match(server) {
+				delete(servers, k)
+			}
+		}
 		jenc := json.NewEncoder(w)
 
 		w.Header().Set("Content-Type", "application/json")
 		jenc.Encode(servers)
 	})
 
+	// Stream server events. Each event carries the server as JSON and an ID, so
+	// reconnecting clients are sent the events they missed. If those are no longer
+	// available a reset event tells the client to reload the list. Takes the same
+	// filters as the list.
 	router.GET("/api/list/subscribe", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-		conn, err := sse.Upgrade(w, r)
+		filter, err := parseServerFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if access != nil {
+			filter.Allow = func(server vncServer) bool { return access.Allowed(r, server, Perm_List) }
+		}
+		stream, err := newEventStream(w)
 		if err != nil {
 			log.Errorln("SSE upgrade failed:", err)
 			http.Error(w, "Failed to upgrade connection", 500)
 			return
 		}
-		defer conn.Close()
 
-		ch := manager.Subscribe()
-		defer manager.Unsubscribe(ch)
+		var sub *managerSubscriber
+		replayed := true
+		if id, ok := lastEventID(r); ok {
+			sub, replayed = manager.SubscribeAfter(id)
+		} else {
+			sub = manager.Subscribe()
+		}
+		defer manager.Unsubscribe(sub)
 
 		log.Debugln("New subcriber:", r.RemoteAddr)
 
-		timeCh := time.Tick(time.Second)
+		keepalive := time.NewTicker(sseKeepaliveInterval)
+		defer keepalive.Stop()
 		func() {
 			for {
-				select {
-				case e := <-ch:
-					err = conn.WriteStringEvent(string(e.action), e.server.Short())
-					if err != nil {
+				if !replayed {
+					if err := stream.WriteEvent(strconv.FormatUint(sub.next-1, 10), "reset", nil); err != nil {
 						return
 					}
-				case <-timeCh:
-					if !conn.IsOpen() {
+					replayed = true
+				}
+
+				select {
+				case <-sub.Notify():
+					var events []ManagerAction
+					events, replayed = sub.Take()
+					for _, e := range events {
+						e, ok := filter.Event(e)
+						if !ok {
+							continue
+						}
+						if err := stream.WriteJSONEvent(strconv.FormatUint(e.ID, 10), string(e.Action), e); err != nil {
+							return
+						}
+					}
+				case <-keepalive.C:
+					if err := stream.WriteComment("keepalive"); err != nil {
 						return
 					}
+				case <-r.Context().Done():
+					return
 				}
 			}
 		}()
@@ -438,12 +978,155 @@ func main() {
 		log.Debugln("Subscriber finished:", r.RemoteAddr)
 	})
 
+	// Screen activity of monitored servers, including whether they are stale
+	router.GET("/api/activity", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		activity := staleMonitor.List()
+		for k := range activity {
+			if !access.AllowedName(r, manager, k, Perm_List) {
+				delete(activity, k)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(activity)
+	})
+
+	// State of every watch rule
+	router.GET("/api/watches", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		states := []watchState{}
+		for _, state := range watches.List() {
+			if access.AllowedName(r, manager, state.Server, Perm_List) {
+				states = append(states, state)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(states)
+	})
+
+	// Stream thumbnails of every server's screen. Each server's latest thumbnail is
+	// sent on connecting.
+	router.GET("/api/thumbnails/subscribe", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		stream, err := newEventStream(w)
+		if err != nil {
+			log.Errorln("SSE upgrade failed:", err)
+			http.Error(w, "Failed to upgrade connection", 500)
+			return
+		}
+
+		sub := thumbnails.Subscribe()
+		defer thumbnails.Unsubscribe(sub)
+
+		// Servers whose thumbnails were sent, so only their removals are
+		sent := make(map[string]struct{})
+
+		log.Debugln("New thumbnail subcriber:", r.RemoteAddr)
+
+		keepalive := time.NewTicker(sseKeepaliveInterval)
+		defer keepalive.Stop()
+		func() {
+			for {
+				select {
+				case <-sub.Notify():
+					for _, thumb := range sub.Take() {
+						if _, ok := sent[thumb.Server]; thumb.Removed && ok {
+							delete(sent, thumb.Server)
+							err = stream.WriteEvent("", string(Manager_RemovedServer), []byte(thumb.Server))
+						} else if !thumb.Removed && access.AllowedName(r, manager, thumb.Server, Perm_View) {
+							sent[thumb.Server] = struct{}{}
+							err = stream.WriteJSONEvent("", "thumbnail", thumb)
+						}
+						if err != nil {
+							return
+						}
+					}
+				case <-keepalive.C:
+					if err := stream.WriteComment("keepalive"); err != nil {
+						return
+					}
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}()
+
+		log.Debugln("Thumbnail subscriber finished:", r.RemoteAddr)
+	})
+
+	// List recordings, optionally only those of one server
+	router.GET("/api/recordings", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		recordings, err := recordings.List()
+		if err != nil {
+			log.Errorln("Error listing recordings:", err)
+			http.Error(w, "Error listing recordings", 500)
+			return
+		}
+
+		server := r.URL.Query().Get("server")
+		filtered := []recordingInfo{}
+		for _, recording := range recordings {
+			if (server == "" || recording.Server == server) && access.AllowedName(r, manager, recording.Server, Perm_Record) {
+				filtered = append(filtered, recording)
+			}
+		}
+		recordings = filtered
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recordings)
+	})
+
+	router.GET("/api/recordings/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		recording, err := recordings.Get(ps.ByName("id"))
+		if err != nil || !access.AllowedName(r, manager, recording.Server, Perm_Record) {
+			http.Error(w, "Recording not found", 404)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recording)
+	})
+
+	// The recording itself, as a script for the player to load
+	router.GET("/api/recordings/:id/data.js", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		recording, err := recordings.Get(ps.ByName("id"))
+		if err != nil || !access.AllowedName(r, manager, recording.Server, Perm_Record) {
+			http.Error(w, "Recording not found", 404)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/javascript")
+		if err := recordings.WriteTo(ps.ByName("id"), w); err != nil {
+			log.Errorln("Error sending recording:", err)
+		}
+	})
+
+	// Who the request is from, when authentication is enabled
+	router.GET("/api/whoami", whoamiHandler())
+
+	var handler http.Handler = router
+	if auth := setupAuth(router); auth != nil {
+		// Covers the websocket and event streams as well as pages and the API
+		handler = auth.Wrap(router)
+	} else {
+		log.Warnln("Authentication disabled, anyone who can connect has full access")
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: handler}
+	if *clientCA != "" {
+		if *insecure {
+			log.Fatalln("Client certificates need SSL, which is disabled")
+		}
+		config, err := ClientCertTLSConfig(*clientCA, *clientOptional)
+		if err != nil {
+			log.Fatalln("Error loading client CA bundle:", err)
+		}
+		srv.TLSConfig = config
+	}
+
 	var err error
 	if *insecure {
 		log.Warnln("SSL DISABLED")
-		err = http.ListenAndServe(*listen, router)
+		err = srv.ListenAndServe()
 	} else {
-		err = http.ListenAndServeTLS(*listen, *sslCert, *sslKey, router)
+		err = srv.ListenAndServeTLS(*sslCert, *sslKey)
 	}
 
 	if err != nil {
@@ -451,42 +1134,118 @@ func main() {
 	}
 }
 
-func vncWebSocket(manager *serverManager) httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		shortname := ps.ByName("shortname")
-		var server vncServer
-
-		foundServer := false
-		for k, knownServer := range manager.List() {
-			if shortname == k {
-				foundServer = true
-				server = knownServer
-				break
+// Set up the login methods configured by flags, returning nil if none are
+func setupAuth(router *httprouter.Router) *authenticator {
+	if *authHtpasswd == "" && *oidcIssuer == "" && *clientCA == "" {
+		return nil
+	}
+
+	sessions, err := NewSessionCodec(*authSessionSecret, *authSessionLifetime)
+	if err != nil {
+		log.Fatalln("Error loading session secret:", err)
+	}
+	auth := &authenticator{sessions: sessions}
+	session := sessionAuth{sessions: sessions}
+
+	if *authMetricsPublic {
+		auth.public = map[string]bool{"/metrics": true}
+	}
+
+	if *clientCA != "" {
+		if !validCertUserField(*certUserField) {
+			log.Fatalln("Invalid -auth.cert.user-field:", *certUserField)
+		}
+		auth.methods = append(auth.methods, certAuth{*certUserField})
+	}
+
+	if *oidcIssuer != "" {
+		if *oidcClientID == "" {
+			log.Fatalln("-auth.oidc.client-id is required with -auth.oidc.issuer")
+		}
+		secret := ""
+		if *oidcClientSecret != "" {
+			b, err := ioutil.ReadFile(*oidcClientSecret)
+			if err != nil {
+				log.Fatalln("Error loading OpenID Connect client secret:", err)
 			}
+			secret = strings.TrimSpace(string(b))
+		}
+		roles, err := parseRoleMap(*oidcRoleMap)
+		if err != nil {
+			log.Fatalln("Error in -auth.oidc.role-map:", err)
+		}
+		auth.oidc = NewOIDCProvider(*oidcIssuer, *oidcClientID, secret, *oidcRedirectURL, strings.Fields(*oidcScopes),
+			*oidcUserClaim, *oidcGroupsClaim, roles, sessions)
+		session.refresher = auth.oidc
+
+		router.GET("/auth/oidc/login", auth.oidc.loginHandler())
+		router.GET("/auth/oidc/callback", auth.oidc.callbackHandler())
+	}
+
+	if *authHtpasswd != "" {
+		passwords, err := NewHtpasswdFile(*authHtpasswd)
+		if err != nil {
+			log.Fatalln("Error loading htpasswd file:", err)
 		}
+		auth.passwords = passwords
+		auth.basic = true
+		session.passwords = passwords
+		auth.methods = append(auth.methods, session, basicAuth{passwords})
+		router.POST("/login", auth.loginHandler())
+	} else {
+		auth.methods = append(auth.methods, session)
+	}
+
+	if auth.canLogin() {
+		router.GET("/login", auth.loginPageHandler())
+		router.GET("/login/methods", auth.loginMethodsHandler())
+		router.GET("/logout", auth.logoutHandler())
+		router.POST("/logout", auth.logoutHandler())
+	}
+	return auth
+}
 
-		if !foundServer {
+func vncWebSocket(manager *serverManager, broker *sessionBroker, access *accessControl, audit *auditLog) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		server, found := manager.Get(ps.ByName("shortname"))
+		if !found {
 			http.Error(w, "VNC host not found", 404)
 			return
 		}
+		if !access.Allowed(r, server, Perm_View) {
+			http.Error(w, ErrForbidden.Error(), 403)
+			return
+		}
+
+		// Users who may only view get the same filtering as read-only servers
+		readOnly := server.ReadOnly || *forceReadOnly || !access.Allowed(r, server, Perm_Interact)
 
 		log.With("type", server.NetType).
 			With("addr", server.Address).
-			With("user", server.Username).Infoln("Opening VNC connection to server")
+			With("user", server.Username).
+			With("readonly", readOnly).Infoln("Opening VNC connection to server")
 
-		vncConn, err := net.Dial(server.NetType, server.Address)
+		// Audited from here, so failed attempts are recorded too
+		auditSession := audit.Start(r, server, Viewer_WebSocket, !readOnly)
+
+		// Join (or start) the shared session for this server. The session
+		// authenticates to the VNC server itself so credentials never reach the browser.
+		session, err := broker.Acquire(server)
 		if err != nil {
-			log.Errorln("Error connecting to VNC server", err)
-			http.Error(w, "Error connecting to VNC server", 500)
+			log.With("server", server.Redacted()).Errorln("Error connecting to VNC server:", err)
+			auditSession.End(0, 0, err)
+			http.Error(w, "Error connecting to VNC server", 502)
 			return
 		}
-		defer vncConn.Close()
+		defer broker.Release(session)
 
 		protocols := websocket.Subprotocols(r)
 		log.Debugln("Subprotocols Requested:", protocols)
 		conn, err := wsupgrader.Upgrade(w, r, http.Header{"Sec-Websocket-Protocol": {protocols[0]}})
 		if err != nil {
 			log.Infoln("Websocket Upgrade:", err)
+			websocketUpgradeFailures.Inc()
+			auditSession.End(0, 0, err)
 			return
 		}
 		defer conn.Close()
@@ -494,54 +1253,25 @@ func vncWebSocket(manager *serverManager) httprouter.Handle {
 		log.With("local_addr", conn.LocalAddr()).
 			With("remote_addr", conn.RemoteAddr()).Debugln("Websocket online")
 
-		writerExit := make(chan int)
-		readerExit := make(chan int)
+		websocketSessions.WithLabelValues(server.Short()).Inc()
+		defer websocketSessions.WithLabelValues(server.Short()).Dec()
 
-		// Websocket -> VNC
-		go func() {
-			// Read loop
-			for {
-				_, message, err := conn.ReadMessage()
-				if err != nil {
-					log.Errorln("WEBSOCKET READ:", err)
-					break
-				}
-				_, err = vncConn.Write(message)
-				if err != nil {
-					log.Errorln("VNC WRITE:", err)
-					break
-				}
-			}
-			log.Debugln("Websocket reader finished")
-			close(readerExit)
-		}()
-
-		// VNC -> websocket
-		go func() {
-			rbuffer := make([]byte, 1024)
-			for {
-				numBytes, err := vncConn.Read(rbuffer)
-				if err != nil {
-					log.Errorln("VNC READ:", err)
-					break
-				}
-				// We originally forgot to specify numBytes, so sent frames of garbage
-				err = conn.WriteMessage(websocket.BinaryMessage, rbuffer[:numBytes])
-				if err != nil {
-					log.Errorln("WEBSOCKET WRITE:", err)
-					break
-				}
-			}
-			log.Debugln("Websocket writer finished")
-			close(writerExit)
-		}()
-
-		// Wait for reader or writer exit
-		select {
-		case <-writerExit:
-			break
-		case <-readerExit:
-			break
+		// Present the browser with a server which needs no authentication
+		wsStream := &countingReadWriter{
+			rw:      newWSConn(conn),
+			read:    proxiedBytes.WithLabelValues(server.Short(), "upstream"),
+			written: proxiedBytes.WithLabelValues(server.Short(), "downstream"),
+		}
+		if _, err := rfbServerHandshake(wsStream); err != nil {
+			log.Errorln("Websocket RFB handshake:", err)
+			bytesIn, bytesOut := wsStream.Totals()
+			auditSession.End(bytesIn, bytesOut, err)
+			return
 		}
+
+		err = serveSessionViewer(session, wsStream, readOnly, auditSession)
+		bytesIn, bytesOut := wsStream.Totals()
+		auditSession.End(bytesIn, bytesOut, err)
+		log.With("remote_addr", conn.RemoteAddr()).Debugln("Websocket viewer finished:", err)
 	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"sync/atomic"
+)
+
+const metricsNamespace = "vncdashboard"
+
+var (
+	websocketSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "websocket_sessions",
+		Help:      "Number of open websocket VNC sessions.",
+	}, []string{"server"})
+
+	proxiedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "proxied_bytes_total",
+		Help:      "Bytes of RFB data proxied over websockets, upstream from viewers or downstream to them.",
+	}, []string{"server", "direction"})
+
+	dialFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "dial_failures_total",
+		Help:      "Failed connections to VNC servers.",
+	}, []string{"server"})
+
+	websocketUpgradeFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "websocket_upgrade_failures_total",
+		Help:      "Websocket connections which failed to upgrade.",
+	})
+
+	droppedEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "sse_dropped_messages_total",
+		Help:      "Times an SSE subscriber fell behind the event journal and missed events.",
+	})
+
+	probeLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "probe_latency_seconds",
+		Help:      "Time taken by successful health probes.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"server"})
+)
+
+func init() {
+	prometheus.MustRegister(websocketSessions)
+	prometheus.MustRegister(proxiedBytes)
+	prometheus.MustRegister(dialFailures)
+	prometheus.MustRegister(websocketUpgradeFailures)
+	prometheus.MustRegister(droppedEvents)
+	prometheus.MustRegister(probeLatency)
+}
+
+// Register metrics which are read from the server manager when scraped
+func registerManagerMetrics(manager *serverManager) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "servers",
+		Help:      "Number of known VNC servers.",
+	}, func() float64 {
+		return float64(len(manager.List()))
+	}))
+}
+
+// Counts the bytes passing through a stream
+type countingReadWriter struct {
+	rw      io.ReadWriter
+	read    prometheus.Counter
+	written prometheus.Counter
+
+	// Totals for this stream alone. Accessed atomically.
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func (this *countingReadWriter) Read(p []byte) (int, error) {
+	n, err := this.rw.Read(p)
+	this.read.Add(float64(n))
+	atomic.AddInt64(&this.bytesRead, int64(n))
+	return n, err
+}
+
+func (this *countingReadWriter) Write(p []byte) (int, error) {
+	n, err := this.rw.Write(p)
+	this.written.Add(float64(n))
+	atomic.AddInt64(&this.bytesWritten, int64(n))
+	return n, err
+}
+
+// Bytes read and written through this stream
+func (this *countingReadWriter) Totals() (int64, int64) {
+	return atomic.LoadInt64(&this.bytesRead), atomic.LoadInt64(&this.bytesWritten)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountingReadWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		writes  []string
+		written float64
+	}{
+		{"idle", "", nil, 0},
+		{"read", "RFB 003.008\n", nil, 0},
+		{"written", "", []string{"RFB 003.008\n", "", "\x01"}, 13},
+		{"both", "abc", []string{"de"}, 2},
+	}
+	for _, test := range tests {
+		read := prometheus.NewCounter(prometheus.CounterOpts{Name: "read"})
+		written := prometheus.NewCounter(prometheus.CounterOpts{Name: "written"})
+		rw := &countingReadWriter{
+			rw: struct {
+				io.Reader
+				io.Writer
+			}{strings.NewReader(test.input), ioutil.Discard},
+			read:    read,
+			written: written,
+		}
+		for _, s := range test.writes {
+			if _, err := io.WriteString(rw, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if b, err := ioutil.ReadAll(rw); err != nil || string(b) != test.input {
+			t.Errorf("%v: read %q, %v", test.name, b, err)
+		}
+		if got := testutil.ToFloat64(read); got != float64(len(test.input)) {
+			t.Errorf("%v: counted %v read, expected %v", test.name, got, len(test.input))
+		}
+		if got := testutil.ToFloat64(written); got != test.written {
+			t.Errorf("%v: counted %v written, expected %v", test.name, got, test.written)
+		}
+		if in, out := rw.Totals(); in != int64(len(test.input)) || out != int64(test.written) {
+			t.Errorf("%v: totals %v in, %v out", test.name, in, out)
+		}
+	}
+}
+
+func TestEventMetrics(t *testing.T) {
+	manager := NewServerManager()
+	sub := manager.Subscribe()
+	server := vncServer{NetType: "tcp", Address: "a:5900"}
+
+	dropped := testutil.ToFloat64(droppedEvents)
+	manager.Notify(Manager_StaleServer, server, nil)
+	sub.Take()
+	if got := testutil.ToFloat64(droppedEvents) - dropped; got != 0 {
+		t.Errorf("counted %v dropped events from a subscriber which kept up", got)
+	}
+
+	for i := 0; i <= managerJournalSize; i++ {
+		manager.Notify(Manager_StaleServer, server, nil)
+	}
+	sub.Take()
+	if got := testutil.ToFloat64(droppedEvents) - dropped; got != 1 {
+		t.Errorf("counted %v dropped events, expected 1", got)
+	}
+}
+
+func TestProbeMetrics(t *testing.T) {
+	up := newFakeVNCServer(t, 8, 8, "desktop")
+	defer up.Close()
+	refused := vncServer{NetType: "tcp", Address: "127.0.0.1:1"}
+
+	failures := testutil.ToFloat64(dialFailures.WithLabelValues(refused.Short()))
+	probeServer(refused, time.Second)
+	if got := testutil.ToFloat64(dialFailures.WithLabelValues(refused.Short())) - failures; got != 1 {
+		t.Errorf("counted %v dial failures, expected 1", got)
+	}
+
+	probeServer(up.Server(), time.Second)
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := uint64(0)
+	for _, family := range families {
+		if family.GetName() != "vncdashboard_probe_latency_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "server" && label.GetValue() == up.Server().Short() {
+					observed = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if observed != 1 {
+		t.Errorf("got %v probe latencies, expected 1", observed)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Longest time between frames of an unchanging screen, so clients which expect a
+// steady stream don't time out.
+const mjpegRefreshInterval = 5 * time.Second
+
+// Parse the frame rate query parameter
+func parseFrameRate(s string, defaultRate float64, maxRate float64) (float64, error) {
+	if s == "" {
+		return defaultRate, nil
+	}
+	fps, err := strconv.ParseFloat(s, 64)
+	if err != nil || fps <= 0 || fps > maxRate {
+		return 0, fmt.Errorf("invalid fps: %v (maximum %v)", s, maxRate)
+	}
+	return fps, nil
+}
+
+// Streams a server's screen as motion JPEG. Frames are only sent when the screen
+// changes, no faster than the requested frame rate.
+func mjpegHandler(manager *serverManager, broker *sessionBroker, audit *auditLog, defaultRate float64, maxRate float64) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		server, found := requestServer(r, manager, ps)
+		if !found {
+			http.Error(w, "VNC host not found", 404)
+			return
+		}
+		query := r.URL.Query()
+		fps, err := parseFrameRate(query.Get("fps"), defaultRate, maxRate)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		quality, err := parseImageQuality(query)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		// Ended with why the stream stopped and how much was sent
+		var sent int64
+		var streamErr error
+		auditSession := audit.Start(r, server, Viewer_MJPEG, false)
+		defer func() { auditSession.End(0, sent, streamErr) }()
+
+		session, err := broker.Acquire(server)
+		if err != nil {
+			log.With("server", server.Redacted()).Errorln("Error connecting to VNC server:", err)
+			streamErr = err
+			http.Error(w, "Error connecting to VNC server", 502)
+			return
+		}
+		defer broker.Release(session)
+
+		sub := session.Subscribe()
+		defer session.Unsubscribe(sub)
+
+		select {
+		case <-session.Updated():
+		case <-session.Done():
+			streamErr = session.Err()
+			http.Error(w, "Error connecting to VNC server", 502)
+			return
+		case <-r.Context().Done():
+			return
+		case <-time.After(screenshotTimeout):
+			streamErr = ErrScreenshotTimeout
+			http.Error(w, "Error connecting to VNC server", 502)
+			return
+		}
+
+		// Check the size against the screen before committing to a stream
+		if _, _, err := parseImageSize(query, session.Bounds()); err != nil {
+			streamErr = err
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+		w.Header().Set("Cache-Control", "no-cache")
+		flusher, _ := w.(http.Flusher)
+
+		log.With("server", server.Redacted()).
+			With("remote_addr", r.RemoteAddr).
+			With("fps", fps).Debugln("Starting MJPEG stream")
+
+		frameInterval := time.Duration(float64(time.Second) / fps)
+		buf := &bytes.Buffer{}
+		changed := true
+		for {
+			if changed {
+				img := session.Snapshot()
+				width, height, err := parseImageSize(query, img.Bounds())
+				if err != nil {
+					log.With("server", server.Redacted()).Debugln("Ending MJPEG stream:", err)
+					return
+				}
+				buf.Reset()
+				if err := jpeg.Encode(buf, scaleImage(img, width, height), &jpeg.Options{Quality: quality}); err != nil {
+					log.With("server", server.Redacted()).Errorln("Error encoding MJPEG frame:", err)
+					streamErr = err
+					return
+				}
+			}
+
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":   {"image/jpeg"},
+				"Content-Length": {strconv.Itoa(buf.Len())},
+			})
+			if err == nil {
+				_, err = part.Write(buf.Bytes())
+			}
+			if err != nil {
+				log.With("remote_addr", r.RemoteAddr).Debugln("MJPEG stream finished:", err)
+				return
+			}
+			sent += int64(buf.Len())
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			select {
+			case <-session.Done():
+				streamErr = session.Err()
+				return
+			case <-r.Context().Done():
+				return
+			case <-time.After(frameInterval):
+			}
+
+			// Wait for a change, resending the last frame if it takes too long
+			changed = false
+			refresh := time.After(mjpegRefreshInterval - frameInterval)
+		wait:
+			for !changed {
+				select {
+				case <-session.Done():
+					streamErr = session.Err()
+					return
+				case <-r.Context().Done():
+					return
+				case <-refresh:
+					break wait
+				case <-sub.Notify():
+					update := sub.Take()
+					changed = !update.Dirty.Empty() || update.Resized
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		s   string
+		fps float64
+		err bool
+	}{
+		{"", 5, false},
+		{"1", 1, false},
+		{"0.5", 0.5, false},
+		{"25", 25, false},
+		{"25.1", 0, true},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"fast", 0, true},
+	}
+	for _, test := range tests {
+		fps, err := parseFrameRate(test.s, 5, 25)
+		if (err != nil) != test.err || fps != test.fps {
+			t.Errorf("%q: got %v, %v, expected %v", test.s, fps, err, test.fps)
+		}
+	}
+}
+
+// Read the next frame of an MJPEG stream
+func readMJPEGFrame(t *testing.T, mr *multipart.Reader) image.Image {
+	t.Helper()
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := part.Header.Get("Content-Type"); contentType != "image/jpeg" {
+		t.Errorf("got frame of %v", contentType)
+	}
+	length, err := strconv.Atoi(part.Header.Get("Content-Length"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(part, b); err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func isColour(img image.Image, expected color.RGBA) bool {
+	r, g, b, _ := img.At(img.Bounds().Dx()/2, img.Bounds().Dy()/2).RGBA()
+	near := func(v uint32, e uint8) bool { return int(v>>8)-int(e) < 0x10 && int(e)-int(v>>8) < 0x10 }
+	return near(r, expected.R) && near(g, expected.G) && near(b, expected.B)
+}
+
+func TestMJPEGHandler(t *testing.T) {
+	upstream := newFakeVNCServer(t, 32, 16, "desktop")
+	defer upstream.Close()
+	filled := newFilledVNCServer(t, 32, 16, color.RGBA{0xff, 0, 0, 0xff})
+	defer filled.Close()
+	manager := NewServerManager()
+	manager.Add(upstream.Server())
+	manager.Add(filled.Server())
+	closed := vncServer{NetType: "tcp", Address: "127.0.0.1:1"}
+	manager.Add(closed)
+	broker := NewSessionBroker(nil)
+	audited := &lockedBuffer{}
+
+	router := httprouter.New()
+	router.GET("/api/servers/:shortname/stream.mjpeg", mjpegHandler(manager, broker, &auditLog{w: audited}, 5, 50))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	tests := []struct {
+		server string
+		query  string
+		status int
+		audit  string
+	}{
+		{filled.Server().Short(), "fps=51", 400, ""},
+		{filled.Server().Short(), "fps=0", 400, ""},
+		{filled.Server().Short(), "quality=0", 400, ""},
+		{filled.Server().Short(), "width=9000", 400, "error"},
+		{"missing", "", 404, ""},
+		{closed.Short(), "", 502, "error"},
+	}
+	for _, test := range tests {
+		audited.Reset()
+		resp, err := http.Get(ts.URL + "/api/servers/" + test.server + "/stream.mjpeg?" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%v?%v: got status %v, expected %v", test.server, test.query, resp.StatusCode, test.status)
+		}
+		if audit := auditOutcome(t, audited.Bytes(), Viewer_MJPEG); audit != test.audit {
+			t.Errorf("%v?%v: audited %q, expected %q", test.server, test.query, audit, test.audit)
+		}
+	}
+	audited.Reset()
+
+	// Frames are sent as the screen changes
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/api/servers/" + upstream.Server().Short() + "/stream.mjpeg?fps=50&width=16")
+		if err != nil {
+			t.Error(err)
+		}
+		responses <- resp
+	}()
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	conn.SendFill(t, image.Rect(0, 0, 32, 16), color.RGBA{0xff, 0, 0, 0xff})
+	resp := <-responses
+	if resp == nil {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/x-mixed-replace" {
+		t.Fatalf("got stream of %v, %v", mediaType, err)
+	}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	img := readMJPEGFrame(t, mr)
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 || !isColour(img, color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("first frame is %v of %v, expected red", img.Bounds(), img.At(8, 4))
+	}
+
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	start := time.Now()
+	conn.SendFill(t, image.Rect(0, 0, 32, 16), color.RGBA{0, 0, 0xff, 0xff})
+	img = readMJPEGFrame(t, mr)
+	if !isColour(img, color.RGBA{0, 0, 0xff, 0xff}) {
+		t.Errorf("second frame is %v, expected blue", img.At(8, 4))
+	}
+	if time.Since(start) > mjpegRefreshInterval/2 {
+		t.Errorf("change took %v to be sent", time.Since(start))
+	}
+
+	// The stream ends with the session
+	conn.Close()
+	ended := make(chan error, 1)
+	go func() {
+		_, err := mr.NextPart()
+		ended <- err
+	}()
+	select {
+	case err := <-ended:
+		if err == nil {
+			t.Error("got another frame after the session ended")
+		}
+	case <-time.After(testTimeout):
+		t.Error("stream still running after the session ended")
+	}
+
+	// The end of the stream is audited with what was sent. The server closing the
+	// connection isn't an error.
+	deadline := time.Now().Add(testTimeout)
+	for len(decodeAuditRecords(t, audited.Bytes(), true)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if audit := auditOutcome(t, audited.Bytes(), Viewer_MJPEG); audit != "ok" {
+		t.Errorf("stream audited %q, expected ok", audit)
+	}
+	if records := decodeAuditRecords(t, audited.Bytes(), true); records[1]["bytes_out"] == nil {
+		t.Errorf("stream audited without bytes sent: %v", records[1])
+	}
+}
+
+func TestMJPEGClientGone(t *testing.T) {
+	filled := newFilledVNCServer(t, 32, 16, color.RGBA{0xff, 0, 0, 0xff})
+	defer filled.Close()
+	manager := NewServerManager()
+	manager.Add(filled.Server())
+	handler := mjpegHandler(manager, NewSessionBroker(nil), nil, 5, 50)
+
+	for _, after := range []time.Duration{0, 300 * time.Millisecond} {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := httptest.NewRequest("GET", "/api/servers/"+filled.Server().Short()+"/stream.mjpeg", nil).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			handler(httptest.NewRecorder(), r, httprouter.Params{{Key: "shortname", Value: filled.Server().Short()}})
+			close(done)
+		}()
+		time.Sleep(after)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(testTimeout):
+			t.Errorf("stream still running %v after the client went away", after)
+		}
+	}
+}
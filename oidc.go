@@ -0,0 +1,582 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie holding the state of a login in progress
+const oidcStateCookieName = "vncdashboard_oidc"
+
+// How long a login may take at the identity provider
+const oidcLoginTimeout = time.Minute * 10
+
+// Timeout for requests to the identity provider
+const oidcRequestTimeout = time.Second * 10
+
+// How long a refreshed session is remembered, so concurrent requests carrying the
+// same expired session don't each spend its refresh token
+const oidcRefreshCacheTime = time.Minute
+
+// Allowed difference between our clock and the identity provider's
+const oidcClockSkew = time.Minute
+
+var (
+	ErrOIDCState        = errors.New("login state is missing or doesn't match")
+	ErrIDTokenInvalid   = errors.New("invalid ID token")
+	ErrIDTokenSignature = errors.New("ID token signature doesn't verify")
+	ErrNoRefreshToken   = errors.New("session can't be refreshed")
+)
+
+// Endpoints from the provider's discovery document
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// A key from the provider's JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Public key of a JSON web key, or nil if it isn't a supported kind
+func (this jsonWebKey) PublicKey() crypto.PublicKey {
+	b64 := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	switch {
+	case this.Kty == "RSA":
+		n, e := b64(this.N), b64(this.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case this.Kty == "EC" && this.Crv == "P-256":
+		x, y := b64(this.X), b64(this.Y)
+		if x == nil || y == nil {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	}
+	return nil
+}
+
+// Response from the token endpoint
+type oidcTokenResponse struct {
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// State of a login in progress, kept in a sealed cookie
+type oidcLoginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	Next     string `json:"next"`     // Where to go after logging in
+	Expires  int64  `json:"exp"`
+}
+
+// Logs users in as an OpenID Connect relying party with the authorization code
+// flow and PKCE, and renews their sessions with refresh tokens
+type oidcProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string // Our callback. Worked out from the request if empty.
+	scopes       []string
+	userClaim    string
+	groupsClaim  string
+	roles        map[string][]string // Roles by group
+	sessions     *sessionCodec
+	client       *http.Client
+
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey // By key ID
+	mtx       sync.Mutex
+
+	refreshed  map[string]oidcRefreshResult // Recent refreshes by old refresh token
+	refreshMtx sync.Mutex
+}
+
+type oidcRefreshResult struct {
+	session sessionPayload
+	err     error
+	time    time.Time
+}
+
+func NewOIDCProvider(issuer string, clientID string, clientSecret string, redirectURL string, scopes []string,
+	userClaim string, groupsClaim string, roles map[string][]string, sessions *sessionCodec) *oidcProvider {
+	return &oidcProvider{
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		userClaim:    userClaim,
+		groupsClaim:  groupsClaim,
+		roles:        roles,
+		sessions:     sessions,
+		client:       &http.Client{Timeout: oidcRequestTimeout},
+		refreshed:    make(map[string]oidcRefreshResult),
+	}
+}
+
+// Parse a group to role mapping like "admins=admin,ops=operator,ops=viewer"
+func parseRoleMap(s string) (map[string][]string, error) {
+	roles := make(map[string][]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid role mapping: %v", entry)
+		}
+		roles[kv[0]] = append(roles[kv[0]], kv[1])
+	}
+	return roles, nil
+}
+
+func (this *oidcProvider) getJSON(url string, v interface{}) error {
+	resp, err := this.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%v returned %v", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Fetch the discovery document, unless it already has been
+func (this *oidcProvider) discover() (*oidcDiscovery, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if this.discovery != nil {
+		return this.discovery, nil
+	}
+	discovery := &oidcDiscovery{}
+	if err := this.getJSON(this.issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != this.issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %v", discovery.Issuer)
+	}
+	log.With("issuer", this.issuer).Infoln("Discovered OpenID Connect provider")
+	this.discovery = discovery
+	return discovery, nil
+}
+
+// Find a signing key, fetching the provider's keys again if it isn't known, since
+// providers rotate them
+func (this *oidcProvider) key(kid string) (crypto.PublicKey, error) {
+	discovery, err := this.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if key, ok := this.keys[kid]; ok {
+		return key, nil
+	}
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := this.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	this.keys = make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key := k.PublicKey(); key != nil {
+			this.keys[k.Kid] = key
+		}
+	}
+
+	key, ok := this.keys[kid]
+	if !ok && kid == "" && len(this.keys) == 1 {
+		// Tokens needn't name the key if there's only one
+		for _, k := range this.keys {
+			key, ok = k, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %v", kid)
+	}
+	return key, nil
+}
+
+// Check an ID token's signature and claims, returning the claims. The nonce is
+// only checked if given, since refreshed tokens needn't carry it.
+func (this *oidcProvider) verifyIDToken(token string, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrIDTokenInvalid
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrIDTokenInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrIDTokenInvalid
+	}
+
+	key, err := this.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return nil, ErrIDTokenSignature
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 ||
+			!ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, ErrIDTokenSignature
+		}
+	default:
+		return nil, ErrIDTokenSignature
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrIDTokenInvalid
+	}
+	now := time.Now()
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != this.issuer {
+		return nil, fmt.Errorf("ID token is from issuer %v", iss)
+	}
+	if !claimContains(claims["aud"], this.clientID) {
+		return nil, errors.New("ID token isn't for this client")
+	}
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("ID token has expired")
+	}
+	if nonce != "" {
+		if n, _ := claims["nonce"].(string); n != nonce {
+			return nil, errors.New("ID token nonce doesn't match")
+		}
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Check whether a string or list of strings claim includes a value
+func claimContains(claim interface{}, value string) bool {
+	for _, v := range claimStrings(claim) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// A claim which may be a string or a list of them
+func claimStrings(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []interface{}:
+		r := []string{}
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				r = append(r, s)
+			}
+		}
+		return r
+	}
+	return nil
+}
+
+// Make the identity for ID token claims
+func (this *oidcProvider) identity(claims map[string]interface{}) (identity, error) {
+	user, _ := claims[this.userClaim].(string)
+	if user == "" {
+		user, _ = claims["sub"].(string)
+	}
+	if user == "" {
+		return identity{}, errors.New("ID token has no subject")
+	}
+
+	id := identity{User: user, Groups: claimStrings(claims[this.groupsClaim]), Method: Auth_OIDC}
+	seen := make(map[string]struct{})
+	for _, group := range id.Groups {
+		for _, role := range this.roles[group] {
+			if _, ok := seen[role]; !ok {
+				seen[role] = struct{}{}
+				id.Roles = append(id.Roles, role)
+			}
+		}
+	}
+	return id, nil
+}
+
+// Make a session from a token response. The session is checked with the
+// provider again when the tokens expire.
+func (this *oidcProvider) session(tokens oidcTokenResponse, id identity) sessionPayload {
+	session := this.sessions.New(id)
+	session.Refresh = tokens.RefreshToken
+	expiresIn := tokens.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = int64(oidcRefreshCacheTime.Seconds()) * 5
+	}
+	session.RefreshAt = time.Now().Unix() + expiresIn
+	if session.RefreshAt > session.Expires {
+		session.RefreshAt = session.Expires
+	}
+	return session
+}
+
+// Call the token endpoint
+func (this *oidcProvider) token(form url.Values) (oidcTokenResponse, error) {
+	discovery, err := this.discover()
+	if err != nil {
+		return oidcTokenResponse{}, err
+	}
+
+	form.Set("client_id", this.clientID)
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oidcTokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if this.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(this.clientID), url.QueryEscape(this.clientSecret))
+	}
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return oidcTokenResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	tokens := oidcTokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return oidcTokenResponse{}, fmt.Errorf("token endpoint returned %v", resp.Status)
+	}
+	if tokens.Error != "" {
+		return oidcTokenResponse{}, fmt.Errorf("token endpoint returned %v: %v", tokens.Error, tokens.ErrorDesc)
+	}
+	if resp.StatusCode != 200 {
+		return oidcTokenResponse{}, fmt.Errorf("token endpoint returned %v", resp.Status)
+	}
+	return tokens, nil
+}
+
+// Renew a session with its refresh token. Sessions are only refreshed once, and
+// requests which arrive with the same session meanwhile share the result.
+func (this *oidcProvider) Refresh(session sessionPayload) (sessionPayload, error) {
+	if session.Refresh == "" {
+		return sessionPayload{}, ErrNoRefreshToken
+	}
+
+	// Held for the whole refresh so each refresh token is only spent once
+	this.refreshMtx.Lock()
+	defer this.refreshMtx.Unlock()
+
+	for k, result := range this.refreshed {
+		if time.Since(result.time) > oidcRefreshCacheTime {
+			delete(this.refreshed, k)
+		}
+	}
+	if result, ok := this.refreshed[session.Refresh]; ok {
+		return result.session, result.err
+	}
+	refreshed, err := this.refresh(session)
+	this.refreshed[session.Refresh] = oidcRefreshResult{refreshed, err, time.Now()}
+	return refreshed, err
+}
+
+func (this *oidcProvider) refresh(session sessionPayload) (sessionPayload, error) {
+	tokens, err := this.token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {session.Refresh},
+	})
+	if err != nil {
+		return sessionPayload{}, err
+	}
+
+	id := session.Identity
+	if tokens.IDToken != "" {
+		claims, err := this.verifyIDToken(tokens.IDToken, "")
+		if err != nil {
+			return sessionPayload{}, err
+		}
+		if id, err = this.identity(claims); err != nil {
+			return sessionPayload{}, err
+		}
+		if id.User != session.Identity.User {
+			return sessionPayload{}, errors.New("refreshed ID token is for another user")
+		}
+	}
+	if tokens.RefreshToken == "" {
+		// Providers which don't rotate refresh tokens keep the old one
+		tokens.RefreshToken = session.Refresh
+	}
+	log.With("user", id.User).Debugln("Refreshed OpenID Connect session")
+	return this.session(tokens, id), nil
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Our callback URL, as registered with the provider
+func (this *oidcProvider) callbackURL(r *http.Request) string {
+	if this.redirectURL != "" {
+		return this.redirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/auth/oidc/callback"
+}
+
+// Send the user to the provider to log in
+func (this *oidcProvider) loginHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		discovery, err := this.discover()
+		if err != nil {
+			log.Errorln("OpenID Connect discovery failed:", err)
+			http.Error(w, "Identity provider unavailable", 502)
+			return
+		}
+
+		state := oidcLoginState{
+			State:    randomString(),
+			Nonce:    randomString(),
+			Verifier: randomString(),
+			Next:     safeRedirect(r.URL.Query().Get("next")),
+			Expires:  time.Now().Add(oidcLoginTimeout).Unix(),
+		}
+		b, _ := json.Marshal(state)
+		this.sessions.setCookie(w, r, oidcStateCookieName, this.sessions.seal(oidcStateCookieName, b), time.Unix(state.Expires, 0))
+
+		challenge := sha256.Sum256([]byte(state.Verifier))
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {this.clientID},
+			"redirect_uri":          {this.callbackURL(r)},
+			"scope":                 {strings.Join(this.scopes, " ")},
+			"state":                 {state.State},
+			"nonce":                 {state.Nonce},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+		target := discovery.AuthorizationEndpoint
+		if strings.Contains(target, "?") {
+			target += "&" + query.Encode()
+		} else {
+			target += "?" + query.Encode()
+		}
+		http.Redirect(w, r, target, 302)
+	}
+}
+
+// Finish logging in when the provider sends the user back
+func (this *oidcProvider) callbackHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		query := r.URL.Query()
+		if e := query.Get("error"); e != "" {
+			log.With("remote_addr", r.RemoteAddr).Warnln("OpenID Connect login failed:", e, query.Get("error_description"))
+			http.Error(w, "Login failed: "+e, 403)
+			return
+		}
+
+		state := oidcLoginState{}
+		cookie, err := r.Cookie(oidcStateCookieName)
+		if err == nil {
+			if b, ok := this.sessions.open(oidcStateCookieName, cookie.Value); ok {
+				err = json.Unmarshal(b, &state)
+			}
+		}
+		this.sessions.clearCookie(w, r, oidcStateCookieName)
+		if err != nil || state.State == "" || state.State != query.Get("state") || time.Now().Unix() >= state.Expires {
+			http.Error(w, ErrOIDCState.Error(), 400)
+			return
+		}
+
+		tokens, err := this.token(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {query.Get("code")},
+			"redirect_uri":  {this.callbackURL(r)},
+			"code_verifier": {state.Verifier},
+		})
+		if err != nil {
+			log.With("remote_addr", r.RemoteAddr).Warnln("OpenID Connect code exchange failed:", err)
+			http.Error(w, "Login failed", 403)
+			return
+		}
+		claims, err := this.verifyIDToken(tokens.IDToken, state.Nonce)
+		if err != nil {
+			log.With("remote_addr", r.RemoteAddr).Warnln("OpenID Connect ID token rejected:", err)
+			http.Error(w, "Login failed", 403)
+			return
+		}
+		id, err := this.identity(claims)
+		if err != nil {
+			http.Error(w, "Login failed: "+err.Error(), 403)
+			return
+		}
+
+		log.With("user", id.User).With("roles", strings.Join(id.Roles, ",")).With("remote_addr", r.RemoteAddr).Infoln("Logged in with OpenID Connect")
+		this.sessions.SetCookie(w, r, this.session(tokens, id))
+		http.Redirect(w, r, state.Next, 303)
+	}
+}
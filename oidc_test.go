@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A stand-in OpenID Connect provider. Every login is for the user alice in the
+// ops group, and the refresh token rt1 can be exchanged once.
+type testIssuer struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	other *rsa.PrivateKey // Signs tokens which shouldn't verify
+
+	mtx       sync.Mutex
+	challenge string
+	nonce     string
+	refreshes int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	this := &testIssuer{}
+	var err error
+	if this.key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if this.other, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                this.URL,
+			AuthorizationEndpoint: this.URL + "/authorize",
+			TokenEndpoint:         this.URL + "/token",
+			JWKSURI:               this.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": {{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(this.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(this.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" || query.Get("response_type") != "code" {
+			http.Error(w, "bad request", 400)
+			return
+		}
+		this.mtx.Lock()
+		this.challenge, this.nonce = query.Get("code_challenge"), query.Get("nonce")
+		this.mtx.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code=c1&state="+url.QueryEscape(query.Get("state")), 302)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		this.mtx.Lock()
+		defer this.mtx.Unlock()
+
+		claims := this.claims()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "c1" || base64.RawURLEncoding.EncodeToString(verifier[:]) != this.challenge {
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_grant"})
+				return
+			}
+			claims["nonce"] = this.nonce
+			json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: this.sign(claims, "RS256", this.key), RefreshToken: "rt1", ExpiresIn: 300})
+		case "refresh_token":
+			this.refreshes++
+			if r.Form.Get("refresh_token") != "rt1" {
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: this.sign(claims, "RS256", this.key), RefreshToken: "rt2", ExpiresIn: 300})
+		}
+	})
+	this.Server = httptest.NewServer(mux)
+	return this
+}
+
+func (this *testIssuer) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                this.URL,
+		"aud":                "dashboard",
+		"sub":                "u1",
+		"preferred_username": "alice",
+		"groups":             []string{"ops", "other"},
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (this *testIssuer) sign(claims map[string]interface{}, alg string, key *rsa.PrivateKey) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": "k1"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// A dashboard logging in through the issuer, serving whoami
+func newTestOIDCDashboard(t *testing.T, issuer *testIssuer) (*httptest.Server, *oidcProvider, *sessionCodec) {
+	sessions, err := NewSessionCodec("", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err := parseRoleMap("ops=operator,ops=viewer,admins=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewOIDCProvider(issuer.URL+"/", "dashboard", "", "", []string{"openid"},
+		"preferred_username", "groups", roles, sessions)
+
+	auth := &authenticator{
+		methods:  []authMethod{sessionAuth{sessions: sessions, refresher: provider}},
+		sessions: sessions,
+		oidc:     provider,
+	}
+	router := httprouter.New()
+	router.GET("/api/whoami", whoamiHandler())
+	router.GET("/auth/oidc/login", provider.loginHandler())
+	router.GET("/auth/oidc/callback", provider.callbackHandler())
+	return httptest.NewServer(auth.Wrap(router)), provider, sessions
+}
+
+func newTestClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar}
+}
+
+func getIdentity(t *testing.T, c *http.Client, url string) (identity, int) {
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	id := identity{}
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id, resp.StatusCode
+}
+
+func TestOIDCLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	dashboard, _, _ := newTestOIDCDashboard(t, issuer)
+	defer dashboard.Close()
+
+	c := newTestClient()
+	id, status := getIdentity(t, c, dashboard.URL+"/auth/oidc/login?next=/api/whoami")
+	if status != 200 {
+		t.Fatalf("login ended with status %v", status)
+	}
+	expected := identity{User: "alice", Groups: []string{"ops", "other"}, Roles: []string{"operator", "viewer"}, Method: Auth_OIDC}
+	if !reflect.DeepEqual(id, expected) {
+		t.Errorf("logged in as %+v, expected %+v", id, expected)
+	}
+
+	// The callback can't be replayed once the login state is used up
+	resp, err := c.Get(dashboard.URL + "/auth/oidc/callback?code=c1&state=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("replayed callback returned %v, expected 400", resp.StatusCode)
+	}
+}
+
+func TestOIDCStateCookieIsNotASession(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	dashboard, _, _ := newTestOIDCDashboard(t, issuer)
+	defer dashboard.Close()
+
+	c := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := c.Get(dashboard.URL + "/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var state *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookieName {
+			state = cookie
+		}
+	}
+	if state == nil {
+		t.Fatal("no login state cookie set")
+	}
+
+	req, _ := http.NewRequest("GET", dashboard.URL+"/api/whoami", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: state.Value})
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Errorf("login state accepted as a session, status %v", resp.StatusCode)
+	}
+}
+
+func TestOIDCRefresh(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	dashboard, _, sessions := newTestOIDCDashboard(t, issuer)
+	defer dashboard.Close()
+	base, _ := url.Parse(dashboard.URL)
+
+	tests := []struct {
+		refresh string
+		status  int
+	}{
+		{"rt1", 200},
+		{"revoked", 401},
+	}
+	for _, test := range tests {
+		session := sessions.New(identity{User: "alice", Method: Auth_OIDC})
+		session.Refresh = test.refresh
+		session.RefreshAt = time.Now().Add(-time.Minute).Unix()
+
+		c := newTestClient()
+		c.Jar.SetCookies(base, []*http.Cookie{{Name: sessionCookieName, Value: sessions.Encode(session)}})
+		id, status := getIdentity(t, c, dashboard.URL+"/api/whoami")
+		if status != test.status {
+			t.Errorf("refresh token %v: status %v, expected %v", test.refresh, status, test.status)
+			continue
+		}
+		if status != 200 {
+			// The session ended, so it isn't sent again
+			if cookies := c.Jar.Cookies(base); len(cookies) != 0 {
+				t.Errorf("refresh token %v: session cookie not cleared", test.refresh)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(id.Roles, []string{"operator", "viewer"}) {
+			t.Errorf("refreshed roles %v", id.Roles)
+		}
+
+		// The renewed session carries the rotated token and isn't refreshed again
+		if _, status := getIdentity(t, c, dashboard.URL+"/api/whoami"); status != 200 {
+			t.Errorf("renewed session rejected with %v", status)
+		}
+	}
+	if issuer.refreshes != 2 {
+		t.Errorf("%v refreshes, expected 2", issuer.refreshes)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	sessions, _ := NewSessionCodec("", time.Hour)
+	provider := NewOIDCProvider(issuer.URL, "dashboard", "", "", nil, "preferred_username", "groups", nil, sessions)
+
+	with := func(k string, v interface{}) map[string]interface{} {
+		claims := issuer.claims()
+		claims["nonce"] = "n1"
+		claims[k] = v
+		return claims
+	}
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", issuer.sign(with("nonce", "n1"), "RS256", issuer.key), true},
+		{"audience list", issuer.sign(with("aud", []string{"x", "dashboard"}), "RS256", issuer.key), true},
+		{"bad signature", issuer.sign(with("nonce", "n1"), "RS256", issuer.other), false},
+		{"wrong alg", issuer.sign(with("nonce", "n1"), "HS256", issuer.key), false},
+		{"no alg", issuer.sign(with("nonce", "n1"), "none", issuer.key), false},
+		{"wrong audience", issuer.sign(with("aud", "someone-else"), "RS256", issuer.key), false},
+		{"wrong issuer", issuer.sign(with("iss", "https://evil.example.com"), "RS256", issuer.key), false},
+		{"wrong nonce", issuer.sign(with("nonce", "n2"), "RS256", issuer.key), false},
+		{"expired", issuer.sign(with("exp", time.Now().Add(-time.Hour).Unix()), "RS256", issuer.key), false},
+		{"no expiry", issuer.sign(with("exp", nil), "RS256", issuer.key), false},
+		{"malformed", "a.b", false},
+	}
+	for _, test := range tests {
+		_, err := provider.verifyIDToken(test.token, "n1")
+		if (err == nil) != test.valid {
+			t.Errorf("%v: got error %v", test.name, err)
+		}
+	}
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	roles, err := parseRoleMap(" ops=operator, ops=viewer,admins=admin,ops=viewer ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &oidcProvider{userClaim: "preferred_username", groupsClaim: "groups", roles: roles}
+
+	tests := []struct {
+		claims map[string]interface{}
+		user   string
+		roles  []string
+	}{
+		{map[string]interface{}{"sub": "u1", "preferred_username": "alice", "groups": []interface{}{"ops", "admins"}}, "alice", []string{"operator", "viewer", "admin"}},
+		{map[string]interface{}{"sub": "u2", "groups": "admins"}, "u2", []string{"admin"}},
+		{map[string]interface{}{"sub": "u3", "groups": []interface{}{"nobody"}}, "u3", nil},
+	}
+	for _, test := range tests {
+		id, err := provider.identity(test.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.User != test.user || !reflect.DeepEqual(id.Roles, test.roles) {
+			t.Errorf("claims %v gave %v with roles %v, expected %v with %v", test.claims, id.User, id.Roles, test.user, test.roles)
+		}
+	}
+
+	if _, err := provider.identity(map[string]interface{}{}); err == nil {
+		t.Error("identity without a subject accepted")
+	}
+	for _, bad := range []string{"ops", "=admin", "ops="} {
+		if _, err := parseRoleMap(bad); err == nil || !strings.Contains(err.Error(), "invalid role mapping") {
+			t.Errorf("role map %q accepted", bad)
+		}
+	}
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	sessions, _ := NewSessionCodec("", time.Hour)
+	provider := NewOIDCProvider(issuer.URL+"/realms/other", "dashboard", "", "", nil, "sub", "groups", nil, sessions)
+	if _, err := provider.discover(); err == nil {
+		t.Error("discovery succeeded for an unknown issuer path")
+	}
+
+	provider = NewOIDCProvider(issuer.URL, "dashboard", "", "", nil, "sub", "groups", nil, sessions)
+	if _, err := provider.key("k1"); err != nil {
+		t.Errorf("key from JWKS: %v", err)
+	}
+	if _, err := provider.key("unknown"); err == nil {
+		t.Error("unknown key ID accepted")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"github.com/prometheus/common/log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health of a server as seen by the prober
+const (
+	Status_Up            = "up"
+	Status_AuthFailed    = "auth-failed"
+	Status_Unreachable   = "unreachable"
+	Status_ProtocolError = "protocol-error"
+)
+
+// Result of the latest probe of a server
+type serverStatus struct {
+	State     string    `json:"state"`             // up, auth-failed, unreachable or protocol-error
+	Error     string    `json:"error,omitempty"`   // Why the probe failed
+	Version   string    `json:"version,omitempty"` // RFB protocol version the server offered
+	Latency   float64   `json:"latency"`           // Seconds taken by the probe
+	LastSeen  time.Time `json:"last_seen"`         // Last time the server was up
+	LastProbe time.Time `json:"last_probe"`        // When the server was last probed
+}
+
+// Published to subscribers when a server's state changes
+type statusEvent struct {
+	Server string       `json:"server"` // Short name of the server
+	Status serverStatus `json:"status"`
+}
+
+// Decide which state a failed probe leaves a server in
+func probeErrorState(err error) string {
+	if errors.Is(err, ErrRFBPasswordRequired) || errors.Is(err, ErrRFBAuthFailed) {
+		return Status_AuthFailed
+	}
+	// Refused, timed out or reset, including servers which accept connections
+	// but never answer
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Status_Unreachable
+	}
+	return Status_ProtocolError
+}
+
+// Connect to a server and authenticate, then hang up
+func probeServer(server vncServer, timeout time.Duration) serverStatus {
+	start := time.Now()
+	status := serverStatus{LastProbe: start}
+
+	conn, err := net.DialTimeout(server.NetType, server.Address, timeout)
+	if err != nil {
+		dialFailures.WithLabelValues(server.Short()).Inc()
+	} else {
+		conn.SetDeadline(start.Add(timeout))
+		var version rfbVersion
+		version, err = rfbClientHandshake(conn, server.Password)
+		if err == nil {
+			status.Version = strings.TrimSpace(version.String())
+		}
+		conn.Close()
+	}
+	status.Latency = time.Since(start).Seconds()
+
+	if err != nil {
+		status.State = probeErrorState(err)
+		status.Error = err.Error()
+	} else {
+		status.State = Status_Up
+		status.LastSeen = time.Now()
+		probeLatency.WithLabelValues(server.Short()).Observe(status.Latency)
+	}
+	return status
+}
+
+// Periodically probes every known server and records the results in the manager
+type healthProber struct {
+	manager  *serverManager
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewHealthProber(manager *serverManager, interval time.Duration, timeout time.Duration) *healthProber {
+	return &healthProber{
+		manager:  manager,
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// Probe every server now, waiting for them all to finish
+func (this *healthProber) ProbeAll() {
+	wg := sync.WaitGroup{}
+	for _, server := range this.manager.List() {
+		wg.Add(1)
+		go func(server vncServer) {
+			defer wg.Done()
+			status := probeServer(server, this.timeout)
+			if status.State != Status_Up {
+				log.With("server", server.Redacted()).
+					With("state", status.State).Debugln("Probe failed:", status.Error)
+			}
+			this.manager.SetStatus(server.Short(), status)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// Probe every server on a schedule
+func (this *healthProber) Run() {
+	for {
+		this.ProbeAll()
+		time.Sleep(this.interval)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProbeErrorState(t *testing.T) {
+	tests := []struct {
+		err   error
+		state string
+	}{
+		{ErrRFBPasswordRequired, Status_AuthFailed},
+		{ErrRFBAuthFailed, Status_AuthFailed},
+		{fmt.Errorf("%w: %v", ErrRFBAuthFailed, errors.New("bad password")), Status_AuthFailed},
+		{errors.New("VNC authentication failed, or so it says"), Status_ProtocolError},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, Status_Unreachable},
+		{fmt.Errorf("probing: %w", &net.OpError{Op: "read", Err: errors.New("i/o timeout")}), Status_Unreachable},
+		{io.ErrUnexpectedEOF, Status_ProtocolError},
+		{ErrRFBNoSecurityType, Status_ProtocolError},
+		{fmt.Errorf("%w: %s", ErrRFBRefused, "too many connections"), Status_ProtocolError},
+	}
+	for _, test := range tests {
+		if state := probeErrorState(test.err); state != test.state {
+			t.Errorf("%v: got %v, expected %v", test.err, state, test.state)
+		}
+	}
+}
+
+func newScriptedVNCServer(t *testing.T, reply []byte) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write(reply)
+				io.Copy(ioutil.Discard, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestProbeServer(t *testing.T) {
+	up := newFakeVNCServer(t, 8, 8, "desktop")
+	defer up.Close()
+	challenge := []byte("0123456789abcdef")
+	vncAuth := [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecVNCAuth}}
+	failed := append(append([]byte{0, 0, 0, 1}, 0, 0, 0, 3), "bad"...)
+
+	scripted := map[string]net.Listener{
+		"password required": newScriptedVNCServer(t, bytes.Join(vncAuth, nil)),
+		"wrong password":    newScriptedVNCServer(t, bytes.Join(append(vncAuth, challenge, failed), nil)),
+		"not vnc":           newScriptedVNCServer(t, []byte("HTTP/1.1 400 Bad Request\r\n\r\n")),
+		"never answers":     newScriptedVNCServer(t, nil),
+	}
+	for _, listener := range scripted {
+		defer listener.Close()
+	}
+	server := func(name string) vncServer {
+		return vncServer{NetType: "tcp", Address: scripted[name].Addr().String()}
+	}
+
+	tests := []struct {
+		name     string
+		server   vncServer
+		password string
+		state    string
+		version  string
+	}{
+		{"up", up.Server(), "", Status_Up, "RFB 003.008"},
+		{"password required", server("password required"), "", Status_AuthFailed, ""},
+		{"wrong password", server("wrong password"), "wrong", Status_AuthFailed, ""},
+		{"not vnc", server("not vnc"), "", Status_ProtocolError, ""},
+		{"never answers", server("never answers"), "", Status_Unreachable, ""},
+		{"refused", vncServer{NetType: "tcp", Address: "127.0.0.1:1"}, "", Status_Unreachable, ""},
+	}
+	for _, test := range tests {
+		test.server.Password = test.password
+		start := time.Now()
+		status := probeServer(test.server, 200*time.Millisecond)
+		if status.State != test.state || status.Version != test.version {
+			t.Errorf("%v: got %v %q (%v), expected %v %q", test.name, status.State, status.Version, status.Error, test.state, test.version)
+		}
+		if (status.Error == "") != (test.state == Status_Up) || (status.LastSeen.IsZero()) != (test.state != Status_Up) {
+			t.Errorf("%v: got error %q, last seen %v", test.name, status.Error, status.LastSeen)
+		}
+		if status.LastProbe.Before(start) || status.Latency <= 0 || status.Latency > time.Since(start).Seconds() {
+			t.Errorf("%v: got probe at %v taking %v", test.name, status.LastProbe, status.Latency)
+		}
+	}
+}
+
+func TestHealthProber(t *testing.T) {
+	up := newFakeVNCServer(t, 8, 8, "desktop")
+	defer up.Close()
+	manager := NewServerManager()
+	manager.Add(up.Server())
+	manager.Add(vncServer{NetType: "tcp", Address: "127.0.0.1:1"})
+
+	NewHealthProber(manager, time.Hour, time.Second).ProbeAll()
+	for _, server := range manager.List() {
+		expected := Status_Up
+		if server.Address == "127.0.0.1:1" {
+			expected = Status_Unreachable
+		}
+		if server.Status == nil || server.Status.State != expected {
+			t.Errorf("%v: got status %+v, expected %v", server.Address, server.Status, expected)
+		}
+	}
+}
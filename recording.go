@@ -0,0 +1,390 @@
+package main
+
+// Session recordings are written as javascript files in the format replayed by
+// static/include/playback.js: a list of frames of server-to-client RFB data, each
+// prefixed by its offset from the start of the recording in milliseconds. The
+// frames are the messages the VNC server sent, after a handshake and a copy of
+// the screen when recording started.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suffix of recording files
+const recordingExt = ".js"
+
+// Layout of the start time in recording file names
+const recordingTimeFormat = "20060102T150405.000Z"
+
+// Terminates the frame list. Missing if the process died while recording.
+const recordingTrailer = "'EOF'];\n"
+
+// Encodings asked for while recording, which the player supports and can play
+// without having seen the session from its start
+var rfbRecordingEncodings = []int32{
+	rfbEncCopyRect,
+	rfbEncHextile,
+	rfbEncRRE,
+	rfbEncRaw,
+	rfbEncDesktopSize,
+	rfbEncLastRect,
+}
+
+// Whether rectangles of an encoding can be recorded as they were sent
+func recordable(encoding int32) bool {
+	for _, e := range rfbRecordingEncodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// Escapes of bytes which can't appear as they are in a frame's string literal:
+// quotes, backslashes and anything unprintable. Nil for the rest.
+var recordingEscapes = func() (escapes [256][]byte) {
+	for b := range escapes {
+		if b < 0x20 || b >= 0x7f || b == '\'' || b == '\\' {
+			escapes[b] = []byte(fmt.Sprintf("\\x%02x", b))
+		}
+	}
+	return escapes
+}()
+
+// Writes the frames of a single recording
+type sessionRecorder struct {
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+}
+
+func newSessionRecorder(filename string, server vncServer) (*sessionRecorder, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	this := &sessionRecorder{
+		file:  file,
+		w:     bufio.NewWriter(file),
+		start: time.Now(),
+	}
+	fmt.Fprintf(this.w, "/* VNC recording of %s started %s */\n", server.Short(), this.start.UTC().Format(time.RFC3339))
+	fmt.Fprintln(this.w, "var VNC_frame_encoding = 'binary';")
+	fmt.Fprintln(this.w, "var VNC_frame_data = [")
+	return this, nil
+}
+
+// Write a frame of server-to-client data
+func (this *sessionRecorder) WriteFrame(data []byte) error {
+	offset := time.Since(this.start) / time.Millisecond
+	fmt.Fprintf(this.w, "'{%d{", offset)
+	// Write runs of printable characters as they are
+	run := 0
+	for i, b := range data {
+		if escape := recordingEscapes[b]; escape != nil {
+			this.w.Write(data[run:i])
+			this.w.Write(escape)
+			run = i + 1
+		}
+	}
+	this.w.Write(data[run:])
+	if _, err := this.w.WriteString("',\n"); err != nil {
+		return err
+	}
+	// Keep the file ending on a frame boundary so it can be played while recording
+	return this.w.Flush()
+}
+
+func (this *sessionRecorder) Close() error {
+	this.w.WriteString(recordingTrailer)
+	if err := this.w.Flush(); err != nil {
+		this.file.Close()
+		return err
+	}
+	return this.file.Close()
+}
+
+// Manages the recordings in the export directory
+type recordingStore struct {
+	dir     string
+	maxAge  time.Duration // Recordings older than this are deleted. 0 keeps forever.
+	maxSize int64         // Total size recordings are kept under. 0 is unlimited.
+
+	active map[string]struct{} // Recordings in progress, which are never pruned
+	mtx    sync.Mutex
+}
+
+func NewRecordingStore(dir string, maxAge time.Duration, maxSize int64) *recordingStore {
+	return &recordingStore{
+		dir:     dir,
+		maxAge:  maxAge,
+		maxSize: maxSize,
+		active:  make(map[string]struct{}),
+	}
+}
+
+// Record a session until it ends or stop is closed
+func (this *recordingStore) Record(session *vncSession, stop <-chan struct{}) {
+	server := session.server
+	filename := filepath.Join(this.dir, fmt.Sprintf("%s-%s%s",
+		server.Short(), time.Now().UTC().Format(recordingTimeFormat), recordingExt))
+
+	if err := os.MkdirAll(this.dir, 0755); err != nil {
+		log.With("server", server.Redacted()).Errorln("Could not create recording directory:", err)
+		return
+	}
+	recorder, err := newSessionRecorder(filename, server)
+	if err != nil {
+		log.With("server", server.Redacted()).Errorln("Could not start recording:", err)
+		return
+	}
+
+	this.mtx.Lock()
+	this.active[filename] = struct{}{}
+	this.mtx.Unlock()
+	defer func() {
+		this.mtx.Lock()
+		delete(this.active, filename)
+		this.mtx.Unlock()
+	}()
+
+	log.With("server", server.Redacted()).With("file", filename).Infoln("Recording session")
+	if err := session.startRecording(recorder); err != nil {
+		log.With("server", server.Redacted()).Errorln("Recording failed:", err)
+	} else {
+		select {
+		case <-session.Done():
+		case <-stop:
+		}
+		session.stopRecording()
+	}
+	if err := recorder.Close(); err != nil {
+		log.With("server", server.Redacted()).Errorln("Error closing recording:", err)
+	}
+	log.With("server", server.Redacted()).With("file", filename).Infoln("Recording finished")
+}
+
+// Frames of the handshake a viewer would see, each in its own since the player
+// handles one handshake step per frame, followed by the whole screen
+func recordingHandshake(fb *image.RGBA, name string) [][]byte {
+	return [][]byte{
+		[]byte(rfbServerVersion),
+		[]byte{1, rfbSecNone},
+		[]byte{0, 0, 0, 0}, // SecurityResult OK
+		rfbServerInitMsg(fb.Bounds().Dx(), fb.Bounds().Dy(), rfbViewerPixelFormat, name),
+		rawUpdateFrame(fb, sessionUpdate{Dirty: fb.Bounds()}),
+	}
+}
+
+// A FramebufferUpdate of the changed part of the framebuffer in raw encoding,
+// after the new size if it was resized
+func rawUpdateFrame(fb *image.RGBA, update sessionUpdate) []byte {
+	var frame []byte
+	if update.Resized {
+		frame = appendRFBRectHeader(rfbFramebufferUpdateHeader(2), fb.Bounds(), rfbEncDesktopSize)
+		update.Dirty = fb.Bounds()
+	} else {
+		frame = rfbFramebufferUpdateHeader(1)
+	}
+	region := update.Dirty.Intersect(fb.Bounds())
+	frame = appendRFBRectHeader(frame, region, rfbEncRaw)
+	return rfbViewerPixelFormat.AppendPixels(frame, fb, region)
+}
+
+// Delete recordings past the configured age, then the oldest recordings until the
+// total size is within the limit.
+func (this *recordingStore) Prune() {
+	files, err := ioutil.ReadDir(this.dir)
+	if os.IsNotExist(err) {
+		// Nothing recorded yet
+		return
+	} else if err != nil {
+		log.Errorln("Error reading recording directory:", err)
+		return
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	recordings := []os.FileInfo{}
+	for _, fi := range files {
+		if fi.Mode().IsRegular() && strings.HasSuffix(fi.Name(), recordingExt) {
+			if _, active := this.active[filepath.Join(this.dir, fi.Name())]; !active {
+				recordings = append(recordings, fi)
+			}
+		}
+	}
+	sort.Sort(byModTime(recordings))
+
+	var total int64
+	for _, fi := range recordings {
+		total += fi.Size()
+	}
+
+	for _, fi := range recordings {
+		expired := this.maxAge != 0 && time.Since(fi.ModTime()) > this.maxAge
+		oversize := this.maxSize != 0 && total > this.maxSize
+		if !expired && !oversize {
+			continue
+		}
+		filename := filepath.Join(this.dir, fi.Name())
+		log.With("file", filename).With("expired", expired).With("oversize", oversize).Infoln("Deleting recording")
+		if err := os.Remove(filename); err != nil {
+			log.Errorln("Error deleting recording:", err)
+			continue
+		}
+		total -= fi.Size()
+	}
+}
+
+// Periodically prune recordings
+func (this *recordingStore) pruneLoop(interval time.Duration) {
+	for {
+		this.Prune()
+		time.Sleep(interval)
+	}
+}
+
+// Sorts files oldest first
+type byModTime []os.FileInfo
+
+func (this byModTime) Len() int           { return len(this) }
+func (this byModTime) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+func (this byModTime) Less(i, j int) bool { return this[i].ModTime().Before(this[j].ModTime()) }
+
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// Description of a recording returned by the API
+type recordingInfo struct {
+	ID       string    `json:"id"`
+	Server   string    `json:"server"`   // Short name of the recorded server
+	Start    time.Time `json:"start"`    // When recording started
+	Duration float64   `json:"duration"` // Length in seconds
+	Size     int64     `json:"size"`     // Size of the recording file in bytes
+	Active   bool      `json:"active"`   // Still being recorded
+}
+
+// Parse a recording file into its description. IDs are file names without the
+// extension, made up of the server short name and start time.
+func (this *recordingStore) info(fi os.FileInfo) (recordingInfo, error) {
+	id := strings.TrimSuffix(fi.Name(), recordingExt)
+	sep := strings.LastIndex(id, "-")
+	if !fi.Mode().IsRegular() || !strings.HasSuffix(fi.Name(), recordingExt) || sep == -1 {
+		return recordingInfo{}, ErrRecordingNotFound
+	}
+	start, err := time.Parse(recordingTimeFormat, id[sep+1:])
+	if err != nil {
+		return recordingInfo{}, ErrRecordingNotFound
+	}
+
+	_, active := this.active[filepath.Join(this.dir, fi.Name())]
+	end := fi.ModTime()
+	if active {
+		end = time.Now()
+	}
+
+	return recordingInfo{
+		ID:       id,
+		Server:   id[:sep],
+		Start:    start,
+		Duration: end.Sub(start).Seconds(),
+		Size:     fi.Size(),
+		Active:   active,
+	}, nil
+}
+
+// List recordings, newest first
+func (this *recordingStore) List() ([]recordingInfo, error) {
+	recordings := []recordingInfo{}
+
+	files, err := ioutil.ReadDir(this.dir)
+	if os.IsNotExist(err) {
+		return recordings, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	for _, fi := range files {
+		if info, err := this.info(fi); err == nil {
+			recordings = append(recordings, info)
+		}
+	}
+	sort.Stable(byStartDescending(recordings))
+	return recordings, nil
+}
+
+// Check an ID can only name a file directly in the recording directory
+func validRecordingID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\") && !strings.HasPrefix(id, ".")
+}
+
+func (this *recordingStore) Get(id string) (recordingInfo, error) {
+	if !validRecordingID(id) {
+		return recordingInfo{}, ErrRecordingNotFound
+	}
+	fi, err := os.Stat(filepath.Join(this.dir, id+recordingExt))
+	if err != nil {
+		return recordingInfo{}, ErrRecordingNotFound
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.info(fi)
+}
+
+// Write the playable recording, terminating the frame list if the recording is
+// still in progress or was interrupted.
+func (this *recordingStore) WriteTo(id string, w io.Writer) error {
+	if _, err := this.Get(id); err != nil {
+		return err
+	}
+	file, err := os.Open(filepath.Join(this.dir, id+recordingExt))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Only what was written when we looked, since a recording in progress
+	// carries on growing
+	size := fi.Size()
+	if _, err := io.Copy(w, io.NewSectionReader(file, 0, size)); err != nil {
+		return err
+	}
+	end := make([]byte, len(recordingTrailer))
+	if size >= int64(len(end)) {
+		if _, err := file.ReadAt(end, size-int64(len(end))); err != nil {
+			return err
+		}
+	}
+	if string(end) != recordingTrailer {
+		_, err = io.WriteString(w, recordingTrailer)
+	}
+	return err
+}
+
+// Sorts recordings newest first
+type byStartDescending []recordingInfo
+
+func (this byStartDescending) Len() int           { return len(this) }
+func (this byStartDescending) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+func (this byStartDescending) Less(i, j int) bool { return this[i].Start.After(this[j].Start) }
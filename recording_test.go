@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A frame of a recording
+type testFrame struct {
+	offset int // Milliseconds from the start
+	data   []byte
+}
+
+// Parse a recording the way the player does, returning its frames and whether
+// it was finished
+func parseTestRecording(t *testing.T, b []byte) ([]testFrame, bool) {
+	t.Helper()
+	s := string(b)
+	start := strings.Index(s, "var VNC_frame_data = [\n")
+	if !strings.HasPrefix(s, "/* VNC recording of ") || start < 0 ||
+		!strings.Contains(s[:start], "var VNC_frame_encoding = 'binary';\n") {
+		t.Fatalf("invalid recording header: %q", s)
+	}
+	s = s[start+len("var VNC_frame_data = [\n"):]
+
+	frames := []testFrame{}
+	for s != "" {
+		if s == recordingTrailer {
+			return frames, true
+		}
+		if !strings.HasPrefix(s, "'{") {
+			t.Fatalf("invalid frame: %q", s)
+		}
+		s = s[2:]
+		end := strings.Index(s, "{")
+		offset, err := strconv.Atoi(s[:end])
+		if err != nil {
+			t.Fatalf("invalid frame offset: %q", s)
+		}
+		s = s[end+1:]
+
+		data := []byte{}
+		for s[0] != '\'' {
+			if s[0] == '\\' {
+				b, err := hex.DecodeString(s[2:4])
+				if s[1] != 'x' || err != nil {
+					t.Fatalf("invalid escape: %q", s)
+				}
+				data = append(data, b[0])
+				s = s[4:]
+				continue
+			}
+			data = append(data, s[0])
+			s = s[1:]
+		}
+		if !strings.HasPrefix(s, "',\n") {
+			t.Fatalf("unterminated frame: %q", s)
+		}
+		s = s[3:]
+		frames = append(frames, testFrame{offset, data})
+	}
+	return frames, false
+}
+
+func TestSessionRecorder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"text", []byte("RFB 003.008\n")},
+		{"quotes", []byte(`it's a "quote"`)},
+		{"backslashes", []byte(`C:\temp\x41`)},
+		{"binary", []byte{0, 1, 0x1f, 0x7f, 0x80, 0xff}},
+		{"frame syntax", []byte("'{12{',\n'EOF'];")},
+	}
+
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.js")
+	recorder, err := newSessionRecorder(filename, vncServer{NetType: "tcp", Address: "console:5900"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		if err := recorder.WriteFrame(test.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSessionRecorder(filename, vncServer{}); err == nil {
+		t.Error("existing recording overwritten")
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, finished := parseTestRecording(t, b)
+	if !finished {
+		t.Error("recording has no trailer")
+	}
+	if len(frames) != len(tests) {
+		t.Fatalf("got %v frames, expected %v", len(frames), len(tests))
+	}
+	for i, test := range tests {
+		if !bytes.Equal(frames[i].data, test.data) {
+			t.Errorf("%v: got %q, expected %q", test.name, frames[i].data, test.data)
+		}
+		if i > 0 && frames[i].offset < frames[i-1].offset {
+			t.Errorf("%v: offset %v before %v", test.name, frames[i].offset, frames[i-1].offset)
+		}
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	every := make([]byte, 256)
+	for i := range every {
+		every[i] = byte(i)
+	}
+	frames := [][]byte{
+		[]byte(`'`),
+		[]byte(`\`),
+		[]byte(`\'\\''`),
+		[]byte(`\x27`),
+		every,
+	}
+
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewRecordingStore(dir, 0, 0)
+	id := "abc-20200102T030405.000Z"
+	recorder, err := newSessionRecorder(filepath.Join(dir, id+recordingExt), vncServer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		if err := recorder.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Played while still recording, and once finished
+	for _, finished := range []bool{false, true} {
+		if finished {
+			if err := recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		buf := &bytes.Buffer{}
+		if err := store.WriteTo(id, buf); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(buf.String(), recordingTrailer) != 1 {
+			t.Errorf("finished %v: trailer appears %v times", finished, strings.Count(buf.String(), recordingTrailer))
+		}
+		played, terminated := parseTestRecording(t, buf.Bytes())
+		if !terminated || len(played) != len(frames) {
+			t.Fatalf("finished %v: got %v frames, terminated %v", finished, len(played), terminated)
+		}
+		for i, frame := range frames {
+			if !bytes.Equal(played[i].data, frame) {
+				t.Errorf("finished %v: frame %v is %q, expected %q", finished, i, played[i].data, frame)
+			}
+		}
+	}
+}
+
+func TestRecordingPrune(t *testing.T) {
+	type file struct {
+		name   string
+		age    time.Duration
+		size   int
+		active bool
+	}
+	files := []file{
+		{"a-20200101T000000Z.js", 72 * time.Hour, 300, false},
+		{"b-20200102T000000Z.js", 48 * time.Hour, 300, true},
+		{"c-20200103T000000Z.js", 24 * time.Hour, 300, false},
+		{"d-20200104T000000Z.js", time.Hour, 300, false},
+		{"e-20200105T000000Z.js", 0, 300, false},
+		{"notes.txt", 96 * time.Hour, 5000, false},
+	}
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		maxSize  int64
+		expected []string
+	}{
+		{"unlimited", 0, 0, []string{"a", "b", "c", "d", "e", "notes.txt"}},
+		{"age", 36 * time.Hour, 0, []string{"b", "c", "d", "e", "notes.txt"}},
+		// Recordings in progress don't count towards the size
+		{"size", 0, 1000, []string{"b", "c", "d", "e", "notes.txt"}},
+		{"size exactly", 0, 1200, []string{"a", "b", "c", "d", "e", "notes.txt"}},
+		{"size of two", 0, 700, []string{"b", "d", "e", "notes.txt"}},
+		{"age and size", 12 * time.Hour, 1000, []string{"b", "d", "e", "notes.txt"}},
+		{"tiny", 0, 1, []string{"b", "notes.txt"}},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "recording")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewRecordingStore(dir, test.maxAge, test.maxSize)
+		for _, f := range files {
+			filename := filepath.Join(dir, f.name)
+			if err := ioutil.WriteFile(filename, make([]byte, f.size), 0644); err != nil {
+				t.Fatal(err)
+			}
+			mtime := time.Now().Add(-f.age)
+			if err := os.Chtimes(filename, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			if f.active {
+				store.active[filename] = struct{}{}
+			}
+		}
+
+		store.Prune()
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		remaining := []string{}
+		for _, fi := range infos {
+			remaining = append(remaining, strings.SplitN(fi.Name(), "-", 2)[0])
+		}
+		sort.Strings(remaining)
+		if !reflect.DeepEqual(remaining, test.expected) {
+			t.Errorf("%v: kept %v, expected %v", test.name, remaining, test.expected)
+		}
+		os.RemoveAll(dir)
+	}
+
+	// Nothing recorded yet
+	NewRecordingStore(filepath.Join(os.TempDir(), "missing-recordings"), time.Hour, 1).Prune()
+}
+
+func TestRecordSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	upstream := newFakeVNCServer(t, 4, 2, "desktop")
+	defer upstream.Close()
+	store := NewRecordingStore(dir, 0, 0)
+	broker := NewSessionBroker(store)
+
+	server := upstream.Server()
+	server.Record = true
+	session, err := broker.Acquire(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	waitFor(t, "recording to start", func() bool {
+		session.rmtx.Lock()
+		defer session.rmtx.Unlock()
+		return session.recorder != nil
+	})
+	if message := conn.Expect(t, rfbMsgSetEncodings); !bytes.Equal(message, rfbSetEncodingsMsg(rfbRecordingEncodings)) {
+		t.Errorf("asked for encodings %v while recording", message)
+	}
+
+	// Raw updates are recorded as they are, ZRLE ones as raw updates since the
+	// player doesn't support them. Clipboards aren't recorded.
+	raw := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(1, 0, 3, 1), rfbEncRaw)
+	raw = append(raw, 0x30, 0x20, 0x10, 0, 0x30, 0x20, 0x10, 0)
+	zrleUpdate := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(2, 1, 4, 2), rfbEncZRLE)
+	zrleUpdate = append(zrleUpdate, zrle(join([]byte{1}, px3('W')))[0]...)
+	messages := [][]byte{raw, zrleUpdate, {rfbMsgBell}, rfbServerCutTextMsg([]byte("secret"))}
+	for _, message := range messages {
+		conn.Write(message)
+	}
+	expectedUpdate := sessionUpdate{Dirty: image.Rect(1, 0, 4, 2), Bells: 1, CutText: []byte("secret")}
+	if update := waitUpdateOf(sub, expectedUpdate); !sameUpdate(update, expectedUpdate) {
+		t.Fatalf("got update %+v", update)
+	}
+	broker.Release(session)
+
+	matches, _ := filepath.Glob(filepath.Join(dir, server.Short()+"-*"+recordingExt))
+	if len(matches) != 1 {
+		t.Fatalf("got recordings %v", matches)
+	}
+	filename := matches[0]
+
+	var b []byte
+	waitFor(t, "recording to finish", func() bool {
+		b, _ = ioutil.ReadFile(filename)
+		return bytes.HasSuffix(b, []byte(recordingTrailer))
+	})
+	frames, _ := parseTestRecording(t, b)
+
+	black := []byte{0, 0, 0, 0}
+	pixel := []byte{0x30, 0x20, 0x10, 0}
+	white := []byte{0xff, 0xff, 0xff, 0}
+	screenUpdate := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 4, 2), rfbEncRaw)
+	expected := [][]byte{
+		[]byte(rfbServerVersion),
+		{1, rfbSecNone},
+		{0, 0, 0, 0},
+		rfbServerInitMsg(4, 2, rfbViewerPixelFormat, "desktop"),
+		append(screenUpdate, bytes.Repeat(black, 8)...),
+		raw,
+		append(appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(2, 1, 4, 2), rfbEncRaw), bytes.Repeat(white, 2)...),
+		{rfbMsgBell},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("got %v frames, expected %v", len(frames), len(expected))
+	}
+	for i, data := range expected {
+		if !bytes.Equal(frames[i].data, data) {
+			t.Errorf("frame %v: got %v, expected %v", i, frames[i].data, data)
+		}
+	}
+
+	// Replaying the updates gives the final screen
+	screen := make([]byte, 8*4)
+	for _, frame := range frames[4:] {
+		data := frame.data
+		if data[0] != rfbMsgFramebufferUpdate {
+			continue
+		}
+		x, y := int(data[4])<<8|int(data[5]), int(data[6])<<8|int(data[7])
+		w, h := int(data[8])<<8|int(data[9]), int(data[10])<<8|int(data[11])
+		pixels := data[16:]
+		if len(pixels) != w*h*4 {
+			t.Fatalf("update of %vx%v has %v bytes of pixels", w, h, len(pixels))
+		}
+		for row := 0; row < h; row++ {
+			copy(screen[((y+row)*4+x)*4:], pixels[row*w*4:(row+1)*w*4])
+		}
+	}
+	expectedScreen := bytes.Join([][]byte{black, pixel, pixel, black, black, black, white, white}, nil)
+	if !bytes.Equal(screen, expectedScreen) {
+		t.Errorf("replayed screen %v, expected %v", screen, expectedScreen)
+	}
+}
+
+func TestRecordingList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewRecordingStore(dir, 0, 0)
+	if recordings, err := store.List(); err != nil || len(recordings) != 0 {
+		t.Errorf("got %v, %v before recording anything", recordings, err)
+	}
+
+	files := map[string]string{
+		"abc-20200102T030405.000Z.js":       "finished" + recordingTrailer,
+		"abc-20200103T030405.500Z.js":       "interrupted',\n",
+		"my-server-20200101T000000.000Z.js": "dashes in the name",
+		"def-20200104T000000.000Z.js":       "active",
+		"abc-notatime.js":                   "",
+		"abc-20200102T030405.000Z.txt":      "",
+		"20200102T030405.000Z.js":           "",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "xyz-20200105T000000.000Z.js"), 0755)
+	store.active[filepath.Join(dir, "def-20200104T000000.000Z.js")] = struct{}{}
+
+	recordings, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, recording := range recordings {
+		ids = append(ids, fmt.Sprintf("%v %v %v %v", recording.Server, recording.Start.Format(time.RFC3339Nano), recording.Size, recording.Active))
+	}
+	expected := []string{
+		"def 2020-01-04T00:00:00Z 6 true",
+		"abc 2020-01-03T03:04:05.5Z 14 false",
+		"abc 2020-01-02T03:04:05Z 16 false",
+		"my-server 2020-01-01T00:00:00Z 18 false",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("got recordings %v, expected %v", ids, expected)
+	}
+
+	tests := []struct {
+		id       string
+		contents string // Played, or empty if not found
+	}{
+		{"abc-20200102T030405.000Z", "finished" + recordingTrailer},
+		{"abc-20200103T030405.500Z", "interrupted',\n" + recordingTrailer},
+		{"def-20200104T000000.000Z", "active" + recordingTrailer},
+		{"abc-notatime", ""},
+		{"missing-20200102T030405.000Z", ""},
+		{"../" + filepath.Base(dir) + "/abc-20200102T030405.000Z", ""},
+		{"sub\\abc-20200102T030405.000Z", ""},
+		{".hidden", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		info, err := store.Get(test.id)
+		if test.contents == "" {
+			if err != ErrRecordingNotFound {
+				t.Errorf("%q: got %+v, %v, expected not found", test.id, info, err)
+			}
+			if err := store.WriteTo(test.id, &bytes.Buffer{}); err != ErrRecordingNotFound {
+				t.Errorf("%q: played with %v", test.id, err)
+			}
+			continue
+		}
+		if err != nil || info.ID != test.id {
+			t.Errorf("%q: got %+v, %v", test.id, info, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := store.WriteTo(test.id, buf); err != nil || buf.String() != test.contents {
+			t.Errorf("%q: played %q, %v, expected %q", test.id, buf.String(), err, test.contents)
+		}
+	}
+}
+
+func TestRecordingFollowsServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	upstream := newFakeVNCServer(t, 4, 2, "desktop")
+	defer upstream.Close()
+	manager := NewServerManager()
+	server := upstream.Server()
+	manager.Add(server)
+	broker := NewSessionBroker(NewRecordingStore(dir, 0, 0))
+	go broker.FollowManager(manager)
+
+	session, err := broker.Acquire(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Release(session)
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgSetEncodings)
+
+	recordings := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(dir, server.Short()+"-*"+recordingExt))
+		return matches
+	}
+	finished := func(filename string) bool {
+		b, _ := ioutil.ReadFile(filename)
+		return bytes.HasSuffix(b, []byte(recordingTrailer))
+	}
+
+	// Turning recording on starts recording the running session, and turning it
+	// off finishes the recording while the session carries on
+	tests := []struct {
+		record     bool
+		recordings int
+		encodings  []int32 // Asked of the server, if they change
+	}{
+		{true, 1, rfbRecordingEncodings},
+		{false, 1, rfbSessionEncodings},
+		{false, 1, nil},
+	}
+	for i, test := range tests {
+		server.Record = test.record
+		manager.Add(server)
+		waitFor(t, fmt.Sprintf("step %v recordings", i), func() bool {
+			matches := recordings()
+			return len(matches) == test.recordings && finished(matches[len(matches)-1]) != test.record
+		})
+		if test.encodings == nil {
+			continue
+		}
+		if message := conn.Expect(t, rfbMsgSetEncodings); !bytes.Equal(message, rfbSetEncodingsMsg(test.encodings)) {
+			t.Errorf("step %v: asked for encodings %v", i, message)
+		}
+	}
+	select {
+	case message := <-conn.messages:
+		if message[0] == rfbMsgSetEncodings {
+			t.Errorf("asked for encodings %v without a change", message)
+		}
+	default:
+	}
+	select {
+	case <-session.Done():
+		t.Error("session ended with the recording")
+	default:
+	}
+}
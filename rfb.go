@@ -0,0 +1,527 @@
+package main
+
+// Helpers for the parts of the RFB protocol (RFC 6143) the proxy needs to speak
+// itself, rather than passing through between the browser and the VNC server.
+
+import (
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// RFB security types
+const (
+	rfbSecInvalid uint8 = 0
+	rfbSecNone    uint8 = 1
+	rfbSecVNCAuth uint8 = 2
+)
+
+// RFB SecurityResult success value
+const rfbSecResultOK uint32 = 0
+
+// Protocol version offered to browsers
+const rfbServerVersion = "RFB 003.008\n"
+
+var (
+	ErrRFBAuthFailed        = errors.New("VNC authentication failed")
+	ErrRFBPasswordRequired  = errors.New("VNC server requires a password but none is configured")
+	ErrRFBNoSecurityType    = errors.New("VNC server offered no supported security types")
+	ErrRFBClientUnsupported = errors.New("VNC client chose an unsupported security type")
+	ErrRFBRefused           = errors.New("VNC server refused connection")
+)
+
+// Minor version of the RFB protocol in use. Only the 3.x series exists.
+type rfbVersion int
+
+const (
+	rfbVersion33 rfbVersion = 3
+	rfbVersion37 rfbVersion = 7
+	rfbVersion38 rfbVersion = 8
+)
+
+func (this rfbVersion) String() string {
+	return fmt.Sprintf("RFB 003.%03d\n", int(this))
+}
+
+// Parse a ProtocolVersion message, clamping it to a version we can speak.
+func parseRFBVersion(b []byte) (rfbVersion, error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(string(b), "RFB %03d.%03d\n", &major, &minor); err != nil {
+		return 0, fmt.Errorf("invalid RFB protocol version %q", string(b))
+	}
+
+	switch {
+	case major > 3 || (major == 3 && minor >= 8 && minor != 889):
+		// 3.889 is Apple Remote Desktop, which behaves like 3.3
+		return rfbVersion38, nil
+	case major == 3 && minor == 7:
+		return rfbVersion37, nil
+	case major == 3:
+		return rfbVersion33, nil
+	}
+	return 0, fmt.Errorf("unsupported RFB protocol version %q", string(b))
+}
+
+// Read a length-prefixed reason string sent on failure
+func readRFBReason(r io.Reader) error {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return err
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(r, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrRFBRefused, string(reason))
+}
+
+// Compute the response to a VNC Authentication challenge. The password is used as
+// a DES key with the bit order of each byte reversed.
+func vncAuthResponse(password string, challenge []byte) ([]byte, error) {
+	key := make([]byte, 8)
+	copy(key, password)
+	for i, b := range key {
+		var r byte
+		for bit := uint(0); bit < 8; bit++ {
+			if b&(1<<bit) != 0 {
+				r |= 0x80 >> bit
+			}
+		}
+		key[i] = r
+	}
+
+	cipher, err := des.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, len(challenge))
+	for i := 0; i < len(challenge); i += cipher.BlockSize() {
+		cipher.Encrypt(response[i:], challenge[i:])
+	}
+	return response, nil
+}
+
+// Perform the client side of the RFB handshake against a VNC server, up to and
+// including the SecurityResult. The caller continues with ClientInit.
+func rfbClientHandshake(conn io.ReadWriter, password string) (rfbVersion, error) {
+	b := make([]byte, 12)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return 0, err
+	}
+	version, err := parseRFBVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(conn, version.String()); err != nil {
+		return 0, err
+	}
+
+	// Negotiate the security type
+	var secType uint8
+	if version == rfbVersion33 {
+		// Server decides
+		var t uint32
+		if err := binary.Read(conn, binary.BigEndian, &t); err != nil {
+			return 0, err
+		}
+		if t == uint32(rfbSecInvalid) {
+			return 0, readRFBReason(conn)
+		}
+		secType = uint8(t)
+	} else {
+		// Server offers, client decides
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return 0, err
+		}
+		if n[0] == 0 {
+			return 0, readRFBReason(conn)
+		}
+		offered := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, offered); err != nil {
+			return 0, err
+		}
+		for _, t := range offered {
+			if t == rfbSecNone {
+				secType = rfbSecNone
+				break
+			} else if t == rfbSecVNCAuth {
+				secType = rfbSecVNCAuth
+			}
+		}
+		if secType == rfbSecInvalid {
+			return 0, ErrRFBNoSecurityType
+		}
+		if _, err := conn.Write([]byte{secType}); err != nil {
+			return 0, err
+		}
+	}
+
+	switch secType {
+	case rfbSecNone:
+		// 3.8 sends a SecurityResult even for no authentication
+		if version != rfbVersion38 {
+			return version, nil
+		}
+	case rfbSecVNCAuth:
+		if password == "" {
+			return 0, ErrRFBPasswordRequired
+		}
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(conn, challenge); err != nil {
+			return 0, err
+		}
+		response, err := vncAuthResponse(password, challenge)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := conn.Write(response); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("VNC server requires unsupported security type %v", secType)
+	}
+
+	var result uint32
+	if err := binary.Read(conn, binary.BigEndian, &result); err != nil {
+		return 0, err
+	}
+	if result != rfbSecResultOK {
+		if version == rfbVersion38 {
+			if reason := readRFBReason(conn); reason != nil {
+				return 0, fmt.Errorf("%w: %v", ErrRFBAuthFailed, reason)
+			}
+		}
+		return 0, ErrRFBAuthFailed
+	}
+
+	return version, nil
+}
+
+// Perform the server side of the RFB handshake with a client, offering only the
+// "None" security type. The caller continues by reading ClientInit.
+func rfbServerHandshake(conn io.ReadWriter) (rfbVersion, error) {
+	if _, err := io.WriteString(conn, rfbServerVersion); err != nil {
+		return 0, err
+	}
+	b := make([]byte, 12)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return 0, err
+	}
+	version, err := parseRFBVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if version == rfbVersion33 {
+		// Server decides
+		if err := binary.Write(conn, binary.BigEndian, uint32(rfbSecNone)); err != nil {
+			return 0, err
+		}
+		return version, nil
+	}
+
+	if _, err := conn.Write([]byte{1, rfbSecNone}); err != nil {
+		return 0, err
+	}
+	chosen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, chosen); err != nil {
+		return 0, err
+	}
+	if chosen[0] != rfbSecNone {
+		return 0, ErrRFBClientUnsupported
+	}
+
+	if version == rfbVersion38 {
+		if err := binary.Write(conn, binary.BigEndian, rfbSecResultOK); err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// RFB client-to-server message types
+const (
+	rfbMsgSetPixelFormat           uint8 = 0
+	rfbMsgSetEncodings             uint8 = 2
+	rfbMsgFramebufferUpdateRequest uint8 = 3
+	rfbMsgKeyEvent                 uint8 = 4
+	rfbMsgPointerEvent             uint8 = 5
+	rfbMsgClientCutText            uint8 = 6
+	rfbMsgEnableContinuousUpdates  uint8 = 150
+	rfbMsgClientFence              uint8 = 248
+	rfbMsgXvp                      uint8 = 250
+	rfbMsgSetDesktopSize           uint8 = 251
+	rfbMsgQEMU                     uint8 = 255
+)
+
+// Largest clipboard transfer accepted from a client
+const rfbMaxCutText = 1 << 24
+
+// Client messages which cannot change the state of the remote desktop
+var rfbViewOnlyMessages = map[uint8]bool{
+	rfbMsgSetPixelFormat:           true,
+	rfbMsgSetEncodings:             true,
+	rfbMsgFramebufferUpdateRequest: true,
+	rfbMsgEnableContinuousUpdates:  true,
+	rfbMsgClientFence:              true,
+}
+
+// Read exactly n more bytes onto the end of msg
+func readRFBMore(r io.Reader, msg []byte, n int) ([]byte, error) {
+	start := len(msg)
+	msg = append(msg, make([]byte, n)...)
+	_, err := io.ReadFull(r, msg[start:])
+	return msg, err
+}
+
+// Read a single complete client-to-server message, returning its type and raw
+// bytes (including the type).
+func readRFBClientMessage(r io.Reader) (uint8, []byte, error) {
+	msg, err := readRFBMore(r, nil, 1)
+	if err != nil {
+		return 0, nil, err
+	}
+	msgType := msg[0]
+
+	switch msgType {
+	case rfbMsgSetPixelFormat:
+		msg, err = readRFBMore(r, msg, 19)
+	case rfbMsgSetEncodings:
+		if msg, err = readRFBMore(r, msg, 3); err == nil {
+			n := int(binary.BigEndian.Uint16(msg[2:4]))
+			msg, err = readRFBMore(r, msg, 4*n)
+		}
+	case rfbMsgFramebufferUpdateRequest:
+		msg, err = readRFBMore(r, msg, 9)
+	case rfbMsgKeyEvent:
+		msg, err = readRFBMore(r, msg, 7)
+	case rfbMsgPointerEvent:
+		msg, err = readRFBMore(r, msg, 5)
+	case rfbMsgClientCutText:
+		if msg, err = readRFBMore(r, msg, 7); err == nil {
+			n := binary.BigEndian.Uint32(msg[4:8])
+			if n > rfbMaxCutText {
+				return msgType, nil, fmt.Errorf("client cut text too long: %v bytes", n)
+			}
+			msg, err = readRFBMore(r, msg, int(n))
+		}
+	case rfbMsgEnableContinuousUpdates:
+		msg, err = readRFBMore(r, msg, 9)
+	case rfbMsgClientFence:
+		if msg, err = readRFBMore(r, msg, 8); err == nil {
+			msg, err = readRFBMore(r, msg, int(msg[8]))
+		}
+	case rfbMsgXvp:
+		msg, err = readRFBMore(r, msg, 3)
+	case rfbMsgSetDesktopSize:
+		if msg, err = readRFBMore(r, msg, 7); err == nil {
+			msg, err = readRFBMore(r, msg, 16*int(msg[6]))
+		}
+	case rfbMsgQEMU:
+		if msg, err = readRFBMore(r, msg, 1); err == nil {
+			if msg[1] != 0 {
+				return msgType, nil, fmt.Errorf("unsupported QEMU client message subtype %v", msg[1])
+			}
+			// Extended key event
+			msg, err = readRFBMore(r, msg, 10)
+		}
+	default:
+		return msgType, nil, fmt.Errorf("unknown RFB client message type %v", msgType)
+	}
+
+	return msgType, msg, err
+}
+
+// RFB server-to-client message types
+const (
+	rfbMsgFramebufferUpdate   uint8 = 0
+	rfbMsgSetColourMapEntries uint8 = 1
+	rfbMsgBell                uint8 = 2
+	rfbMsgServerCutText       uint8 = 3
+)
+
+// RFB encodings and pseudo-encodings
+const (
+	rfbEncRaw         int32 = 0
+	rfbEncCopyRect    int32 = 1
+	rfbEncRRE         int32 = 2
+	rfbEncHextile     int32 = 5
+	rfbEncTight       int32 = 7
+	rfbEncZRLE        int32 = 16
+	rfbEncDesktopSize int32 = -223
+	rfbEncLastRect    int32 = -224
+)
+
+// RFB PIXEL_FORMAT structure
+type rfbPixelFormat struct {
+	BPP        uint8
+	Depth      uint8
+	BigEndian  bool
+	TrueColour bool
+	RedMax     uint16
+	GreenMax   uint16
+	BlueMax    uint16
+	RedShift   uint8
+	GreenShift uint8
+	BlueShift  uint8
+}
+
+// Pixel format requested from VNC servers. It's the one viewers use, so updates
+// from the server can be recorded as they arrive. Pixels arrive as B, G, R, X
+// bytes.
+var rfbSessionPixelFormat = rfbViewerPixelFormat
+
+// Pixel format advertised to clients, which is what noVNC asks for anyway.
+var rfbViewerPixelFormat = rfbPixelFormat{
+	BPP: 32, Depth: 24, TrueColour: true,
+	RedMax: 255, GreenMax: 255, BlueMax: 255,
+	RedShift: 16, GreenShift: 8, BlueShift: 0,
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func parseRFBPixelFormat(b []byte) rfbPixelFormat {
+	return rfbPixelFormat{
+		BPP:        b[0],
+		Depth:      b[1],
+		BigEndian:  b[2] != 0,
+		TrueColour: b[3] != 0,
+		RedMax:     binary.BigEndian.Uint16(b[4:6]),
+		GreenMax:   binary.BigEndian.Uint16(b[6:8]),
+		BlueMax:    binary.BigEndian.Uint16(b[8:10]),
+		RedShift:   b[10],
+		GreenShift: b[11],
+		BlueShift:  b[12],
+	}
+}
+
+// Wire form, including the 3 bytes of padding
+func (this rfbPixelFormat) Marshal() []byte {
+	b := make([]byte, 16)
+	b[0] = this.BPP
+	b[1] = this.Depth
+	b[2] = boolByte(this.BigEndian)
+	b[3] = boolByte(this.TrueColour)
+	binary.BigEndian.PutUint16(b[4:6], this.RedMax)
+	binary.BigEndian.PutUint16(b[6:8], this.GreenMax)
+	binary.BigEndian.PutUint16(b[8:10], this.BlueMax)
+	b[10] = this.RedShift
+	b[11] = this.GreenShift
+	b[12] = this.BlueShift
+	return b
+}
+
+func (this rfbPixelFormat) BytesPerPixel() int {
+	return int(this.BPP) / 8
+}
+
+// Check the format is one pixels can be encoded to
+func (this rfbPixelFormat) Validate() error {
+	if !this.TrueColour {
+		return errors.New("colour map pixel formats are not supported")
+	}
+	switch this.BPP {
+	case 8, 16, 32:
+		return nil
+	}
+	return fmt.Errorf("unsupported bits per pixel: %v", this.BPP)
+}
+
+// Append the pixels of a region of an image in this format
+func (this rfbPixelFormat) AppendPixels(dst []byte, img *image.RGBA, rect image.Rectangle) []byte {
+	bpp := this.BytesPerPixel()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(rect.Min.X, y):img.PixOffset(rect.Max.X, y)]
+		if this == rfbViewerPixelFormat {
+			// Fast path for the usual case
+			for i := 0; i < len(row); i += 4 {
+				dst = append(dst, row[i+2], row[i+1], row[i], 0)
+			}
+			continue
+		}
+		for i := 0; i < len(row); i += 4 {
+			v := uint32(row[i])*uint32(this.RedMax)/255<<this.RedShift |
+				uint32(row[i+1])*uint32(this.GreenMax)/255<<this.GreenShift |
+				uint32(row[i+2])*uint32(this.BlueMax)/255<<this.BlueShift
+			for b := 0; b < bpp; b++ {
+				shift := uint(b * 8)
+				if this.BigEndian {
+					shift = uint((bpp - 1 - b) * 8)
+				}
+				dst = append(dst, byte(v>>shift))
+			}
+		}
+	}
+	return dst
+}
+
+// Client message builders
+
+func rfbSetPixelFormatMsg(format rfbPixelFormat) []byte {
+	return append([]byte{rfbMsgSetPixelFormat, 0, 0, 0}, format.Marshal()...)
+}
+
+func rfbSetEncodingsMsg(encodings []int32) []byte {
+	b := make([]byte, 4+4*len(encodings))
+	b[0] = rfbMsgSetEncodings
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(encodings)))
+	for i, enc := range encodings {
+		binary.BigEndian.PutUint32(b[4+4*i:], uint32(enc))
+	}
+	return b
+}
+
+func rfbFramebufferUpdateRequestMsg(incremental bool, rect image.Rectangle) []byte {
+	b := make([]byte, 10)
+	b[0] = rfbMsgFramebufferUpdateRequest
+	b[1] = boolByte(incremental)
+	binary.BigEndian.PutUint16(b[2:4], uint16(rect.Min.X))
+	binary.BigEndian.PutUint16(b[4:6], uint16(rect.Min.Y))
+	binary.BigEndian.PutUint16(b[6:8], uint16(rect.Dx()))
+	binary.BigEndian.PutUint16(b[8:10], uint16(rect.Dy()))
+	return b
+}
+
+// Server message builders
+
+func rfbServerInitMsg(width int, height int, format rfbPixelFormat, name string) []byte {
+	b := make([]byte, 4, 24+len(name))
+	binary.BigEndian.PutUint16(b[0:2], uint16(width))
+	binary.BigEndian.PutUint16(b[2:4], uint16(height))
+	b = append(b, format.Marshal()...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[20:24], uint32(len(name)))
+	return append(b, name...)
+}
+
+func rfbFramebufferUpdateHeader(numRects int) []byte {
+	b := []byte{rfbMsgFramebufferUpdate, 0, 0, 0}
+	binary.BigEndian.PutUint16(b[2:4], uint16(numRects))
+	return b
+}
+
+func appendRFBRectHeader(dst []byte, rect image.Rectangle, encoding int32) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:2], uint16(rect.Min.X))
+	binary.BigEndian.PutUint16(b[2:4], uint16(rect.Min.Y))
+	binary.BigEndian.PutUint16(b[4:6], uint16(rect.Dx()))
+	binary.BigEndian.PutUint16(b[6:8], uint16(rect.Dy()))
+	binary.BigEndian.PutUint32(b[8:12], uint32(encoding))
+	return append(dst, b...)
+}
+
+func rfbServerCutTextMsg(text []byte) []byte {
+	b := []byte{rfbMsgServerCutText, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[4:8], uint32(len(text)))
+	return append(b, text...)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Any error at all, for failures without a sentinel
+var errAny = errors.New("any error")
+
+// Server or client side of a scripted connection. Reads come from the script
+// and writes are kept to compare afterwards.
+type scriptedConn struct {
+	io.Reader
+	written bytes.Buffer
+}
+
+func (this *scriptedConn) Write(b []byte) (int, error) {
+	return this.written.Write(b)
+}
+
+func newScriptedConn(parts ...[]byte) *scriptedConn {
+	return &scriptedConn{Reader: bytes.NewReader(bytes.Join(parts, nil))}
+}
+
+func checkErr(t *testing.T, name string, err error, expected error) {
+	t.Helper()
+	switch {
+	case expected == nil && err != nil:
+		t.Errorf("%v: unexpected error: %v", name, err)
+	case expected == errAny && err == nil:
+		t.Errorf("%v: expected an error", name)
+	case expected != nil && expected != errAny && !errors.Is(err, expected):
+		t.Errorf("%v: got error %v, expected %v", name, err, expected)
+	}
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParseRFBVersion(t *testing.T) {
+	tests := []struct {
+		message string
+		version rfbVersion
+		err     error
+	}{
+		{"RFB 003.003\n", rfbVersion33, nil},
+		{"RFB 003.007\n", rfbVersion37, nil},
+		{"RFB 003.008\n", rfbVersion38, nil},
+		{"RFB 003.005\n", rfbVersion33, nil},
+		{"RFB 003.889\n", rfbVersion33, nil},
+		{"RFB 004.001\n", rfbVersion38, nil},
+		{"RFB 002.000\n", 0, errAny},
+		{"HTTP/1.1 200", 0, errAny},
+	}
+	for _, test := range tests {
+		version, err := parseRFBVersion([]byte(test.message))
+		checkErr(t, test.message, err, test.err)
+		if version != test.version {
+			t.Errorf("%q: got version %v, expected %v", test.message, int(version), int(test.version))
+		}
+	}
+}
+
+func TestVNCAuthResponse(t *testing.T) {
+	// Expected responses from openssl des-ecb with the bit-reversed keys
+	challenge := []byte("0123456789abcdef")
+	tests := []struct {
+		password string
+		response string
+	}{
+		{"password", "5645abeb5f1e6475e8feb11beb66ea19"},
+		{"secret", "752440ee2bfcc2a0d9013fd20371e23b"},
+		{"", "a068dbeab73d140b56541eb3c7625177"},
+		{"longerthan8chars", "28427cea8d645edc714b18c88bee5012"}, // Only the first 8 characters count
+	}
+	for _, test := range tests {
+		response, err := vncAuthResponse(test.password, challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(response) != test.response {
+			t.Errorf("%q: got %x, expected %v", test.password, response, test.response)
+		}
+	}
+}
+
+func TestRFBClientHandshake(t *testing.T) {
+	challenge := []byte("0123456789abcdef")
+	response := mustHex("5645abeb5f1e6475e8feb11beb66ea19")
+	ok := []byte{0, 0, 0, 0}
+	failed := []byte{0, 0, 0, 1}
+	reason := append([]byte{0, 0, 0, 3}, "bad"...)
+
+	tests := []struct {
+		name     string
+		server   [][]byte // Sent by the server
+		password string
+		version  rfbVersion
+		written  [][]byte // Expected from the client
+		err      error
+	}{
+		{"3.8 none", [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecNone}, ok}, "",
+			rfbVersion38, [][]byte{[]byte("RFB 003.008\n"), {rfbSecNone}}, nil},
+		{"3.8 vnc auth", [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecVNCAuth}, challenge, ok}, "password",
+			rfbVersion38, [][]byte{[]byte("RFB 003.008\n"), {rfbSecVNCAuth}, response}, nil},
+		{"3.8 prefers none", [][]byte{[]byte("RFB 003.008\n"), {2, rfbSecVNCAuth, rfbSecNone}, ok}, "password",
+			rfbVersion38, [][]byte{[]byte("RFB 003.008\n"), {rfbSecNone}}, nil},
+		{"3.8 wrong password", [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecVNCAuth}, challenge, failed, reason}, "wrong",
+			0, nil, ErrRFBAuthFailed},
+		{"3.8 no password", [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecVNCAuth}}, "",
+			0, nil, ErrRFBPasswordRequired},
+		{"3.8 unsupported types", [][]byte{[]byte("RFB 003.008\n"), {2, 16, 19}}, "password",
+			0, nil, ErrRFBNoSecurityType},
+		{"3.8 refused", [][]byte{[]byte("RFB 003.008\n"), {0}, reason}, "",
+			0, nil, ErrRFBRefused},
+		{"3.7 none", [][]byte{[]byte("RFB 003.007\n"), {1, rfbSecNone}}, "",
+			rfbVersion37, [][]byte{[]byte("RFB 003.007\n"), {rfbSecNone}}, nil},
+		{"3.7 wrong password", [][]byte{[]byte("RFB 003.007\n"), {1, rfbSecVNCAuth}, challenge, failed}, "wrong",
+			0, nil, ErrRFBAuthFailed},
+		{"3.3 none", [][]byte{[]byte("RFB 003.003\n"), {0, 0, 0, rfbSecNone}}, "",
+			rfbVersion33, [][]byte{[]byte("RFB 003.003\n")}, nil},
+		{"3.3 vnc auth", [][]byte{[]byte("RFB 003.003\n"), {0, 0, 0, rfbSecVNCAuth}, challenge, ok}, "password",
+			rfbVersion33, [][]byte{[]byte("RFB 003.003\n"), response}, nil},
+		{"3.3 refused", [][]byte{[]byte("RFB 003.003\n"), {0, 0, 0, 0}, reason}, "",
+			0, nil, ErrRFBRefused},
+		{"apple remote desktop", [][]byte{[]byte("RFB 003.889\n"), {0, 0, 0, rfbSecNone}}, "",
+			rfbVersion33, [][]byte{[]byte("RFB 003.003\n")}, nil},
+		{"not vnc", [][]byte{[]byte("SSH-2.0-Open")}, "",
+			0, nil, errAny},
+		{"truncated", [][]byte{[]byte("RFB 003.008\n"), {2, rfbSecVNCAuth}}, "password",
+			0, nil, io.ErrUnexpectedEOF},
+		{"hung up", nil, "",
+			0, nil, io.EOF},
+	}
+	for _, test := range tests {
+		conn := newScriptedConn(test.server...)
+		version, err := rfbClientHandshake(conn, test.password)
+		checkErr(t, test.name, err, test.err)
+		if version != test.version {
+			t.Errorf("%v: got version %v, expected %v", test.name, int(version), int(test.version))
+		}
+		if test.written != nil && !bytes.Equal(conn.written.Bytes(), bytes.Join(test.written, nil)) {
+			t.Errorf("%v: wrote %q, expected %q", test.name, conn.written.Bytes(), bytes.Join(test.written, nil))
+		}
+	}
+}
+
+func TestRFBServerHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  [][]byte // Sent by the client
+		version rfbVersion
+		written [][]byte // Expected from the server
+		err     error
+	}{
+		{"3.8", [][]byte{[]byte("RFB 003.008\n"), {rfbSecNone}},
+			rfbVersion38, [][]byte{[]byte(rfbServerVersion), {1, rfbSecNone}, {0, 0, 0, 0}}, nil},
+		{"3.7", [][]byte{[]byte("RFB 003.007\n"), {rfbSecNone}},
+			rfbVersion37, [][]byte{[]byte(rfbServerVersion), {1, rfbSecNone}}, nil},
+		{"3.3", [][]byte{[]byte("RFB 003.003\n")},
+			rfbVersion33, [][]byte{[]byte(rfbServerVersion), {0, 0, 0, rfbSecNone}}, nil},
+		{"vnc auth chosen", [][]byte{[]byte("RFB 003.008\n"), {rfbSecVNCAuth}},
+			0, nil, ErrRFBClientUnsupported},
+		{"not vnc", [][]byte{[]byte("GET / HTTP/1")},
+			0, nil, errAny},
+		{"hung up", [][]byte{[]byte("RFB 003.008\n")},
+			0, nil, io.EOF},
+	}
+	for _, test := range tests {
+		conn := newScriptedConn(test.client...)
+		version, err := rfbServerHandshake(conn)
+		checkErr(t, test.name, err, test.err)
+		if version != test.version {
+			t.Errorf("%v: got version %v, expected %v", test.name, int(version), int(test.version))
+		}
+		if test.written != nil && !bytes.Equal(conn.written.Bytes(), bytes.Join(test.written, nil)) {
+			t.Errorf("%v: wrote %q, expected %q", test.name, conn.written.Bytes(), bytes.Join(test.written, nil))
+		}
+	}
+}
+
+func TestReadRFBClientMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		msgType uint8
+		err     error
+	}{
+		{"set pixel format", append([]byte{rfbMsgSetPixelFormat}, make([]byte, 19)...), rfbMsgSetPixelFormat, nil},
+		{"set encodings", []byte{rfbMsgSetEncodings, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 16}, rfbMsgSetEncodings, nil},
+		{"no encodings", []byte{rfbMsgSetEncodings, 0, 0, 0}, rfbMsgSetEncodings, nil},
+		{"update request", []byte{rfbMsgFramebufferUpdateRequest, 1, 0, 0, 0, 0, 0, 64, 0, 32}, rfbMsgFramebufferUpdateRequest, nil},
+		{"key", []byte{rfbMsgKeyEvent, 1, 0, 0, 0, 0, 0xff, 0x0d}, rfbMsgKeyEvent, nil},
+		{"pointer", []byte{rfbMsgPointerEvent, 1, 0, 10, 0, 20}, rfbMsgPointerEvent, nil},
+		{"cut text", append([]byte{rfbMsgClientCutText, 0, 0, 0, 0, 0, 0, 5}, "hello"...), rfbMsgClientCutText, nil},
+		{"empty cut text", []byte{rfbMsgClientCutText, 0, 0, 0, 0, 0, 0, 0}, rfbMsgClientCutText, nil},
+		{"cut text too long", []byte{rfbMsgClientCutText, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff}, rfbMsgClientCutText, errAny},
+		{"continuous updates", []byte{rfbMsgEnableContinuousUpdates, 1, 0, 0, 0, 0, 0, 64, 0, 32}, rfbMsgEnableContinuousUpdates, nil},
+		{"fence", []byte{rfbMsgClientFence, 0, 0, 0, 0, 0, 0, 0, 2, 'h', 'i'}, rfbMsgClientFence, nil},
+		{"xvp", []byte{rfbMsgXvp, 0, 1, 2}, rfbMsgXvp, nil},
+		{"set desktop size", append([]byte{rfbMsgSetDesktopSize, 0, 4, 0, 3, 0, 1, 0}, make([]byte, 16)...), rfbMsgSetDesktopSize, nil},
+		{"qemu extended key", []byte{rfbMsgQEMU, 0, 0, 1, 0, 0, 0xff, 0x0d, 0, 0, 0, 28}, rfbMsgQEMU, nil},
+		{"qemu audio", []byte{rfbMsgQEMU, 1, 0, 0}, rfbMsgQEMU, errAny},
+		{"unknown", []byte{99, 0, 0, 0}, 99, errAny},
+		{"truncated key", []byte{rfbMsgKeyEvent, 1, 0}, rfbMsgKeyEvent, io.ErrUnexpectedEOF},
+		{"truncated encodings", []byte{rfbMsgSetEncodings, 0, 0, 2, 0, 0, 0, 0}, rfbMsgSetEncodings, io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		// The next message must be left alone
+		r := bytes.NewReader(append(append([]byte{}, test.message...), rfbMsgKeyEvent))
+		msgType, message, err := readRFBClientMessage(r)
+		checkErr(t, test.name, err, test.err)
+		if msgType != test.msgType {
+			t.Errorf("%v: got type %v, expected %v", test.name, msgType, test.msgType)
+		}
+		if test.err == nil {
+			if !bytes.Equal(message, test.message) {
+				t.Errorf("%v: got %v, expected %v", test.name, message, test.message)
+			}
+			if r.Len() != 1 {
+				t.Errorf("%v: %v bytes left, expected 1", test.name, r.Len())
+			}
+		}
+	}
+}
+
+func TestRFBViewOnlyMessages(t *testing.T) {
+	tests := []struct {
+		msgType uint8
+		allowed bool
+	}{
+		{rfbMsgSetPixelFormat, true},
+		{rfbMsgSetEncodings, true},
+		{rfbMsgFramebufferUpdateRequest, true},
+		{rfbMsgEnableContinuousUpdates, true},
+		{rfbMsgClientFence, true},
+		{rfbMsgKeyEvent, false},
+		{rfbMsgPointerEvent, false},
+		{rfbMsgClientCutText, false},
+		{rfbMsgXvp, false},
+		{rfbMsgSetDesktopSize, false},
+		{rfbMsgQEMU, false},
+	}
+	for _, test := range tests {
+		if allowed := rfbViewOnlyMessages[test.msgType]; allowed != test.allowed {
+			t.Errorf("message type %v: allowed %v, expected %v", test.msgType, allowed, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,624 @@
+package main
+
+// Decoders for the framebuffer encodings sent by VNC servers. All assume the
+// session pixel format (rfbSessionPixelFormat), so pixels are 4 bytes of blue,
+// green, red and padding, and ZRLE's compressed pixels (CPIXEL) are the first 3 of
+// them. Tight's compressed pixels (TPIXEL) are always red, green and blue.
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// Decodes a single rectangle of a FramebufferUpdate into dst, which covers just
+// that rectangle. Decoding happens without the framebuffer locked, so decoders
+// only touch the session's decompression state.
+type rfbDecoder func(this *vncSession, r io.Reader, dst *image.RGBA) error
+
+// Encodings the session can decode, in order of preference
+var rfbSessionEncodings = []int32{
+	rfbEncCopyRect,
+	rfbEncZRLE,
+	rfbEncTight,
+	rfbEncHextile,
+	rfbEncRRE,
+	rfbEncRaw,
+	rfbEncDesktopSize,
+	rfbEncLastRect,
+}
+
+var rfbDecoders = map[int32]rfbDecoder{
+	rfbEncRaw:     decodeRaw,
+	rfbEncRRE:     decodeRRE,
+	rfbEncHextile: decodeHextile,
+	rfbEncZRLE:    decodeZRLE,
+	rfbEncTight:   decodeTight,
+}
+
+// Largest compressed rectangle accepted from a server
+const rfbMaxCompressedLength = 1 << 26
+
+var errBadSubrect = errors.New("subrectangle outside of rectangle")
+
+// A zlib stream which continues from one rectangle to the next
+type zlibStream struct {
+	input  bytes.Buffer
+	reader io.ReadCloser
+}
+
+// Read n bytes of compressed data from r into the stream, returning a reader of
+// the decompressed stream. Only as much as the compressed data holds should be
+// read from it.
+func (this *zlibStream) Feed(r io.Reader, n int) (io.Reader, error) {
+	if n > rfbMaxCompressedLength {
+		return nil, fmt.Errorf("compressed data too long: %v bytes", n)
+	}
+	if _, err := io.CopyN(&this.input, r, int64(n)); err != nil {
+		return nil, err
+	}
+	if this.reader == nil {
+		reader, err := zlib.NewReader(&this.input)
+		if err != nil {
+			return nil, err
+		}
+		this.reader = reader
+	}
+	return this.reader, nil
+}
+
+// Start again with a new stream
+func (this *zlibStream) Reset() {
+	this.input.Reset()
+	this.reader = nil
+}
+
+// Image value of a pixel or CPIXEL, with alpha forced opaque
+func rgba(b []byte) [4]byte {
+	return [4]byte{b[2], b[1], b[0], 0xff}
+}
+
+// Image value of a TPIXEL, with alpha forced opaque
+func tightRGBA(b []byte) [4]byte {
+	return [4]byte{b[0], b[1], b[2], 0xff}
+}
+
+// Fill a rectangle of the framebuffer with a single pixel value
+func fillRect(fb *image.RGBA, rect image.Rectangle, pixel [4]byte) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := fb.Pix[fb.PixOffset(rect.Min.X, y):fb.PixOffset(rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			copy(row[i:i+4], pixel[:])
+		}
+	}
+}
+
+// Copy pixels in session format into the framebuffer, forcing them opaque
+func setPixels(fb *image.RGBA, rect image.Rectangle, pixels []byte) {
+	idx := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := fb.Pix[fb.PixOffset(rect.Min.X, y):fb.PixOffset(rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			row[i] = pixels[idx+2]
+			row[i+1] = pixels[idx+1]
+			row[i+2] = pixels[idx]
+			row[i+3] = 0xff
+			idx += 4
+		}
+	}
+}
+
+// Copy 3 byte pixels into the framebuffer, converting each with pixel
+func setCompactPixels(fb *image.RGBA, rect image.Rectangle, pixels []byte, pixel func([]byte) [4]byte) {
+	idx := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := fb.Pix[fb.PixOffset(rect.Min.X, y):fb.PixOffset(rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			value := pixel(pixels[idx : idx+3])
+			copy(row[i:i+4], value[:])
+			idx += 3
+		}
+	}
+}
+
+// Read a rectangle header within an encoding (x, y, w, h as 16 bit values),
+// relative to rect
+func readSubrect(r io.Reader, rect image.Rectangle) (image.Rectangle, error) {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return image.Rectangle{}, err
+	}
+	x := int(binary.BigEndian.Uint16(b[0:2]))
+	y := int(binary.BigEndian.Uint16(b[2:4]))
+	w := int(binary.BigEndian.Uint16(b[4:6]))
+	h := int(binary.BigEndian.Uint16(b[6:8]))
+	sub := image.Rect(x, y, x+w, y+h).Add(rect.Min)
+	if !sub.In(rect) {
+		return image.Rectangle{}, errBadSubrect
+	}
+	return sub, nil
+}
+
+func decodeRaw(this *vncSession, r io.Reader, dst *image.RGBA) error {
+	rect := dst.Rect
+	pixels := make([]byte, rect.Dx()*rect.Dy()*4)
+	if _, err := io.ReadFull(r, pixels); err != nil {
+		return err
+	}
+	setPixels(dst, rect, pixels)
+	return nil
+}
+
+// Copy a rectangle of the framebuffer from src, as CopyRect asks. Needs the
+// framebuffer locked for writing.
+func copyRect(fb *image.RGBA, rect image.Rectangle, src image.Point) error {
+	srcRect := image.Rectangle{src, src.Add(rect.Size())}
+	if !srcRect.In(fb.Bounds()) {
+		return errRectOutOfBounds
+	}
+
+	// Copy via a temporary buffer since the regions may overlap
+	width := rect.Dx() * 4
+	tmp := make([]byte, width*rect.Dy())
+	for y := 0; y < rect.Dy(); y++ {
+		off := fb.PixOffset(src.X, src.Y+y)
+		copy(tmp[y*width:], fb.Pix[off:off+width])
+	}
+	for y := 0; y < rect.Dy(); y++ {
+		off := fb.PixOffset(rect.Min.X, rect.Min.Y+y)
+		copy(fb.Pix[off:off+width], tmp[y*width:])
+	}
+	return nil
+}
+
+func decodeRRE(this *vncSession, r io.Reader, dst *image.RGBA) error {
+	rect := dst.Rect
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	numSubrects := binary.BigEndian.Uint32(b[0:4])
+	fillRect(dst, rect, rgba(b[4:8]))
+
+	pixel := make([]byte, 4)
+	for i := uint32(0); i < numSubrects; i++ {
+		if _, err := io.ReadFull(r, pixel); err != nil {
+			return err
+		}
+		sub, err := readSubrect(r, rect)
+		if err != nil {
+			return err
+		}
+		fillRect(dst, sub, rgba(pixel))
+	}
+	return nil
+}
+
+// Hextile subencoding flags
+const (
+	hextileRaw                 = 1
+	hextileBackgroundSpecified = 2
+	hextileForegroundSpecified = 4
+	hextileAnySubrects         = 8
+	hextileSubrectsColoured    = 16
+)
+
+func decodeHextile(this *vncSession, r io.Reader, dst *image.RGBA) error {
+	rect := dst.Rect
+	var background, foreground [4]byte
+	b := make([]byte, 16*16*4)
+
+	for ty := rect.Min.Y; ty < rect.Max.Y; ty += 16 {
+		for tx := rect.Min.X; tx < rect.Max.X; tx += 16 {
+			tile := image.Rect(tx, ty, tx+16, ty+16).Intersect(rect)
+
+			if _, err := io.ReadFull(r, b[:1]); err != nil {
+				return err
+			}
+			subencoding := b[0]
+
+			if subencoding&hextileRaw != 0 {
+				pixels := b[:tile.Dx()*tile.Dy()*4]
+				if _, err := io.ReadFull(r, pixels); err != nil {
+					return err
+				}
+				setPixels(dst, tile, pixels)
+				continue
+			}
+
+			if subencoding&hextileBackgroundSpecified != 0 {
+				if _, err := io.ReadFull(r, b[:4]); err != nil {
+					return err
+				}
+				background = rgba(b[:4])
+			}
+			fillRect(dst, tile, background)
+
+			if subencoding&hextileForegroundSpecified != 0 {
+				if _, err := io.ReadFull(r, b[:4]); err != nil {
+					return err
+				}
+				foreground = rgba(b[:4])
+			}
+
+			if subencoding&hextileAnySubrects == 0 {
+				continue
+			}
+			if _, err := io.ReadFull(r, b[:1]); err != nil {
+				return err
+			}
+			numSubrects := int(b[0])
+			coloured := subencoding&hextileSubrectsColoured != 0
+
+			for i := 0; i < numSubrects; i++ {
+				colour := foreground
+				if coloured {
+					if _, err := io.ReadFull(r, b[:4]); err != nil {
+						return err
+					}
+					colour = rgba(b[:4])
+				}
+				if _, err := io.ReadFull(r, b[:2]); err != nil {
+					return err
+				}
+				x := int(b[0] >> 4)
+				y := int(b[0] & 0x0f)
+				w := int(b[1]>>4) + 1
+				h := int(b[1]&0x0f) + 1
+				sub := image.Rect(x, y, x+w, y+h).Add(tile.Min)
+				if !sub.In(tile) {
+					return errBadSubrect
+				}
+				fillRect(dst, sub, colour)
+			}
+		}
+	}
+	return nil
+}
+
+// Read the palette of 3 byte pixels used by ZRLE and Tight, converting each with
+// pixel
+func readPalette(r io.Reader, n int, pixel func([]byte) [4]byte) ([][4]byte, error) {
+	b := make([]byte, 3*n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	palette := make([][4]byte, n)
+	for i := range palette {
+		palette[i] = pixel(b[3*i:])
+	}
+	return palette, nil
+}
+
+// Read rows of packed palette indices of the given bit width, each row padded to
+// a whole byte, and draw them into the framebuffer.
+func readPackedPalette(fb *image.RGBA, r io.Reader, rect image.Rectangle, palette [][4]byte, bits uint) error {
+	rowBytes := (rect.Dx()*int(bits) + 7) / 8
+	row := make([]byte, rowBytes)
+	mask := byte(1<<bits - 1)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return err
+		}
+		for x := 0; x < rect.Dx(); x++ {
+			bit := uint(x) * bits
+			idx := int(row[bit/8]>>(8-bits-bit%8)) & int(mask)
+			if idx >= len(palette) {
+				return fmt.Errorf("palette index %v out of range", idx)
+			}
+			off := fb.PixOffset(rect.Min.X+x, y)
+			copy(fb.Pix[off:off+4], palette[idx][:])
+		}
+	}
+	return nil
+}
+
+// Read a run length encoded as a sequence of bytes, ending on one less than 255
+func readRunLength(r io.Reader) (int, error) {
+	b := make([]byte, 1)
+	length := 1
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, err
+		}
+		length += int(b[0])
+		if b[0] != 255 {
+			return length, nil
+		}
+	}
+}
+
+// Draw runs of pixels across a rectangle in raster order
+type runWriter struct {
+	fb   *image.RGBA
+	rect image.Rectangle
+	pos  int
+}
+
+func (this *runWriter) Write(pixel [4]byte, length int) error {
+	if this.pos+length > this.rect.Dx()*this.rect.Dy() {
+		return errBadSubrect
+	}
+	for i := 0; i < length; i++ {
+		x := this.rect.Min.X + this.pos%this.rect.Dx()
+		y := this.rect.Min.Y + this.pos/this.rect.Dx()
+		off := this.fb.PixOffset(x, y)
+		copy(this.fb.Pix[off:off+4], pixel[:])
+		this.pos++
+	}
+	return nil
+}
+
+func decodeZRLE(this *vncSession, r io.Reader, dst *image.RGBA) error {
+	rect := dst.Rect
+	b := make([]byte, 64*64*3)
+	if _, err := io.ReadFull(r, b[:4]); err != nil {
+		return err
+	}
+	zr, err := this.zrle.Feed(r, int(binary.BigEndian.Uint32(b[:4])))
+	if err != nil {
+		return err
+	}
+
+	for ty := rect.Min.Y; ty < rect.Max.Y; ty += 64 {
+		for tx := rect.Min.X; tx < rect.Max.X; tx += 64 {
+			tile := image.Rect(tx, ty, tx+64, ty+64).Intersect(rect)
+
+			if _, err := io.ReadFull(zr, b[:1]); err != nil {
+				return err
+			}
+			subencoding := int(b[0])
+
+			switch {
+			case subencoding == 0:
+				// Raw
+				pixels := b[:tile.Dx()*tile.Dy()*3]
+				if _, err := io.ReadFull(zr, pixels); err != nil {
+					return err
+				}
+				setCompactPixels(dst, tile, pixels, rgba)
+			case subencoding == 1:
+				// Solid
+				if _, err := io.ReadFull(zr, b[:3]); err != nil {
+					return err
+				}
+				fillRect(dst, tile, rgba(b[:3]))
+			case subencoding <= 16:
+				// Packed palette
+				palette, err := readPalette(zr, subencoding, rgba)
+				if err != nil {
+					return err
+				}
+				bits := uint(4)
+				if subencoding == 2 {
+					bits = 1
+				} else if subencoding <= 4 {
+					bits = 2
+				}
+				if err := readPackedPalette(dst, zr, tile, palette, bits); err != nil {
+					return err
+				}
+			case subencoding == 128:
+				// Plain RLE
+				runs := &runWriter{fb: dst, rect: tile}
+				for runs.pos < tile.Dx()*tile.Dy() {
+					if _, err := io.ReadFull(zr, b[:3]); err != nil {
+						return err
+					}
+					pixel := rgba(b[:3])
+					length, err := readRunLength(zr)
+					if err != nil {
+						return err
+					}
+					if err := runs.Write(pixel, length); err != nil {
+						return err
+					}
+				}
+			case subencoding >= 130:
+				// Palette RLE
+				palette, err := readPalette(zr, subencoding-128, rgba)
+				if err != nil {
+					return err
+				}
+				runs := &runWriter{fb: dst, rect: tile}
+				for runs.pos < tile.Dx()*tile.Dy() {
+					if _, err := io.ReadFull(zr, b[:1]); err != nil {
+						return err
+					}
+					idx := int(b[0] & 0x7f)
+					if idx >= len(palette) {
+						return fmt.Errorf("palette index %v out of range", idx)
+					}
+					length := 1
+					if b[0]&0x80 != 0 {
+						if length, err = readRunLength(zr); err != nil {
+							return err
+						}
+					}
+					if err := runs.Write(palette[idx], length); err != nil {
+						return err
+					}
+				}
+			default:
+				return fmt.Errorf("invalid ZRLE subencoding %v", subencoding)
+			}
+		}
+	}
+	return nil
+}
+
+// Tight compression control values
+const (
+	tightFill    = 0x08
+	tightJPEG    = 0x09
+	tightMaxComp = 0x09
+
+	tightExplicitFilter = 0x04
+
+	tightFilterCopy     = 0
+	tightFilterPalette  = 1
+	tightFilterGradient = 2
+
+	// Data shorter than this is sent without compression
+	tightMinToCompress = 12
+)
+
+// Read Tight's variable length "compact" representation of a length
+func readCompactLength(r io.Reader) (int, error) {
+	b := make([]byte, 1)
+	length := 0
+	for i := uint(0); i < 3; i++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, err
+		}
+		if i == 2 {
+			length |= int(b[0]) << 14
+			break
+		}
+		length |= int(b[0]&0x7f) << (7 * i)
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	return length, nil
+}
+
+func decodeTight(this *vncSession, r io.Reader, dst *image.RGBA) error {
+	rect := dst.Rect
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	control := b[0]
+
+	// Low bits request the zlib streams are reset
+	for i := uint(0); i < 4; i++ {
+		if control&(1<<i) != 0 {
+			this.tight[i].Reset()
+		}
+	}
+	control >>= 4
+
+	switch {
+	case control == tightFill:
+		pixel := make([]byte, 3)
+		if _, err := io.ReadFull(r, pixel); err != nil {
+			return err
+		}
+		fillRect(dst, rect, tightRGBA(pixel))
+		return nil
+	case control == tightJPEG:
+		length, err := readCompactLength(r)
+		if err != nil {
+			return err
+		}
+		if length > rfbMaxCompressedLength {
+			return fmt.Errorf("JPEG data too long: %v bytes", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		draw.Draw(dst, rect, img, img.Bounds().Min, draw.Src)
+		return nil
+	case control > tightMaxComp:
+		return fmt.Errorf("invalid Tight compression control %v", control)
+	}
+
+	// Basic compression
+	stream := &this.tight[control&0x03]
+	filter := tightFilterCopy
+	if control&tightExplicitFilter != 0 {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		filter = int(b[0])
+	}
+
+	var palette [][4]byte
+	var err error
+	size := rect.Dx() * rect.Dy() * 3
+	switch filter {
+	case tightFilterCopy, tightFilterGradient:
+	case tightFilterPalette:
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		if palette, err = readPalette(r, int(b[0])+1, tightRGBA); err != nil {
+			return err
+		}
+		if len(palette) == 2 {
+			size = (rect.Dx() + 7) / 8 * rect.Dy()
+		} else {
+			size = rect.Dx() * rect.Dy()
+		}
+	default:
+		return fmt.Errorf("invalid Tight filter %v", filter)
+	}
+
+	// Short data is sent as is, the rest through the zlib stream
+	var data io.Reader = r
+	if size >= tightMinToCompress {
+		length, err := readCompactLength(r)
+		if err != nil {
+			return err
+		}
+		if data, err = stream.Feed(r, length); err != nil {
+			return err
+		}
+	}
+
+	if filter == tightFilterPalette {
+		if len(palette) == 2 {
+			return readPackedPalette(dst, data, rect, palette, 1)
+		}
+		return readPackedPalette(dst, data, rect, palette, 8)
+	}
+
+	pixels := make([]byte, size)
+	if _, err := io.ReadFull(data, pixels); err != nil {
+		return err
+	}
+
+	if filter == tightFilterGradient {
+		// Each pixel is stored as the difference from a prediction made from its
+		// neighbours above and to the left.
+		stride := rect.Dx() * 3
+		for y := 0; y < rect.Dy(); y++ {
+			for x := 0; x < rect.Dx(); x++ {
+				for c := 0; c < 3; c++ {
+					var left, up, upLeft int
+					if x > 0 {
+						left = int(pixels[y*stride+(x-1)*3+c])
+					}
+					if y > 0 {
+						up = int(pixels[(y-1)*stride+x*3+c])
+						if x > 0 {
+							upLeft = int(pixels[(y-1)*stride+(x-1)*3+c])
+						}
+					}
+					predicted := left + up - upLeft
+					if predicted < 0 {
+						predicted = 0
+					} else if predicted > 255 {
+						predicted = 255
+					}
+					pixels[y*stride+x*3+c] += byte(predicted)
+				}
+			}
+		}
+	}
+
+	setCompactPixels(dst, rect, pixels, tightRGBA)
+	return nil
+}
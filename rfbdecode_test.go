@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+// Colours in expected framebuffers. K is the blank framebuffer.
+var testColours = map[byte][3]byte{
+	'K': {0, 0, 0},
+	'R': {0xff, 0, 0},
+	'G': {0, 0xff, 0},
+	'B': {0, 0, 0xff},
+	'W': {0xff, 0xff, 0xff},
+}
+
+// Pixel in the session format
+func px4(c byte) []byte {
+	rgb := testColours[c]
+	return []byte{rgb[2], rgb[1], rgb[0], 0}
+}
+
+// ZRLE's compressed pixel (CPIXEL)
+func px3(c byte) []byte {
+	return px4(c)[:3]
+}
+
+// Tight's compressed pixel (TPIXEL)
+func tpx(c byte) []byte {
+	rgb := testColours[c]
+	return rgb[:]
+}
+
+// Pixels of each colour in turn, in the given format
+func pixels(format func(byte) []byte, colours string) []byte {
+	b := []byte{}
+	for i := 0; i < len(colours); i++ {
+		b = append(b, format(colours[i])...)
+	}
+	return b
+}
+
+// Chunks of one zlib stream, flushed after each so they can be decoded in turn
+func zlibChunks(chunks ...[]byte) [][]byte {
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	r := [][]byte{}
+	for _, chunk := range chunks {
+		w.Write(chunk)
+		w.Flush()
+		r = append(r, append([]byte{}, buf.Bytes()...))
+		buf.Reset()
+	}
+	return r
+}
+
+// ZRLE data of each rectangle, compressed in one stream
+func zrle(rects ...[]byte) [][]byte {
+	r := [][]byte{}
+	for _, chunk := range zlibChunks(rects...) {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(chunk)))
+		r = append(r, append(length, chunk...))
+	}
+	return r
+}
+
+// Tight data compressed on one stream, after its compact length
+func tightCompressed(data []byte) []byte {
+	chunk := zlibChunks(data)[0]
+	return append(compactLength(len(chunk)), chunk...)
+}
+
+func compactLength(n int) []byte {
+	b := []byte{byte(n & 0x7f)}
+	if n > 0x7f {
+		b[0] |= 0x80
+		b = append(b, byte(n>>7&0x7f))
+		if n > 0x3fff {
+			b[1] |= 0x80
+			b = append(b, byte(n>>14))
+		}
+	}
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+type decodeStep struct {
+	rect image.Rectangle
+	data []byte
+}
+
+type decodeTest struct {
+	name     string
+	steps    []decodeStep
+	expected []string // Top left of the framebuffer. The rest must stay blank.
+	err      error
+}
+
+func runDecodeTests(t *testing.T, encoding int32, tests []decodeTest) {
+	for _, test := range tests {
+		session := &vncSession{fb: newFramebuffer(18, 2)}
+		var err error
+		for _, step := range test.steps {
+			r := bytes.NewReader(step.data)
+			decoded := image.NewRGBA(step.rect)
+			if err = rfbDecoders[encoding](session, r, decoded); err != nil {
+				break
+			}
+			blit(session.fb, decoded)
+			if r.Len() != 0 {
+				t.Errorf("%v: %v bytes left over", test.name, r.Len())
+			}
+		}
+		checkErr(t, test.name, err, test.err)
+		if test.err != nil {
+			continue
+		}
+
+		fb := session.fb
+		for y := 0; y < fb.Bounds().Dy(); y++ {
+			for x := 0; x < fb.Bounds().Dx(); x++ {
+				expected := byte('K')
+				if y < len(test.expected) && x < len(test.expected[y]) {
+					expected = test.expected[y][x]
+				}
+				rgb := testColours[expected]
+				off := fb.PixOffset(x, y)
+				if !bytes.Equal(fb.Pix[off:off+4], []byte{rgb[0], rgb[1], rgb[2], 0xff}) {
+					t.Errorf("%v: pixel %v,%v is %v, expected %c", test.name, x, y, fb.Pix[off:off+4], expected)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeHextile(t *testing.T) {
+	rect := image.Rect(0, 0, 4, 2)
+	runDecodeTests(t, rfbEncHextile, []decodeTest{
+		{"raw", []decodeStep{{rect, join([]byte{hextileRaw}, pixels(px4, "RGBWWBGR"))}},
+			[]string{"RGBW", "WBGR"}, nil},
+		{"background", []decodeStep{{rect, join([]byte{hextileBackgroundSpecified}, px4('R'))}},
+			[]string{"RRRR", "RRRR"}, nil},
+		{"foreground subrects", []decodeStep{{rect, join(
+			[]byte{hextileBackgroundSpecified | hextileForegroundSpecified | hextileAnySubrects},
+			px4('R'), px4('G'),
+			[]byte{2, 0x10, 0x10, 0x31, 0x00})}}, // 2x1 at 1,0 and 1x1 at 3,1
+			[]string{"RGGR", "RRRG"}, nil},
+		{"coloured subrects", []decodeStep{{rect, join(
+			[]byte{hextileBackgroundSpecified | hextileAnySubrects | hextileSubrectsColoured},
+			px4('R'), []byte{2},
+			px4('B'), []byte{0x00, 0x01}, // 1x2 at 0,0
+			px4('W'), []byte{0x21, 0x10})}}, // 2x1 at 2,1
+			[]string{"BRRR", "BRWW"}, nil},
+		{"colours carry over tiles", []decodeStep{{image.Rect(0, 0, 18, 1), join(
+			[]byte{hextileBackgroundSpecified | hextileForegroundSpecified}, px4('B'), px4('W'),
+			[]byte{hextileAnySubrects, 1, 0x00, 0x00})}},
+			[]string{"BBBBBBBBBBBBBBBBWB"}, nil},
+		{"offset rectangle", []decodeStep{{image.Rect(2, 1, 4, 2), join([]byte{hextileRaw}, pixels(px4, "GB"))}},
+			[]string{"KKKK", "KKGB"}, nil},
+		{"subrect outside tile", []decodeStep{{rect, join(
+			[]byte{hextileBackgroundSpecified | hextileAnySubrects}, px4('R'),
+			[]byte{1, 0x30, 0x10})}}, // 2x1 at 3,0
+			nil, errBadSubrect},
+		{"truncated", []decodeStep{{rect, join([]byte{hextileRaw}, pixels(px4, "RGB"))}},
+			nil, io.ErrUnexpectedEOF},
+	})
+}
+
+func TestDecodeZRLE(t *testing.T) {
+	rect := image.Rect(0, 0, 4, 2)
+	continued := zrle(join([]byte{1}, px3('R')), join([]byte{1}, px3('G')))
+	runDecodeTests(t, rfbEncZRLE, []decodeTest{
+		{"raw", []decodeStep{{rect, zrle(join([]byte{0}, pixels(px3, "RGBWWBGR")))[0]}},
+			[]string{"RGBW", "WBGR"}, nil},
+		{"solid", []decodeStep{{rect, zrle(join([]byte{1}, px3('B')))[0]}},
+			[]string{"BBBB", "BBBB"}, nil},
+		{"packed palette 1 bit", []decodeStep{{rect, zrle(join([]byte{2}, px3('R'), px3('G'),
+			[]byte{0x60, 0xf0}))[0]}},
+			[]string{"RGGR", "GGGG"}, nil},
+		{"packed palette 2 bits", []decodeStep{{rect, zrle(join([]byte{3}, px3('R'), px3('G'), px3('B'),
+			[]byte{0x18, 0xaa}))[0]}},
+			[]string{"RGBR", "BBBB"}, nil},
+		{"packed palette 4 bits", []decodeStep{{rect, zrle(join([]byte{5}, pixels(px3, "RGBWK"),
+			[]byte{0x01, 0x23, 0x44, 0x32}))[0]}},
+			[]string{"RGBW", "KKWB"}, nil},
+		{"plain rle", []decodeStep{{rect, zrle(join([]byte{128}, px3('R'), []byte{2}, px3('G'), []byte{4}))[0]}},
+			[]string{"RRRG", "GGGG"}, nil},
+		{"run across rows", []decodeStep{{image.Rect(0, 0, 18, 2), zrle(join([]byte{128}, px3('W'), []byte{19}, px3('B'), []byte{15}))[0]}},
+			[]string{"WWWWWWWWWWWWWWWWWW", "WWBBBBBBBBBBBBBBBB"}, nil},
+		{"palette rle", []decodeStep{{rect, zrle(join([]byte{130}, px3('R'), px3('G'),
+			[]byte{0x80, 4, 1, 0x81, 1}))[0]}},
+			[]string{"RRRR", "RGGG"}, nil},
+		{"continued stream", []decodeStep{{image.Rect(0, 0, 2, 1), continued[0]}, {image.Rect(2, 1, 4, 2), continued[1]}},
+			[]string{"RR", "KKGG"}, nil},
+		{"run too long", []decodeStep{{rect, zrle(join([]byte{128}, px3('R'), []byte{8}))[0]}},
+			nil, errBadSubrect},
+		{"palette index out of range", []decodeStep{{rect, zrle(join([]byte{130}, px3('R'), px3('G'), []byte{0x82, 7}))[0]}},
+			nil, errAny},
+		{"invalid subencoding", []decodeStep{{rect, zrle([]byte{17})[0]}},
+			nil, errAny},
+	})
+}
+
+func TestDecodeTight(t *testing.T) {
+	rect := image.Rect(0, 0, 4, 2)
+	white := &bytes.Buffer{}
+	whiteImg := image.NewRGBA(rect)
+	for i := range whiteImg.Pix {
+		whiteImg.Pix[i] = 0xff
+	}
+	jpeg.Encode(white, whiteImg, &jpeg.Options{Quality: 100})
+	continued := zlibChunks(pixels(tpx, "RRRRGGGG"), pixels(tpx, "BBBBWWWW"))
+	restarted := zlibChunks(pixels(tpx, "BBBBWWWW"))
+
+	runDecodeTests(t, rfbEncTight, []decodeTest{
+		{"fill", []decodeStep{{rect, join([]byte{tightFill << 4}, tpx('G'))}},
+			[]string{"GGGG", "GGGG"}, nil},
+		{"jpeg", []decodeStep{{rect, join([]byte{tightJPEG << 4}, compactLength(white.Len()), white.Bytes())}},
+			[]string{"WWWW", "WWWW"}, nil},
+		{"copy uncompressed", []decodeStep{{image.Rect(0, 0, 3, 1), join([]byte{0}, pixels(tpx, "RGB"))}},
+			[]string{"RGB"}, nil},
+		{"copy compressed", []decodeStep{{rect, join([]byte{0}, tightCompressed(pixels(tpx, "RGBWWBGR")))}},
+			[]string{"RGBW", "WBGR"}, nil},
+		{"explicit copy filter", []decodeStep{{image.Rect(0, 0, 2, 1), join([]byte{tightExplicitFilter << 4, tightFilterCopy}, pixels(tpx, "BW"))}},
+			[]string{"BW"}, nil},
+		{"palette 2 colours", []decodeStep{{rect, join([]byte{tightExplicitFilter << 4, tightFilterPalette, 1}, tpx('R'), tpx('G'),
+			[]byte{0x60, 0xf0})}},
+			[]string{"RGGR", "GGGG"}, nil},
+		{"palette 3 colours", []decodeStep{{rect, join([]byte{tightExplicitFilter << 4, tightFilterPalette, 2}, pixels(tpx, "RGB"),
+			[]byte{0, 1, 2, 0, 2, 2, 1, 1})}},
+			[]string{"RGBR", "BBGG"}, nil},
+		{"palette compressed", []decodeStep{{image.Rect(0, 0, 12, 1), join([]byte{tightExplicitFilter << 4, tightFilterPalette, 2}, pixels(tpx, "RGB"),
+			tightCompressed([]byte{0, 1, 2, 0, 1, 2, 0, 1, 2, 0, 1, 2}))}},
+			[]string{"RGBRGBRGBRGB"}, nil},
+		{"gradient", []decodeStep{{image.Rect(0, 0, 2, 1), join([]byte{tightExplicitFilter << 4, tightFilterGradient},
+			[]byte{0xff, 0, 0, 0x01, 0xff, 0})}},
+			[]string{"RG"}, nil},
+		{"gradient compressed", []decodeStep{{image.Rect(0, 0, 2, 2), join([]byte{tightExplicitFilter << 4, tightFilterGradient},
+			tightCompressed([]byte{0, 0, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0}))}},
+			[]string{"BB", "BB"}, nil},
+		{"continued stream", []decodeStep{
+			{rect, join([]byte{0}, compactLength(len(continued[0])), continued[0])},
+			{rect, join([]byte{0}, compactLength(len(continued[1])), continued[1])}},
+			[]string{"BBBB", "WWWW"}, nil},
+		{"reset stream", []decodeStep{
+			{rect, join([]byte{0}, compactLength(len(continued[0])), continued[0])},
+			{rect, join([]byte{0x01}, compactLength(len(restarted[0])), restarted[0])}},
+			[]string{"BBBB", "WWWW"}, nil},
+		{"other stream", []decodeStep{
+			{rect, join([]byte{0}, compactLength(len(continued[0])), continued[0])},
+			{rect, join([]byte{0x10}, compactLength(len(restarted[0])), restarted[0])}},
+			[]string{"BBBB", "WWWW"}, nil},
+		{"new stream without reset", []decodeStep{
+			{rect, join([]byte{0}, compactLength(len(continued[0])), continued[0])},
+			{rect, join([]byte{0}, compactLength(len(restarted[0])), restarted[0])}},
+			nil, errAny},
+		{"invalid control", []decodeStep{{rect, []byte{0xa0}}},
+			nil, errAny},
+		{"invalid filter", []decodeStep{{rect, []byte{tightExplicitFilter << 4, 3}}},
+			nil, errAny},
+	})
+}
+
+func TestReadCompactLength(t *testing.T) {
+	tests := []struct {
+		data   []byte
+		length int
+	}{
+		{[]byte{0x05}, 5},
+		{[]byte{0x7f}, 127},
+		{[]byte{0x80, 0x01}, 128},
+		{[]byte{0x90, 0x4e}, 10000},
+		{[]byte{0xff, 0xff, 0xff}, 4194303},
+	}
+	for _, test := range tests {
+		length, err := readCompactLength(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != test.length {
+			t.Errorf("%x: got %v, expected %v", test.data, length, test.length)
+		}
+		if encoded := compactLength(test.length); !bytes.Equal(encoded, test.data) {
+			t.Errorf("%v: encoded as %x, expected %x", test.length, encoded, test.data)
+		}
+	}
+}
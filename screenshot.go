@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// How long to wait for a new session to receive its first full framebuffer
+const screenshotTimeout = 10 * time.Second
+
+// Largest image dimension which can be requested
+const screenshotMaxSize = 8192
+
+var ErrScreenshotTimeout = errors.New("timed out waiting for framebuffer")
+
+// Encodes an image in a particular format
+type imageEncoder struct {
+	contentType string
+	encode      func(buf *bytes.Buffer, img image.Image, quality int) error
+}
+
+var screenshotEncoders = map[string]imageEncoder{
+	"screenshot.png": {"image/png", func(buf *bytes.Buffer, img image.Image, _ int) error {
+		return png.Encode(buf, img)
+	}},
+	"screenshot.jpg": {"image/jpeg", func(buf *bytes.Buffer, img image.Image, quality int) error {
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	}},
+}
+
+// Parse the size of a scaled image from width, height or scale query parameters.
+// Giving only one of width or height keeps the aspect ratio.
+func parseImageSize(query url.Values, bounds image.Rectangle) (int, int, error) {
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if s := query.Get("scale"); s != "" {
+		scale, err := strconv.ParseFloat(s, 64)
+		if err != nil || scale <= 0 {
+			return 0, 0, fmt.Errorf("invalid scale: %v", s)
+		}
+		width = int(float64(width)*scale + 0.5)
+		height = int(float64(height)*scale + 0.5)
+	}
+
+	var reqWidth, reqHeight int
+	var err error
+	if s := query.Get("width"); s != "" {
+		if reqWidth, err = strconv.Atoi(s); err != nil || reqWidth <= 0 {
+			return 0, 0, fmt.Errorf("invalid width: %v", s)
+		}
+	}
+	if s := query.Get("height"); s != "" {
+		if reqHeight, err = strconv.Atoi(s); err != nil || reqHeight <= 0 {
+			return 0, 0, fmt.Errorf("invalid height: %v", s)
+		}
+	}
+	switch {
+	case reqWidth != 0 && reqHeight != 0:
+		width, height = reqWidth, reqHeight
+	case reqWidth != 0:
+		height = (bounds.Dy()*reqWidth + bounds.Dx()/2) / bounds.Dx()
+		width = reqWidth
+	case reqHeight != 0:
+		width = (bounds.Dx()*reqHeight + bounds.Dy()/2) / bounds.Dy()
+		height = reqHeight
+	}
+
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	if width > screenshotMaxSize || height > screenshotMaxSize {
+		return 0, 0, fmt.Errorf("image too large: %vx%v", width, height)
+	}
+	return width, height, nil
+}
+
+// Parse the JPEG quality query parameter
+func parseImageQuality(query url.Values) (int, error) {
+	s := query.Get("quality")
+	if s == "" {
+		return jpeg.DefaultQuality, nil
+	}
+	quality, err := strconv.Atoi(s)
+	if err != nil || quality < 1 || quality > 100 {
+		return 0, fmt.Errorf("invalid quality: %v", s)
+	}
+	return quality, nil
+}
+
+// Resize an image. Each destination pixel averages the source pixels it covers
+// when shrinking, and samples the nearest source pixel when enlarging.
+func scaleImage(src *image.RGBA, width int, height int) *image.RGBA {
+	bounds := src.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if bounds.Empty() {
+		return dst
+	}
+	sw, sh := bounds.Dx(), bounds.Dy()
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := (y + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := (x + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				p := src.Pix[src.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy):]
+				for sx := x0; sx < x1; sx++ {
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					p = p[4:]
+					n++
+				}
+			}
+			d := dst.Pix[dst.PixOffset(x, y):]
+			d[0] = uint8(r / n)
+			d[1] = uint8(g / n)
+			d[2] = uint8(b / n)
+			d[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Take a copy of a server's screen, joining its session or connecting for just
+// long enough to receive a complete framebuffer.
+func captureScreen(broker *sessionBroker, server vncServer) (*image.RGBA, error) {
+	session, err := broker.Acquire(server)
+	if err != nil {
+		return nil, err
+	}
+	defer broker.Release(session)
+
+	select {
+	case <-session.Updated():
+	case <-session.Done():
+		return nil, session.Err()
+	case <-time.After(screenshotTimeout):
+		return nil, ErrScreenshotTimeout
+	}
+	return session.Snapshot(), nil
+}
+
+// Serves a still image of a server's screen as PNG or JPEG
+func screenshotHandler(manager *serverManager, broker *sessionBroker, audit *auditLog) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		encoder, ok := screenshotEncoders[ps.ByName("file")]
+		if !ok {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		server, found := requestServer(r, manager, ps)
+		if !found {
+			http.Error(w, "VNC host not found", 404)
+			return
+		}
+		quality, err := parseImageQuality(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		auditSession := audit.Start(r, server, Viewer_Screenshot, false)
+		img, err := captureScreen(broker, server)
+		if err != nil {
+			auditSession.End(0, 0, err)
+			log.With("server", server.Redacted()).Errorln("Error capturing screenshot:", err)
+			http.Error(w, "Error connecting to VNC server", 502)
+			return
+		}
+
+		width, height, err := parseImageSize(r.URL.Query(), img.Bounds())
+		if err != nil {
+			auditSession.End(0, 0, err)
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if err := encoder.encode(buf, scaleImage(img, width, height), quality); err != nil {
+			auditSession.End(0, 0, err)
+			log.With("server", server.Redacted()).Errorln("Error encoding screenshot:", err)
+			http.Error(w, "Error encoding screenshot", 500)
+			return
+		}
+		auditSession.End(0, int64(buf.Len()), nil)
+
+		w.Header().Set("Content-Type", encoder.contentType)
+		w.Header().Set("Content-Length", fmt.Sprintf("%v", buf.Len()))
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseImageSize(t *testing.T) {
+	bounds := image.Rect(0, 0, 1280, 720)
+	tests := []struct {
+		query  string
+		width  int
+		height int
+		err    bool
+	}{
+		{"", 1280, 720, false},
+		{"scale=0.5", 640, 360, false},
+		{"scale=2", 2560, 1440, false},
+		{"width=320", 320, 180, false},
+		{"height=90", 160, 90, false},
+		{"width=100&height=100", 100, 100, false},
+		{"scale=0.5&width=320", 320, 180, false}, // Width and height win
+		{"scale=0.0001", 1, 1, false},
+		{"scale=0", 0, 0, true},
+		{"scale=-1", 0, 0, true},
+		{"scale=big", 0, 0, true},
+		{"width=0", 0, 0, true},
+		{"height=-5", 0, 0, true},
+		{"width=wide", 0, 0, true},
+		{"scale=10", 0, 0, true},
+		{"width=9000", 0, 0, true},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		width, height, err := parseImageSize(query, bounds)
+		if (err != nil) != test.err || width != test.width || height != test.height {
+			t.Errorf("%q: got %vx%v, %v, expected %vx%v", test.query, width, height, err, test.width, test.height)
+		}
+	}
+}
+
+func TestParseImageQuality(t *testing.T) {
+	tests := []struct {
+		query   string
+		quality int
+		err     bool
+	}{
+		{"", jpeg.DefaultQuality, false},
+		{"quality=1", 1, false},
+		{"quality=100", 100, false},
+		{"quality=0", 0, true},
+		{"quality=101", 0, true},
+		{"quality=high", 0, true},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		quality, err := parseImageQuality(query)
+		if (err != nil) != test.err || quality != test.quality {
+			t.Errorf("%q: got %v, %v, expected %v", test.query, quality, err, test.quality)
+		}
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	// Two columns of black and white
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{0, 0, 0, 0xff}
+			if x >= 2 {
+				c = color.RGBA{0xff, 0xff, 0xff, 0xff}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	tests := []struct {
+		width    int
+		height   int
+		expected map[image.Point]color.RGBA
+	}{
+		{4, 2, map[image.Point]color.RGBA{{1, 1}: {0, 0, 0, 0xff}, {2, 0}: {0xff, 0xff, 0xff, 0xff}}},
+		{2, 1, map[image.Point]color.RGBA{{0, 0}: {0, 0, 0, 0xff}, {1, 0}: {0xff, 0xff, 0xff, 0xff}}},
+		{1, 1, map[image.Point]color.RGBA{{0, 0}: {0x7f, 0x7f, 0x7f, 0xff}}},
+		{8, 4, map[image.Point]color.RGBA{{3, 3}: {0, 0, 0, 0xff}, {4, 0}: {0xff, 0xff, 0xff, 0xff}}},
+		{3, 2, map[image.Point]color.RGBA{{0, 0}: {0, 0, 0, 0xff}, {2, 1}: {0xff, 0xff, 0xff, 0xff}}},
+	}
+	for _, test := range tests {
+		dst := scaleImage(src, test.width, test.height)
+		if dst.Bounds() != image.Rect(0, 0, test.width, test.height) {
+			t.Errorf("%vx%v: got %v", test.width, test.height, dst.Bounds())
+		}
+		for pt, expected := range test.expected {
+			if c := dst.RGBAAt(pt.X, pt.Y); c != expected {
+				t.Errorf("%vx%v: pixel at %v is %v, expected %v", test.width, test.height, pt, c, expected)
+			}
+		}
+	}
+}
+
+func TestScreenshotHandler(t *testing.T) {
+	upstream := newFilledVNCServer(t, 8, 4, color.RGBA{0xff, 0, 0, 0xff})
+	defer upstream.Close()
+	manager := NewServerManager()
+	manager.Add(upstream.Server())
+	closed := vncServer{NetType: "tcp", Address: "127.0.0.1:1"}
+	manager.Add(closed)
+	broker := NewSessionBroker(nil)
+
+	tests := []struct {
+		server string
+		image  string
+		query  string
+		status int
+		width  int
+		audit  string // Outcome recorded in the audit log, if the capture was attempted
+	}{
+		{upstream.Server().Short(), "screenshot.png", "", 200, 8, "ok"},
+		{upstream.Server().Short(), "screenshot.png", "width=4", 200, 4, "ok"},
+		{upstream.Server().Short(), "screenshot.jpg", "quality=50", 200, 8, "ok"},
+		{upstream.Server().Short(), "screenshot.gif", "", 404, 0, ""},
+		{upstream.Server().Short(), "screenshot.png", "quality=0", 400, 0, ""},
+		{upstream.Server().Short(), "screenshot.png", "width=9000", 400, 0, "error"},
+		{"missing", "screenshot.png", "", 404, 0, ""},
+		{closed.Short(), "screenshot.png", "", 502, 0, "error"},
+	}
+	audited := &bytes.Buffer{}
+	handler := screenshotHandler(manager, broker, &auditLog{w: audited})
+	for _, test := range tests {
+		audited.Reset()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/servers/"+test.server+"/"+test.image+"?"+test.query, nil)
+		handler(w, r, httprouter.Params{{Key: "shortname", Value: test.server}, {Key: "file", Value: test.image}})
+		if audit := auditOutcome(t, audited.Bytes(), Viewer_Screenshot); audit != test.audit {
+			t.Errorf("%v %v?%v: audited %q, expected %q", test.server, test.image, test.query, audit, test.audit)
+		}
+		if w.Code != test.status {
+			t.Errorf("%v %v?%v: got status %v, expected %v", test.server, test.image, test.query, w.Code, test.status)
+			continue
+		}
+		if w.Code != 200 {
+			continue
+		}
+
+		decode := png.Decode
+		if test.image == "screenshot.jpg" {
+			decode = jpeg.Decode
+		}
+		img, err := decode(w.Body)
+		if err != nil {
+			t.Errorf("%v?%v: %v", test.image, test.query, err)
+			continue
+		}
+		if img.Bounds().Dx() != test.width {
+			t.Errorf("%v?%v: got %v wide, expected %v", test.image, test.query, img.Bounds().Dx(), test.width)
+		}
+		if r, g, _, _ := img.At(0, 0).RGBA(); r>>8 < 0xf0 || g>>8 > 0x10 {
+			t.Errorf("%v?%v: got colour %v, expected red", test.image, test.query, img.At(0, 0))
+		}
+	}
+}
@@ -0,0 +1,688 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"image"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// Longest desktop name accepted from a VNC server
+const rfbMaxNameLength = 1 << 16
+
+var (
+	ErrSessionClosed   = errors.New("VNC session closed")
+	errRectOutOfBounds = errors.New("rectangle outside of framebuffer")
+)
+
+// Changes to a session since a subscriber last looked
+type sessionUpdate struct {
+	Dirty   image.Rectangle // Region of the framebuffer which changed
+	Resized bool            // The framebuffer changed size
+	Bells   int             // Number of bells rung
+	CutText []byte          // Most recent server clipboard contents, if any
+}
+
+// Receives notification of changes to a session's framebuffer. Changes accumulate
+// until collected with Take, so slow subscribers never block the session.
+type sessionSubscriber struct {
+	mtx     sync.Mutex
+	pending sessionUpdate
+	notify  chan struct{}
+}
+
+// Channel which is signalled when there are changes to collect
+func (this *sessionSubscriber) Notify() <-chan struct{} {
+	return this.notify
+}
+
+// Collect and reset accumulated changes
+func (this *sessionSubscriber) Take() sessionUpdate {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	update := this.pending
+	this.pending = sessionUpdate{}
+	return update
+}
+
+func (this *sessionSubscriber) add(update sessionUpdate) {
+	this.mtx.Lock()
+	this.pending.Dirty = this.pending.Dirty.Union(update.Dirty)
+	this.pending.Resized = this.pending.Resized || update.Resized
+	this.pending.Bells += update.Bells
+	if update.CutText != nil {
+		this.pending.CutText = update.CutText
+	}
+	this.mtx.Unlock()
+
+	select {
+	case this.notify <- struct{}{}:
+	default:
+	}
+}
+
+// A single upstream connection to a VNC server, maintaining a copy of its
+// framebuffer which any number of subscribers can follow.
+type vncSession struct {
+	server vncServer
+	conn   net.Conn
+	reader *messageCapture
+	wmtx   sync.Mutex // Serialises writes to the server
+
+	fb    *image.RGBA
+	name  string
+	fbmtx sync.RWMutex
+
+	zrle  zlibStream    // Decompression state for ZRLE
+	tight [4]zlibStream // Decompression state for Tight
+
+	recorder *sessionRecorder // Recording of the server's messages, if there is one
+	rmtx     sync.Mutex       // Held while a message is read, so recordings start and stop between messages
+
+	subscribers map[*sessionSubscriber]struct{}
+	smtx        sync.Mutex
+
+	refs    int           // Reference count, protected by the broker
+	record  bool          // Whether the session should be recorded, protected by the broker
+	stopRec chan struct{} // Closed to stop the recording, if there is one. Protected by the broker.
+	ready   chan struct{} // Closed once connected (or failed to)
+	updated chan struct{} // Closed once the first framebuffer update has been received
+	err     error         // Connection or session error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Channel closed when the session ends
+func (this *vncSession) Done() <-chan struct{} {
+	return this.done
+}
+
+// Error which ended the session. Blocks until it has ended.
+func (this *vncSession) Err() error {
+	<-this.done
+	return this.err
+}
+
+// Channel closed once the framebuffer holds a complete picture of the screen
+func (this *vncSession) Updated() <-chan struct{} {
+	return this.updated
+}
+
+// Desktop name reported by the server
+func (this *vncSession) Name() string {
+	this.fbmtx.RLock()
+	defer this.fbmtx.RUnlock()
+	return this.name
+}
+
+// Current framebuffer dimensions
+func (this *vncSession) Bounds() image.Rectangle {
+	this.fbmtx.RLock()
+	defer this.fbmtx.RUnlock()
+	return this.fb.Bounds()
+}
+
+// Copy of the current framebuffer
+func (this *vncSession) Snapshot() *image.RGBA {
+	this.fbmtx.RLock()
+	defer this.fbmtx.RUnlock()
+
+	img := image.NewRGBA(this.fb.Bounds())
+	copy(img.Pix, this.fb.Pix)
+	return img
+}
+
+// Run a function with read access to the framebuffer
+func (this *vncSession) WithFramebuffer(fn func(fb *image.RGBA)) {
+	this.fbmtx.RLock()
+	defer this.fbmtx.RUnlock()
+	fn(this.fb)
+}
+
+func (this *vncSession) Subscribe() *sessionSubscriber {
+	this.smtx.Lock()
+	defer this.smtx.Unlock()
+
+	sub := &sessionSubscriber{notify: make(chan struct{}, 1)}
+	this.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (this *vncSession) Unsubscribe(sub *sessionSubscriber) {
+	this.smtx.Lock()
+	defer this.smtx.Unlock()
+	delete(this.subscribers, sub)
+}
+
+func (this *vncSession) publish(update sessionUpdate) {
+	this.smtx.Lock()
+	defer this.smtx.Unlock()
+
+	for sub := range this.subscribers {
+		sub.add(update)
+	}
+}
+
+// Send a raw client message to the server
+func (this *vncSession) Write(msg []byte) error {
+	this.wmtx.Lock()
+	defer this.wmtx.Unlock()
+
+	_, err := this.conn.Write(msg)
+	return err
+}
+
+// End the session, closing the upstream connection
+func (this *vncSession) Close() {
+	this.closeWithError(ErrSessionClosed)
+}
+
+func (this *vncSession) closeWithError(err error) {
+	this.closeOnce.Do(func() {
+		this.err = err
+		close(this.done)
+		if this.conn != nil {
+			this.conn.Close()
+		}
+	})
+}
+
+// Connect and initialise the session with the VNC server
+func (this *vncSession) connect() error {
+	conn, err := net.DialTimeout(this.server.NetType, this.server.Address, *handshakeTimeout)
+	if err != nil {
+		dialFailures.WithLabelValues(this.server.Short()).Inc()
+		return err
+	}
+	this.conn = conn
+
+	conn.SetDeadline(time.Now().Add(*handshakeTimeout))
+	if _, err := rfbClientHandshake(conn, this.server.Password); err != nil {
+		return err
+	}
+
+	// ClientInit. Always ask to share, so other clients of the server are left alone.
+	if _, err := conn.Write([]byte{1}); err != nil {
+		return err
+	}
+
+	idle := &idleTimeoutConn{Conn: conn}
+	this.reader = &messageCapture{r: bufio.NewReader(idle)}
+	b := make([]byte, 24)
+	if _, err := io.ReadFull(this.reader, b); err != nil {
+		return err
+	}
+	width := int(binary.BigEndian.Uint16(b[0:2]))
+	height := int(binary.BigEndian.Uint16(b[2:4]))
+	nameLength := binary.BigEndian.Uint32(b[20:24])
+	if nameLength > rfbMaxNameLength {
+		return fmt.Errorf("desktop name too long: %v bytes", nameLength)
+	}
+	name := make([]byte, nameLength)
+	if _, err := io.ReadFull(this.reader, name); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	// Keepalive requests are answered, so a server which stays quiet for longer is
+	// gone
+	idle.timeout = 2 * *sessionKeepalive
+
+	this.name = string(name)
+	this.fb = newFramebuffer(width, height)
+
+	// Everything is decoded in our own pixel format
+	if err := this.Write(rfbSetPixelFormatMsg(rfbSessionPixelFormat)); err != nil {
+		return err
+	}
+	if err := this.Write(rfbSetEncodingsMsg(rfbSessionEncodings)); err != nil {
+		return err
+	}
+	return this.Write(rfbFramebufferUpdateRequestMsg(false, this.fb.Bounds()))
+}
+
+// Blank opaque framebuffer
+func newFramebuffer(width int, height int) *image.RGBA {
+	fb := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 3; i < len(fb.Pix); i += 4 {
+		fb.Pix[i] = 0xff
+	}
+	return fb
+}
+
+// Read server messages until the connection fails or is closed
+func (this *vncSession) run() {
+	if *sessionKeepalive > 0 {
+		go this.keepalive(*sessionKeepalive)
+	}
+
+	var err error
+	for err == nil {
+		msgType := make([]byte, 1)
+		if _, err = io.ReadFull(this.reader, msgType); err != nil {
+			break
+		}
+
+		this.rmtx.Lock()
+		this.reader.Start(this.recorder != nil)
+		var update sessionUpdate
+		var replayable bool
+		update, replayable, err = this.readMessage(msgType[0])
+		if err == nil && this.recorder != nil {
+			this.recordMessage(msgType[0], update, replayable)
+		}
+		this.rmtx.Unlock()
+	}
+
+	select {
+	case <-this.done:
+	default:
+		log.With("server", this.server.Redacted()).Errorln("VNC session ended:", err)
+	}
+	this.closeWithError(err)
+}
+
+// Read the rest of a server message and act on it, returning the changes it made
+// and whether it can be played back as it was read
+func (this *vncSession) readMessage(msgType uint8) (sessionUpdate, bool, error) {
+	switch msgType {
+	case rfbMsgFramebufferUpdate:
+		update, replayable, err := this.readFramebufferUpdate()
+		if err != nil {
+			return update, false, err
+		}
+		// The first update answers the full update request made on connecting
+		select {
+		case <-this.updated:
+		default:
+			close(this.updated)
+		}
+		return update, replayable, this.Write(rfbFramebufferUpdateRequestMsg(true, this.Bounds()))
+	case rfbMsgSetColourMapEntries:
+		// Only possible before our pixel format takes effect
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(this.reader, b); err != nil {
+			return sessionUpdate{}, false, err
+		}
+		_, err := io.CopyN(ioutil.Discard, this.reader, 6*int64(binary.BigEndian.Uint16(b[3:5])))
+		return sessionUpdate{}, false, err
+	case rfbMsgBell:
+		update := sessionUpdate{Bells: 1}
+		this.publish(update)
+		return update, true, nil
+	case rfbMsgServerCutText:
+		b := make([]byte, 7)
+		if _, err := io.ReadFull(this.reader, b); err != nil {
+			return sessionUpdate{}, false, err
+		}
+		length := binary.BigEndian.Uint32(b[3:7])
+		if length > rfbMaxCutText {
+			return sessionUpdate{}, false, fmt.Errorf("server cut text too long: %v bytes", length)
+		}
+		text := make([]byte, length)
+		if _, err := io.ReadFull(this.reader, text); err != nil {
+			return sessionUpdate{}, false, err
+		}
+		// Clipboards aren't recorded
+		update := sessionUpdate{CutText: text}
+		this.publish(update)
+		return update, false, nil
+	default:
+		return sessionUpdate{}, false, fmt.Errorf("unknown RFB server message type %v", msgType)
+	}
+}
+
+// Record a server message as it was read, or the changes it made to the
+// framebuffer if it can't be played back. Must be called with rmtx held.
+func (this *vncSession) recordMessage(msgType uint8, update sessionUpdate, replayable bool) {
+	var frame []byte
+	if replayable {
+		frame = append([]byte{msgType}, this.reader.Bytes()...)
+	} else if !update.Dirty.Empty() || update.Resized {
+		this.fbmtx.RLock()
+		frame = rawUpdateFrame(this.fb, update)
+		this.fbmtx.RUnlock()
+	} else {
+		return
+	}
+	if err := this.recorder.WriteFrame(frame); err != nil {
+		log.With("server", this.server.Redacted()).Errorln("Recording failed:", err)
+		this.recorder = nil
+	}
+}
+
+// Start recording the session. The recording begins with the handshake a viewer
+// would see and the screen as it is, followed by the server's messages.
+func (this *vncSession) startRecording(recorder *sessionRecorder) error {
+	this.rmtx.Lock()
+	defer this.rmtx.Unlock()
+
+	// Stateful encodings can't be played from the middle of the session, and
+	// whatever is still sent with them is recorded from the framebuffer
+	if err := this.Write(rfbSetEncodingsMsg(rfbRecordingEncodings)); err != nil {
+		return err
+	}
+	this.fbmtx.RLock()
+	frames := recordingHandshake(this.fb, this.name)
+	this.fbmtx.RUnlock()
+	for _, frame := range frames {
+		if err := recorder.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+	this.recorder = recorder
+	return nil
+}
+
+// Stop recording the session, if it is being recorded
+func (this *vncSession) stopRecording() {
+	this.rmtx.Lock()
+	defer this.rmtx.Unlock()
+	this.recorder = nil
+	this.Write(rfbSetEncodingsMsg(rfbSessionEncodings))
+}
+
+// Reads server messages, keeping what was read of the current one if asked to
+type messageCapture struct {
+	r       io.Reader
+	buf     bytes.Buffer
+	capture bool
+}
+
+func (this *messageCapture) Read(b []byte) (int, error) {
+	n, err := this.r.Read(b)
+	if this.capture {
+		this.buf.Write(b[:n])
+	}
+	return n, err
+}
+
+// Start a new message, forgetting the last
+func (this *messageCapture) Start(capture bool) {
+	this.buf.Reset()
+	this.capture = capture
+}
+
+// What has been read of the current message
+func (this *messageCapture) Bytes() []byte {
+	return this.buf.Bytes()
+}
+
+// Ask for a single pixel now and then. Servers only send updates when the
+// screen changes, so a quiet server would otherwise look the same as a dead one.
+func (this *vncSession) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-ticker.C:
+			if err := this.Write(rfbFramebufferUpdateRequestMsg(false, image.Rect(0, 0, 1, 1))); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// A connection whose reads time out once it has been quiet for too long. Zero
+// disables the timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (this *idleTimeoutConn) Read(b []byte) (int, error) {
+	if this.timeout > 0 {
+		this.Conn.SetReadDeadline(time.Now().Add(this.timeout))
+	}
+	return this.Conn.Read(b)
+}
+
+// Read a FramebufferUpdate into the framebuffer, publishing and returning what
+// changed and whether the update can be played back as it was sent
+func (this *vncSession) readFramebufferUpdate() (sessionUpdate, bool, error) {
+	update := sessionUpdate{}
+	replayable := true
+
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(this.reader, b); err != nil {
+		return update, false, err
+	}
+	numRects := int(binary.BigEndian.Uint16(b[1:3]))
+
+	header := make([]byte, 12)
+	for i := 0; i < numRects; i++ {
+		if _, err := io.ReadFull(this.reader, header); err != nil {
+			return update, false, err
+		}
+		x := int(binary.BigEndian.Uint16(header[0:2]))
+		y := int(binary.BigEndian.Uint16(header[2:4]))
+		w := int(binary.BigEndian.Uint16(header[4:6]))
+		h := int(binary.BigEndian.Uint16(header[6:8]))
+		rect := image.Rect(x, y, x+w, y+h)
+		encoding := int32(binary.BigEndian.Uint32(header[8:12]))
+
+		switch encoding {
+		case rfbEncLastRect:
+			// Sent in place of the real number of rectangles
+			numRects = 0
+			continue
+		case rfbEncDesktopSize:
+			this.fbmtx.Lock()
+			this.fb = newFramebuffer(w, h)
+			this.fbmtx.Unlock()
+			update.Resized = true
+			update.Dirty = image.Rect(0, 0, w, h)
+			continue
+		}
+
+		if !rect.In(this.Bounds()) {
+			return update, false, errRectOutOfBounds
+		}
+		if encoding == rfbEncCopyRect {
+			if _, err := io.ReadFull(this.reader, header[:4]); err != nil {
+				return update, false, err
+			}
+			src := image.Pt(int(binary.BigEndian.Uint16(header[0:2])), int(binary.BigEndian.Uint16(header[2:4])))
+			this.fbmtx.Lock()
+			err := copyRect(this.fb, rect, src)
+			this.fbmtx.Unlock()
+			if err != nil {
+				return update, false, err
+			}
+			update.Dirty = update.Dirty.Union(rect)
+			continue
+		}
+
+		decoder, ok := rfbDecoders[encoding]
+		replayable = replayable && recordable(encoding)
+		if !ok {
+			return update, false, fmt.Errorf("unsupported RFB encoding %v", encoding)
+		}
+		// Decode off to the side, so readers of the framebuffer aren't held up by
+		// a slow server
+		decoded := image.NewRGBA(rect)
+		if err := decoder(this, this.reader, decoded); err != nil {
+			return update, false, err
+		}
+		this.fbmtx.Lock()
+		changed := blit(this.fb, decoded)
+		this.fbmtx.Unlock()
+		if changed {
+			update.Dirty = update.Dirty.Union(rect)
+		}
+	}
+
+	if !update.Dirty.Empty() || update.Resized {
+		this.publish(update)
+	}
+	return update, replayable, nil
+}
+
+// Copy a decoded rectangle into the framebuffer, reporting whether any pixels
+// changed. Needs the framebuffer locked for writing.
+func blit(fb *image.RGBA, src *image.RGBA) bool {
+	changed := false
+	width := src.Rect.Dx() * 4
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		row := fb.Pix[fb.PixOffset(src.Rect.Min.X, y):][:width]
+		srcRow := src.Pix[src.PixOffset(src.Rect.Min.X, y):][:width]
+		if !changed && !bytes.Equal(row, srcRow) {
+			changed = true
+		}
+		copy(row, srcRow)
+	}
+	return changed
+}
+
+// Shares one session per VNC server between everything which wants to use it
+type sessionBroker struct {
+	sessions   map[string]*vncSession // By URL, so servers whose credentials change get new sessions
+	recordings *recordingStore        // Records sessions of servers which ask for it
+	mtx        sync.Mutex
+}
+
+func NewSessionBroker(recordings *recordingStore) *sessionBroker {
+	return &sessionBroker{
+		sessions:   make(map[string]*vncSession),
+		recordings: recordings,
+	}
+}
+
+// Get the session for a server, connecting if there isn't one running. Every
+// successful call must be paired with a call to Release.
+func (this *sessionBroker) Acquire(server vncServer) (*vncSession, error) {
+	this.mtx.Lock()
+	session, ok := this.sessions[server.String()]
+	if ok {
+		select {
+		case <-session.done:
+			// Ended but not yet cleaned up
+			ok = false
+		default:
+		}
+	}
+	if !ok {
+		session = &vncSession{
+			server:      server,
+			record:      server.Record,
+			subscribers: make(map[*sessionSubscriber]struct{}),
+			ready:       make(chan struct{}),
+			updated:     make(chan struct{}),
+			done:        make(chan struct{}),
+		}
+		this.sessions[server.String()] = session
+		go this.start(session)
+	}
+	session.refs++
+	this.mtx.Unlock()
+
+	<-session.ready
+	select {
+	case <-session.done:
+		this.Release(session)
+		return nil, session.err
+	default:
+	}
+	return session, nil
+}
+
+// Give up a reference to a session, closing it when it has no more users
+func (this *sessionBroker) Release(session *vncSession) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	session.refs--
+	if session.refs > 0 {
+		return
+	}
+	if this.sessions[session.server.String()] == session {
+		delete(this.sessions, session.server.String())
+	}
+	session.Close()
+	log.With("server", session.server.Redacted()).Debugln("VNC session released")
+}
+
+func (this *sessionBroker) start(session *vncSession) {
+	log.With("type", session.server.NetType).
+		With("addr", session.server.Address).
+		With("user", session.server.Username).Infoln("Opening VNC session to server")
+
+	err := session.connect()
+	if err != nil {
+		session.closeWithError(err)
+		close(session.ready)
+		return
+	}
+	close(session.ready)
+
+	this.mtx.Lock()
+	this.updateRecording(session)
+	this.mtx.Unlock()
+
+	session.run()
+
+	// Stop handing out the dead session
+	this.mtx.Lock()
+	if this.sessions[session.server.String()] == session {
+		delete(this.sessions, session.server.String())
+	}
+	this.mtx.Unlock()
+}
+
+// Start or stop recording a connected session to match its record setting.
+// Must be called with the broker locked.
+func (this *sessionBroker) updateRecording(session *vncSession) {
+	if this.recordings == nil {
+		return
+	}
+	select {
+	case <-session.done:
+		return
+	default:
+	}
+	if session.record && session.stopRec == nil {
+		session.stopRec = make(chan struct{})
+		go this.recordings.Record(session, session.stopRec)
+	} else if !session.record && session.stopRec != nil {
+		close(session.stopRec)
+		session.stopRec = nil
+	}
+}
+
+// Follow changes to servers, so turning recording on or off applies to sessions
+// already running
+func (this *sessionBroker) FollowManager(manager *serverManager) {
+	sub := manager.Subscribe()
+	defer manager.Unsubscribe(sub)
+
+	for range sub.Notify() {
+		events, _ := sub.Take()
+		for _, e := range events {
+			if e.Action != Manager_UpdatedServer {
+				continue
+			}
+			this.mtx.Lock()
+			if session, ok := this.sessions[e.Server.String()]; ok {
+				session.record = e.Server.Record
+				select {
+				case <-session.ready:
+					this.updateRecording(session)
+				default:
+					// Still connecting, so recording starts once it has
+				}
+			}
+			this.mtx.Unlock()
+		}
+	}
+}
@@ -0,0 +1,716 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// How long tests wait for something which should happen straight away
+const testTimeout = 5 * time.Second
+
+// A VNC server for tests. It completes the handshake with each client and
+// hands the connection to the test to script the rest.
+type fakeVNCServer struct {
+	listener net.Listener
+	width    int
+	height   int
+	name     string
+	conns    chan *fakeVNCConn
+	fill     *color.RGBA // If set, answer full update requests with this colour instead of handing out connections
+}
+
+// A client connection to a fakeVNCServer
+type fakeVNCConn struct {
+	net.Conn
+	messages chan []byte // Client messages after ClientInit. Closed when the client goes.
+	shared   bool        // Shared flag of ClientInit
+}
+
+func newFakeVNCServer(t *testing.T, width int, height int, name string) *fakeVNCServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	this := &fakeVNCServer{
+		listener: listener,
+		width:    width,
+		height:   height,
+		name:     name,
+		conns:    make(chan *fakeVNCConn, 16),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go this.serve(conn)
+		}
+	}()
+	return this
+}
+
+// The server to connect to
+func (this *fakeVNCServer) Server() vncServer {
+	return vncServer{NetType: "tcp", Address: this.listener.Addr().String()}
+}
+
+func (this *fakeVNCServer) Close() {
+	this.listener.Close()
+}
+
+func (this *fakeVNCServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	b := make([]byte, 12)
+	if _, err := io.WriteString(conn, "RFB 003.008\n"); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, b); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{1, rfbSecNone}); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return
+	}
+	if _, err := conn.Write(rfbServerInitMsg(this.width, this.height, rfbSessionPixelFormat, this.name)); err != nil {
+		return
+	}
+
+	fc := &fakeVNCConn{Conn: conn, messages: make(chan []byte, 100), shared: b[0] != 0}
+	go func() {
+		defer close(fc.messages)
+		for {
+			_, message, err := readRFBClientMessage(r)
+			if err != nil {
+				return
+			}
+			fc.messages <- message
+		}
+	}()
+	if this.fill == nil {
+		this.conns <- fc
+		return
+	}
+	defer conn.Close()
+	for message := range fc.messages {
+		if message[0] == rfbMsgFramebufferUpdateRequest && message[1] == 0 {
+			msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, this.width, this.height), rfbEncRaw)
+			for i := 0; i < this.width*this.height; i++ {
+				msg = append(msg, this.fill.B, this.fill.G, this.fill.R, 0)
+			}
+			if _, err := conn.Write(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Answer every client's first update request with a screen of one colour
+func newFilledVNCServer(t *testing.T, width int, height int, c color.RGBA) *fakeVNCServer {
+	this := newFakeVNCServer(t, width, height, "desktop")
+	this.fill = &c
+	return this
+}
+
+// Wait for the next client to connect
+func (this *fakeVNCServer) Accept(t *testing.T) *fakeVNCConn {
+	t.Helper()
+	select {
+	case conn := <-this.conns:
+		return conn
+	case <-time.After(testTimeout):
+		t.Fatal("no connection to the VNC server")
+		return nil
+	}
+}
+
+// Wait for the next client message of a type, skipping others
+func (this *fakeVNCConn) Expect(t *testing.T, msgType uint8) []byte {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case message, ok := <-this.messages:
+			if !ok {
+				t.Fatalf("client went away waiting for message type %v", msgType)
+			}
+			if message[0] == msgType {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no message of type %v", msgType)
+		}
+	}
+}
+
+// Wait for the client to go away
+func (this *fakeVNCConn) ExpectClosed(t *testing.T) {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case _, ok := <-this.messages:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("client still connected")
+		}
+	}
+}
+
+// Wait for a condition to become true
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(testTimeout); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+	}
+}
+
+// Send a FramebufferUpdate filling a rectangle with a colour in raw encoding
+func (this *fakeVNCConn) SendFill(t *testing.T, rect image.Rectangle, c color.RGBA) {
+	t.Helper()
+	msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), rect, rfbEncRaw)
+	for i := 0; i < rect.Dx()*rect.Dy(); i++ {
+		msg = append(msg, c.B, c.G, c.R, 0)
+	}
+	if _, err := this.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sameUpdate(a sessionUpdate, b sessionUpdate) bool {
+	return a.Dirty == b.Dirty && a.Resized == b.Resized && a.Bells == b.Bells && bytes.Equal(a.CutText, b.CutText)
+}
+
+// Collect changes until they add up to those expected, or it takes too long
+func waitUpdateOf(sub *sessionSubscriber, expected sessionUpdate) sessionUpdate {
+	collected := &sessionSubscriber{notify: make(chan struct{}, 1)}
+	timeout := time.After(testTimeout)
+	for !sameUpdate(collected.pending, expected) {
+		select {
+		case <-sub.Notify():
+			collected.add(sub.Take())
+		case <-timeout:
+			return collected.pending
+		}
+	}
+	return collected.pending
+}
+
+func TestSessionUpdates(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	tests := []struct {
+		name    string
+		message func(conn *fakeVNCConn)
+		request bool // Answers an update request, so the session asks for another
+		update  sessionUpdate
+		pixels  map[image.Point]color.RGBA // Expected in the framebuffer afterwards
+	}{
+		{
+			"raw",
+			func(conn *fakeVNCConn) { conn.SendFill(t, image.Rect(2, 1, 6, 3), red) },
+			true,
+			sessionUpdate{Dirty: image.Rect(2, 1, 6, 3)},
+			map[image.Point]color.RGBA{{2, 1}: red, {5, 2}: red, {1, 1}: {0, 0, 0, 0xff}, {6, 2}: {0, 0, 0, 0xff}},
+		},
+		{
+			"copy rect",
+			func(conn *fakeVNCConn) {
+				msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(10, 4, 14, 6), rfbEncCopyRect)
+				conn.Write(append(msg, 0, 2, 0, 1))
+			},
+			true,
+			sessionUpdate{Dirty: image.Rect(10, 4, 14, 6)},
+			map[image.Point]color.RGBA{{10, 4}: red, {13, 5}: red, {9, 4}: {0, 0, 0, 0xff}},
+		},
+		{
+			"last rect",
+			func(conn *fakeVNCConn) {
+				msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(0xffff), image.Rect(0, 0, 1, 1), rfbEncRaw)
+				msg = append(msg, 0xff, 0, 0, 0)
+				conn.Write(appendRFBRectHeader(msg, image.Rectangle{}, rfbEncLastRect))
+			},
+			true,
+			sessionUpdate{Dirty: image.Rect(0, 0, 1, 1)},
+			map[image.Point]color.RGBA{{0, 0}: blue},
+		},
+		{
+			"bell",
+			func(conn *fakeVNCConn) { conn.Write([]byte{rfbMsgBell, rfbMsgBell}) },
+			false,
+			sessionUpdate{Bells: 2},
+			nil,
+		},
+		{
+			"cut text",
+			func(conn *fakeVNCConn) { conn.Write(rfbServerCutTextMsg([]byte("copied"))) },
+			false,
+			sessionUpdate{CutText: []byte("copied")},
+			nil,
+		},
+		{
+			"resize",
+			func(conn *fakeVNCConn) {
+				conn.Write(appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 32, 8), rfbEncDesktopSize))
+			},
+			true,
+			sessionUpdate{Dirty: image.Rect(0, 0, 32, 8), Resized: true},
+			map[image.Point]color.RGBA{{2, 1}: {0, 0, 0, 0xff}, {31, 7}: {0, 0, 0, 0xff}},
+		},
+	}
+
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	session, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Release(session)
+	conn := upstream.Accept(t)
+	if !conn.shared {
+		t.Error("session didn't ask to share the desktop")
+	}
+	if format := conn.Expect(t, rfbMsgSetPixelFormat); parseRFBPixelFormat(format[4:]) != rfbSessionPixelFormat {
+		t.Errorf("session asked for pixel format %+v", parseRFBPixelFormat(format[4:]))
+	}
+	if session.Name() != "desktop" || session.Bounds() != image.Rect(0, 0, 16, 8) {
+		t.Errorf("got desktop %q of %v", session.Name(), session.Bounds())
+	}
+
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	for _, test := range tests {
+		test.message(conn)
+		update := waitUpdateOf(sub, test.update)
+		if !sameUpdate(update, test.update) {
+			t.Errorf("%v: got update %+v, expected %+v", test.name, update, test.update)
+		}
+		snapshot := session.Snapshot()
+		for pt, expected := range test.pixels {
+			if c := snapshot.RGBAAt(pt.X, pt.Y); c != expected {
+				t.Errorf("%v: pixel at %v is %v, expected %v", test.name, pt, c, expected)
+			}
+		}
+		if test.request {
+			conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+		}
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"unknown message", []byte{99}},
+		{"unknown encoding", appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 1, 1), 99)},
+		{"out of bounds", appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(12, 0, 20, 1), rfbEncRaw)},
+		{"copy from out of bounds", append(appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 4, 4), rfbEncCopyRect), 0, 14, 0, 0)},
+		{"cut text too long", []byte{rfbMsgServerCutText, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff}},
+	}
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	for _, test := range tests {
+		session, err := broker.Acquire(upstream.Server())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := upstream.Accept(t)
+		conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+		conn.Write(test.message)
+		select {
+		case <-session.Done():
+			if session.Err() == nil || session.Err() == ErrSessionClosed {
+				t.Errorf("%v: session ended with %v", test.name, session.Err())
+			}
+		case <-time.After(testTimeout):
+			t.Errorf("%v: session still running", test.name)
+		}
+		conn.ExpectClosed(t)
+		broker.Release(session)
+	}
+}
+
+func TestSessionBroker(t *testing.T) {
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	other := newFakeVNCServer(t, 8, 8, "other")
+	defer other.Close()
+	broker := NewSessionBroker(nil)
+
+	// Users of a server share its session
+	first, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := upstream.Accept(t)
+	second, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("second user got a new session")
+	}
+	third, err := broker.Acquire(other.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || third.Name() != "other" {
+		t.Errorf("other server shares session %q", third.Name())
+	}
+	otherConn := other.Accept(t)
+
+	// The session stays until its last user is done
+	broker.Release(first)
+	select {
+	case <-first.Done():
+		t.Fatal("session closed with a user left")
+	default:
+	}
+	broker.Release(second)
+	select {
+	case <-first.Done():
+	case <-time.After(testTimeout):
+		t.Fatal("session still open without users")
+	}
+	if first.Err() != ErrSessionClosed {
+		t.Errorf("session ended with %v", first.Err())
+	}
+	conn.ExpectClosed(t)
+
+	// Sessions which end are replaced
+	again, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Release(again)
+	if again == first {
+		t.Error("closed session handed out")
+	}
+	upstream.Accept(t)
+
+	otherConn.Close()
+	<-third.Done()
+	replaced, err := broker.Acquire(other.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced == third {
+		t.Error("failed session handed out")
+	}
+	other.Accept(t)
+	broker.Release(replaced)
+	broker.Release(third)
+
+	// Connection failures are returned to every waiting user
+	other.Close()
+	if _, err := broker.Acquire(other.Server()); err == nil {
+		t.Error("connected to a closed server")
+	}
+	broker.mtx.Lock()
+	sessions := len(broker.sessions)
+	broker.mtx.Unlock()
+	if sessions != 1 {
+		t.Errorf("broker has %v sessions, expected 1", sessions)
+	}
+}
+
+// Act as a viewer of a session over a pipe, returning the viewer's end and a
+// channel with the result of serving it
+func startViewer(t *testing.T, session *vncSession, readOnly bool) (net.Conn, <-chan error, []byte) {
+	t.Helper()
+	client, server := net.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		result <- serveSessionViewer(session, server, readOnly, nil)
+		server.Close()
+	}()
+	if _, err := client.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	serverInit := make([]byte, 24)
+	if _, err := io.ReadFull(client, serverInit); err != nil {
+		t.Fatal(err)
+	}
+	name := make([]byte, binary.BigEndian.Uint32(serverInit[20:24]))
+	if _, err := io.ReadFull(client, name); err != nil {
+		t.Fatal(err)
+	}
+	return client, result, serverInit
+}
+
+// Read a FramebufferUpdate with a single raw rectangle in the viewer pixel format
+func readViewerUpdate(t *testing.T, r io.Reader) (image.Rectangle, []byte) {
+	t.Helper()
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != rfbMsgFramebufferUpdate || binary.BigEndian.Uint16(header[2:4]) != 1 {
+		t.Fatalf("unexpected update header %v", header)
+	}
+	x := int(binary.BigEndian.Uint16(header[4:6]))
+	y := int(binary.BigEndian.Uint16(header[6:8]))
+	rect := image.Rect(x, y, x+int(binary.BigEndian.Uint16(header[8:10])), y+int(binary.BigEndian.Uint16(header[10:12])))
+	if encoding := int32(binary.BigEndian.Uint32(header[12:16])); encoding != rfbEncRaw {
+		return rect, nil
+	}
+	pixels := make([]byte, rect.Dx()*rect.Dy()*4)
+	if _, err := io.ReadFull(r, pixels); err != nil {
+		t.Fatal(err)
+	}
+	return rect, pixels
+}
+
+func TestSessionViewer(t *testing.T) {
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	session, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	conn.SendFill(t, image.Rect(0, 0, 16, 8), color.RGBA{0x10, 0x20, 0x30, 0xff})
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+
+	client, result, serverInit := startViewer(t, session, false)
+	defer client.Close()
+	if width, height := binary.BigEndian.Uint16(serverInit[0:2]), binary.BigEndian.Uint16(serverInit[2:4]); width != 16 || height != 8 {
+		t.Errorf("viewer told of a %vx%v desktop", width, height)
+	}
+	if format := parseRFBPixelFormat(serverInit[4:]); format != rfbViewerPixelFormat {
+		t.Errorf("viewer told of pixel format %+v", format)
+	}
+
+	// A joining viewer gets the whole screen, in its pixel format
+	client.Write(rfbSetEncodingsMsg([]int32{rfbEncRaw, rfbEncDesktopSize}))
+	client.Write(rfbFramebufferUpdateRequestMsg(true, image.Rect(0, 0, 16, 8)))
+	rect, pixels := readViewerUpdate(t, client)
+	if rect != image.Rect(0, 0, 16, 8) || !bytes.Equal(pixels[:4], []byte{0x30, 0x20, 0x10, 0}) {
+		t.Errorf("got first update of %v starting %v", rect, pixels[:4])
+	}
+
+	// Then only what changed
+	client.Write(rfbFramebufferUpdateRequestMsg(true, image.Rect(0, 0, 16, 8)))
+	conn.SendFill(t, image.Rect(4, 4, 6, 5), color.RGBA{0xff, 0, 0, 0xff})
+	rect, pixels = readViewerUpdate(t, client)
+	if rect != image.Rect(4, 4, 6, 5) || !bytes.Equal(pixels, []byte{0, 0, 0xff, 0, 0, 0, 0xff, 0}) {
+		t.Errorf("got update of %v with %v", rect, pixels)
+	}
+
+	// Input goes to the server
+	key := []byte{rfbMsgKeyEvent, 1, 0, 0, 0, 0, 0, 'a'}
+	client.Write(key)
+	if message := conn.Expect(t, rfbMsgKeyEvent); !bytes.Equal(message, key) {
+		t.Errorf("server got key %v, expected %v", message, key)
+	}
+
+	// Resizes are passed on as DesktopSize
+	client.Write(rfbFramebufferUpdateRequestMsg(true, image.Rect(0, 0, 16, 8)))
+	conn.Write(appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 20, 10), rfbEncDesktopSize))
+	if rect, _ := readViewerUpdate(t, client); rect != image.Rect(0, 0, 20, 10) {
+		t.Errorf("got resize to %v", rect)
+	}
+
+	// The viewer ends with the session
+	broker.Release(session)
+	select {
+	case err := <-result:
+		if err != ErrSessionClosed {
+			t.Errorf("viewer ended with %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Error("viewer still running after the session closed")
+	}
+}
+
+func TestSessionViewerDisconnects(t *testing.T) {
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	session, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Release(session)
+	upstream.Accept(t)
+
+	tests := []struct {
+		name   string
+		script func(client net.Conn)
+		err    bool
+	}{
+		{"hung up", func(client net.Conn) { client.Close() }, true},
+		{"bad pixel format", func(client net.Conn) {
+			client.Write(rfbSetPixelFormatMsg(rfbPixelFormat{BPP: 8}))
+		}, true},
+		{"resize without desktop size", func(client net.Conn) {
+			client.Write(rfbSetEncodingsMsg([]int32{rfbEncRaw}))
+			client.Write(rfbFramebufferUpdateRequestMsg(false, image.Rect(0, 0, 16, 8)))
+			readViewerUpdate(t, client)
+			client.Write(rfbFramebufferUpdateRequestMsg(true, image.Rect(0, 0, 16, 8)))
+			session.fbmtx.Lock()
+			session.fb = newFramebuffer(8, 8)
+			session.fbmtx.Unlock()
+			session.publish(sessionUpdate{Dirty: image.Rect(0, 0, 8, 8), Resized: true})
+		}, true},
+	}
+	for _, test := range tests {
+		client, result, _ := startViewer(t, session, false)
+		test.script(client)
+		select {
+		case err := <-result:
+			if (err != nil) != test.err {
+				t.Errorf("%v: viewer ended with %v", test.name, err)
+			}
+		case <-time.After(testTimeout):
+			t.Errorf("%v: viewer still running", test.name)
+		}
+		client.Close()
+
+		session.smtx.Lock()
+		subscribers := len(session.subscribers)
+		session.smtx.Unlock()
+		if subscribers != 0 {
+			t.Errorf("%v: %v subscribers left", test.name, subscribers)
+		}
+	}
+	select {
+	case <-session.Done():
+		t.Error("session ended with its viewers")
+	default:
+	}
+}
+
+func TestSessionKeepalive(t *testing.T) {
+	defer func(interval time.Duration) { *sessionKeepalive = interval }(*sessionKeepalive)
+	*sessionKeepalive = 50 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		answer   bool // Answer keepalive requests with the pixel asked for
+		survives bool
+	}{
+		{"answered", true, true},
+		{"silent", false, false},
+	}
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	for _, test := range tests {
+		session, err := broker.Acquire(upstream.Server())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub := session.Subscribe()
+		conn := upstream.Accept(t)
+		conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+
+		deadline := time.After(10 * *sessionKeepalive)
+		keepalives := 0
+	wait:
+		for {
+			select {
+			case message, ok := <-conn.messages:
+				if !ok {
+					break wait
+				}
+				// Non-incremental requests for the top left pixel
+				if !bytes.Equal(message, rfbFramebufferUpdateRequestMsg(false, image.Rect(0, 0, 1, 1))) {
+					continue
+				}
+				keepalives++
+				if test.answer {
+					conn.SendFill(t, image.Rect(0, 0, 1, 1), color.RGBA{0, 0, 0, 0xff})
+				}
+			case <-deadline:
+				break wait
+			}
+		}
+
+		select {
+		case <-session.Done():
+			if test.survives {
+				t.Errorf("%v: session ended with %v", test.name, session.Err())
+			}
+		default:
+			if !test.survives {
+				t.Errorf("%v: session still running", test.name)
+			}
+		}
+		if keepalives == 0 {
+			t.Errorf("%v: no keepalive requests", test.name)
+		}
+		// Answers which change nothing aren't updates
+		select {
+		case <-sub.Notify():
+			if update := sub.Take(); !update.Dirty.Empty() {
+				t.Errorf("%v: got update %+v", test.name, update)
+			}
+		default:
+		}
+		session.Unsubscribe(sub)
+		broker.Release(session)
+	}
+}
+
+func TestSessionDecodesUnlocked(t *testing.T) {
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	broker := NewSessionBroker(nil)
+	session, err := broker.Acquire(upstream.Server())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Release(session)
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+
+	// Send half of a rectangle, leaving the session waiting for the rest
+	msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), image.Rect(0, 0, 16, 8), rfbEncRaw)
+	half := bytes.Repeat([]byte{0xff, 0, 0, 0}, 16*4)
+	conn.Write(append(msg, half...))
+	time.Sleep(10 * time.Millisecond)
+
+	snapshot := make(chan *image.RGBA, 1)
+	go func() { snapshot <- session.Snapshot() }()
+	select {
+	case img := <-snapshot:
+		if c := img.RGBAAt(0, 0); c != (color.RGBA{0, 0, 0, 0xff}) {
+			t.Errorf("snapshot shows a rectangle still being read: %v", c)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("framebuffer locked while reading from the server")
+	}
+
+	conn.Write(half)
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+	if update := waitUpdateOf(sub, sessionUpdate{Dirty: image.Rect(0, 0, 16, 8)}); update.Dirty != image.Rect(0, 0, 16, 8) {
+		t.Errorf("got update %+v", update)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/common/log"
+	"gopkg.in/fsnotify.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Metadata for a discovered socket, read from a file next to it with the same
+// name and a .json extension, e.g. kiosk.json for kiosk.sock. A .name file holds
+// just the display name.
+type socketMetadata struct {
+	Name   string            `json:"name"`
+	Tags   []string          `json:"tags"`
+	Labels map[string]string `json:"labels"`
+	Groups []string          `json:"groups"`
+}
+
+const (
+	sidecarMetadataExt = ".json"
+	sidecarNameExt     = ".name"
+)
+
+// Path of a socket's sidecar files without their extension
+func sidecarBase(socketPath string) string {
+	return strings.TrimSuffix(socketPath, filepath.Ext(socketPath))
+}
+
+// Check whether a path is a sidecar file rather than a socket
+func isSidecarFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == sidecarMetadataExt || ext == sidecarNameExt
+}
+
+// Build the server for a discovered socket, including any metadata next to it
+func watchedServer(socketPath string) vncServer {
+	server := vncServer{
+		NetType: "unix",
+		Address: socketPath,
+		Record:  *recordAll,
+		Source:  Source_Watch,
+	}
+
+	base := sidecarBase(socketPath)
+	if b, err := ioutil.ReadFile(base + sidecarMetadataExt); err == nil {
+		metadata := socketMetadata{}
+		if err := json.Unmarshal(b, &metadata); err != nil {
+			log.With("socket", socketPath).Warnln("Ignoring invalid socket metadata:", err)
+		} else {
+			server.Name = metadata.Name
+			server.Tags = metadata.Tags
+			server.Labels = metadata.Labels
+			server.Groups = metadata.Groups
+		}
+	} else if !os.IsNotExist(err) {
+		log.With("socket", socketPath).Warnln("Can't read socket metadata:", err)
+	}
+	if b, err := ioutil.ReadFile(base + sidecarNameExt); err == nil {
+		server.Name = strings.TrimSpace(string(b))
+	} else if !os.IsNotExist(err) {
+		log.With("socket", socketPath).Warnln("Can't read socket name:", err)
+	}
+
+	return server
+}
+
+// Reload the metadata of discovered sockets when a sidecar file changes
+func handleSidecarEvent(manager *serverManager, e fsnotify.Event) {
+	base := sidecarBase(e.Name)
+	for _, server := range manager.List() {
+		if server.Source == Source_Watch && sidecarBase(server.Address) == base {
+			log.With("socket", server.Address).Debugln("Reloading socket metadata")
+			manager.Add(watchedServer(server.Address))
+		}
+	}
+}
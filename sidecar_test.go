@@ -0,0 +1,100 @@
+package main
+
+import (
+	"gopkg.in/fsnotify.v1"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsSidecarFile(t *testing.T) {
+	tests := []struct {
+		path    string
+		sidecar bool
+	}{
+		{"/run/vnc/kiosk.sock", false},
+		{"/run/vnc/kiosk", false},
+		{"/run/vnc/kiosk.json", true},
+		{"/run/vnc/kiosk.name", true},
+	}
+	for _, test := range tests {
+		if sidecar := isSidecarFile(test.path); sidecar != test.sidecar {
+			t.Errorf("%v: got %v, expected %v", test.path, sidecar, test.sidecar)
+		}
+	}
+}
+
+func TestWatchedServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string // Sidecar files by extension
+		expected vncServer         // Without the network type, address and source
+	}{
+		{"none", nil, vncServer{}},
+		{"metadata", map[string]string{
+			".json": `{"name": "Kiosk", "tags": ["lobby"], "labels": {"site": "hq"}, "groups": ["kiosks"]}`,
+		}, vncServer{Name: "Kiosk", Tags: []string{"lobby"}, Labels: map[string]string{"site": "hq"}, Groups: []string{"kiosks"}}},
+		{"name", map[string]string{".name": "Front desk\n"}, vncServer{Name: "Front desk"}},
+		{"name overrides metadata", map[string]string{
+			".json": `{"name": "Kiosk", "groups": ["kiosks"]}`,
+			".name": "Front desk",
+		}, vncServer{Name: "Front desk", Groups: []string{"kiosks"}}},
+		{"invalid metadata", map[string]string{".json": `{"name": `}, vncServer{}},
+	}
+	for _, test := range tests {
+		socket, cleanup := writeTempFile(t, "kiosk.sock", "")
+		for ext, contents := range test.files {
+			if err := ioutil.WriteFile(sidecarBase(socket)+ext, []byte(contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		server := watchedServer(socket)
+		cleanup()
+
+		test.expected.NetType = "unix"
+		test.expected.Address = socket
+		test.expected.Source = Source_Watch
+		if !reflect.DeepEqual(server, test.expected) {
+			t.Errorf("%v: got %+v, expected %+v", test.name, server, test.expected)
+		}
+	}
+}
+
+func TestSocketMetadataReload(t *testing.T) {
+	socket, cleanup := writeTempFile(t, "kiosk.sock", "")
+	defer cleanup()
+	glob := filepath.Join(filepath.Dir(socket), "*")
+	other := filepath.Join(filepath.Dir(socket), "other.sock")
+	if err := ioutil.WriteFile(other, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewServerManager()
+	pollSocketDirectory(glob, manager)
+	events := manager.Subscribe()
+
+	// Sidecar files aren't servers
+	name := sidecarBase(socket) + sidecarNameExt
+	if err := ioutil.WriteFile(name, []byte("Kiosk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	handleSocketDirectoryEvent(glob, manager, fsnotify.Event{Name: name, Op: fsnotify.Create})
+	if got := listNames(manager); !reflect.DeepEqual(got, []string{socket + "=Kiosk", other + "="}) {
+		t.Errorf("got servers %v", got)
+	}
+	if got := takeEvents(events); !reflect.DeepEqual(got, []string{"updated " + socket}) {
+		t.Errorf("got events %v", got)
+	}
+
+	// Polling picks up changes too, without republishing unchanged servers
+	if err := ioutil.WriteFile(name, []byte("Lobby"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pollSocketDirectory(glob, manager)
+	if got := listNames(manager); !reflect.DeepEqual(got, []string{socket + "=Lobby", other + "="}) {
+		t.Errorf("got servers %v after poll", got)
+	}
+	if got := takeEvents(events); !reflect.DeepEqual(got, []string{"updated " + socket}) {
+		t.Errorf("got events %v after poll", got)
+	}
+}
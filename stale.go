@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/prometheus/common/log"
+	"image"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Window over which screen change rates are measured
+const staleRateWindow = time.Minute
+
+// Timeout for delivering a webhook
+const staleWebhookTimeout = 10 * time.Second
+
+// Screen activity of a server
+type serverActivity struct {
+	Server     string    `json:"server"`      // Short name of the server
+	Monitored  bool      `json:"monitored"`   // Whether the monitor currently has a session to the server
+	Stale      bool      `json:"stale"`       // The screen hasn't changed for the stale period
+	LastChange time.Time `json:"last_change"` // Last update which changed any pixels
+	ChangeRate float64   `json:"change_rate"` // Updates which changed pixels per minute
+
+	changes []time.Time // Times of changing updates within the rate window
+	screen  *image.RGBA // Copy of the screen to compare updates against
+}
+
+func (this *serverActivity) changed(now time.Time) {
+	this.LastChange = now
+	this.changes = append(this.changes, now)
+}
+
+// Drop changes which have left the rate window and recalculate the rate
+func (this *serverActivity) updateRate(now time.Time) {
+	i := 0
+	for i < len(this.changes) && now.Sub(this.changes[i]) > staleRateWindow {
+		i++
+	}
+	this.changes = this.changes[i:]
+	this.ChangeRate = float64(len(this.changes)) * float64(time.Minute) / float64(staleRateWindow)
+}
+
+// Body of webhook requests
+type staleWebhookEvent struct {
+	Event      ManagerActionType `json:"event"`  // stale or recovered
+	Server     string            `json:"server"` // Short name of the server
+	Name       string            `json:"name,omitempty"`
+	LastChange time.Time         `json:"last_change"`
+	Time       time.Time         `json:"time"`
+}
+
+// Watches the screens of every known server, marking them stale when no update
+// has changed any pixels for a while.
+type staleMonitor struct {
+	manager    *serverManager
+	broker     *sessionBroker
+	staleAfter time.Duration
+	webhook    string // URL to POST stale and recovered events to. Empty disables.
+
+	activity map[string]*serverActivity
+	workers  map[string]chan struct{} // Closed to stop the worker for a server
+	mtx      sync.Mutex
+}
+
+func NewStaleMonitor(manager *serverManager, broker *sessionBroker, staleAfter time.Duration, webhook string) *staleMonitor {
+	return &staleMonitor{
+		manager:    manager,
+		broker:     broker,
+		staleAfter: staleAfter,
+		webhook:    webhook,
+		activity:   make(map[string]*serverActivity),
+		workers:    make(map[string]chan struct{}),
+	}
+}
+
+// Activity of every known server
+func (this *staleMonitor) List() map[string]serverActivity {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	r := make(map[string]serverActivity)
+	for k, activity := range this.activity {
+		a := *activity
+		a.changes = nil
+		a.screen = nil
+		r[k] = a
+	}
+	return r
+}
+
+// Keep a worker running for each known server
+func (this *staleMonitor) Run() {
+	interval := this.checkInterval()
+	for {
+		servers := this.manager.List()
+
+		this.mtx.Lock()
+		for k, server := range servers {
+			if _, ok := this.workers[k]; !ok {
+				stop := make(chan struct{})
+				this.workers[k] = stop
+				if _, ok := this.activity[k]; !ok {
+					this.activity[k] = &serverActivity{Server: k, LastChange: time.Now()}
+				}
+				go this.worker(server, stop)
+			}
+		}
+		for k, stop := range this.workers {
+			if _, ok := servers[k]; !ok {
+				close(stop)
+				delete(this.workers, k)
+				delete(this.activity, k)
+			}
+		}
+		this.mtx.Unlock()
+
+		time.Sleep(interval)
+	}
+}
+
+// How often to check for stale screens
+func (this *staleMonitor) checkInterval() time.Duration {
+	interval := this.staleAfter / 10
+	if interval > time.Second*5 {
+		interval = time.Second * 5
+	}
+	if interval < time.Millisecond*100 {
+		interval = time.Millisecond * 100
+	}
+	return interval
+}
+
+// Watch a server until stopped, reconnecting after errors
+func (this *staleMonitor) worker(server vncServer, stop <-chan struct{}) {
+	for {
+		err := this.follow(server, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		log.With("server", server.Redacted()).Debugln("Stale monitor session failed:", err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(this.checkInterval()):
+		}
+
+		// Pick up changed credentials before reconnecting
+		if current, ok := this.manager.Get(server.Short()); ok {
+			server = current
+		}
+	}
+}
+
+// Track changes to a server's screen. Returns nil when stopped.
+func (this *staleMonitor) follow(server vncServer, stop <-chan struct{}) error {
+	session, err := this.broker.Acquire(server)
+	if err != nil {
+		return err
+	}
+	defer this.broker.Release(session)
+
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+
+	select {
+	case <-stop:
+		return nil
+	case <-session.Done():
+		return session.Err()
+	case <-session.Updated():
+	}
+
+	this.setMonitored(server, true)
+	defer this.setMonitored(server, false)
+
+	// A reconnection only counts as a change if the screen is different
+	this.compare(server, session, sessionUpdate{Dirty: session.Bounds()})
+
+	ticker := time.NewTicker(this.checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-session.Done():
+			return session.Err()
+		case <-sub.Notify():
+			this.compare(server, session, sub.Take())
+		case <-ticker.C:
+			this.check(server)
+		}
+	}
+}
+
+func (this *staleMonitor) setMonitored(server vncServer, monitored bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if activity, ok := this.activity[server.Short()]; ok {
+		activity.Monitored = monitored
+	}
+}
+
+// Record an update if it changed any pixels
+func (this *staleMonitor) compare(server vncServer, session *vncSession, update sessionUpdate) {
+	if update.Dirty.Empty() && !update.Resized {
+		return
+	}
+
+	this.mtx.Lock()
+	activity, ok := this.activity[server.Short()]
+	if !ok {
+		this.mtx.Unlock()
+		return
+	}
+
+	changed := false
+	session.WithFramebuffer(func(fb *image.RGBA) {
+		if activity.screen == nil || activity.screen.Bounds() != fb.Bounds() {
+			changed = activity.screen != nil
+			activity.screen = image.NewRGBA(fb.Bounds())
+			copy(activity.screen.Pix, fb.Pix)
+			return
+		}
+		region := update.Dirty.Intersect(fb.Bounds())
+		for y := region.Min.Y; y < region.Max.Y; y++ {
+			start := fb.PixOffset(region.Min.X, y)
+			end := fb.PixOffset(region.Max.X, y)
+			if !bytes.Equal(fb.Pix[start:end], activity.screen.Pix[start:end]) {
+				copy(activity.screen.Pix[start:end], fb.Pix[start:end])
+				changed = true
+			}
+		}
+	})
+
+	recovered := false
+	if changed {
+		activity.changed(time.Now())
+		recovered = activity.Stale
+		activity.Stale = false
+	}
+	lastChange := activity.LastChange
+	this.mtx.Unlock()
+
+	if recovered {
+		log.With("server", server.Redacted()).Infoln("Server screen changing again")
+		this.notify(Manager_RecoveredServer, server, lastChange)
+	}
+}
+
+// Mark a server stale if its screen hasn't changed for long enough
+func (this *staleMonitor) check(server vncServer) {
+	this.mtx.Lock()
+	activity, ok := this.activity[server.Short()]
+	if !ok {
+		this.mtx.Unlock()
+		return
+	}
+	now := time.Now()
+	activity.updateRate(now)
+	stale := !activity.Stale && now.Sub(activity.LastChange) > this.staleAfter
+	if stale {
+		activity.Stale = true
+	}
+	lastChange := activity.LastChange
+	this.mtx.Unlock()
+
+	if stale {
+		log.With("server", server.Redacted()).With("last_change", lastChange).Warnln("Server screen is stale")
+		this.notify(Manager_StaleServer, server, lastChange)
+	}
+}
+
+// Publish a stale or recovered event to subscribers and the webhook
+func (this *staleMonitor) notify(action ManagerActionType, server vncServer, lastChange time.Time) {
+	this.manager.Notify(action, server, nil)
+
+	if this.webhook == "" {
+		return
+	}
+	go func() {
+		body, err := json.Marshal(staleWebhookEvent{
+			Event:      action,
+			Server:     server.Short(),
+			Name:       server.Name,
+			LastChange: lastChange,
+			Time:       time.Now(),
+		})
+		if err != nil {
+			log.Errorln("Error encoding webhook:", err)
+			return
+		}
+		client := http.Client{Timeout: staleWebhookTimeout}
+		resp, err := client.Post(this.webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.With("server", server.Redacted()).Errorln("Error calling stale webhook:", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			log.With("server", server.Redacted()).Errorln("Stale webhook returned", resp.Status)
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerActivityRate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		changes []time.Duration // Ages of changes, oldest first
+		rate    float64
+	}{
+		{"none", nil, 0},
+		{"recent", []time.Duration{30 * time.Second, 10 * time.Second, 0}, 3},
+		{"expired", []time.Duration{2 * time.Minute, 61 * time.Second}, 0},
+		{"mixed", []time.Duration{5 * time.Minute, 59 * time.Second, time.Second}, 2},
+	}
+	for _, test := range tests {
+		activity := &serverActivity{}
+		for _, age := range test.changes {
+			activity.changed(now.Add(-age))
+		}
+		activity.updateRate(now)
+		if activity.ChangeRate != test.rate || len(activity.changes) != int(test.rate) {
+			t.Errorf("%v: got rate %v from %v changes, expected %v", test.name, activity.ChangeRate, len(activity.changes), test.rate)
+		}
+	}
+}
+
+func TestStaleMonitorCheckInterval(t *testing.T) {
+	tests := []struct {
+		staleAfter time.Duration
+		interval   time.Duration
+	}{
+		{time.Second, 100 * time.Millisecond},
+		{10 * time.Second, time.Second},
+		{time.Hour, 5 * time.Second},
+		{time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		monitor := NewStaleMonitor(nil, nil, test.staleAfter, "")
+		if interval := monitor.checkInterval(); interval != test.interval {
+			t.Errorf("%v: got %v, expected %v", test.staleAfter, interval, test.interval)
+		}
+	}
+}
+
+func TestStaleMonitor(t *testing.T) {
+	upstream := newFakeVNCServer(t, 8, 4, "desktop")
+	defer upstream.Close()
+	server := upstream.Server()
+	manager := NewServerManager()
+	manager.Add(server)
+	events := manager.Subscribe()
+
+	hooks := make(chan staleWebhookEvent, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event staleWebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		hooks <- event
+	}))
+	defer webhook.Close()
+
+	monitor := NewStaleMonitor(manager, NewSessionBroker(nil), 300*time.Millisecond, webhook.URL)
+	monitor.activity[server.Short()] = &serverActivity{Server: server.Short(), LastChange: time.Now()}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		monitor.worker(server, stop)
+		close(stopped)
+	}()
+
+	activity := func() serverActivity { return monitor.List()[server.Short()] }
+	expectEvent := func(action ManagerActionType) {
+		t.Helper()
+		select {
+		case hook := <-hooks:
+			if hook.Event != action || hook.Server != server.Short() {
+				t.Errorf("got webhook %v for %v, expected %v", hook.Event, hook.Server, action)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no %v webhook", action)
+		}
+		if got := takeEvents(events); len(got) != 1 || got[0] != string(action)+" "+server.Address {
+			t.Errorf("got events %v, expected %v", got, action)
+		}
+	}
+
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	conn.SendFill(t, image.Rect(0, 0, 8, 4), color.RGBA{0xff, 0, 0, 0xff})
+	waitFor(t, "monitoring", func() bool { return activity().Monitored })
+	if a := activity(); a.Stale || a.ChangeRate != 0 {
+		t.Errorf("first screen counted as a change: %+v", a)
+	}
+
+	// Nothing changes
+	expectEvent(Manager_StaleServer)
+	if !activity().Stale {
+		t.Error("server not marked stale")
+	}
+
+	// Redrawing the same pixels doesn't count
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	conn.SendFill(t, image.Rect(0, 0, 8, 4), color.RGBA{0xff, 0, 0, 0xff})
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	if !activity().Stale {
+		t.Error("unchanged redraw recovered the server")
+	}
+
+	conn.SendFill(t, image.Rect(2, 1, 3, 2), color.RGBA{0, 0xff, 0, 0xff})
+	expectEvent(Manager_RecoveredServer)
+	monitor.mtx.Lock()
+	if a := monitor.activity[server.Short()]; a.Stale || len(a.changes) != 1 {
+		t.Errorf("got %+v after change", *a)
+	}
+	monitor.mtx.Unlock()
+
+	// A lost session is retried
+	conn.Close()
+	waitFor(t, "session loss", func() bool { return !activity().Monitored })
+	conn = upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	conn.SendFill(t, image.Rect(0, 0, 8, 4), color.RGBA{0, 0, 0xff, 0xff})
+	waitFor(t, "monitoring", func() bool { return activity().Monitored })
+
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("worker still running")
+	}
+	conn.ExpectClosed(t)
+}
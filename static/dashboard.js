@@ -1,12 +1,41 @@
 
-var evtSource = new EventSource("/api/list/subscribe");
+// Only show the servers selected by group and label parameters, e.g.
+// dashboard.html?group=plant-a&label=site=north
+var serverFilter = (function() {
+    var params = new URLSearchParams(window.location.search);
+    var filter = new URLSearchParams();
+    params.getAll("group").forEach(function(v) { filter.append("group", v); });
+    params.getAll("label").forEach(function(v) { filter.append("label", v); });
+    var q = filter.toString();
+    return q ? "?" + q : "";
+})();
+
+var evtSource = new EventSource("/api/list/subscribe" + serverFilter);
+
+// Saved layout being shown, from /dashboard/<name> URLs. Without one servers are
+// shown in the order they arrive.
+var layoutName = (function() {
+    var m = window.location.pathname.match(/^\/dashboard\/([^\/]+)/);
+    return m ? decodeURIComponent(m[1]) : null;
+})();
+var layout = null;
+var layoutCells = []; // [ cell, div ] for each cell of the layout
+
+// A single server filling the page, from ?server=<name>, as shown by kiosks
+var singleServer = new URLSearchParams(window.location.search).get("server");
 var vncSessions = {};
+var vncStatus = {}; // Health probe state of each server
 
 var host, port;
 
+function isUp(server) {
+    return vncStatus[server] === undefined || vncStatus[server] == "up";
+}
+
 function updateState(rfb, state, oldstate, msg) {
     console.log(state);
-    if (state == 'disconnected') {
+    // Servers which are down are reconnected when their status says they are up
+    if (state == 'disconnected' && isUp(rfb._rfb_path.replace(/^vnc\//, ""))) {
         rfb.connect(rfb._rfb_host, rfb._rfb_port, rfb._rfb_password, rfb._rfb_path)
     }
 }
@@ -23,6 +52,7 @@ function newVNCClient(path, div, canvas) {
             'shared':       WebUtil.getConfigVar('shared', true),
             'view_only':    WebUtil.getConfigVar('view_only', true),
             'onUpdateState':  updateState,
+            'onFBResize':   scaleToCell,
             'onXvpInit':    null,
             'onPasswordRequired':  null,
             'onFBUComplete': null});
@@ -36,21 +66,48 @@ function newVNCClient(path, div, canvas) {
     vncSessions[path] = [ div, rfb ];
 }
 
-function newVNCHost(e) {
-    t = vncSessions["vnc/" + e.data]
-    if (t == "undefined") {
-        console.log("Already have a session to" + e.data);
+// Find where a server goes: its layout cell, or the end of the page
+function hostContainer(shortname, server) {
+    if ((layoutName || singleServer) && !layout) {
+        // Picked up once the layout has loaded
+        return null;
+    }
+    if (!layout) {
+        return document.body;
+    }
+    for (var i = 0; i < layoutCells.length; i++) {
+        var name = layoutCells[i][0].server;
+        if (name == shortname || name == server.id || name == server.slug) {
+            return layoutCells[i][1];
+        }
+    }
+    return null;
+}
+
+function newVNCHost(shortname, server) {
+    t = vncSessions["vnc/" + shortname]
+    if (t !== undefined) {
+        console.log("Already have a session to " + shortname);
+        return
+    }
+    container = hostContainer(shortname, server);
+    if (!container) {
+        console.log("Not in the layout: " + shortname);
         return
     }
 
     div = document.createElement("div");
     div.className = "vnc-container";
-    div.id = e.data;
+    div.id = shortname;
 
     controlDiv = document.createElement("div");
     controlDiv.className = "vnc-controls";
+        label = document.createElement("span");
+        label.className = "vnc-name";
+        label.textContent = server.name || "";
+        controlDiv.appendChild(label);
         link = document.createElement("a");
-        link.setAttribute("href", "/static/vnc_auto.html?path=vnc/" + e.data);
+        link.setAttribute("href", "/static/vnc_auto.html?path=vnc/" + shortname);
         link.innerHTML = "Fullscreen";
     controlDiv.appendChild(link);
 
@@ -60,26 +117,191 @@ function newVNCHost(e) {
     div.appendChild(controlDiv);
     div.appendChild(canvas);
 
-    document.body.appendChild(div);
+    container.appendChild(div);
+
+    if (server.status) {
+        setStatus(shortname, server.status);
+    }
 
     // Instantiate a new VNC client
-    newVNCClient("vnc/" + e.data, div, canvas);
+    newVNCClient("vnc/" + shortname, div, canvas);
+
+}
 
+function updateVNCHost(shortname, server) {
+    div = document.getElementById(shortname);
+    if (!div) {
+        newVNCHost(shortname, server);
+        return
+    }
+    div.querySelector(".vnc-name").textContent = server.name || "";
 }
 
-function removedVNCHost(e) {
-    console.log("Removing VNC client: " + e.data);
-    t = vncSessions["vnc/" + e.data]
-    if (t == "undefined") {
-        console.log("Missing session, doing nothing for: " + e.data);
+function removeVNCHost(shortname) {
+    console.log("Removing VNC client: " + shortname);
+    t = vncSessions["vnc/" + shortname]
+    if (t === undefined) {
+        console.log("Missing session, doing nothing for: " + shortname);
         return
     }
     div = t[0];
     rfb = t[1];
 
     rfb.disconnect();
-    document.body.removeChild(div);
-    delete vncSessions["vnc/" + e.data];
+    div.parentNode.removeChild(div);
+    delete vncSessions["vnc/" + shortname];
+}
+
+// Scale a screen to fit its layout cell, as the layout asks
+function scaleToCell(rfb) {
+    if (!layout || layout.scale == "none") {
+        return;
+    }
+    var t = vncSessions[rfb._rfb_path];
+    if (t === undefined || !rfb.get_display().get_width()) {
+        return;
+    }
+    var cell = t[0].parentNode;
+    var controls = t[0].querySelector(".vnc-controls");
+    var scale = rfb.get_display().autoscale(cell.clientWidth,
+        cell.clientHeight - controls.offsetHeight, layout.scale == "shrink");
+    rfb.get_mouse().set_scale(scale);
+}
+
+// Build the grid of a layout
+function showLayout(l) {
+    layout = l;
+    document.title = layout.name + " - VNC Dashboard";
+
+    var grid = document.createElement("div");
+    grid.className = "layout-grid";
+    grid.style.gridTemplateColumns = "repeat(" + layout.columns + ", 1fr)";
+    grid.style.gridTemplateRows = "repeat(" + layout.rows + ", 1fr)";
+    layout.cells.forEach(function(cell) {
+        var div = document.createElement("div");
+        div.className = "layout-cell";
+        div.style.gridColumn = (cell.column + 1) + " / span " + cell.width;
+        div.style.gridRow = (cell.row + 1) + " / span " + cell.height;
+        grid.appendChild(div);
+        layoutCells.push([ cell, div ]);
+    });
+    document.body.appendChild(grid);
+
+    window.addEventListener("resize", function() {
+        for (var path in vncSessions) {
+            scaleToCell(vncSessions[path][1]);
+        }
+    });
+}
+
+function loadLayout(done) {
+    var req = new XMLHttpRequest();
+    req.addEventListener("load", function() {
+        if (req.status != 200) {
+            console.log("Failed loading layout: " + req.responseText);
+            return
+        }
+        showLayout(JSON.parse(req.responseText));
+        done();
+    });
+    req.open("GET", "/api/layouts/" + encodeURIComponent(layoutName), true);
+    req.send();
+}
+
+// Show who is logged in, when authentication is enabled
+function loadIdentity() {
+    var req = new XMLHttpRequest();
+    req.addEventListener("load", function() {
+        if (req.status != 200) {
+            return
+        }
+        var id = JSON.parse(req.responseText);
+        var span = document.getElementById("identity");
+        span.appendChild(document.createTextNode(id.user + " "));
+        if (id.method == "session" || id.method == "oidc") {
+            var link = document.createElement("a");
+            link.setAttribute("href", "/logout");
+            link.textContent = "Log out";
+            span.appendChild(link);
+        }
+    });
+    req.open("GET", "/api/whoami", true);
+    req.send();
+}
+
+// Link to every saved layout
+function loadLayoutLinks() {
+    var req = new XMLHttpRequest();
+    req.addEventListener("load", function() {
+        var span = document.getElementById("layouts");
+        JSON.parse(req.responseText).forEach(function(l) {
+            var link = document.createElement("a");
+            link.setAttribute("href", "/dashboard/" + encodeURIComponent(l.name));
+            link.textContent = l.name;
+            span.appendChild(link);
+            span.appendChild(document.createTextNode(" "));
+        });
+    });
+    req.open("GET", "/api/layouts", true);
+    req.send();
+}
+
+function setStatus(server, status) {
+    vncStatus[server] = status.state;
+    div = document.getElementById(server);
+    if (div) {
+        div.title = status.state + (status.error ? ": " + status.error : "");
+        if (status.state == "up") {
+            div.classList.remove("vnc-down");
+        } else {
+            div.classList.add("vnc-down");
+        }
+    }
+
+    t = vncSessions["vnc/" + server];
+    if (t !== undefined && status.state == "up" && t[1]._rfb_state == 'disconnected') {
+        rfb = t[1];
+        rfb.connect(rfb._rfb_host, rfb._rfb_port, rfb._rfb_password, rfb._rfb_path)
+    }
+}
+
+// Server events carry the server as it was when the event happened
+function addedEvent(e) {
+    ev = JSON.parse(e.data);
+    newVNCHost(ev.shortname, ev.server);
+}
+
+function updatedEvent(e) {
+    ev = JSON.parse(e.data);
+    updateVNCHost(ev.shortname, ev.server);
+}
+
+function removedEvent(e) {
+    removeVNCHost(JSON.parse(e.data).shortname);
+}
+
+function staleEvent(e) {
+    div = document.getElementById(JSON.parse(e.data).shortname);
+    if (div) {
+        div.classList.add("vnc-stale");
+    }
+}
+
+function recoveredEvent(e) {
+    div = document.getElementById(JSON.parse(e.data).shortname);
+    if (div) {
+        div.classList.remove("vnc-stale");
+    }
+}
+
+function statusEvent(e) {
+    ev = JSON.parse(e.data);
+    setStatus(ev.shortname, ev.server.status);
+}
+
+// Events were missed while disconnected, so start again from the full list
+function resetEvent(e) {
+    loadRunningVncs();
 }
 
 function runApp() {
@@ -89,22 +311,29 @@ function runApp() {
 }
 
 function loadRunningVncs() {
-    // Pickup any servers we might've missed
+    // Pickup any servers we might've missed, and drop any which went away
     var req = new XMLHttpRequest();
     req.addEventListener("load", function() {
         try {
             vncList = JSON.parse(req.responseText)
-            for (var property in vncList) {
-                if (vncList.hasOwnProperty(property)) {
-                    newVNCHost({ 'data' : property });
-                }
-            }
         } catch (exc) {
             console.log("Failed parsing response text. Will retry in 1s.")
             setTimeout(loadRunningVncs, 1000)
+            return
+        }
+        for (var path in vncSessions) {
+            shortname = path.replace(/^vnc\//, "");
+            if (!vncList.hasOwnProperty(shortname)) {
+                removeVNCHost(shortname);
+            }
+        }
+        for (var property in vncList) {
+            if (vncList.hasOwnProperty(property)) {
+                newVNCHost(property, vncList[property]);
+            }
         }
     });
-    req.open("GET", "/api/list", true);
+    req.open("GET", "/api/list" + serverFilter, true);
     req.send();
 }
 
@@ -126,10 +355,31 @@ window.onscriptsload = function () {
         }
     }
 
-    evtSource.addEventListener("added", newVNCHost, false);
-    evtSource.addEventListener("removed", removedVNCHost, false);
+    evtSource.addEventListener("added", addedEvent, false);
+    evtSource.addEventListener("updated", updatedEvent, false);
+    evtSource.addEventListener("removed", removedEvent, false);
+    evtSource.addEventListener("stale", staleEvent, false);
+    evtSource.addEventListener("recovered", recoveredEvent, false);
+    evtSource.addEventListener("status", statusEvent, false);
+    evtSource.addEventListener("reset", resetEvent, false);
 
-    loadRunningVncs();
+    // Kiosks show the page without its controls
+    if (WebUtil.getConfigVar('kiosk', false)) {
+        document.body.classList.add("kiosk");
+    } else {
+        loadLayoutLinks();
+        loadIdentity();
+    }
+
+    if (layoutName) {
+        loadLayout(loadRunningVncs);
+    } else if (singleServer) {
+        showLayout({"name": singleServer, "columns": 1, "rows": 1, "scale": "fit",
+            "cells": [{"server": singleServer, "column": 0, "row": 0, "width": 1, "height": 1}]});
+        loadRunningVncs();
+    } else {
+        loadRunningVncs();
+    }
 }
 
 document.onreadystatechange = function () {
@@ -0,0 +1,40 @@
+// Kiosks follow show commands from the server, which keeps the rotation, so every
+// screen showing a kiosk stays in step and can be switched remotely.
+var kioskName = decodeURIComponent(window.location.pathname.replace(/^\/kiosk\//, ""));
+var evtSource = new EventSource("/api/kiosks/" + encodeURIComponent(kioskName) + "/subscribe");
+var currentSrc = null;
+
+// Page of the dashboard showing a view
+function viewSrc(view) {
+    if (view.layout) {
+        return "/dashboard/" + encodeURIComponent(view.layout) + "?kiosk=1";
+    }
+    if (view.server) {
+        return "/static/dashboard.html?kiosk=1&server=" + encodeURIComponent(view.server);
+    }
+    return null;
+}
+
+function showEvent(e) {
+    view = JSON.parse(e.data);
+    src = viewSrc(view);
+    if (src == currentSrc) {
+        return;
+    }
+    currentSrc = src;
+
+    frame = document.getElementById("kiosk-page");
+    empty = document.getElementById("kiosk-empty");
+    if (src) {
+        frame.src = src;
+        frame.style.display = "";
+        empty.style.display = "none";
+        document.title = (view.name || view.layout || view.server) + " - VNC Dashboard";
+    } else {
+        frame.removeAttribute("src");
+        frame.style.display = "none";
+        empty.style.display = "";
+    }
+}
+
+evtSource.addEventListener("show", showEvent, false);
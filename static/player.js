@@ -0,0 +1,169 @@
+// Plays back recordings made by the dashboard, which are in the format used by
+// include/playback.js. Unlike playback.js this supports pausing, seeking and
+// changing speed.
+
+var rfb;
+var frameIdx = 0;       // Next frame to deliver
+var position = 0;       // Playback position in ms, as of positionTime
+var positionTime = 0;   // Wall clock time position was last updated
+var speed = 1;
+var paused = false;
+var timer = null;
+
+function frameTime(idx) {
+    var frame = VNC_frame_data[idx];
+    return parseInt(frame.slice(1, frame.indexOf('{', 1)), 10);
+}
+
+function frameCount() {
+    // The recording is terminated by 'EOF'
+    return VNC_frame_data.length - 1;
+}
+
+function duration() {
+    return frameCount() > 0 ? frameTime(frameCount() - 1) : 0;
+}
+
+function currentPosition() {
+    if (paused || timer === null) {
+        return position;
+    }
+    return Math.min(position + ((new Date()).getTime() - positionTime) * speed, duration());
+}
+
+function setPosition(ms) {
+    position = ms;
+    positionTime = (new Date()).getTime();
+}
+
+function formatTime(ms) {
+    var seconds = Math.floor(ms / 1000);
+    var h = Math.floor(seconds / 3600);
+    var m = Math.floor((seconds % 3600) / 60);
+    var s = seconds % 60;
+    return h + ":" + (m < 10 ? "0" : "") + m + ":" + (s < 10 ? "0" : "") + s;
+}
+
+// Create a client which is fed frames directly instead of from a websocket
+function newPlayer() {
+    rfb = new RFB({'target': $D('player-canvas'),
+                   'view_only': true,
+                   'local_cursor': false,
+                   'onUpdateState': function (rfb, state, oldstate, msg) {
+                       console.log(state + (msg ? ": " + msg : ""));
+                   }});
+    rfb._sock._mode = VNC_frame_encoding;
+    rfb._sock.send = function () {};
+    rfb._sock.close = function () {};
+    rfb._sock.flush = function () {};
+    rfb._checkEvents = function () {};
+    rfb._sock.init('binary', 'ws');
+    rfb._updateState('ProtocolVersion', "Starting playback");
+}
+
+function deliverFrame(idx) {
+    var frame = VNC_frame_data[idx];
+    var start = frame.indexOf('{', 1) + 1;
+    var u8 = new Uint8Array(frame.length - start);
+    for (var i = 0; i < frame.length - start; i++) {
+        u8[i] = frame.charCodeAt(start + i);
+    }
+    rfb._sock._recv_message({'data': u8});
+}
+
+function scheduleNext() {
+    clearTimeout(timer);
+    timer = null;
+    if (paused || frameIdx >= frameCount()) {
+        return;
+    }
+
+    var delay = Math.max((frameTime(frameIdx) - currentPosition()) / speed, 0);
+    timer = setTimeout(function () {
+        setPosition(frameTime(frameIdx));
+        deliverFrame(frameIdx);
+        frameIdx++;
+        scheduleNext();
+    }, delay);
+}
+
+// Jump to a position by replaying every frame up to it from the beginning
+function seek(ms) {
+    clearTimeout(timer);
+    timer = null;
+    newPlayer();
+    frameIdx = 0;
+    while (frameIdx < frameCount() && frameTime(frameIdx) <= ms) {
+        deliverFrame(frameIdx);
+        frameIdx++;
+    }
+    setPosition(ms);
+    scheduleNext();
+}
+
+function togglePause() {
+    if (paused) {
+        paused = false;
+        setPosition(position);
+        $D('play').value = "Pause";
+        scheduleNext();
+    } else {
+        setPosition(currentPosition());
+        paused = true;
+        $D('play').value = "Play";
+        scheduleNext();
+    }
+}
+
+function setSpeed() {
+    setPosition(currentPosition());
+    speed = parseFloat($D('speed').value);
+    scheduleNext();
+}
+
+function updateControls() {
+    var seekBar = $D('seek');
+    if (document.activeElement !== seekBar) {
+        seekBar.value = currentPosition();
+    }
+    $D('position').textContent = formatTime(currentPosition());
+}
+
+function startPlayback() {
+    $D('seek').max = duration();
+    $D('duration').textContent = formatTime(duration());
+    $D('play').onclick = togglePause;
+    $D('speed').onchange = setSpeed;
+    $D('seek').onchange = function () {
+        seek(parseInt($D('seek').value, 10));
+    };
+    setInterval(updateControls, 250);
+    seek(0);
+}
+
+window.onscriptsload = function () {
+    WebUtil.init_logging(WebUtil.getConfigVar('logging', 'warn'));
+
+    var id = WebUtil.getConfigVar('id', null);
+    if (!id) {
+        $D('position').textContent = "No recording specified";
+        return;
+    }
+    document.title = "VNC Dashboard - Playback - " + id;
+
+    var script = document.createElement("script");
+    script.src = "/api/recordings/" + encodeURIComponent(id) + "/data.js";
+    script.onload = startPlayback;
+    script.onerror = function () {
+        $D('position').textContent = "Could not load recording";
+    };
+    document.head.appendChild(script);
+};
+
+document.onreadystatechange = function () {
+    if (document.readyState == "complete") {
+        Util.load_scripts(["webutil.js", "base64.js", "websock.js", "des.js",
+            "keysymdef.js", "keyboard.js", "input.js", "display.js",
+            "inflator.js", "rfb.js", "keysym.js"]);
+    }
+};
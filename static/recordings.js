@@ -0,0 +1,96 @@
+
+function formatDuration(seconds) {
+    seconds = Math.floor(seconds);
+    var h = Math.floor(seconds / 3600);
+    var m = Math.floor((seconds % 3600) / 60);
+    var s = seconds % 60;
+    return h + ":" + (m < 10 ? "0" : "") + m + ":" + (s < 10 ? "0" : "") + s;
+}
+
+function formatSize(bytes) {
+    var units = ["B", "KB", "MB", "GB"];
+    var i = 0;
+    while (bytes >= 1024 && i < units.length - 1) {
+        bytes /= 1024;
+        i++;
+    }
+    return bytes.toFixed(i == 0 ? 0 : 1) + " " + units[i];
+}
+
+function newCell(row, text) {
+    cell = document.createElement("td");
+    cell.textContent = text;
+    row.appendChild(cell);
+    return cell;
+}
+
+function showRecordings(recordings, servers) {
+    container = document.getElementById("recordings");
+
+    // Group by server, keeping the newest-first order from the API
+    var byServer = {};
+    var order = [];
+    for (var i = 0; i < recordings.length; i++) {
+        r = recordings[i];
+        if (!byServer.hasOwnProperty(r.server)) {
+            byServer[r.server] = [];
+            order.push(r.server);
+        }
+        byServer[r.server].push(r);
+    }
+
+    if (order.length == 0) {
+        container.textContent = "No recordings.";
+        return;
+    }
+
+    for (var i = 0; i < order.length; i++) {
+        server = servers[order[i]];
+        heading = document.createElement("h2");
+        heading.textContent = (server && server.name) ? server.name : order[i];
+        container.appendChild(heading);
+
+        table = document.createElement("table");
+        table.className = "recordings";
+        header = document.createElement("tr");
+        ["Started", "Duration", "Size", ""].forEach(function (title) {
+            th = document.createElement("th");
+            th.textContent = title;
+            header.appendChild(th);
+        });
+        table.appendChild(header);
+
+        byServer[order[i]].forEach(function (r) {
+            row = document.createElement("tr");
+            newCell(row, new Date(r.start).toLocaleString());
+            newCell(row, formatDuration(r.duration) + (r.active ? " (recording)" : ""));
+            newCell(row, formatSize(r.size));
+            link = document.createElement("a");
+            link.setAttribute("href", "/static/player.html?id=" + encodeURIComponent(r.id));
+            link.textContent = "Play";
+            newCell(row, "").appendChild(link);
+            table.appendChild(row);
+        });
+        container.appendChild(table);
+    }
+}
+
+function getJSON(url, callback) {
+    var req = new XMLHttpRequest();
+    req.addEventListener("load", function() {
+        callback(JSON.parse(req.responseText));
+    });
+    req.open("GET", url, true);
+    req.send();
+}
+
+document.onreadystatechange = function () {
+    if (document.readyState == "complete") {
+        // Server names come from the list of known servers
+        getJSON("/api/list", function (servers) {
+            getJSON("/api/recordings", function (recordings) {
+                showRecordings(recordings, servers);
+            });
+        });
+    }
+};
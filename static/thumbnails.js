@@ -0,0 +1,58 @@
+// Thumbnails are rendered by the server, so the wall costs one image per server
+// every few seconds rather than a live VNC client each.
+var evtSource = new EventSource("/api/thumbnails/subscribe");
+var thumbnails = {};
+
+function newThumbnail(server) {
+    div = document.createElement("div");
+    div.className = "thumbnail-container";
+
+    // Click through to a live session
+    link = document.createElement("a");
+    link.setAttribute("href", "/static/vnc_auto.html?path=vnc/" + server);
+
+    img = document.createElement("img");
+    img.className = "thumbnail";
+
+    caption = document.createElement("div");
+    caption.className = "thumbnail-caption";
+
+    link.appendChild(img);
+    link.appendChild(caption);
+    div.appendChild(link);
+    document.getElementById("thumbnails").appendChild(div);
+
+    thumbnails[server] = { 'div' : div, 'img' : img, 'caption' : caption };
+    return thumbnails[server];
+}
+
+function updateThumbnail(e) {
+    thumb = JSON.parse(e.data);
+    t = thumbnails[thumb.server];
+    if (t === undefined) {
+        t = newThumbnail(thumb.server);
+    }
+
+    t.caption.textContent = thumb.name ? thumb.name : thumb.server;
+    if (thumb.error) {
+        t.div.classList.add("thumbnail-error");
+        t.caption.textContent += " - " + thumb.error;
+        return;
+    }
+    t.div.classList.remove("thumbnail-error");
+    t.img.width = thumb.width;
+    t.img.height = thumb.height;
+    t.img.src = thumb.image;
+}
+
+function removeThumbnail(e) {
+    t = thumbnails[e.data];
+    if (t === undefined) {
+        return;
+    }
+    document.getElementById("thumbnails").removeChild(t.div);
+    delete thumbnails[e.data];
+}
+
+evtSource.addEventListener("thumbnail", updateThumbnail, false);
+evtSource.addEventListener("removed", removeThumbnail, false);
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/prometheus/common/log"
+	"image/jpeg"
+	"sync"
+	"time"
+)
+
+// A scaled-down image of a server's screen
+type thumbnail struct {
+	Server  string    `json:"server"` // Short name of the server
+	Name    string    `json:"name,omitempty"`
+	Width   int       `json:"width,omitempty"`
+	Height  int       `json:"height,omitempty"`
+	Image   string    `json:"image,omitempty"` // JPEG data URI
+	Error   string    `json:"error,omitempty"` // Why there is no image
+	Time    time.Time `json:"time"`
+	Removed bool      `json:"-"` // The server has gone away
+}
+
+// Receives the latest thumbnail of each server. Thumbnails replace older ones of
+// the same server which haven't been taken yet, so slow subscribers skip frames
+// rather than falling behind.
+type thumbnailSubscriber struct {
+	pending map[string]thumbnail
+	notify  chan struct{}
+	mtx     sync.Mutex
+}
+
+// Channel signalled when there are thumbnails to take
+func (this *thumbnailSubscriber) Notify() <-chan struct{} {
+	return this.notify
+}
+
+// Take the pending thumbnails
+func (this *thumbnailSubscriber) Take() []thumbnail {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	thumbnails := make([]thumbnail, 0, len(this.pending))
+	for _, thumb := range this.pending {
+		thumbnails = append(thumbnails, thumb)
+	}
+	this.pending = make(map[string]thumbnail)
+	return thumbnails
+}
+
+func (this *thumbnailSubscriber) add(thumb thumbnail) {
+	this.mtx.Lock()
+	this.pending[thumb.Server] = thumb
+	this.mtx.Unlock()
+
+	select {
+	case this.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Maintains periodically refreshed thumbnails of every known server while anyone
+// is subscribed to them.
+type thumbnailer struct {
+	manager  *serverManager
+	broker   *sessionBroker
+	interval time.Duration // Minimum time between thumbnails of a server
+	width    int           // Width of thumbnails. Height keeps the aspect ratio.
+	quality  int           // JPEG quality
+
+	thumbnails  map[string]thumbnail
+	subscribers map[*thumbnailSubscriber]struct{}
+	workers     map[string]chan struct{} // Closed to stop the worker for a server
+	stop        chan struct{}            // Closed to stop the supervisor
+	mtx         sync.Mutex
+}
+
+func NewThumbnailer(manager *serverManager, broker *sessionBroker, interval time.Duration, width int, quality int) *thumbnailer {
+	return &thumbnailer{
+		manager:     manager,
+		broker:      broker,
+		interval:    interval,
+		width:       width,
+		quality:     quality,
+		thumbnails:  make(map[string]thumbnail),
+		subscribers: make(map[*thumbnailSubscriber]struct{}),
+		workers:     make(map[string]chan struct{}),
+	}
+}
+
+// Subscribe to thumbnails, starting with the current one of every server. The
+// first subscriber starts thumbnailing.
+func (this *thumbnailer) Subscribe() *thumbnailSubscriber {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	sub := &thumbnailSubscriber{
+		pending: make(map[string]thumbnail),
+		notify:  make(chan struct{}, 1),
+	}
+	for _, thumb := range this.thumbnails {
+		sub.add(thumb)
+	}
+	this.subscribers[sub] = struct{}{}
+
+	if this.stop == nil {
+		log.Debugln("Starting thumbnails")
+		this.stop = make(chan struct{})
+		go this.supervise(this.stop)
+	}
+	return sub
+}
+
+// Unsubscribe from thumbnails. The last subscriber leaving stops thumbnailing,
+// releasing the sessions it held open.
+func (this *thumbnailer) Unsubscribe(sub *thumbnailSubscriber) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	delete(this.subscribers, sub)
+	if len(this.subscribers) > 0 || this.stop == nil {
+		return
+	}
+
+	log.Debugln("Stopping thumbnails")
+	close(this.stop)
+	this.stop = nil
+	for k, stop := range this.workers {
+		close(stop)
+		delete(this.workers, k)
+	}
+	// Old thumbnails would be stale by the time anyone subscribes again
+	this.thumbnails = make(map[string]thumbnail)
+}
+
+func (this *thumbnailer) publish(thumb thumbnail) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if _, running := this.workers[thumb.Server]; !running && !thumb.Removed {
+		// Raced with the worker being stopped
+		return
+	}
+	if thumb.Removed {
+		delete(this.thumbnails, thumb.Server)
+	} else {
+		this.thumbnails[thumb.Server] = thumb
+	}
+	for sub := range this.subscribers {
+		sub.add(thumb)
+	}
+}
+
+// Keep a worker running for each known server until stopped
+func (this *thumbnailer) supervise(stop <-chan struct{}) {
+	for {
+		servers := this.manager.List()
+
+		this.mtx.Lock()
+		select {
+		case <-stop:
+			this.mtx.Unlock()
+			return
+		default:
+		}
+		for k, server := range servers {
+			if _, ok := this.workers[k]; !ok {
+				workerStop := make(chan struct{})
+				this.workers[k] = workerStop
+				go this.worker(server, workerStop)
+			}
+		}
+		removed := []string{}
+		for k, workerStop := range this.workers {
+			if _, ok := servers[k]; !ok {
+				close(workerStop)
+				delete(this.workers, k)
+				removed = append(removed, k)
+			}
+		}
+		this.mtx.Unlock()
+
+		for _, k := range removed {
+			this.publish(thumbnail{Server: k, Time: time.Now(), Removed: true})
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(this.interval):
+		}
+	}
+}
+
+// Thumbnail a server until stopped, reconnecting after errors
+func (this *thumbnailer) worker(server vncServer, stop <-chan struct{}) {
+	for {
+		err := this.follow(server, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		log.With("server", server.Redacted()).Debugln("Thumbnail session failed:", err)
+		this.publish(thumbnail{
+			Server: server.Short(),
+			Name:   server.Name,
+			Error:  err.Error(),
+			Time:   time.Now(),
+		})
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(this.interval):
+		}
+
+		// Pick up changed credentials before reconnecting
+		if current, ok := this.manager.Get(server.Short()); ok {
+			server = current
+		}
+	}
+}
+
+// Publish thumbnails from a server's session whenever its screen changes, no
+// more often than the interval. Returns nil when stopped.
+func (this *thumbnailer) follow(server vncServer, stop <-chan struct{}) error {
+	session, err := this.broker.Acquire(server)
+	if err != nil {
+		return err
+	}
+	defer this.broker.Release(session)
+
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+
+	select {
+	case <-stop:
+		return nil
+	case <-session.Done():
+		return session.Err()
+	case <-session.Updated():
+	}
+
+	for {
+		thumb, err := this.render(server, session)
+		if err != nil {
+			return err
+		}
+		this.publish(thumb)
+
+		select {
+		case <-stop:
+			return nil
+		case <-session.Done():
+			return session.Err()
+		case <-time.After(this.interval):
+		}
+
+		// Wait for the screen to change
+		for {
+			select {
+			case <-stop:
+				return nil
+			case <-session.Done():
+				return session.Err()
+			case <-sub.Notify():
+			}
+			if update := sub.Take(); !update.Dirty.Empty() || update.Resized {
+				break
+			}
+		}
+	}
+}
+
+// Scale and encode the current screen of a session
+func (this *thumbnailer) render(server vncServer, session *vncSession) (thumbnail, error) {
+	img := session.Snapshot()
+	bounds := img.Bounds()
+	width := this.width
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := 1
+	if bounds.Dx() > 0 {
+		height = (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("data:image/jpeg;base64,")
+	enc := base64.NewEncoder(base64.StdEncoding, buf)
+	if err := jpeg.Encode(enc, scaleImage(img, width, height), &jpeg.Options{Quality: this.quality}); err != nil {
+		return thumbnail{}, err
+	}
+	enc.Close()
+
+	return thumbnail{
+		Server: server.Short(),
+		Name:   server.Name,
+		Width:  width,
+		Height: height,
+		Image:  buf.String(),
+		Time:   time.Now(),
+	}, nil
+}
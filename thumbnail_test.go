@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/base64"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThumbnailSubscriber(t *testing.T) {
+	sub := &thumbnailSubscriber{pending: make(map[string]thumbnail), notify: make(chan struct{}, 1)}
+	sub.add(thumbnail{Server: "a", Width: 1})
+	sub.add(thumbnail{Server: "b", Width: 1})
+	sub.add(thumbnail{Server: "a", Width: 2})
+	<-sub.Notify()
+	widths := make(map[string]int)
+	for _, thumb := range sub.Take() {
+		widths[thumb.Server] = thumb.Width
+	}
+	if len(widths) != 2 || widths["a"] != 2 || widths["b"] != 1 {
+		t.Errorf("got thumbnails %v, expected only the latest of each server", widths)
+	}
+	if thumbs := sub.Take(); len(thumbs) != 0 {
+		t.Errorf("got %v thumbnails again", len(thumbs))
+	}
+}
+
+// Collect thumbnails until a condition holds for the latest of each server
+func waitThumbnails(t *testing.T, sub *thumbnailSubscriber, latest map[string]thumbnail, done func() bool) {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for !done() {
+		select {
+		case <-sub.Notify():
+			for _, thumb := range sub.Take() {
+				latest[thumb.Server] = thumb
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for thumbnails, got %+v", latest)
+		}
+	}
+}
+
+func TestThumbnailer(t *testing.T) {
+	upstream := newFilledVNCServer(t, 64, 32, color.RGBA{0, 0xff, 0, 0xff})
+	defer upstream.Close()
+	manager := NewServerManager()
+	server := upstream.Server()
+	server.Name = "Green"
+	manager.Add(server)
+	closed := vncServer{NetType: "tcp", Address: "127.0.0.1:1"}
+	manager.Add(closed)
+	broker := NewSessionBroker(nil)
+	thumbnails := NewThumbnailer(manager, broker, 10*time.Millisecond, 16, 80)
+
+	sub := thumbnails.Subscribe()
+	latest := make(map[string]thumbnail)
+	waitThumbnails(t, sub, latest, func() bool {
+		return latest[server.Short()].Image != "" && latest[closed.Short()].Error != ""
+	})
+
+	thumb := latest[server.Short()]
+	if thumb.Name != "Green" || thumb.Width != 16 || thumb.Height != 8 {
+		t.Errorf("got %+v, expected a 16x8 thumbnail of Green", thumb)
+	}
+	if !strings.HasPrefix(thumb.Image, "data:image/jpeg;base64,") {
+		t.Fatalf("got image %q", thumb.Image)
+	}
+	img, err := jpeg.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.TrimPrefix(thumb.Image, "data:image/jpeg;base64,"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Errorf("image is %v", img.Bounds())
+	}
+	if r, g, _, _ := img.At(8, 4).RGBA(); r>>8 > 0x10 || g>>8 < 0xf0 {
+		t.Errorf("got colour %v, expected green", img.At(8, 4))
+	}
+
+	// Later subscribers start with the current thumbnails
+	late := thumbnails.Subscribe()
+	<-late.Notify()
+	if thumbs := late.Take(); len(thumbs) != 2 {
+		t.Errorf("late subscriber got %v thumbnails", len(thumbs))
+	}
+	thumbnails.Unsubscribe(late)
+
+	// Removed servers are announced
+	manager.RemoveByAddress("", closed.Address)
+	waitThumbnails(t, sub, latest, func() bool { return latest[closed.Short()].Removed })
+
+	// The last subscriber leaving lets go of the sessions
+	thumbnails.Unsubscribe(sub)
+	waitFor(t, "sessions to be released", func() bool {
+		broker.mtx.Lock()
+		defer broker.mtx.Unlock()
+		return len(broker.sessions) == 0
+	})
+	thumbnails.mtx.Lock()
+	workers, cached := len(thumbnails.workers), len(thumbnails.thumbnails)
+	thumbnails.mtx.Unlock()
+	if workers != 0 || cached != 0 {
+		t.Errorf("%v workers and %v thumbnails left", workers, cached)
+	}
+}
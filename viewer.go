@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"github.com/prometheus/common/log"
+	"image"
+	"io"
+	"sync"
+)
+
+var ErrViewerNoDesktopSize = errors.New("framebuffer resized but client does not support DesktopSize")
+
+// A FramebufferUpdateRequest from a viewer
+type viewerUpdateRequest struct {
+	incremental bool
+	rect        image.Rectangle
+}
+
+// A single RFB client served from a shared session
+type sessionViewer struct {
+	session  *vncSession
+	conn     io.ReadWriter
+	readOnly bool
+	audit    *auditSession // May be nil.
+
+	mtx         sync.Mutex
+	format      rfbPixelFormat
+	desktopSize bool // Client supports the DesktopSize pseudo-encoding
+
+	requests chan viewerUpdateRequest
+	done     chan struct{} // Closed when the viewer stops sending updates
+}
+
+// Serve an RFB client which has completed the security handshake from a shared
+// session, until either side disconnects. Input and clipboard transfers are
+// recorded to the audit session, if there is one.
+func serveSessionViewer(session *vncSession, conn io.ReadWriter, readOnly bool, audit *auditSession) error {
+	this := &sessionViewer{
+		session:  session,
+		conn:     conn,
+		readOnly: readOnly,
+		audit:    audit,
+		format:   rfbViewerPixelFormat,
+		requests: make(chan viewerUpdateRequest, 16),
+		done:     make(chan struct{}),
+	}
+
+	// ClientInit. The shared flag is meaningless since the upstream connection is
+	// always shared.
+	rstream := bufio.NewReader(conn)
+	clientInit := make([]byte, 1)
+	if _, err := io.ReadFull(rstream, clientInit); err != nil {
+		return err
+	}
+
+	// Subscribe before ServerInit so no changes are missed
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+
+	var bounds image.Rectangle
+	var name string
+	session.WithFramebuffer(func(fb *image.RGBA) {
+		bounds = fb.Bounds()
+		name = session.name
+	})
+	if _, err := conn.Write(rfbServerInitMsg(bounds.Dx(), bounds.Dy(), rfbViewerPixelFormat, name)); err != nil {
+		return err
+	}
+
+	readerExit := make(chan error, 1)
+	go func() {
+		readerExit <- this.readLoop(rstream)
+	}()
+
+	defer close(this.done)
+	return this.writeLoop(sub, bounds, readerExit)
+}
+
+// Handle messages from the client
+func (this *sessionViewer) readLoop(r io.Reader) error {
+	for {
+		msgType, message, err := readRFBClientMessage(r)
+		if err != nil {
+			return err
+		}
+
+		switch msgType {
+		case rfbMsgSetPixelFormat:
+			format := parseRFBPixelFormat(message[4:])
+			if err := format.Validate(); err != nil {
+				return err
+			}
+			this.mtx.Lock()
+			this.format = format
+			this.mtx.Unlock()
+		case rfbMsgSetEncodings:
+			desktopSize := false
+			for i := 4; i < len(message); i += 4 {
+				if int32(binary.BigEndian.Uint32(message[i:])) == rfbEncDesktopSize {
+					desktopSize = true
+				}
+			}
+			this.mtx.Lock()
+			this.desktopSize = desktopSize
+			this.mtx.Unlock()
+		case rfbMsgFramebufferUpdateRequest:
+			x := int(binary.BigEndian.Uint16(message[2:4]))
+			y := int(binary.BigEndian.Uint16(message[4:6]))
+			w := int(binary.BigEndian.Uint16(message[6:8]))
+			h := int(binary.BigEndian.Uint16(message[8:10]))
+			req := viewerUpdateRequest{
+				incremental: message[1] != 0,
+				rect:        image.Rect(x, y, x+w, y+h),
+			}
+			select {
+			case this.requests <- req:
+			case <-this.done:
+				return nil
+			}
+		case rfbMsgEnableContinuousUpdates, rfbMsgClientFence:
+			// Never advertised, so should never be sent
+			log.Debugln("Ignoring unsolicited client message:", msgType)
+		default:
+			// Input. Forwarded to the server unless read-only.
+			if this.readOnly && !rfbViewOnlyMessages[msgType] {
+				log.Debugln("Dropping client message in read-only session:", msgType)
+				this.audit.Input(msgType, message, true)
+				continue
+			}
+			this.audit.Input(msgType, message, false)
+			if err := this.session.Write(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Send updates to the client as it requests them
+func (this *sessionViewer) writeLoop(sub *sessionSubscriber, bounds image.Rectangle, readerExit <-chan error) error {
+	// Everything is dirty to begin with, so a joining client gets a full update
+	dirty := bounds
+	resized := false
+
+	var requested image.Rectangle
+	pending := false
+
+	for {
+		select {
+		case err := <-readerExit:
+			return err
+		case <-this.session.Done():
+			return this.session.Err()
+		case req := <-this.requests:
+			pending = true
+			requested = requested.Union(req.rect)
+			if !req.incremental {
+				dirty = dirty.Union(req.rect)
+			}
+		case <-sub.Notify():
+			update := sub.Take()
+			dirty = dirty.Union(update.Dirty)
+			resized = resized || update.Resized
+
+			for i := 0; i < update.Bells; i++ {
+				if _, err := this.conn.Write([]byte{rfbMsgBell}); err != nil {
+					return err
+				}
+			}
+			if update.CutText != nil && !this.readOnly {
+				if _, err := this.conn.Write(rfbServerCutTextMsg(update.CutText)); err != nil {
+					return err
+				}
+				this.audit.ServerCutText(update.CutText)
+			}
+		}
+
+		if !pending {
+			continue
+		}
+
+		if resized {
+			this.mtx.Lock()
+			desktopSize := this.desktopSize
+			this.mtx.Unlock()
+			if !desktopSize {
+				return ErrViewerNoDesktopSize
+			}
+			// The client asks again for the whole new framebuffer
+			bounds = this.session.Bounds()
+			msg := appendRFBRectHeader(rfbFramebufferUpdateHeader(1), bounds, rfbEncDesktopSize)
+			if _, err := this.conn.Write(msg); err != nil {
+				return err
+			}
+			resized = false
+			pending = false
+			requested = image.Rectangle{}
+			dirty = bounds
+			continue
+		}
+
+		region := dirty.Intersect(requested)
+		if region.Empty() {
+			continue
+		}
+		if err := this.sendUpdate(region); err != nil {
+			return err
+		}
+		if dirty.In(requested) {
+			dirty = image.Rectangle{}
+		}
+		pending = false
+		requested = image.Rectangle{}
+	}
+}
+
+// Send a region of the framebuffer as a single raw rectangle
+func (this *sessionViewer) sendUpdate(region image.Rectangle) error {
+	this.mtx.Lock()
+	format := this.format
+	this.mtx.Unlock()
+
+	var msg []byte
+	var err error
+	this.session.WithFramebuffer(func(fb *image.RGBA) {
+		region = region.Intersect(fb.Bounds())
+		if region.Empty() {
+			// Raced with a resize, which will be sent next
+			return
+		}
+		msg = make([]byte, 0, 16+region.Dx()*region.Dy()*format.BytesPerPixel())
+		msg = append(msg, rfbFramebufferUpdateHeader(1)...)
+		msg = appendRFBRectHeader(msg, region, rfbEncRaw)
+		msg = format.AppendPixels(msg, fb, region)
+	})
+	if msg != nil {
+		_, err = this.conn.Write(msg)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestViewerReadOnlyFilter(t *testing.T) {
+	updateRequest := []byte{rfbMsgFramebufferUpdateRequest, 1, 0, 0, 0, 0, 0, 64, 0, 32}
+	tests := []struct {
+		name    string
+		message []byte
+		input   bool // Passed to the server unless read-only. Everything else the proxy handles itself.
+		audited string
+	}{
+		{"key", []byte{rfbMsgKeyEvent, 1, 0, 0, 0, 0, 0xff, 0x0d}, true, Audit_Key},
+		{"pointer", []byte{rfbMsgPointerEvent, 1, 0, 10, 0, 20}, true, Audit_Pointer},
+		{"cut text", append([]byte{rfbMsgClientCutText, 0, 0, 0, 0, 0, 0, 2}, "hi"...), true, Audit_Clipboard},
+		{"xvp", []byte{rfbMsgXvp, 0, 1, 2}, true, ""},
+		{"set desktop size", append([]byte{rfbMsgSetDesktopSize, 0, 4, 0, 3, 0, 1, 0}, make([]byte, 16)...), true, ""},
+		{"qemu extended key", []byte{rfbMsgQEMU, 0, 0, 1, 0, 0, 0xff, 0x0d, 0, 0, 0, 28}, true, ""},
+		{"update request", updateRequest, false, ""},
+		{"set encodings", []byte{rfbMsgSetEncodings, 0, 0, 1, 0xff, 0xff, 0xff, 0x21}, false, ""},
+		{"fence", []byte{rfbMsgClientFence, 0, 0, 0, 0, 0, 0, 0, 0}, false, ""},
+	}
+	for _, test := range tests {
+		for _, readOnly := range []bool{true, false} {
+			upstream, server := net.Pipe()
+			received := make(chan []byte)
+			go func() {
+				b, _ := ioutil.ReadAll(server)
+				received <- b
+			}()
+
+			audited := &bytes.Buffer{}
+			audit := &auditLog{w: audited, input: true}
+			viewer := &sessionViewer{
+				session:  &vncSession{conn: upstream},
+				readOnly: readOnly,
+				format:   rfbViewerPixelFormat,
+				requests: make(chan viewerUpdateRequest, 16),
+				done:     make(chan struct{}),
+				audit:    audit.Start(httptest.NewRequest("GET", "/", nil), vncServer{ID: "console"}, Viewer_WebSocket, !readOnly),
+			}
+			audited.Reset()
+			err := viewer.readLoop(bytes.NewReader(test.message))
+			upstream.Close()
+			forwarded := <-received
+
+			if err != io.EOF {
+				t.Errorf("%v: unexpected error: %v", test.name, err)
+			}
+			expected := []byte{}
+			if test.input && !readOnly {
+				expected = test.message
+			}
+			if !bytes.Equal(forwarded, expected) {
+				t.Errorf("%v (read-only %v): forwarded %v, expected %v", test.name, readOnly, forwarded, expected)
+			}
+
+			// Input is audited whether or not it got through
+			records := []auditInputRecord{}
+			decoder := json.NewDecoder(audited)
+			for decoder.More() {
+				record := auditInputRecord{}
+				if err := decoder.Decode(&record); err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, record)
+			}
+			if test.audited == "" && len(records) != 0 {
+				t.Errorf("%v (read-only %v): audited %+v", test.name, readOnly, records)
+			}
+			if test.audited != "" && (len(records) != 1 || records[0].Event != test.audited || records[0].Dropped != readOnly) {
+				t.Errorf("%v (read-only %v): audited %+v, expected one %v", test.name, readOnly, records, test.audited)
+			}
+		}
+	}
+}
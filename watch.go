@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Events fired by watch rules
+const (
+	Watch_Changed  = "changed"  // The region changed by more than the tolerance
+	Watch_Mismatch = "mismatch" // The region stopped matching the reference image
+	Watch_Match    = "match"    // The region matches the reference image again
+)
+
+var ErrWatchOutOfBounds = errors.New("region is outside the screen")
+
+// Watches a rectangle of a server's screen. Without a reference image it fires
+// whenever the region changes; with one it fires when the region stops or starts
+// matching it.
+type watchRule struct {
+	Name      string  `yaml:"name" json:"name"`
+	X         int     `yaml:"x" json:"x"`
+	Y         int     `yaml:"y" json:"y"`
+	Width     int     `yaml:"width" json:"width"`
+	Height    int     `yaml:"height" json:"height"`
+	Reference string  `yaml:"reference" json:"reference,omitempty"` // PNG or JPEG file the region should match
+	Tolerance float64 `yaml:"tolerance" json:"tolerance"`           // Mean difference per colour channel ignored, from 0 to 1
+
+	reference *image.RGBA // Reference image scaled to the region
+}
+
+func (this watchRule) Rect() image.Rectangle {
+	return image.Rect(this.X, this.Y, this.X+this.Width, this.Y+this.Height)
+}
+
+// Check a set of rules and load their reference images. Relative paths are
+// relative to dir.
+func loadWatchRules(rules []watchRule, dir string) error {
+	names := make(map[string]struct{})
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("watch %v: no name specified", i)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("watch %v: duplicate name", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		if rule.X < 0 || rule.Y < 0 || rule.Width <= 0 || rule.Height <= 0 {
+			return fmt.Errorf("watch %v: invalid region", rule.Name)
+		}
+		if rule.Tolerance < 0 || rule.Tolerance > 1 {
+			return fmt.Errorf("watch %v: tolerance must be between 0 and 1", rule.Name)
+		}
+		if rule.Reference == "" {
+			continue
+		}
+
+		filename := rule.Reference
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+		reference, err := loadReferenceImage(filename, rule.Width, rule.Height)
+		if err != nil {
+			return fmt.Errorf("watch %v: %v", rule.Name, err)
+		}
+		rule.reference = reference
+	}
+	return nil
+}
+
+// Load an image, scaling it to the given size
+func loadReferenceImage(filename string, width int, height int) (*image.RGBA, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	return scaleImage(img, width, height), nil
+}
+
+// Copy a region of an image
+func cropImage(src *image.RGBA, rect image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := 0; y < rect.Dy(); y++ {
+		copy(img.Pix[y*img.Stride:(y+1)*img.Stride], src.Pix[src.PixOffset(rect.Min.X, rect.Min.Y+y):])
+	}
+	return img
+}
+
+// Mean difference per colour channel between two images of the same size, from
+// 0 (identical) to 1
+func imageDifference(a *image.RGBA, b *image.RGBA) float64 {
+	var total, n int64
+	for i := 0; i+3 < len(a.Pix) && i+3 < len(b.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			d := int64(a.Pix[i+c]) - int64(b.Pix[i+c])
+			if d < 0 {
+				d = -d
+			}
+			total += d
+		}
+		n += 3
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(total) / float64(n*255)
+}
+
+// Current state of a watch rule
+type watchState struct {
+	Server     string    `json:"server"`          // Short name of the server
+	Rule       string    `json:"rule"`            // Name of the rule
+	Difference float64   `json:"difference"`      // Latest difference from the reference, or the region when it last changed
+	Mismatch   bool      `json:"mismatch"`        // The region doesn't match the reference image
+	Event      string    `json:"event,omitempty"` // Last event fired
+	LastEvent  time.Time `json:"last_event"`      // When the last event fired
+	Events     int       `json:"events"`          // Number of events fired
+	Error      string    `json:"error,omitempty"` // Why the rule can't be evaluated
+
+	evaluated bool
+	baseline  *image.RGBA // Region as of the last change
+}
+
+// Published to subscribers when a rule fires
+type watchEvent struct {
+	Server     string    `json:"server"`
+	Rule       string    `json:"rule"`
+	Event      string    `json:"event"`
+	Difference float64   `json:"difference"`
+	Time       time.Time `json:"time"`
+}
+
+// A running evaluation of the rules of one server
+type watchWorker struct {
+	rules []watchRule
+	stop  chan struct{}
+}
+
+// Evaluates the watch rules of every server which has any
+type watchEngine struct {
+	manager  *serverManager
+	broker   *sessionBroker
+	interval time.Duration // How often to check for changed servers and rules
+
+	states  map[string][]*watchState
+	workers map[string]watchWorker
+	mtx     sync.Mutex
+}
+
+func NewWatchEngine(manager *serverManager, broker *sessionBroker, interval time.Duration) *watchEngine {
+	return &watchEngine{
+		manager:  manager,
+		broker:   broker,
+		interval: interval,
+		states:   make(map[string][]*watchState),
+		workers:  make(map[string]watchWorker),
+	}
+}
+
+// State of every rule of every server
+func (this *watchEngine) List() []watchState {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	r := []watchState{}
+	for _, states := range this.states {
+		for _, state := range states {
+			s := *state
+			s.baseline = nil
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// Keep a worker running for each server with rules, restarting it when they change
+func (this *watchEngine) Run() {
+	for {
+		servers := this.manager.List()
+
+		this.mtx.Lock()
+		for k, worker := range this.workers {
+			server, ok := servers[k]
+			if !ok || !reflect.DeepEqual(server.Watches, worker.rules) {
+				close(worker.stop)
+				delete(this.workers, k)
+				delete(this.states, k)
+			}
+		}
+		for k, server := range servers {
+			if _, ok := this.workers[k]; ok || len(server.Watches) == 0 {
+				continue
+			}
+			states := []*watchState{}
+			for _, rule := range server.Watches {
+				states = append(states, &watchState{Server: k, Rule: rule.Name})
+			}
+			worker := watchWorker{rules: server.Watches, stop: make(chan struct{})}
+			this.workers[k] = worker
+			this.states[k] = states
+			go this.worker(server, states, worker.stop)
+		}
+		this.mtx.Unlock()
+
+		time.Sleep(this.interval)
+	}
+}
+
+// Evaluate a server's rules until stopped, reconnecting after errors
+func (this *watchEngine) worker(server vncServer, states []*watchState, stop <-chan struct{}) {
+	for {
+		err := this.follow(server, states, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		log.With("server", server.Redacted()).Debugln("Watch session failed:", err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(this.interval):
+		}
+
+		// Pick up changed credentials before reconnecting
+		if current, ok := this.manager.Get(server.Short()); ok {
+			server = current
+		}
+	}
+}
+
+// Evaluate rules whenever their regions are updated. Returns nil when stopped.
+func (this *watchEngine) follow(server vncServer, states []*watchState, stop <-chan struct{}) error {
+	session, err := this.broker.Acquire(server)
+	if err != nil {
+		return err
+	}
+	defer this.broker.Release(session)
+
+	sub := session.Subscribe()
+	defer session.Unsubscribe(sub)
+
+	select {
+	case <-stop:
+		return nil
+	case <-session.Done():
+		return session.Err()
+	case <-session.Updated():
+	}
+
+	update := sessionUpdate{Dirty: session.Bounds(), Resized: true}
+	for {
+		this.evaluate(server, session, states, update)
+
+		select {
+		case <-stop:
+			return nil
+		case <-session.Done():
+			return session.Err()
+		case <-sub.Notify():
+			update = sub.Take()
+		}
+	}
+}
+
+// Evaluate the rules whose regions an update touched
+func (this *watchEngine) evaluate(server vncServer, session *vncSession, states []*watchState, update sessionUpdate) {
+	events := []watchEvent{}
+
+	this.mtx.Lock()
+	session.WithFramebuffer(func(fb *image.RGBA) {
+		for i, rule := range server.Watches {
+			state := states[i]
+			region := rule.Rect()
+			if !update.Resized && !region.Overlaps(update.Dirty) {
+				continue
+			}
+			if !region.In(fb.Bounds()) {
+				state.Error = ErrWatchOutOfBounds.Error()
+				continue
+			}
+			state.Error = ""
+
+			current := cropImage(fb, region)
+			event := ""
+			if rule.reference != nil {
+				state.Difference = imageDifference(current, rule.reference)
+				mismatch := state.Difference > rule.Tolerance
+				if mismatch && (!state.Mismatch || !state.evaluated) {
+					event = Watch_Mismatch
+				} else if !mismatch && state.Mismatch {
+					event = Watch_Match
+				}
+				state.Mismatch = mismatch
+			} else if state.baseline == nil {
+				state.baseline = current
+			} else if difference := imageDifference(current, state.baseline); difference > rule.Tolerance {
+				state.Difference = difference
+				state.baseline = current
+				event = Watch_Changed
+			}
+			state.evaluated = true
+
+			if event != "" {
+				now := time.Now()
+				state.Event = event
+				state.LastEvent = now
+				state.Events++
+				events = append(events, watchEvent{
+					Server:     state.Server,
+					Rule:       rule.Name,
+					Event:      event,
+					Difference: state.Difference,
+					Time:       now,
+				})
+			}
+		}
+	})
+	this.mtx.Unlock()
+
+	for _, event := range events {
+		log.With("server", server.Redacted()).
+			With("rule", event.Rule).
+			With("difference", event.Difference).Infoln("Watch rule fired:", event.Event)
+		this.manager.Notify(Manager_WatchEvent, server, event)
+	}
+}
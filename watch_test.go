@@ -0,0 +1,211 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// An image of one colour
+func filledImage(width int, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestLoadWatchRules(t *testing.T) {
+	filename, cleanup := writeTempFile(t, "servers.yml", "")
+	defer cleanup()
+	dir := filepath.Dir(filename)
+	f, err := os.Create(filepath.Join(dir, "red.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, filledImage(8, 8, color.RGBA{0xff, 0, 0, 0xff})); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name  string
+		rules []watchRule
+		err   string
+	}{
+		{"none", nil, ""},
+		{"changes", []watchRule{{Name: "a", Width: 1, Height: 1}, {Name: "b", X: 5, Y: 5, Width: 2, Height: 3, Tolerance: 1}}, ""},
+		{"reference", []watchRule{{Name: "a", Width: 2, Height: 4, Reference: "red.png"}}, ""},
+		{"absolute reference", []watchRule{{Name: "a", Width: 2, Height: 4, Reference: filepath.Join(dir, "red.png")}}, ""},
+		{"no name", []watchRule{{Width: 1, Height: 1}}, "watch 0: no name specified"},
+		{"duplicate", []watchRule{{Name: "a", Width: 1, Height: 1}, {Name: "a", Width: 1, Height: 1}}, "watch a: duplicate name"},
+		{"negative", []watchRule{{Name: "a", X: -1, Width: 1, Height: 1}}, "watch a: invalid region"},
+		{"empty", []watchRule{{Name: "a", Width: 1}}, "watch a: invalid region"},
+		{"tolerance", []watchRule{{Name: "a", Width: 1, Height: 1, Tolerance: 1.5}}, "watch a: tolerance"},
+		{"missing reference", []watchRule{{Name: "a", Width: 1, Height: 1, Reference: "missing.png"}}, "watch a: open"},
+		{"invalid reference", []watchRule{{Name: "a", Width: 1, Height: 1, Reference: "servers.yml"}}, "watch a: image: unknown format"},
+	}
+	for _, test := range tests {
+		err := loadWatchRules(test.rules, dir)
+		checkErrText(t, test.name, err, test.err)
+		if err != nil {
+			continue
+		}
+		for _, rule := range test.rules {
+			if rule.Reference == "" {
+				if rule.reference != nil {
+					t.Errorf("%v: rule %v has a reference", test.name, rule.Name)
+				}
+			} else if rule.reference == nil || rule.reference.Bounds() != image.Rect(0, 0, rule.Width, rule.Height) {
+				t.Errorf("%v: rule %v has reference %v", test.name, rule.Name, rule.reference)
+			}
+		}
+	}
+}
+
+func TestImageDifference(t *testing.T) {
+	red := filledImage(4, 4, color.RGBA{0xff, 0, 0, 0xff})
+	tests := []struct {
+		name       string
+		a          *image.RGBA
+		b          *image.RGBA
+		difference float64
+	}{
+		{"same", red, red, 0},
+		{"opposite", filledImage(4, 4, color.RGBA{0, 0, 0, 0xff}), filledImage(4, 4, color.RGBA{0xff, 0xff, 0xff, 0}), 1},
+		{"one channel", red, filledImage(4, 4, color.RGBA{0, 0, 0, 0xff}), 1.0 / 3},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), red, 0},
+	}
+	for _, test := range tests {
+		if difference := imageDifference(test.a, test.b); difference != test.difference {
+			t.Errorf("%v: got %v, expected %v", test.name, difference, test.difference)
+		}
+	}
+}
+
+func TestCropImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+	img := cropImage(src, image.Rect(1, 1, 3, 3))
+	if img.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("got bounds %v", img.Bounds())
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if img.RGBAAt(x, y) != src.RGBAAt(x+1, y+1) {
+				t.Errorf("%v,%v: got %v, expected %v", x, y, img.RGBAAt(x, y), src.RGBAAt(x+1, y+1))
+			}
+		}
+	}
+}
+
+func TestWatchEngine(t *testing.T) {
+	upstream := newFakeVNCServer(t, 16, 8, "desktop")
+	defer upstream.Close()
+	server := upstream.Server()
+	server.Watches = []watchRule{
+		{Name: "status", X: 0, Y: 0, Width: 4, Height: 4, reference: filledImage(4, 4, color.RGBA{0, 0xff, 0, 0xff})},
+		{Name: "clock", X: 8, Y: 0, Width: 8, Height: 8, Tolerance: 0.1},
+		{Name: "offscreen", X: 12, Y: 4, Width: 8, Height: 8},
+	}
+	manager := NewServerManager()
+	manager.Add(server)
+	events := manager.Subscribe()
+	engine := NewWatchEngine(manager, NewSessionBroker(nil), 10*time.Millisecond)
+
+	states := []*watchState{}
+	for _, rule := range server.Watches {
+		states = append(states, &watchState{Server: server.Short(), Rule: rule.Name})
+	}
+	engine.states[server.Short()] = states
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		engine.worker(server, states, stop)
+		close(stopped)
+	}()
+
+	state := func(rule string) watchState {
+		for _, state := range engine.List() {
+			if state.Rule == rule {
+				return state
+			}
+		}
+		return watchState{}
+	}
+	expectEvents := func(name string, expected ...string) {
+		t.Helper()
+		got := []string{}
+		take := func() {
+			taken, _ := events.Take()
+			for _, e := range taken {
+				event := e.Detail.(watchEvent)
+				got = append(got, event.Rule+" "+event.Event)
+			}
+		}
+		timeout := time.After(testTimeout)
+		for len(got) < len(expected) {
+			select {
+			case <-events.Notify():
+				take()
+			case <-timeout:
+				t.Fatalf("%v: got events %v, expected %v", name, got, expected)
+			}
+		}
+		// Catch any extra events
+		time.Sleep(50 * time.Millisecond)
+		take()
+		if !reflect.DeepEqual(got, append([]string{}, expected...)) {
+			t.Errorf("%v: got events %v, expected %v", name, got, expected)
+		}
+	}
+
+	conn := upstream.Accept(t)
+	conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+	tests := []struct {
+		name   string
+		rect   image.Rectangle
+		colour color.RGBA
+		events []string
+	}{
+		// The first screen sets the baseline of change rules
+		{"first", image.Rect(0, 0, 16, 8), color.RGBA{0xff, 0, 0, 0xff}, []string{"status mismatch"}},
+		{"still mismatched", image.Rect(0, 0, 2, 2), color.RGBA{0, 0, 0xff, 0xff}, nil},
+		{"matched", image.Rect(0, 0, 4, 4), color.RGBA{0, 0xff, 0, 0xff}, []string{"status match"}},
+		{"outside regions", image.Rect(4, 4, 8, 8), color.RGBA{0, 0, 0xff, 0xff}, nil},
+		{"within tolerance", image.Rect(8, 0, 9, 1), color.RGBA{0, 0, 0xff, 0xff}, nil},
+		{"changed", image.Rect(8, 0, 16, 4), color.RGBA{0, 0, 0xff, 0xff}, []string{"clock changed"}},
+		{"unchanged redraw", image.Rect(8, 0, 16, 4), color.RGBA{0, 0, 0xff, 0xff}, nil},
+		{"mismatched", image.Rect(3, 3, 4, 4), color.RGBA{0, 0, 0, 0xff}, []string{"status mismatch"}},
+	}
+	for _, test := range tests {
+		conn.SendFill(t, test.rect, test.colour)
+		conn.Expect(t, rfbMsgFramebufferUpdateRequest)
+		expectEvents(test.name, test.events...)
+	}
+
+	if s := state("status"); !s.Mismatch || s.Events != 3 || s.Event != Watch_Mismatch {
+		t.Errorf("got status %+v", s)
+	}
+	if s := state("clock"); s.Events != 1 || s.Event != Watch_Changed || s.Difference <= 0.1 {
+		t.Errorf("got clock %+v", s)
+	}
+	if s := state("offscreen"); s.Error != ErrWatchOutOfBounds.Error() || s.Events != 0 {
+		t.Errorf("got offscreen %+v", s)
+	}
+
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("worker still running")
+	}
+	conn.ExpectClosed(t)
+}
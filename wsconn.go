@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/gorilla/websocket"
+	"io"
+)
+
+// Adapts a websocket connection to a byte stream, so RFB messages can be read and
+// written without regard to how they are split into websocket frames.
+type wsConn struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func newWSConn(conn *websocket.Conn) *wsConn {
+	return &wsConn{conn: conn}
+}
+
+func (this *wsConn) Read(p []byte) (int, error) {
+	for {
+		if this.reader == nil {
+			_, r, err := this.conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			this.reader = r
+		}
+
+		n, err := this.reader.Read(p)
+		if err == io.EOF {
+			// End of this message, move on to the next
+			this.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Each write is sent as a single binary message.
+func (this *wsConn) Write(p []byte) (int, error) {
+	if err := this.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}